@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"test_trigger/internal/http_wrapper"
+	"test_trigger/internal/loadtest"
+	"test_trigger/internal/logger"
+	"test_trigger/internal/realtime"
+)
+
+const requestTimeout = 30 * time.Second
+
+func main() {
+	target := flag.String("target", "http://localhost:8328", "base URL of the trigger server")
+	apiKey := flag.String("api-key", "", "API key of the trigger server client, also TRIGGER_API_KEY")
+	signingSecret := flag.String("signing-secret", "", "signing secret of the client, if it signs requests, also TRIGGER_SIGNING_SECRET")
+	providerStats := flag.String("provider-stats", "", "provider simulator stats URL, e.g. http://localhost:8330/stats")
+	pattern := flag.String("pattern", loadtest.PatternConstant, "traffic pattern: constant, burst, ramp")
+	rate := flag.Float64("rate", 5, "requests per second(start rate for ramp)")
+	rampTo := flag.Float64("ramp-to", 20, "final rate for ramp")
+	burstSize := flag.Int("burst-size", 100, "requests in one burst")
+	burstInterval := flag.Duration("burst-interval", 30*time.Second, "interval between bursts")
+	duration := flag.Duration("duration", time.Minute, "duration of sending")
+	pollInterval := flag.Duration("poll-interval", time.Second, "interval of /calls/{id} polling")
+	pollTimeout := flag.Duration("poll-timeout", 10*time.Minute, "how long to wait for results after sending")
+	virtualAgentID := flag.String("virtual-agent-id", "loadtest-agent", "virtual_agent_id for all calls")
+	providerLimit := flag.Uint64("provider-limit", 25, "provider requests per window, for theoretical throughput")
+	providerWindow := flag.Duration("provider-window", 10*time.Second, "provider rate limit window")
+	logLevel := flag.String("log-level", "info", "debug, info, warn or error")
+	logFormat := flag.String("log-format", logger.FormatLogfmt, "json or logfmt")
+	flag.Parse()
+	// secrets in flags are visible in the process list, env is preferred.
+	if *apiKey == "" {
+		*apiKey = os.Getenv("TRIGGER_API_KEY")
+	}
+	if *signingSecret == "" {
+		*signingSecret = os.Getenv("TRIGGER_SIGNING_SECRET")
+	}
+
+	l, err := logger.NewStdout(*logLevel, *logFormat)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	runner := loadtest.NewRunner(loadtest.Config{
+		Target:           *target,
+		APIKey:           *apiKey,
+		SigningSecret:    *signingSecret,
+		ProviderStatsURL: *providerStats,
+		Pattern: loadtest.PatternConfig{
+			Name:          *pattern,
+			Rate:          *rate,
+			RampTo:        *rampTo,
+			BurstSize:     *burstSize,
+			BurstInterval: *burstInterval,
+			Duration:      *duration,
+		},
+		PollInterval:   *pollInterval,
+		PollTimeout:    *pollTimeout,
+		VirtualAgentID: *virtualAgentID,
+		ProviderLimit:  *providerLimit,
+		ProviderWindow: *providerWindow,
+	}, http_wrapper.NewClient(requestTimeout), realtime.NewRealTime(time.Now), l)
+
+	report, err := runner.Run(ctx)
+	if err != nil {
+		l.Error("load test", "error", err)
+		os.Exit(2)
+	}
+	// the report is the output of the command, not a log record.
+	fmt.Print(report.String())
+}
@@ -2,7 +2,11 @@ package main
 
 import (
 	"context"
+	crand "crypto/rand"
 	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
@@ -14,26 +18,28 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"test_trigger/internal"
+	"test_trigger/internal/admin"
+	"test_trigger/internal/admission"
+	"test_trigger/internal/auth"
 	"test_trigger/internal/call"
+	"test_trigger/internal/call/dispatch"
+	"test_trigger/internal/call/expiry"
 	"test_trigger/internal/call/pool"
+	"test_trigger/internal/call/router"
+	"test_trigger/internal/call/sqlite"
 	"test_trigger/internal/call/worker"
+	"test_trigger/internal/config"
+	"test_trigger/internal/events"
+	"test_trigger/internal/health"
+	"test_trigger/internal/http_wrapper"
 	"test_trigger/internal/limiter"
 	"test_trigger/internal/logger"
+	"test_trigger/internal/metrics"
+	"test_trigger/internal/quota"
 	"test_trigger/internal/realtime"
-)
-
-const (
-	workerStepTime         = time.Millisecond * 500
-	maxWorkers             = 30
-	poolDefaultRecheckTime = time.Second * 3
-	poolCloseTimeout       = time.Minute * 10
-	defaultPort            = ":8328"
-	readHeaderTimeout      = 20 * time.Second
-	readTimeout            = 1 * time.Minute
-	writeTimeout           = 2 * time.Minute
-	shutdownTimeout        = 30 * time.Second
-	limiterSecondsSize     = 10
-	limiterMaxRequests     = 25
+	"test_trigger/internal/retention"
+	"test_trigger/internal/tracing"
+	"test_trigger/internal/webhook"
 )
 
 func main() {
@@ -43,40 +49,213 @@ func main() {
 	poolCtx, poolCancel := context.WithCancel(context.Background())
 	defer poolCancel()
 
-	l := logger.NewSimple()
-	storage := call.NewStorage()
-	lim := limiter.NewSlidingWindow(limiterSecondsSize, limiterMaxRequests, realtime.NewRealTime(time.Now))
+	cfg, err := config.Load(os.Args[1:], os.Getenv, os.Stderr)
+	if err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Println(err)
+		}
+		return
+	}
+	l, err := logger.NewStdout(cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	rt := realtime.NewRealTime(time.Now)
+	var storage call.Store = call.NewStorage()
+	var saved []call.StatusRecord // statuses of the previous run, retention and quotas continue from them.
+	if cfg.StoragePath != "" {
+		db, err := sqlite.Open(mainCtx, cfg.StoragePath, cfg.StorageLeaseTimeout, cfg.StoragePollInterval, rt)
+		if err != nil {
+			l.Error("storage", "error", err)
+			return
+		}
+		defer func() {
+			if err := db.Close(); err != nil {
+				l.Error("storage: close", "error", err)
+			}
+		}()
+		storage = db
+		if saved, err = db.Statuses(mainCtx); err != nil {
+			l.Error("storage: Statuses", "error", err)
+			return
+		}
+	}
+	control := dispatch.NewControl(storage)
+	lim := limiter.NewSlidingWindow(cfg.LimiterSize, cfg.LimiterLimit, rt)
 
 	advncedLogger := logrus.New()
 	ctrl := gomock.NewController(advncedLogger)
 	externalAPIClient := worker.NewMockExternalCaller(ctrl)
-	externalAPIClient.EXPECT().Call(gomock.Any(), gomock.Any(), gomock.Any()).Return(200, nil).AnyTimes()
+	externalAPIClient.EXPECT().Call(gomock.Any(), gomock.Any(), gomock.Any()).Return(call.Result{StatusCode: 200, Outcome: call.OutcomeAnswered}, nil).AnyTimes()
+
+	limiters := limiter.NewNamed(rt)
+	// urls aren't used by the mock, but providers, their limits and router settings are still reloaded.
+	providers := func(cfg config.Config) []*router.Provider {
+		if len(cfg.Providers) == 0 {
+			return []*router.Provider{router.NewProvider(config.DefaultProvider, externalAPIClient, lim, 1)}
+		}
+		res := make([]*router.Provider, 0, len(cfg.Providers))
+		for _, p := range cfg.Providers {
+			res = append(res, router.NewProvider(p.Name, externalAPIClient, limiters.Get(p.Name, p.LimiterSize, p.LimiterLimit), p.Weight))
+		}
+		return res
+	}
 
-	workerCreator := worker.NewCreate(lim, storage, storage, l, externalAPIClient, workerStepTime)
-	p := pool.NewPool(workerCreator, storage, l)
-	err := p.Start(poolCtx, maxWorkers)
-	//defer pool.Close(poolCtx, poolCancel, poolDefaultRecheckTime)
+	callRouter := router.NewRouter(providers(cfg), cfg.AgentPreferences, cfg.RouterFailureThreshold, cfg.RouterCooldown, cfg.RateLimitBackoff, rt, l)
+
+	var exporter tracing.Exporter = tracing.NewNop()
+	if cfg.OTLPTracesURL != "" {
+		otlp := tracing.NewOTLP(cfg.OTLPTracesURL, cfg.ServiceName, http_wrapper.NewClient(cfg.OTLPTimeout), l, cfg.OTLPMaxSpans)
+		stopExporter := otlp.Start(rt, cfg.OTLPFlushInterval)
+		defer stopExporter()
+		exporter = otlp
+	}
+	tracer := tracing.NewTracer(exporter, rt, crand.Reader)
+
+	registry := metrics.NewRegistry()
+	callMetrics := metrics.NewCalls(registry)
+	registry.NewGaugeFunc("queue_length", "Calls waiting in the queue.", func() float64 {
+		length, _ := storage.QueueLength(context.Background())
+		return float64(length)
+	})
+	admissionController := admission.NewController(storage, callRouter, cfg.MaxQueueDepth, cfg.AdmissionMaxWait, rt)
+	registry.NewGaugeFunc("estimated_wait_seconds", "Projected time to dial the queue at the limiter rate.", func() float64 {
+		wait, _ := admissionController.EstimatedWait(context.Background())
+		return wait.Seconds()
+	})
+	registry.NewGaugeFunc("limiter_remaining", "Originate requests allowed by the limiter right now.", func() float64 {
+		return float64(callRouter.Remaining())
+	})
+	webhooks := webhook.NewDispatcher(http_wrapper.NewPublicClient(cfg.WebhookTimeout), cfg.WebhookSecret, cfg.WebhookTenantSecrets, cfg.WebhookSenders,
+		cfg.WebhookMaxAttempts, cfg.WebhookBackoff, cfg.WebhookMaxBackoff, cfg.WebhookLogSize, func() string { return uuid.New().String() }, rt, l, callMetrics)
+	// the dispatcher is stopped after the pool, webhooks of the last calls are flushed then.
+	webhooksCtx, webhooksCancel := context.WithCancel(context.Background())
+	webhooksStopped := make(chan struct{})
+	go func() {
+		defer close(webhooksStopped)
+		webhooks.Run(webhooksCtx)
+	}()
+	registry.NewGaugeFunc("webhook_pending", "Webhooks waiting for delivery or retry.", func() float64 {
+		return float64(webhooks.Pending())
+	})
+	broker := events.NewBroker(cfg.EventsLogSize, cfg.EventsBuffer, rt, callMetrics)
+	// every status change goes through statuses, so it's streamed.
+	quotas := quota.NewQuotas(quota.Limits{Rate: cfg.QuotaRate, Burst: cfg.QuotaBurst, MaxQueued: cfg.QuotaMaxQueued}, cfg.QuotaRetryAfter, rt, l, callMetrics)
+	quotas.Restore(saved)
+	archiver, closeArchiver, err := retention.Open(cfg.StatusArchive, http_wrapper.NewClient(cfg.StatusArchiveTimeout))
+	if err != nil {
+		l.Error("status archive", "error", err)
+		return
+	}
+	defer func() {
+		if err := closeArchiver(); err != nil {
+			l.Error("status archive: close", "error", err)
+		}
+	}()
+	retainer := retention.NewRetainer(storage, cfg.StatusMaxAge, cfg.StatusMaxCount, cfg.StatusEvictionInterval, archiver, rt, l, callMetrics)
+	retainer.Restore(saved)
+	go retainer.Run(poolCtx)
+	registry.NewGaugeFunc("statuses_retained", "Statuses of completed calls, which aren't evicted yet.", func() float64 {
+		return float64(retainer.Retained())
+	})
+	statuses := quota.NewTracker(events.NewRecorder(retainer, broker), quotas)
+	registry.NewGaugeFunc("event_streams", "Open status event streams.", func() float64 {
+		return float64(broker.Subscribers())
+	})
+	registry.NewGaugeFunc("dispatch_paused", "1 if dispatching is paused.", func() float64 {
+		if control.Mode() == dispatch.ModePaused {
+			return 1
+		}
+		return 0
+	})
+	registry.NewGaugeFunc("intake_draining", "1 if new calls are rejected until the queue is drained.", func() float64 {
+		if !control.Accepting() {
+			return 1
+		}
+		return 0
+	})
+	registry.NewGaugeFunc("paused_agents", "Virtual agents with paused dispatching.", func() float64 {
+		return float64(len(control.PausedAgents()))
+	})
+	registry.NewGaugeFunc("parked_calls", "Queued calls of paused virtual agents.", func() float64 {
+		return float64(control.State().Parked)
+	})
+
+	// workers and the sweeper complete calls, webhooks are sent for them.
+	completions := webhook.NewNotifier(statuses, webhooks)
+	sweeper := expiry.NewSweeper(storage, completions, cfg.ExpirySweepInterval, rt, l, callMetrics)
+	go sweeper.Run(poolCtx)
+	workerCreator := worker.NewCreate(callRouter, storage, completions, l, callRouter, cfg.WorkerStepTime, rt, callMetrics, tracer, control, storage, cfg.MaxAttempts)
+	p := pool.NewPool(workerCreator, storage, l, rt, control)
+	startWorkers := cfg.MaxWorkers
+	if cfg.Autoscale {
+		startWorkers = cfg.MinWorkers
+	}
+	err = p.Start(poolCtx, startWorkers)
+	//defer pool.Close(poolCtx, poolCancel, cfg.PoolRecheckTime)
 	if err != nil {
-		l.Error(err)
+		l.Error("pool start", "error", err)
 		return
 	}
+	// with autoscale max_workers is the upper bound, reload changes it instead of the pool size.
+	var poolResizer admin.PoolResizer = p
+	if cfg.Autoscale {
+		autoscaler := pool.NewAutoscaler(p, storage, callMetrics.OriginateLatency, callRouter, cfg.MinWorkers, cfg.MaxWorkers, cfg.WorkerStepTime, cfg.AutoscaleLatency, cfg.AutoscaleInterval, rt, l)
+		go autoscaler.Run(poolCtx)
+		poolResizer = autoscaler
+	}
 
-	handler := internal.NewServer(storage, func() string { return uuid.New().String() }, l)
+	// new calls are queued through admission, it keeps the queue within max_queue_depth.
+	handler := internal.NewServer(admissionController, statuses, func() string { return uuid.New().String() }, rt, l, callMetrics, tracer, control, webhooks, admissionController, cfg.CallTTL)
+	authenticator := auth.NewAuthenticator(cfg.APIClients, cfg.AuthMaxSkew, rt, l)
+	if !authenticator.Enabled() {
+		l.Warn("auth is disabled, api_clients is empty, /admin/ is forbidden")
+	}
 	serverMux := http.NewServeMux()
-	serverMux.HandleFunc("/trigger", handler.Trigger)
+	serverMux.Handle("/trigger", authenticator.Authenticate(quotas.Limit(http.HandlerFunc(handler.Trigger))))
+	eventsHandler := events.NewHandler(broker, cfg.EventsHeartbeat, rt, l)
+	serverMux.Handle("/calls/", authenticator.Authenticate(eventsHandler.Route(handler.CallStatus)))
+	serverMux.Handle("/metrics", registry)
+	checker := health.NewChecker(storage, p, callRouter, callRouter, control, cfg.BreakerGrace, rt, l)
+	serverMux.HandleFunc("/healthz", checker.Healthz)
+	serverMux.HandleFunc("/readyz", checker.Readyz)
+	serverMux.HandleFunc("/status", checker.Status)
+	// the same sources are read again, flags still win over the file.
+	reloader := admin.NewReloader(cfg, func() (config.Config, error) {
+		return config.Load(os.Args[1:], os.Getenv, io.Discard)
+	}, providers, lim, poolResizer, callRouter, l)
+	// health and metrics are left open for probes and scrapers, admin endpoints need admin clients.
+	adminMux := http.NewServeMux()
+	adminMux.HandleFunc("/admin/reload", reloader.HandleReload)
+	controlHandler := admin.NewControlHandler(control, l)
+	adminMux.HandleFunc("/admin/pause", controlHandler.Pause)
+	adminMux.HandleFunc("/admin/resume", controlHandler.Resume)
+	adminMux.HandleFunc("/admin/drain", controlHandler.Drain)
+	adminMux.HandleFunc("/admin/agents/", controlHandler.Agent)
+	deadLetterHandler := admin.NewDeadLetterHandler(storage, storage, statuses, rt, l)
+	adminMux.HandleFunc("/admin/dlq", deadLetterHandler.List)
+	adminMux.HandleFunc("/admin/dlq/", deadLetterHandler.Replay)
+	adminMux.HandleFunc("/admin/webhooks", webhooks.HandleDeliveries)
+	serverMux.Handle("/admin/", authenticator.AuthenticateAdmin(adminMux))
+	reloadSignals := make(chan os.Signal, 1)
+	signal.Notify(reloadSignals, syscall.SIGHUP)
+	go reloader.Run(mainCtx, reloadSignals)
 	server := &http.Server{
-		Addr:              defaultPort,
+		Addr:              cfg.Port,
 		Handler:           serverMux,
-		ReadHeaderTimeout: readHeaderTimeout,
-		ReadTimeout:       readTimeout,
-		WriteTimeout:      writeTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
 	}
+	// Shutdown waits for handlers, event streams would keep it until the timeout.
+	server.RegisterOnShutdown(broker.Close)
 
 	serverStopped := make(chan struct{}, 1)
 	go func() {
 		l.Info("HTTP server is started")
 		if err := server.ListenAndServe(); err != http.ErrServerClosed {
-			l.Error(err)
+			l.Error("listen and serve", "error", err)
 		}
 		serverStopped <- struct{}{}
 	}()
@@ -84,28 +263,37 @@ func main() {
 	select {
 	case <-mainCtx.Done():
 		l.Info("graceful shutting down…")
+		// readiness is false while the server still accepts connections, so traffic is moved away without errors.
+		checker.SetShuttingDown()
+		time.Sleep(cfg.ReadinessDrainDelay)
 		// stop http server first
-		serverShutdown(l, server)
+		serverShutdown(l, server, cfg.ShutdownTimeout)
 		<-serverStopped
 		l.Info("http server is stopped")
-		// stop workers, but process all remaining calls(with deadline). Since I have memory storage and don't want to lose calls.
-		p.Close(poolCtx, poolCancel, poolDefaultRecheckTime, poolCloseTimeout)
+		// stop workers, but process all remaining calls(with deadline). Memory storage loses calls on exit, SQLite keeps them anyway.
+		p.Close(poolCtx, poolCancel, cfg.PoolRecheckTime, cfg.PoolCloseTimeout)
 	case <-serverStopped:
-		serverShutdown(l, server)
+		checker.SetShuttingDown()
+		serverShutdown(l, server, cfg.ShutdownTimeout)
 		l.Info("http server is stopped")
-		p.Close(poolCtx, poolCancel, poolDefaultRecheckTime, poolCloseTimeout)
+		p.Close(poolCtx, poolCancel, cfg.PoolRecheckTime, cfg.PoolCloseTimeout)
 	}
+	webhooksCancel()
+	<-webhooksStopped
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), cfg.WebhookFlushTimeout)
+	webhooks.Flush(flushCtx)
+	flushCancel()
 
 	l.Info("done")
 }
 
-func serverShutdown(l *logger.Simple, server *http.Server) {
+func serverShutdown(l logger.Logger, server *http.Server, shutdownTimeout time.Duration) {
 	l.Info("stop http server")
 	timeoutCtx, cancelTimeout := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancelTimeout()
 	if err := server.Shutdown(timeoutCtx); err != nil &&
 		!errors.Is(err, context.Canceled) &&
 		!errors.Is(err, http.ErrServerClosed) {
-		l.Error(err)
+		l.Error("server shutdown", "error", err)
 	}
 }
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"test_trigger/internal/call"
+	"test_trigger/internal/logger"
+	"test_trigger/internal/realtime"
+	"test_trigger/internal/simulator"
+)
+
+const (
+	readHeaderTimeout = 20 * time.Second
+	shutdownTimeout   = 30 * time.Second
+)
+
+func main() {
+	addr := flag.String("addr", ":8330", "listen address")
+	minLatency := flag.Duration("min-latency", 5*time.Second, "minimal call latency")
+	maxLatency := flag.Duration("max-latency", 10*time.Second, "maximal call latency")
+	limit := flag.Uint64("limit", 25, "requests allowed per window")
+	window := flag.Uint64("window", 10, "rate limit window in seconds")
+	penalty := flag.Duration("penalty", 30*time.Second, "backoff after 429, all requests are rejected during it")
+	errorRate := flag.Float64("error-rate", 0, "part of requests answered with 500")
+	unavailableRate := flag.Float64("unavailable-rate", 0, "part of requests answered with 503")
+	timeoutRate := flag.Float64("timeout-rate", 0, "part of requests which hang and return 504")
+	hang := flag.Duration("hang", 2*time.Minute, "duration of hanging requests")
+	outcomes := flag.String("outcomes", "answered=1", "outcome weights for numbers without script, e.g. answered=80,busy=10,no_answer=10")
+	scriptPath := flag.String("script", "", `JSON file with outcomes per attempt, e.g. {"+4478": ["busy", "answered"]}`)
+	seed := flag.Int64("seed", time.Now().UnixNano(), "random seed")
+	logLevel := flag.String("log-level", "info", "debug, info, warn or error")
+	logFormat := flag.String("log-format", logger.FormatLogfmt, "json or logfmt")
+	flag.Parse()
+
+	l, err := logger.NewStdout(*logLevel, *logFormat)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	outcomeWeights, err := simulator.ParseOutcomes(*outcomes)
+	if err != nil {
+		l.Error("parse outcomes", "error", err)
+		os.Exit(2)
+	}
+	scripts, err := loadScripts(*scriptPath)
+	if err != nil {
+		l.Error("load scripts", "error", err)
+		os.Exit(2)
+	}
+
+	cfg := simulator.Config{
+		MinLatency:      *minLatency,
+		MaxLatency:      *maxLatency,
+		WindowSeconds:   *window,
+		Limit:           *limit,
+		Penalty:         *penalty,
+		ErrorRate:       *errorRate,
+		UnavailableRate: *unavailableRate,
+		TimeoutRate:     *timeoutRate,
+		Hang:            *hang,
+		Outcomes:        outcomeWeights,
+		Scripts:         scripts,
+	}
+	if err := cfg.Validate(); err != nil {
+		l.Error("config", "error", err)
+		os.Exit(2)
+	}
+	provider := simulator.NewProvider(cfg, realtime.NewRealTime(time.Now), *seed)
+
+	serverMux := http.NewServeMux()
+	serverMux.Handle("/originate_call", provider)
+	serverMux.HandleFunc("/stats", provider.StatsHandler)
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           serverMux,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	mainCtx, mainCtxCancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer mainCtxCancel()
+
+	serverStopped := make(chan struct{}, 1)
+	go func() {
+		l.Info("provider simulator is started", "addr", *addr, "seed", *seed)
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			l.Error("listen and serve", "error", err)
+		}
+		serverStopped <- struct{}{}
+	}()
+
+	select {
+	case <-mainCtx.Done():
+	case <-serverStopped:
+	}
+
+	timeoutCtx, cancelTimeout := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelTimeout()
+	if err := server.Shutdown(timeoutCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		l.Error("server shutdown", "error", err)
+	}
+	stats, _ := json.Marshal(provider.Stats())
+	l.Info("provider stats", "stats", string(stats))
+}
+
+func loadScripts(path string) (map[string][]call.Outcome, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("load script: %v", err)
+	}
+	res := make(map[string][]call.Outcome)
+	if err = json.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("load script: %v", err)
+	}
+	return res, nil
+}
@@ -0,0 +1,90 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"test_trigger/internal/call/dispatch"
+	"test_trigger/internal/logger"
+)
+
+//go:generate go run github.com/golang/mock/mockgen --source=control.go --destination=control_mock.go --package=admin
+
+// DispatchControl is dispatch.Control.
+type DispatchControl interface {
+	Pause()
+	Resume()
+	Drain()
+	PauseAgent(virtualAgentID string)
+	ResumeAgent(virtualAgentID string)
+	State() dispatch.State
+}
+
+// ControlHandler stops and continues dispatching during incidents, every response is the new dispatch state.
+type ControlHandler struct {
+	control DispatchControl
+	logger  logger.Logger
+}
+
+func NewControlHandler(control DispatchControl, logger logger.Logger) *ControlHandler {
+	return &ControlHandler{control: control, logger: logger}
+}
+
+// Pause stops dispatching, calls are still accepted and queued, path is /admin/pause.
+func (h *ControlHandler) Pause(w http.ResponseWriter, r *http.Request) {
+	h.handle(w, r, func() {
+		h.control.Pause()
+		h.logger.Warn("admin: dispatching paused")
+	})
+}
+
+// Resume continues dispatching and opens intake, path is /admin/resume.
+func (h *ControlHandler) Resume(w http.ResponseWriter, r *http.Request) {
+	h.handle(w, r, func() {
+		h.control.Resume()
+		h.logger.Info("admin: dispatching resumed")
+	})
+}
+
+// Drain closes intake, queued calls are still dispatched, path is /admin/drain.
+func (h *ControlHandler) Drain(w http.ResponseWriter, r *http.Request) {
+	h.handle(w, r, func() {
+		h.control.Drain()
+		h.logger.Warn("admin: intake closed, draining")
+	})
+}
+
+// Agent pauses or resumes one virtual agent, path is /admin/agents/{id}/pause or /admin/agents/{id}/resume.
+func (h *ControlHandler) Agent(w http.ResponseWriter, r *http.Request) {
+	agentID, action, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/admin/agents/"), "/")
+	if !ok || agentID == "" || (action != "pause" && action != "resume") {
+		http.NotFound(w, r)
+		return
+	}
+	h.handle(w, r, func() {
+		if action == "pause" {
+			h.control.PauseAgent(agentID)
+			h.logger.Warn("admin: virtual agent paused", "virtual_agent_id", agentID)
+			return
+		}
+		h.control.ResumeAgent(agentID)
+		h.logger.Info("admin: virtual agent resumed", "virtual_agent_id", agentID)
+	})
+}
+
+func (h *ControlHandler) handle(w http.ResponseWriter, r *http.Request, apply func()) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	apply()
+	respBody, err := json.Marshal(h.control.State())
+	if err != nil {
+		h.logger.Error("admin: marshall", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(respBody)
+}
@@ -0,0 +1,109 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: control.go
+
+// Package admin is a generated GoMock package.
+package admin
+
+import (
+	reflect "reflect"
+	dispatch "test_trigger/internal/call/dispatch"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockDispatchControl is a mock of DispatchControl interface.
+type MockDispatchControl struct {
+	ctrl     *gomock.Controller
+	recorder *MockDispatchControlMockRecorder
+}
+
+// MockDispatchControlMockRecorder is the mock recorder for MockDispatchControl.
+type MockDispatchControlMockRecorder struct {
+	mock *MockDispatchControl
+}
+
+// NewMockDispatchControl creates a new mock instance.
+func NewMockDispatchControl(ctrl *gomock.Controller) *MockDispatchControl {
+	mock := &MockDispatchControl{ctrl: ctrl}
+	mock.recorder = &MockDispatchControlMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDispatchControl) EXPECT() *MockDispatchControlMockRecorder {
+	return m.recorder
+}
+
+// Drain mocks base method.
+func (m *MockDispatchControl) Drain() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Drain")
+}
+
+// Drain indicates an expected call of Drain.
+func (mr *MockDispatchControlMockRecorder) Drain() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Drain", reflect.TypeOf((*MockDispatchControl)(nil).Drain))
+}
+
+// Pause mocks base method.
+func (m *MockDispatchControl) Pause() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Pause")
+}
+
+// Pause indicates an expected call of Pause.
+func (mr *MockDispatchControlMockRecorder) Pause() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pause", reflect.TypeOf((*MockDispatchControl)(nil).Pause))
+}
+
+// PauseAgent mocks base method.
+func (m *MockDispatchControl) PauseAgent(virtualAgentID string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "PauseAgent", virtualAgentID)
+}
+
+// PauseAgent indicates an expected call of PauseAgent.
+func (mr *MockDispatchControlMockRecorder) PauseAgent(virtualAgentID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PauseAgent", reflect.TypeOf((*MockDispatchControl)(nil).PauseAgent), virtualAgentID)
+}
+
+// Resume mocks base method.
+func (m *MockDispatchControl) Resume() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Resume")
+}
+
+// Resume indicates an expected call of Resume.
+func (mr *MockDispatchControlMockRecorder) Resume() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Resume", reflect.TypeOf((*MockDispatchControl)(nil).Resume))
+}
+
+// ResumeAgent mocks base method.
+func (m *MockDispatchControl) ResumeAgent(virtualAgentID string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ResumeAgent", virtualAgentID)
+}
+
+// ResumeAgent indicates an expected call of ResumeAgent.
+func (mr *MockDispatchControlMockRecorder) ResumeAgent(virtualAgentID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResumeAgent", reflect.TypeOf((*MockDispatchControl)(nil).ResumeAgent), virtualAgentID)
+}
+
+// State mocks base method.
+func (m *MockDispatchControl) State() dispatch.State {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "State")
+	ret0, _ := ret[0].(dispatch.State)
+	return ret0
+}
+
+// State indicates an expected call of State.
+func (mr *MockDispatchControlMockRecorder) State() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "State", reflect.TypeOf((*MockDispatchControl)(nil).State))
+}
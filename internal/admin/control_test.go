@@ -0,0 +1,148 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"test_trigger/internal/call/dispatch"
+	"test_trigger/internal/logger"
+)
+
+func TestControlHandler(t *testing.T) {
+	paused := dispatch.State{Mode: dispatch.ModePaused, Accepting: true, PausedAgents: []string{}}
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		handler      func(h *ControlHandler) http.HandlerFunc
+		expectedFunc func(control *MockDispatchControl, l *logger.MockLogger)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:   "pause",
+			method: http.MethodPost,
+			path:   "/admin/pause",
+			handler: func(h *ControlHandler) http.HandlerFunc {
+				return h.Pause
+			},
+			expectedFunc: func(control *MockDispatchControl, l *logger.MockLogger) {
+				control.EXPECT().Pause()
+				l.EXPECT().Warn("admin: dispatching paused")
+				control.EXPECT().State().Return(paused)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"mode":"paused","accepting":true,"paused_agents":[],"parked_calls":0}`,
+		},
+		{
+			name:   "pause, method not allowed",
+			method: http.MethodGet,
+			path:   "/admin/pause",
+			handler: func(h *ControlHandler) http.HandlerFunc {
+				return h.Pause
+			},
+			expectedFunc: func(control *MockDispatchControl, l *logger.MockLogger) {},
+			expectedCode: http.StatusMethodNotAllowed,
+		},
+		{
+			name:   "resume",
+			method: http.MethodPost,
+			path:   "/admin/resume",
+			handler: func(h *ControlHandler) http.HandlerFunc {
+				return h.Resume
+			},
+			expectedFunc: func(control *MockDispatchControl, l *logger.MockLogger) {
+				control.EXPECT().Resume()
+				l.EXPECT().Info("admin: dispatching resumed")
+				control.EXPECT().State().Return(dispatch.State{Mode: dispatch.ModeRunning, Accepting: true, PausedAgents: []string{"a"}, Parked: 2})
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"mode":"running","accepting":true,"paused_agents":["a"],"parked_calls":2}`,
+		},
+		{
+			name:   "drain",
+			method: http.MethodPost,
+			path:   "/admin/drain",
+			handler: func(h *ControlHandler) http.HandlerFunc {
+				return h.Drain
+			},
+			expectedFunc: func(control *MockDispatchControl, l *logger.MockLogger) {
+				control.EXPECT().Drain()
+				l.EXPECT().Warn("admin: intake closed, draining")
+				control.EXPECT().State().Return(dispatch.State{Mode: dispatch.ModeDraining, PausedAgents: []string{}})
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"mode":"draining","accepting":false,"paused_agents":[],"parked_calls":0}`,
+		},
+		{
+			name:   "pause agent",
+			method: http.MethodPost,
+			path:   "/admin/agents/a/pause",
+			handler: func(h *ControlHandler) http.HandlerFunc {
+				return h.Agent
+			},
+			expectedFunc: func(control *MockDispatchControl, l *logger.MockLogger) {
+				control.EXPECT().PauseAgent("a")
+				l.EXPECT().Warn("admin: virtual agent paused", "virtual_agent_id", "a")
+				control.EXPECT().State().Return(dispatch.State{Mode: dispatch.ModeRunning, Accepting: true, PausedAgents: []string{"a"}})
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"mode":"running","accepting":true,"paused_agents":["a"],"parked_calls":0}`,
+		},
+		{
+			name:   "resume agent",
+			method: http.MethodPost,
+			path:   "/admin/agents/a/resume",
+			handler: func(h *ControlHandler) http.HandlerFunc {
+				return h.Agent
+			},
+			expectedFunc: func(control *MockDispatchControl, l *logger.MockLogger) {
+				control.EXPECT().ResumeAgent("a")
+				l.EXPECT().Info("admin: virtual agent resumed", "virtual_agent_id", "a")
+				control.EXPECT().State().Return(dispatch.State{Mode: dispatch.ModeRunning, Accepting: true, PausedAgents: []string{}})
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"mode":"running","accepting":true,"paused_agents":[],"parked_calls":0}`,
+		},
+		{
+			name:   "unknown agent action",
+			method: http.MethodPost,
+			path:   "/admin/agents/a/stop",
+			handler: func(h *ControlHandler) http.HandlerFunc {
+				return h.Agent
+			},
+			expectedFunc: func(control *MockDispatchControl, l *logger.MockLogger) {},
+			expectedCode: http.StatusNotFound,
+			expectedBody: "404 page not found\n",
+		},
+		{
+			name:   "empty agent",
+			method: http.MethodPost,
+			path:   "/admin/agents//pause",
+			handler: func(h *ControlHandler) http.HandlerFunc {
+				return h.Agent
+			},
+			expectedFunc: func(control *MockDispatchControl, l *logger.MockLogger) {},
+			expectedCode: http.StatusNotFound,
+			expectedBody: "404 page not found\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			control := NewMockDispatchControl(ctrl)
+			l := logger.NewMockLogger(ctrl)
+			tt.expectedFunc(control, l)
+			h := NewControlHandler(control, l)
+
+			resp := httptest.NewRecorder()
+			tt.handler(h)(resp, httptest.NewRequest(tt.method, tt.path, nil))
+			assert.Equal(t, tt.expectedCode, resp.Code)
+			assert.Equal(t, tt.expectedBody, resp.Body.String())
+		})
+	}
+}
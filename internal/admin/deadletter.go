@@ -0,0 +1,220 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"test_trigger/internal/call"
+	"test_trigger/internal/logger"
+	"test_trigger/internal/realtime"
+)
+
+//go:generate go run github.com/golang/mock/mockgen --source=deadletter.go --destination=deadletter_mock.go --package=admin
+
+type DeadLetterStorage interface {
+	AddDeadLetter(_ context.Context, deadLetter call.DeadLetter) error
+	DeadLetters(_ context.Context, filter call.DeadLetterFilter) ([]call.DeadLetter, error)
+	TakeDeadLetter(_ context.Context, id call.ID) (call.DeadLetter, bool, error)
+}
+
+// CallRequeuer puts replayed calls back to the main queue.
+type CallRequeuer interface {
+	AddToQueueBack(_ context.Context, meta call.Meta) error
+}
+
+type StatusSaver interface {
+	SaveStatus(_ context.Context, status call.Status, meta call.Meta) error
+}
+
+// DeadLetterResponse is one dead letter in /admin/dlq response.
+type DeadLetterResponse struct {
+	CallID         string         `json:"call_id"`
+	PhoneNumber    string         `json:"phone_number"`
+	VirtualAgentID string         `json:"virtual_agent_id"`
+	Attempts       int            `json:"attempts"`
+	Failures       []call.Failure `json:"failures"`
+	DeadAt         time.Time      `json:"dead_at"`
+}
+
+// DeadLettersResponse response struct for /admin/dlq request.
+type DeadLettersResponse struct {
+	DeadLetters []DeadLetterResponse `json:"dead_letters"`
+}
+
+// ReplayRequest selects dead letters by ids or by filter, all should be set to replay everything.
+// Set fixes one call, it's rejected for bulk replays, one number shouldn't be dialed for many calls.
+type ReplayRequest struct {
+	IDs            []call.ID    `json:"ids"`
+	VirtualAgentID string       `json:"virtual_agent_id"`
+	Outcome        call.Outcome `json:"outcome"`
+	All            bool         `json:"all"`
+	Set            ReplaySet    `json:"set"`
+}
+
+// ReplaySet replaces the phone number or the agent of the replayed call, empty fields are kept.
+type ReplaySet struct {
+	PhoneNumber    string `json:"phone_number"`
+	VirtualAgentID string `json:"virtual_agent_id"`
+}
+
+// ReplayResponse response struct for replay requests.
+type ReplayResponse struct {
+	Replayed []call.ID `json:"replayed"`
+}
+
+var (
+	errReplayFilter = errors.New("ids, virtual_agent_id, outcome or all should be set")
+	errReplaySet    = errors.New("set can be used for exactly one id only")
+)
+
+// DeadLetterHandler lists dead letters and replays them to the end of the main queue.
+type DeadLetterHandler struct {
+	storage       DeadLetterStorage
+	queue         CallRequeuer
+	statusStorage StatusSaver
+	realTime      realtime.Time
+	logger        logger.Logger
+}
+
+func NewDeadLetterHandler(storage DeadLetterStorage, queue CallRequeuer, statusStorage StatusSaver, t realtime.Time, logger logger.Logger) *DeadLetterHandler {
+	return &DeadLetterHandler{storage: storage, queue: queue, statusStorage: statusStorage, realTime: t, logger: logger}
+}
+
+// List returns dead letters, the oldest first, path is /admin/dlq?virtual_agent_id=&outcome=.
+func (h *DeadLetterHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	deadLetters, err := h.storage.DeadLetters(r.Context(), call.DeadLetterFilter{
+		VirtualAgentID: query.Get("virtual_agent_id"),
+		Outcome:        call.Outcome(query.Get("outcome")),
+	})
+	if err != nil {
+		h.logger.Error("dlq: DeadLetters", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	resp := DeadLettersResponse{DeadLetters: make([]DeadLetterResponse, 0, len(deadLetters))}
+	for _, d := range deadLetters {
+		resp.DeadLetters = append(resp.DeadLetters, DeadLetterResponse{
+			CallID:         string(d.ID),
+			PhoneNumber:    d.PhoneNumber,
+			VirtualAgentID: d.VirtualAgentID,
+			Attempts:       d.Attempts,
+			Failures:       d.Failures,
+			DeadAt:         d.DeadAt,
+		})
+	}
+	h.writeJSON(w, resp)
+}
+
+// Replay puts dead letters back to the queue, path is /admin/dlq/replay(bulk) or /admin/dlq/{id}/replay.
+// Body is ReplayRequest, it's optional for a single call.
+func (h *DeadLetterHandler) Replay(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/admin/dlq/")
+	id, single := strings.CutSuffix(path, "/replay")
+	if path != "replay" && (!single || id == "" || strings.Contains(id, "/")) {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	req := ReplayRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if single {
+		req.IDs = []call.ID{call.ID(id)}
+	}
+	if len(req.IDs) == 0 && req.VirtualAgentID == "" && req.Outcome == "" && !req.All {
+		http.Error(w, errReplayFilter.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Set != (ReplaySet{}) && (len(req.IDs) != 1 || req.All) {
+		http.Error(w, errReplaySet.Error(), http.StatusBadRequest)
+		return
+	}
+
+	replayed, err := h.replay(r.Context(), req)
+	if len(replayed) > 0 {
+		h.logger.Info("dlq: replayed", "count", len(replayed))
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if single && len(replayed) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	h.writeJSON(w, ReplayResponse{Replayed: replayed})
+}
+
+// replay returns calls, which were put back to the queue before an error.
+func (h *DeadLetterHandler) replay(ctx context.Context, req ReplayRequest) ([]call.ID, error) {
+	deadLetters, err := h.storage.DeadLetters(ctx, call.DeadLetterFilter{IDs: req.IDs, VirtualAgentID: req.VirtualAgentID, Outcome: req.Outcome})
+	if err != nil {
+		h.logger.Error("dlq: DeadLetters", "error", err)
+		return nil, err
+	}
+	replayed := make([]call.ID, 0, len(deadLetters))
+	for _, listed := range deadLetters {
+		// taken first, so concurrent replays don't queue the call twice.
+		d, ok, err := h.storage.TakeDeadLetter(ctx, listed.ID)
+		if err != nil {
+			h.logger.Error("dlq: TakeDeadLetter", "call_id", string(listed.ID), "error", err)
+			return replayed, err
+		}
+		if !ok {
+			continue
+		}
+		if err = h.requeue(ctx, d.Meta, req.Set); err != nil {
+			h.logger.Error("dlq: requeue", "call_id", string(d.ID), "error", err)
+			if err := h.storage.AddDeadLetter(ctx, d); err != nil {
+				h.logger.Error("dlq: AddDeadLetter", "call_id", string(d.ID), "error", err)
+			}
+			return replayed, err
+		}
+		replayed = append(replayed, d.ID)
+	}
+	return replayed, nil
+}
+
+// requeue resets attempts and expiry, the failure history is kept.
+func (h *DeadLetterHandler) requeue(ctx context.Context, meta call.Meta, set ReplaySet) error {
+	if set.PhoneNumber != "" {
+		meta.PhoneNumber = set.PhoneNumber
+	}
+	if set.VirtualAgentID != "" {
+		meta.VirtualAgentID = set.VirtualAgentID
+	}
+	meta.Attempts = 0
+	// replay is a decision of the operator, the call would be expired by its original ttl mostly.
+	meta.ExpiresAt = time.Time{}
+	meta.EnqueuedAt = h.realTime.Now()
+	if err := h.statusStorage.SaveStatus(ctx, call.Status{State: call.StateQueued}, meta); err != nil {
+		return err
+	}
+	return h.queue.AddToQueueBack(ctx, meta)
+}
+
+func (h *DeadLetterHandler) writeJSON(w http.ResponseWriter, resp interface{}) {
+	respBody, err := json.Marshal(resp)
+	if err != nil {
+		h.logger.Error("dlq: marshall", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(respBody)
+}
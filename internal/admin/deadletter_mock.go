@@ -0,0 +1,155 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: deadletter.go
+
+// Package admin is a generated GoMock package.
+package admin
+
+import (
+	context "context"
+	reflect "reflect"
+	call "test_trigger/internal/call"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockDeadLetterStorage is a mock of DeadLetterStorage interface.
+type MockDeadLetterStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockDeadLetterStorageMockRecorder
+}
+
+// MockDeadLetterStorageMockRecorder is the mock recorder for MockDeadLetterStorage.
+type MockDeadLetterStorageMockRecorder struct {
+	mock *MockDeadLetterStorage
+}
+
+// NewMockDeadLetterStorage creates a new mock instance.
+func NewMockDeadLetterStorage(ctrl *gomock.Controller) *MockDeadLetterStorage {
+	mock := &MockDeadLetterStorage{ctrl: ctrl}
+	mock.recorder = &MockDeadLetterStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDeadLetterStorage) EXPECT() *MockDeadLetterStorageMockRecorder {
+	return m.recorder
+}
+
+// AddDeadLetter mocks base method.
+func (m *MockDeadLetterStorage) AddDeadLetter(arg0 context.Context, deadLetter call.DeadLetter) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddDeadLetter", arg0, deadLetter)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddDeadLetter indicates an expected call of AddDeadLetter.
+func (mr *MockDeadLetterStorageMockRecorder) AddDeadLetter(arg0, deadLetter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDeadLetter", reflect.TypeOf((*MockDeadLetterStorage)(nil).AddDeadLetter), arg0, deadLetter)
+}
+
+// DeadLetters mocks base method.
+func (m *MockDeadLetterStorage) DeadLetters(arg0 context.Context, filter call.DeadLetterFilter) ([]call.DeadLetter, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeadLetters", arg0, filter)
+	ret0, _ := ret[0].([]call.DeadLetter)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeadLetters indicates an expected call of DeadLetters.
+func (mr *MockDeadLetterStorageMockRecorder) DeadLetters(arg0, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeadLetters", reflect.TypeOf((*MockDeadLetterStorage)(nil).DeadLetters), arg0, filter)
+}
+
+// TakeDeadLetter mocks base method.
+func (m *MockDeadLetterStorage) TakeDeadLetter(arg0 context.Context, id call.ID) (call.DeadLetter, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TakeDeadLetter", arg0, id)
+	ret0, _ := ret[0].(call.DeadLetter)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// TakeDeadLetter indicates an expected call of TakeDeadLetter.
+func (mr *MockDeadLetterStorageMockRecorder) TakeDeadLetter(arg0, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TakeDeadLetter", reflect.TypeOf((*MockDeadLetterStorage)(nil).TakeDeadLetter), arg0, id)
+}
+
+// MockCallRequeuer is a mock of CallRequeuer interface.
+type MockCallRequeuer struct {
+	ctrl     *gomock.Controller
+	recorder *MockCallRequeuerMockRecorder
+}
+
+// MockCallRequeuerMockRecorder is the mock recorder for MockCallRequeuer.
+type MockCallRequeuerMockRecorder struct {
+	mock *MockCallRequeuer
+}
+
+// NewMockCallRequeuer creates a new mock instance.
+func NewMockCallRequeuer(ctrl *gomock.Controller) *MockCallRequeuer {
+	mock := &MockCallRequeuer{ctrl: ctrl}
+	mock.recorder = &MockCallRequeuerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCallRequeuer) EXPECT() *MockCallRequeuerMockRecorder {
+	return m.recorder
+}
+
+// AddToQueueBack mocks base method.
+func (m *MockCallRequeuer) AddToQueueBack(arg0 context.Context, meta call.Meta) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddToQueueBack", arg0, meta)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddToQueueBack indicates an expected call of AddToQueueBack.
+func (mr *MockCallRequeuerMockRecorder) AddToQueueBack(arg0, meta interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddToQueueBack", reflect.TypeOf((*MockCallRequeuer)(nil).AddToQueueBack), arg0, meta)
+}
+
+// MockStatusSaver is a mock of StatusSaver interface.
+type MockStatusSaver struct {
+	ctrl     *gomock.Controller
+	recorder *MockStatusSaverMockRecorder
+}
+
+// MockStatusSaverMockRecorder is the mock recorder for MockStatusSaver.
+type MockStatusSaverMockRecorder struct {
+	mock *MockStatusSaver
+}
+
+// NewMockStatusSaver creates a new mock instance.
+func NewMockStatusSaver(ctrl *gomock.Controller) *MockStatusSaver {
+	mock := &MockStatusSaver{ctrl: ctrl}
+	mock.recorder = &MockStatusSaverMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStatusSaver) EXPECT() *MockStatusSaverMockRecorder {
+	return m.recorder
+}
+
+// SaveStatus mocks base method.
+func (m *MockStatusSaver) SaveStatus(arg0 context.Context, status call.Status, meta call.Meta) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveStatus", arg0, status, meta)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveStatus indicates an expected call of SaveStatus.
+func (mr *MockStatusSaverMockRecorder) SaveStatus(arg0, status, meta interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveStatus", reflect.TypeOf((*MockStatusSaver)(nil).SaveStatus), arg0, status, meta)
+}
@@ -0,0 +1,236 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"test_trigger/internal/call"
+	"test_trigger/internal/logger"
+	"test_trigger/internal/realtime"
+)
+
+func TestDeadLetterHandler_List(t *testing.T) {
+	ctx := context.Background()
+	storage := call.NewStorage()
+	deadAt := time.Unix(1709464831, 0).UTC()
+	failure := call.Failure{At: deadAt, Reason: "provider_error", Code: 500, Outcome: call.OutcomeProviderError}
+	require.NoError(t, storage.AddDeadLetter(ctx, call.DeadLetter{Meta: call.Meta{ID: "1", PhoneNumber: "777", VirtualAgentID: "a", Attempts: 1, Failures: []call.Failure{failure}}, DeadAt: deadAt}))
+	require.NoError(t, storage.AddDeadLetter(ctx, call.DeadLetter{Meta: call.Meta{ID: "2", PhoneNumber: "888", VirtualAgentID: "b"}, DeadAt: deadAt}))
+	h := NewDeadLetterHandler(storage, storage, storage, realtime.NewFake(deadAt), nil)
+
+	resp := httptest.NewRecorder()
+	h.List(resp, httptest.NewRequest(http.MethodGet, "/admin/dlq?virtual_agent_id=a&outcome=provider_error", nil))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"dead_letters":[{"call_id":"1","phone_number":"777","virtual_agent_id":"a","attempts":1,"dead_at":"2024-03-03T11:20:31Z",
+		"failures":[{"at":"2024-03-03T11:20:31Z","reason":"provider_error","code":500,"outcome":"provider_error"}]}]}`, resp.Body.String())
+
+	resp = httptest.NewRecorder()
+	h.List(resp, httptest.NewRequest(http.MethodGet, "/admin/dlq?virtual_agent_id=c", nil))
+	assert.JSONEq(t, `{"dead_letters":[]}`, resp.Body.String())
+
+	resp = httptest.NewRecorder()
+	h.List(resp, httptest.NewRequest(http.MethodPost, "/admin/dlq", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+}
+
+func TestDeadLetterHandler_Replay(t *testing.T) {
+	now := time.Unix(1709464831, 0)
+	failure := call.Failure{At: now.Add(-time.Minute), Reason: "provider_error", Code: 500, Outcome: call.OutcomeProviderError}
+	tests := []struct {
+		name             string
+		path             string
+		body             string
+		expectedCode     int
+		expectedBody     string
+		expectedQueue    []call.Meta
+		expectedRemained []call.ID
+	}{
+		{
+			name:             "single",
+			path:             "/admin/dlq/1/replay",
+			expectedCode:     http.StatusOK,
+			expectedBody:     `{"replayed":["1"]}`,
+			expectedQueue:    []call.Meta{{ID: "1", PhoneNumber: "777", VirtualAgentID: "a", EnqueuedAt: now, Failures: []call.Failure{failure}}},
+			expectedRemained: []call.ID{"2", "3"},
+		},
+		{
+			name:             "single, edited",
+			path:             "/admin/dlq/1/replay",
+			body:             `{"set":{"phone_number":"999","virtual_agent_id":"c"}}`,
+			expectedCode:     http.StatusOK,
+			expectedBody:     `{"replayed":["1"]}`,
+			expectedQueue:    []call.Meta{{ID: "1", PhoneNumber: "999", VirtualAgentID: "c", EnqueuedAt: now, Failures: []call.Failure{failure}}},
+			expectedRemained: []call.ID{"2", "3"},
+		},
+		{
+			name:             "single, unknown",
+			path:             "/admin/dlq/4/replay",
+			expectedCode:     http.StatusNotFound,
+			expectedBody:     "404 page not found\n",
+			expectedQueue:    []call.Meta{},
+			expectedRemained: []call.ID{"1", "2", "3"},
+		},
+		{
+			name:             "bulk by agent",
+			path:             "/admin/dlq/replay",
+			body:             `{"virtual_agent_id":"a"}`,
+			expectedCode:     http.StatusOK,
+			expectedBody:     `{"replayed":["1","3"]}`,
+			expectedQueue:    []call.Meta{{ID: "1", PhoneNumber: "777", VirtualAgentID: "a", EnqueuedAt: now, Failures: []call.Failure{failure}}, {ID: "3", PhoneNumber: "999", VirtualAgentID: "a", EnqueuedAt: now}},
+			expectedRemained: []call.ID{"2"},
+		},
+		{
+			name:             "bulk by ids",
+			path:             "/admin/dlq/replay",
+			body:             `{"ids":["2","3"]}`,
+			expectedCode:     http.StatusOK,
+			expectedBody:     `{"replayed":["2","3"]}`,
+			expectedQueue:    []call.Meta{{ID: "2", PhoneNumber: "888", VirtualAgentID: "b", EnqueuedAt: now}, {ID: "3", PhoneNumber: "999", VirtualAgentID: "a", EnqueuedAt: now}},
+			expectedRemained: []call.ID{"1"},
+		},
+		{
+			name:             "bulk by ids, edited",
+			path:             "/admin/dlq/replay",
+			body:             `{"ids":["2"],"set":{"phone_number":"111"}}`,
+			expectedCode:     http.StatusOK,
+			expectedBody:     `{"replayed":["2"]}`,
+			expectedQueue:    []call.Meta{{ID: "2", PhoneNumber: "111", VirtualAgentID: "b", EnqueuedAt: now}},
+			expectedRemained: []call.ID{"1", "3"},
+		},
+		{
+			name:             "bulk by ids, set for many",
+			path:             "/admin/dlq/replay",
+			body:             `{"ids":["2","3"],"set":{"phone_number":"111"}}`,
+			expectedCode:     http.StatusBadRequest,
+			expectedBody:     "set can be used for exactly one id only\n",
+			expectedQueue:    []call.Meta{},
+			expectedRemained: []call.ID{"1", "2", "3"},
+		},
+		{
+			name:             "bulk by agent, set",
+			path:             "/admin/dlq/replay",
+			body:             `{"virtual_agent_id":"a","set":{"virtual_agent_id":"c"}}`,
+			expectedCode:     http.StatusBadRequest,
+			expectedBody:     "set can be used for exactly one id only\n",
+			expectedQueue:    []call.Meta{},
+			expectedRemained: []call.ID{"1", "2", "3"},
+		},
+		{
+			name:             "bulk all, set",
+			path:             "/admin/dlq/replay",
+			body:             `{"all":true,"ids":["1"],"set":{"virtual_agent_id":"c"}}`,
+			expectedCode:     http.StatusBadRequest,
+			expectedBody:     "set can be used for exactly one id only\n",
+			expectedQueue:    []call.Meta{},
+			expectedRemained: []call.ID{"1", "2", "3"},
+		},
+		{
+			name:             "bulk without filter",
+			path:             "/admin/dlq/replay",
+			body:             `{}`,
+			expectedCode:     http.StatusBadRequest,
+			expectedBody:     "ids, virtual_agent_id, outcome or all should be set\n",
+			expectedQueue:    []call.Meta{},
+			expectedRemained: []call.ID{"1", "2", "3"},
+		},
+		{
+			name:             "bulk all",
+			path:             "/admin/dlq/replay",
+			body:             `{"all":true}`,
+			expectedCode:     http.StatusOK,
+			expectedBody:     `{"replayed":["1","2","3"]}`,
+			expectedQueue:    []call.Meta{{ID: "1", PhoneNumber: "777", VirtualAgentID: "a", EnqueuedAt: now, Failures: []call.Failure{failure}}, {ID: "2", PhoneNumber: "888", VirtualAgentID: "b", EnqueuedAt: now}, {ID: "3", PhoneNumber: "999", VirtualAgentID: "a", EnqueuedAt: now}},
+			expectedRemained: []call.ID{},
+		},
+		{
+			name:             "failed body",
+			path:             "/admin/dlq/replay",
+			body:             `[`,
+			expectedCode:     http.StatusBadRequest,
+			expectedBody:     "unexpected EOF\n",
+			expectedQueue:    []call.Meta{},
+			expectedRemained: []call.ID{"1", "2", "3"},
+		},
+		{
+			name:             "unknown path",
+			path:             "/admin/dlq/1/2/replay",
+			expectedCode:     http.StatusNotFound,
+			expectedBody:     "404 page not found\n",
+			expectedQueue:    []call.Meta{},
+			expectedRemained: []call.ID{"1", "2", "3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			storage := call.NewStorage()
+			// expired calls are replayed without expiry.
+			require.NoError(t, storage.AddDeadLetter(ctx, call.DeadLetter{Meta: call.Meta{ID: "1", PhoneNumber: "777", VirtualAgentID: "a", Attempts: 5, ExpiresAt: now.Add(-time.Hour), Failures: []call.Failure{failure}}}))
+			require.NoError(t, storage.AddDeadLetter(ctx, call.DeadLetter{Meta: call.Meta{ID: "2", PhoneNumber: "888", VirtualAgentID: "b", Attempts: 5}}))
+			require.NoError(t, storage.AddDeadLetter(ctx, call.DeadLetter{Meta: call.Meta{ID: "3", PhoneNumber: "999", VirtualAgentID: "a", Attempts: 5}}))
+			ctrl := gomock.NewController(t)
+			l := logger.NewMockLogger(ctrl)
+			l.EXPECT().Info("dlq: replayed", "count", gomock.Any()).AnyTimes()
+			h := NewDeadLetterHandler(storage, storage, storage, realtime.NewFake(now), l)
+
+			resp := httptest.NewRecorder()
+			h.Replay(resp, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+			assert.Equal(t, tt.expectedCode, resp.Code)
+			assert.Equal(t, tt.expectedBody, resp.Body.String())
+
+			queue := make([]call.Meta, 0)
+			for {
+				meta, ok, _ := storage.Next(ctx, nil)
+				if !ok {
+					break
+				}
+				status, _, _ := storage.Status(ctx, meta.ID)
+				assert.Equal(t, call.StateQueued, status.State)
+				queue = append(queue, meta)
+			}
+			assert.Equal(t, tt.expectedQueue, queue)
+			remained, _ := storage.DeadLetters(ctx, call.DeadLetterFilter{})
+			ids := make([]call.ID, 0)
+			for _, d := range remained {
+				ids = append(ids, d.ID)
+			}
+			assert.Equal(t, tt.expectedRemained, ids)
+		})
+	}
+}
+
+func TestDeadLetterHandler_Replay_RequeueError(t *testing.T) {
+	testErr := errors.New("test")
+	ctrl := gomock.NewController(t)
+	storage := NewMockDeadLetterStorage(ctrl)
+	queue := NewMockCallRequeuer(ctrl)
+	statusStorage := NewMockStatusSaver(ctrl)
+	l := logger.NewMockLogger(ctrl)
+	h := NewDeadLetterHandler(storage, queue, statusStorage, realtime.NewFake(time.Unix(1709464831, 0)), l)
+
+	first := call.DeadLetter{Meta: call.Meta{ID: "1"}}
+	second := call.DeadLetter{Meta: call.Meta{ID: "2"}}
+	storage.EXPECT().DeadLetters(gomock.Any(), call.DeadLetterFilter{Outcome: call.OutcomeProviderError}).Return([]call.DeadLetter{first, second}, nil)
+	storage.EXPECT().TakeDeadLetter(gomock.Any(), call.ID("1")).Return(first, true, nil)
+	statusStorage.EXPECT().SaveStatus(gomock.Any(), call.Status{State: call.StateQueued}, gomock.Any()).Return(nil)
+	queue.EXPECT().AddToQueueBack(gomock.Any(), gomock.Any()).Return(nil)
+	// the second is returned to dead letters.
+	storage.EXPECT().TakeDeadLetter(gomock.Any(), call.ID("2")).Return(second, true, nil)
+	statusStorage.EXPECT().SaveStatus(gomock.Any(), call.Status{State: call.StateQueued}, gomock.Any()).Return(testErr)
+	l.EXPECT().Error("dlq: requeue", "call_id", "2", "error", testErr)
+	storage.EXPECT().AddDeadLetter(gomock.Any(), second).Return(nil)
+	l.EXPECT().Info("dlq: replayed", "count", 1)
+
+	resp := httptest.NewRecorder()
+	h.Replay(resp, httptest.NewRequest(http.MethodPost, "/admin/dlq/replay", strings.NewReader(`{"outcome":"provider_error"}`)))
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+}
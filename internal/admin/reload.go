@@ -0,0 +1,130 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"test_trigger/internal/call/router"
+	"test_trigger/internal/config"
+	"test_trigger/internal/logger"
+)
+
+//go:generate go run github.com/golang/mock/mockgen --source=reload.go --destination=reload_mock.go --package=admin
+
+type LimiterResizer interface {
+	Resize(size uint64, limit uint64)
+}
+
+type PoolResizer interface {
+	Resize(workers int) error
+}
+
+type ProvidersReloader interface {
+	Reload(providers []*router.Provider, preferences map[string]string, failureThreshold int, cooldown, rateLimitBackoff time.Duration)
+}
+
+// ReloadResponse response struct for /admin/reload request.
+type ReloadResponse struct {
+	Changes []config.Change `json:"changes"`
+}
+
+// Reloader applies config changes without restart, queued calls aren't lost.
+// load reads config from the same sources as on start, providers builds providers for the new config.
+type Reloader struct {
+	running   config.Config
+	load      func() (config.Config, error)
+	providers func(cfg config.Config) []*router.Provider
+	limiter   LimiterResizer
+	pool      PoolResizer
+	router    ProvidersReloader
+	logger    logger.Logger
+	mu        *sync.Mutex
+}
+
+func NewReloader(running config.Config, load func() (config.Config, error), providers func(cfg config.Config) []*router.Provider, limiter LimiterResizer, pool PoolResizer, router ProvidersReloader, logger logger.Logger) *Reloader {
+	return &Reloader{running: running, load: load, providers: providers, limiter: limiter, pool: pool, router: router, logger: logger, mu: &sync.Mutex{}}
+}
+
+// Reload loads config and applies reloadable changes, the rest is logged as requiring restart.
+func (r *Reloader) Reload() ([]config.Change, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	loaded, err := r.load()
+	if err != nil {
+		r.logger.Error("reload: load", "error", err)
+		return nil, err
+	}
+	changes := config.Diff(r.running, loaded)
+	if len(changes) == 0 {
+		r.logger.Info("reload: nothing changed")
+		return changes, nil
+	}
+
+	next := r.running.Reload(loaded)
+	// the pool resize is the only one, which can fail, it goes first, so a failed reload doesn't apply anything.
+	if next.MaxWorkers != r.running.MaxWorkers {
+		if err = r.pool.Resize(next.MaxWorkers); err != nil {
+			r.logger.Error("reload: pool Resize", "error", err)
+			return nil, err
+		}
+	}
+	if next.LimiterSize != r.running.LimiterSize || next.LimiterLimit != r.running.LimiterLimit {
+		r.limiter.Resize(next.LimiterSize, next.LimiterLimit)
+	}
+	if next.OriginateURL != r.running.OriginateURL || next.OriginateTimeout != r.running.OriginateTimeout ||
+		!reflect.DeepEqual(next.Providers, r.running.Providers) || !reflect.DeepEqual(next.AgentPreferences, r.running.AgentPreferences) ||
+		!reflect.DeepEqual(next.OutcomeRules, r.running.OutcomeRules) ||
+		next.RouterFailureThreshold != r.running.RouterFailureThreshold || next.RouterCooldown != r.running.RouterCooldown ||
+		next.RateLimitBackoff != r.running.RateLimitBackoff {
+		r.router.Reload(r.providers(next), next.AgentPreferences, next.RouterFailureThreshold, next.RouterCooldown, next.RateLimitBackoff)
+	}
+	r.running = next
+
+	for _, c := range changes {
+		if c.Reloadable {
+			r.logger.Info("reload: config changed", "key", c.Key, "old", c.Old, "new", c.New)
+		} else {
+			r.logger.Warn("reload: config changed, restart required", "key", c.Key, "old", c.Old, "new", c.New)
+		}
+	}
+	return changes, nil
+}
+
+// Run reloads on every signal, usually SIGHUP, until ctx is cancelled.
+func (r *Reloader) Run(ctx context.Context, signals <-chan os.Signal) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			// errors are logged in Reload, the old config keeps working.
+			_, _ = r.Reload()
+		}
+	}
+}
+
+// HandleReload reloads config, path is /admin/reload.
+func (r *Reloader) HandleReload(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	changes, err := r.Reload()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	respBody, err := json.Marshal(ReloadResponse{Changes: changes})
+	if err != nil {
+		r.logger.Error("reload: marshall", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(respBody)
+}
@@ -0,0 +1,120 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: reload.go
+
+// Package admin is a generated GoMock package.
+package admin
+
+import (
+	reflect "reflect"
+	router "test_trigger/internal/call/router"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockLimiterResizer is a mock of LimiterResizer interface.
+type MockLimiterResizer struct {
+	ctrl     *gomock.Controller
+	recorder *MockLimiterResizerMockRecorder
+}
+
+// MockLimiterResizerMockRecorder is the mock recorder for MockLimiterResizer.
+type MockLimiterResizerMockRecorder struct {
+	mock *MockLimiterResizer
+}
+
+// NewMockLimiterResizer creates a new mock instance.
+func NewMockLimiterResizer(ctrl *gomock.Controller) *MockLimiterResizer {
+	mock := &MockLimiterResizer{ctrl: ctrl}
+	mock.recorder = &MockLimiterResizerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLimiterResizer) EXPECT() *MockLimiterResizerMockRecorder {
+	return m.recorder
+}
+
+// Resize mocks base method.
+func (m *MockLimiterResizer) Resize(size, limit uint64) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Resize", size, limit)
+}
+
+// Resize indicates an expected call of Resize.
+func (mr *MockLimiterResizerMockRecorder) Resize(size, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Resize", reflect.TypeOf((*MockLimiterResizer)(nil).Resize), size, limit)
+}
+
+// MockPoolResizer is a mock of PoolResizer interface.
+type MockPoolResizer struct {
+	ctrl     *gomock.Controller
+	recorder *MockPoolResizerMockRecorder
+}
+
+// MockPoolResizerMockRecorder is the mock recorder for MockPoolResizer.
+type MockPoolResizerMockRecorder struct {
+	mock *MockPoolResizer
+}
+
+// NewMockPoolResizer creates a new mock instance.
+func NewMockPoolResizer(ctrl *gomock.Controller) *MockPoolResizer {
+	mock := &MockPoolResizer{ctrl: ctrl}
+	mock.recorder = &MockPoolResizerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPoolResizer) EXPECT() *MockPoolResizerMockRecorder {
+	return m.recorder
+}
+
+// Resize mocks base method.
+func (m *MockPoolResizer) Resize(workers int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Resize", workers)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Resize indicates an expected call of Resize.
+func (mr *MockPoolResizerMockRecorder) Resize(workers interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Resize", reflect.TypeOf((*MockPoolResizer)(nil).Resize), workers)
+}
+
+// MockProvidersReloader is a mock of ProvidersReloader interface.
+type MockProvidersReloader struct {
+	ctrl     *gomock.Controller
+	recorder *MockProvidersReloaderMockRecorder
+}
+
+// MockProvidersReloaderMockRecorder is the mock recorder for MockProvidersReloader.
+type MockProvidersReloaderMockRecorder struct {
+	mock *MockProvidersReloader
+}
+
+// NewMockProvidersReloader creates a new mock instance.
+func NewMockProvidersReloader(ctrl *gomock.Controller) *MockProvidersReloader {
+	mock := &MockProvidersReloader{ctrl: ctrl}
+	mock.recorder = &MockProvidersReloaderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockProvidersReloader) EXPECT() *MockProvidersReloaderMockRecorder {
+	return m.recorder
+}
+
+// Reload mocks base method.
+func (m *MockProvidersReloader) Reload(providers []*router.Provider, preferences map[string]string, failureThreshold int, cooldown, rateLimitBackoff time.Duration) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Reload", providers, preferences, failureThreshold, cooldown, rateLimitBackoff)
+}
+
+// Reload indicates an expected call of Reload.
+func (mr *MockProvidersReloaderMockRecorder) Reload(providers, preferences, failureThreshold, cooldown, rateLimitBackoff interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reload", reflect.TypeOf((*MockProvidersReloader)(nil).Reload), providers, preferences, failureThreshold, cooldown, rateLimitBackoff)
+}
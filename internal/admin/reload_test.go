@@ -0,0 +1,206 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"test_trigger/internal/call/router"
+	"test_trigger/internal/config"
+	"test_trigger/internal/logger"
+)
+
+func TestReloader_Reload(t *testing.T) {
+	testErr := errors.New("test")
+	provider := router.NewProvider("default", nil, nil, 1)
+	tests := []struct {
+		name            string
+		loaded          func(cfg *config.Config)
+		loadErr         error
+		expectedFunc    func(lim *MockLimiterResizer, pool *MockPoolResizer, r *MockProvidersReloader, l *logger.MockLogger)
+		expectedChanges []config.Change
+		expectedErr     error
+		expectedRunning func(cfg *config.Config)
+	}{
+		{
+			name:   "nothing changed",
+			loaded: func(cfg *config.Config) {},
+			expectedFunc: func(lim *MockLimiterResizer, pool *MockPoolResizer, r *MockProvidersReloader, l *logger.MockLogger) {
+				l.EXPECT().Info("reload: nothing changed")
+			},
+			expectedChanges: []config.Change{},
+			expectedRunning: func(cfg *config.Config) {},
+		},
+		{
+			name:    "load error",
+			loadErr: testErr,
+			expectedFunc: func(lim *MockLimiterResizer, pool *MockPoolResizer, r *MockProvidersReloader, l *logger.MockLogger) {
+				l.EXPECT().Error("reload: load", "error", testErr)
+			},
+			expectedErr:     testErr,
+			expectedRunning: func(cfg *config.Config) {},
+		},
+		{
+			name: "limiter and pool",
+			loaded: func(cfg *config.Config) {
+				cfg.LimiterLimit = 50
+				cfg.MaxWorkers = 10
+			},
+			expectedFunc: func(lim *MockLimiterResizer, pool *MockPoolResizer, r *MockProvidersReloader, l *logger.MockLogger) {
+				lim.EXPECT().Resize(uint64(10), uint64(50))
+				pool.EXPECT().Resize(10).Return(nil)
+				l.EXPECT().Info("reload: config changed", "key", "max_workers", "old", "30", "new", "10")
+				l.EXPECT().Info("reload: config changed", "key", "limiter_limit", "old", "25", "new", "50")
+			},
+			expectedChanges: []config.Change{
+				{Key: "max_workers", Old: "30", New: "10", Reloadable: true},
+				{Key: "limiter_limit", Old: "25", New: "50", Reloadable: true},
+			},
+			expectedRunning: func(cfg *config.Config) {
+				cfg.LimiterLimit = 50
+				cfg.MaxWorkers = 10
+			},
+		},
+		{
+			name: "providers, restart required",
+			loaded: func(cfg *config.Config) {
+				cfg.OriginateURL = "http://new"
+				cfg.Port = ":9000"
+			},
+			expectedFunc: func(lim *MockLimiterResizer, pool *MockPoolResizer, r *MockProvidersReloader, l *logger.MockLogger) {
+				r.EXPECT().Reload([]*router.Provider{provider}, nil, 5, 30*time.Second, 30*time.Second)
+				l.EXPECT().Warn("reload: config changed, restart required", "key", "port", "old", ":8328", "new", ":9000")
+				l.EXPECT().Info("reload: config changed", "key", "originate_url", "old", "https://google.com", "new", "http://new")
+			},
+			expectedChanges: []config.Change{
+				{Key: "port", Old: ":8328", New: ":9000"},
+				{Key: "originate_url", Old: "https://google.com", New: "http://new", Reloadable: true},
+			},
+			expectedRunning: func(cfg *config.Config) {
+				cfg.OriginateURL = "http://new"
+			},
+		},
+		{
+			name: "providers and preferences",
+			loaded: func(cfg *config.Config) {
+				cfg.Providers = []config.Provider{{Name: "a", URL: "http://a", Weight: 1, LimiterSize: 10, LimiterLimit: 25}}
+				cfg.AgentPreferences = map[string]string{"agent": "a"}
+			},
+			expectedFunc: func(lim *MockLimiterResizer, pool *MockPoolResizer, r *MockProvidersReloader, l *logger.MockLogger) {
+				r.EXPECT().Reload([]*router.Provider{provider}, map[string]string{"agent": "a"}, 5, 30*time.Second, 30*time.Second)
+				l.EXPECT().Info("reload: config changed", "key", "providers", "old", "[]", "new", "[{a http://a 1 10 25}]")
+				l.EXPECT().Info("reload: config changed", "key", "agent_preferences", "old", "map[]", "new", "map[agent:a]")
+			},
+			expectedChanges: []config.Change{
+				{Key: "providers", Old: "[]", New: "[{a http://a 1 10 25}]", Reloadable: true},
+				{Key: "agent_preferences", Old: "map[]", New: "map[agent:a]", Reloadable: true},
+			},
+			expectedRunning: func(cfg *config.Config) {
+				cfg.Providers = []config.Provider{{Name: "a", URL: "http://a", Weight: 1, LimiterSize: 10, LimiterLimit: 25}}
+				cfg.AgentPreferences = map[string]string{"agent": "a"}
+			},
+		},
+		{
+			name: "pool error, limiter isn't resized",
+			loaded: func(cfg *config.Config) {
+				cfg.MaxWorkers = 10
+				cfg.LimiterLimit = 50
+			},
+			expectedFunc: func(lim *MockLimiterResizer, pool *MockPoolResizer, r *MockProvidersReloader, l *logger.MockLogger) {
+				pool.EXPECT().Resize(10).Return(testErr)
+				l.EXPECT().Error("reload: pool Resize", "error", testErr)
+			},
+			expectedErr:     testErr,
+			expectedRunning: func(cfg *config.Config) {},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			lim := NewMockLimiterResizer(ctrl)
+			pool := NewMockPoolResizer(ctrl)
+			r := NewMockProvidersReloader(ctrl)
+			l := logger.NewMockLogger(ctrl)
+			tt.expectedFunc(lim, pool, r, l)
+			load := func() (config.Config, error) {
+				if tt.loadErr != nil {
+					return config.Config{}, tt.loadErr
+				}
+				cfg := config.Default()
+				tt.loaded(&cfg)
+				return cfg, nil
+			}
+			expected := config.Default()
+			tt.expectedRunning(&expected)
+			providers := func(cfg config.Config) []*router.Provider {
+				assert.Equal(t, expected, cfg)
+				return []*router.Provider{provider}
+			}
+			reloader := NewReloader(config.Default(), load, providers, lim, pool, r, l)
+
+			changes, err := reloader.Reload()
+			assert.Equal(t, tt.expectedErr, err)
+			if tt.expectedChanges != nil {
+				assert.ElementsMatch(t, tt.expectedChanges, changes)
+			}
+			assert.Equal(t, expected, reloader.running)
+		})
+	}
+}
+
+func TestReloader_HandleReload(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	pool := NewMockPoolResizer(ctrl)
+	l := logger.NewMockLogger(ctrl)
+	loaded := config.Default()
+	loaded.MaxWorkers = 10
+	var loadErr error
+	load := func() (config.Config, error) { return loaded, loadErr }
+	reloader := NewReloader(config.Default(), load, nil, NewMockLimiterResizer(ctrl), pool, NewMockProvidersReloader(ctrl), l)
+
+	resp := httptest.NewRecorder()
+	reloader.HandleReload(resp, httptest.NewRequest(http.MethodGet, "/admin/reload", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+
+	pool.EXPECT().Resize(10).Return(nil)
+	l.EXPECT().Info("reload: config changed", "key", "max_workers", "old", "30", "new", "10")
+	resp = httptest.NewRecorder()
+	reloader.HandleReload(resp, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"changes":[{"key":"max_workers","old":"30","new":"10","reloadable":true}]}`, resp.Body.String())
+
+	loadErr = errors.New("max_workers should be greater than 0, got 0")
+	l.EXPECT().Error("reload: load", "error", loadErr)
+	resp = httptest.NewRecorder()
+	reloader.HandleReload(resp, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, "max_workers should be greater than 0, got 0\n", resp.Body.String())
+}
+
+func TestReloader_Run(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	l := logger.NewMockLogger(ctrl)
+	reloader := NewReloader(config.Default(), func() (config.Config, error) { return config.Default(), nil }, nil, nil, nil, nil, l)
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reloader.Run(ctx, signals)
+	}()
+
+	reloaded := make(chan struct{})
+	l.EXPECT().Info("reload: nothing changed").Do(func(_ string, _ ...interface{}) { close(reloaded) })
+	signals <- syscall.SIGHUP
+	<-reloaded
+	cancel()
+	<-done
+}
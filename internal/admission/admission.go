@@ -0,0 +1,114 @@
+package admission
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"test_trigger/internal/call"
+	"test_trigger/internal/realtime"
+)
+
+//go:generate go run github.com/golang/mock/mockgen --source=admission.go --destination=admission_mock.go --package=admission
+
+// Reasons of rejections.
+const (
+	ReasonQueueFull = "queue is full"
+	ReasonWait      = "estimated wait is too long"
+)
+
+// Queue is the call storage, new calls are queued through the Controller.
+type Queue interface {
+	QueueLength(_ context.Context) (int, error)
+	AddToQueueBack(_ context.Context, meta call.Meta) error
+}
+
+// Throughput is router.Router, calls aren't dialed faster than the sum of provider limits.
+type Throughput interface {
+	Rate() float64
+}
+
+// QueueFullError is returned by AddToQueueBack, when the queue has max calls.
+type QueueFullError struct {
+	RetryAfter time.Duration // when a slot is free at the limiter rate.
+}
+
+func (e *QueueFullError) Error() string {
+	return ReasonQueueFull
+}
+
+// Decision is the projection for a new call, Reason is empty if it's accepted.
+type Decision struct {
+	Reason     string
+	Wait       time.Duration // until the call is dialed.
+	DialAt     time.Time
+	RetryAfter time.Duration // when the call would be accepted, only if it's rejected.
+}
+
+// Controller admits new calls by projected time to dial and queues them up to max queue depth.
+// The projection assumes calls are dialed at the limiter rate, it doesn't know about pauses and retries.
+type Controller struct {
+	queue      Queue
+	throughput Throughput
+	maxQueue   int           // 0 is unlimited.
+	maxWait    time.Duration // 0 is unlimited.
+	clock      realtime.Time
+	mu         *sync.Mutex // of the queue depth check and queueing.
+}
+
+func NewController(queue Queue, throughput Throughput, maxQueue int, maxWait time.Duration, clock realtime.Time) *Controller {
+	return &Controller{queue: queue, throughput: throughput, maxQueue: maxQueue, maxWait: maxWait, clock: clock, mu: &sync.Mutex{}}
+}
+
+// Admit projects when a new call is dialed, it's rejected if the wait is too long.
+// The wait is an estimate, the queue depth is enforced by AddToQueueBack.
+func (c *Controller) Admit(ctx context.Context) (Decision, error) {
+	length, err := c.queue.QueueLength(ctx)
+	if err != nil {
+		return Decision{}, err
+	}
+	// the new call is dialed after the whole queue.
+	wait := c.duration(length + 1)
+	decision := Decision{Wait: wait, DialAt: c.clock.Now().Add(wait)}
+	if c.maxWait > 0 && wait > c.maxWait {
+		decision.Reason = ReasonWait
+		decision.RetryAfter = wait - c.maxWait
+	}
+	return decision, nil
+}
+
+// AddToQueueBack queues a new call, *QueueFullError is returned if the queue is full.
+// The check and queueing are under one lock, so concurrent requests don't exceed max queue depth.
+// Retries and replays are queued by the storage directly, calls, which were accepted once, aren't rejected.
+func (c *Controller) AddToQueueBack(ctx context.Context, meta call.Meta) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.maxQueue > 0 {
+		length, err := c.queue.QueueLength(ctx)
+		if err != nil {
+			return err
+		}
+		if length >= c.maxQueue {
+			return &QueueFullError{RetryAfter: c.duration(length - c.maxQueue + 1)}
+		}
+	}
+	return c.queue.AddToQueueBack(ctx, meta)
+}
+
+// EstimatedWait returns time to dial the current queue.
+func (c *Controller) EstimatedWait(ctx context.Context) (time.Duration, error) {
+	length, err := c.queue.QueueLength(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return c.duration(length), nil
+}
+
+func (c *Controller) duration(calls int) time.Duration {
+	rate := c.throughput.Rate()
+	if rate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(float64(calls) / rate * float64(time.Second))
+}
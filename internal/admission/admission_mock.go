@@ -0,0 +1,102 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: admission.go
+
+// Package admission is a generated GoMock package.
+package admission
+
+import (
+	context "context"
+	reflect "reflect"
+	call "test_trigger/internal/call"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockQueue is a mock of Queue interface.
+type MockQueue struct {
+	ctrl     *gomock.Controller
+	recorder *MockQueueMockRecorder
+}
+
+// MockQueueMockRecorder is the mock recorder for MockQueue.
+type MockQueueMockRecorder struct {
+	mock *MockQueue
+}
+
+// NewMockQueue creates a new mock instance.
+func NewMockQueue(ctrl *gomock.Controller) *MockQueue {
+	mock := &MockQueue{ctrl: ctrl}
+	mock.recorder = &MockQueueMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockQueue) EXPECT() *MockQueueMockRecorder {
+	return m.recorder
+}
+
+// AddToQueueBack mocks base method.
+func (m *MockQueue) AddToQueueBack(arg0 context.Context, meta call.Meta) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddToQueueBack", arg0, meta)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddToQueueBack indicates an expected call of AddToQueueBack.
+func (mr *MockQueueMockRecorder) AddToQueueBack(arg0, meta interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddToQueueBack", reflect.TypeOf((*MockQueue)(nil).AddToQueueBack), arg0, meta)
+}
+
+// QueueLength mocks base method.
+func (m *MockQueue) QueueLength(arg0 context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueueLength", arg0)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueueLength indicates an expected call of QueueLength.
+func (mr *MockQueueMockRecorder) QueueLength(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueueLength", reflect.TypeOf((*MockQueue)(nil).QueueLength), arg0)
+}
+
+// MockThroughput is a mock of Throughput interface.
+type MockThroughput struct {
+	ctrl     *gomock.Controller
+	recorder *MockThroughputMockRecorder
+}
+
+// MockThroughputMockRecorder is the mock recorder for MockThroughput.
+type MockThroughputMockRecorder struct {
+	mock *MockThroughput
+}
+
+// NewMockThroughput creates a new mock instance.
+func NewMockThroughput(ctrl *gomock.Controller) *MockThroughput {
+	mock := &MockThroughput{ctrl: ctrl}
+	mock.recorder = &MockThroughputMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockThroughput) EXPECT() *MockThroughputMockRecorder {
+	return m.recorder
+}
+
+// Rate mocks base method.
+func (m *MockThroughput) Rate() float64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rate")
+	ret0, _ := ret[0].(float64)
+	return ret0
+}
+
+// Rate indicates an expected call of Rate.
+func (mr *MockThroughputMockRecorder) Rate() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rate", reflect.TypeOf((*MockThroughput)(nil).Rate))
+}
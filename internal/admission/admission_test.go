@@ -0,0 +1,173 @@
+package admission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"test_trigger/internal/call"
+	"test_trigger/internal/realtime"
+)
+
+func TestController_Admit(t *testing.T) {
+	now := time.Unix(1709464831, 0)
+	testErr := errors.New("test")
+	tests := []struct {
+		name        string
+		length      int
+		lengthErr   error
+		maxQueue    int
+		maxWait     time.Duration
+		expected    Decision
+		expectedErr error
+	}{
+		{
+			name:     "empty queue",
+			maxQueue: 10,
+			expected: Decision{Wait: 400 * time.Millisecond, DialAt: now.Add(400 * time.Millisecond)},
+		},
+		{
+			name:     "accepted",
+			length:   9,
+			maxQueue: 10,
+			maxWait:  time.Minute,
+			expected: Decision{Wait: 4 * time.Second, DialAt: now.Add(4 * time.Second)},
+		},
+		{
+			name:     "unlimited",
+			length:   1000,
+			expected: Decision{Wait: 400400 * time.Millisecond, DialAt: now.Add(400400 * time.Millisecond)},
+		},
+		{
+			name:     "queue is full, it's checked by AddToQueueBack",
+			length:   12,
+			maxQueue: 10,
+			expected: Decision{Wait: 5200 * time.Millisecond, DialAt: now.Add(5200 * time.Millisecond)},
+		},
+		{
+			name:     "exactly max wait",
+			length:   149,
+			maxWait:  time.Minute,
+			expected: Decision{Wait: time.Minute, DialAt: now.Add(time.Minute)},
+		},
+		{
+			name:     "wait is too long",
+			length:   174,
+			maxWait:  time.Minute,
+			expected: Decision{Reason: ReasonWait, Wait: 70 * time.Second, DialAt: now.Add(70 * time.Second), RetryAfter: 10 * time.Second},
+		},
+		{
+			name:        "queue length error",
+			lengthErr:   testErr,
+			expectedErr: testErr,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			queue := NewMockQueue(ctrl)
+			queue.EXPECT().QueueLength(gomock.Any()).Return(tt.length, tt.lengthErr)
+			throughput := NewMockThroughput(ctrl)
+			throughput.EXPECT().Rate().Return(2.5).AnyTimes()
+			c := NewController(queue, throughput, tt.maxQueue, tt.maxWait, realtime.NewFake(now))
+
+			decision, err := c.Admit(context.Background())
+			assert.Equal(t, tt.expectedErr, err)
+			assert.Equal(t, tt.expected, decision)
+		})
+	}
+}
+
+func TestController_EstimatedWait(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	queue := NewMockQueue(ctrl)
+	queue.EXPECT().QueueLength(gomock.Any()).Return(25, nil)
+	throughput := NewMockThroughput(ctrl)
+	throughput.EXPECT().Rate().Return(2.5)
+	c := NewController(queue, throughput, 0, 0, realtime.NewFake(time.Unix(1709464831, 0)))
+
+	wait, err := c.EstimatedWait(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 10*time.Second, wait)
+}
+
+func TestController_AddToQueueBack(t *testing.T) {
+	testErr := errors.New("test")
+	tests := []struct {
+		name        string
+		length      int
+		lengthErr   error
+		maxQueue    int
+		queued      bool
+		expectedErr error
+	}{
+		{
+			name:     "queued",
+			length:   9,
+			maxQueue: 10,
+			queued:   true,
+		},
+		{
+			name:   "unlimited",
+			length: 1000,
+			queued: true,
+		},
+		{
+			name:     "queue is full",
+			length:   12,
+			maxQueue: 10,
+			// 3 calls should be dialed before a slot is free.
+			expectedErr: &QueueFullError{RetryAfter: 1200 * time.Millisecond},
+		},
+		{
+			name:        "queue length error",
+			lengthErr:   testErr,
+			maxQueue:    10,
+			expectedErr: testErr,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			queue := NewMockQueue(ctrl)
+			if tt.maxQueue > 0 {
+				queue.EXPECT().QueueLength(gomock.Any()).Return(tt.length, tt.lengthErr)
+			}
+			if tt.queued {
+				queue.EXPECT().AddToQueueBack(gomock.Any(), call.Meta{ID: "1"}).Return(nil)
+			}
+			throughput := NewMockThroughput(ctrl)
+			throughput.EXPECT().Rate().Return(2.5).AnyTimes()
+			c := NewController(queue, throughput, tt.maxQueue, 0, realtime.NewFake(time.Unix(1709464831, 0)))
+
+			assert.Equal(t, tt.expectedErr, c.AddToQueueBack(context.Background(), call.Meta{ID: "1"}))
+		})
+	}
+}
+
+func TestController_AddToQueueBack_Concurrent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	storage := call.NewStorage()
+	throughput := NewMockThroughput(ctrl)
+	throughput.EXPECT().Rate().Return(2.5).AnyTimes()
+	c := NewController(storage, throughput, 10, 0, realtime.NewFake(time.Unix(1709464831, 0)))
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_ = c.AddToQueueBack(context.Background(), call.Meta{ID: call.ID(fmt.Sprint(i))})
+		}(i)
+	}
+	wg.Wait()
+	length, err := storage.QueueLength(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 10, length)
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"test_trigger/internal/logger"
+	"test_trigger/internal/realtime"
+	"test_trigger/internal/signature"
+)
+
+const (
+	HeaderTimestamp = "X-Signature-Timestamp"
+	HeaderSignature = "X-Signature"
+	// AllAgents in VirtualAgents allows every virtual agent.
+	AllAgents = "*"
+	// maxSignedBody limits bodies, which are read before the handler for signature check.
+	maxSignedBody = 1 << 20
+)
+
+// Client is an API client. The key isn't stored, only its hex SHA-256: echo -n "$KEY" | sha256sum.
+type Client struct {
+	ID            string   `yaml:"id" json:"id"`
+	KeySHA256     string   `yaml:"key_sha256" json:"key_sha256"`
+	Tenant        string   `yaml:"tenant" json:"tenant"` // the id is used if it's empty.
+	VirtualAgents []string `yaml:"virtual_agents" json:"virtual_agents"`
+	SigningSecret string   `yaml:"signing_secret" json:"signing_secret"` // requests should be signed if it's set.
+	Admin         bool     `yaml:"admin" json:"admin"`
+	// intake quotas, zero values are taken from quota_ config.
+	Rate      float64 `yaml:"rate" json:"rate"` // /trigger requests per second.
+	Burst     int     `yaml:"burst" json:"burst"`
+	MaxQueued int     `yaml:"max_queued" json:"max_queued"` // calls, which aren't completed yet.
+}
+
+// Allowed is true if the client can trigger calls of the agent.
+func (c Client) Allowed(virtualAgentID string) bool {
+	for _, agent := range c.VirtualAgents {
+		if agent == AllAgents || agent == virtualAgentID {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Client) TenantID() string {
+	if c.Tenant != "" {
+		return c.Tenant
+	}
+	return c.ID
+}
+
+// HashKey returns hex SHA-256 of the key, like in Client.KeySHA256.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+type contextKey struct{}
+
+func ContextWithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, contextKey{}, client)
+}
+
+// ClientFromContext returns the authenticated client, false if auth is disabled.
+func ClientFromContext(ctx context.Context) (Client, bool) {
+	client, ok := ctx.Value(contextKey{}).(Client)
+	return client, ok
+}
+
+// Authenticator checks API keys of requests, it's disabled if there are no clients.
+type Authenticator struct {
+	clients map[string]Client // by key hash.
+	maxSkew time.Duration
+	clock   realtime.Time
+	logger  logger.Logger
+}
+
+func NewAuthenticator(clients []Client, maxSkew time.Duration, clock realtime.Time, logger logger.Logger) *Authenticator {
+	byHash := make(map[string]Client, len(clients))
+	for _, c := range clients {
+		byHash[strings.ToLower(c.KeySHA256)] = c
+	}
+	return &Authenticator{clients: byHash, maxSkew: maxSkew, clock: clock, logger: logger}
+}
+
+func (a *Authenticator) Enabled() bool {
+	return len(a.clients) > 0
+}
+
+// Authenticate passes requests with a known key in "Authorization: Bearer <key>" to next, the client is in the context.
+func (a *Authenticator) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		client, ok := a.authenticate(w, r)
+		if !ok {
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ContextWithClient(r.Context(), client)))
+	})
+}
+
+// AuthenticateAdmin is Authenticate, which passes only admin clients. Without clients there is no admin,
+// so admin endpoints are forbidden rather than open to everyone who reaches the port.
+func (a *Authenticator) AuthenticateAdmin(next http.Handler) http.Handler {
+	return a.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.Enabled() {
+			a.logger.Warn("auth: admin is disabled without api clients", "remote_addr", r.RemoteAddr, "path", r.URL.Path)
+			http.Error(w, "admin endpoints need api_clients", http.StatusForbidden)
+			return
+		}
+		if client, ok := ClientFromContext(r.Context()); ok && !client.Admin {
+			a.logger.Warn("auth: admin is required", "client_id", client.ID, "path", r.URL.Path)
+			http.Error(w, "admin client is required", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
+
+func (a *Authenticator) authenticate(w http.ResponseWriter, r *http.Request) (Client, bool) {
+	key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	// the map is looked up by the hash, so timing doesn't tell anything about the key.
+	client, known := a.clients[HashKey(key)]
+	if !ok || key == "" || !known {
+		a.logger.Warn("auth: unknown api key", "remote_addr", r.RemoteAddr, "path", r.URL.Path)
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "api key is missing or unknown", http.StatusUnauthorized)
+		return Client{}, false
+	}
+	if client.SigningSecret == "" {
+		return client, true
+	}
+	if reason := a.verify(r, client.SigningSecret); reason != "" {
+		a.logger.Warn("auth: bad signature", "client_id", client.ID, "reason", reason)
+		http.Error(w, reason, http.StatusUnauthorized)
+		return Client{}, false
+	}
+	return client, true
+}
+
+// verify checks the signature of the body and returns the reason if it's wrong. The body is read and replaced.
+func (a *Authenticator) verify(r *http.Request, secret string) string {
+	timestamp := r.Header.Get(HeaderTimestamp)
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return HeaderTimestamp + " should be unix seconds"
+	}
+	// old signed requests are rejected, a request can still be sent again within maxSkew, there is no nonce.
+	if skew := a.clock.Now().Sub(time.Unix(unix, 0)); skew > a.maxSkew || skew < -a.maxSkew {
+		return HeaderTimestamp + " is too far from the server time"
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSignedBody))
+	if err != nil {
+		return "can't read body"
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if !hmac.Equal([]byte(r.Header.Get(HeaderSignature)), []byte(signature.Sign(secret, timestamp, body))) {
+		return HeaderSignature + " doesn't match"
+	}
+	return ""
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"test_trigger/internal/logger"
+	"test_trigger/internal/realtime"
+	"test_trigger/internal/signature"
+)
+
+func TestClient_Allowed(t *testing.T) {
+	c := Client{ID: "crm", VirtualAgents: []string{"a", "b"}}
+	assert.True(t, c.Allowed("a"))
+	assert.False(t, c.Allowed("c"))
+	assert.False(t, Client{}.Allowed("a"))
+	assert.True(t, Client{VirtualAgents: []string{AllAgents}}.Allowed("c"))
+	assert.Equal(t, "crm", c.TenantID())
+	c.Tenant = "acme"
+	assert.Equal(t, "acme", c.TenantID())
+}
+
+func TestAuthenticator_Authenticate(t *testing.T) {
+	now := time.Unix(1709464831, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	body := `{"phone_number":"777","virtual_agent_id":"a"}`
+	crm := Client{ID: "crm", KeySHA256: HashKey("crm-key"), VirtualAgents: []string{"a"}}
+	signed := Client{ID: "signed", KeySHA256: strings.ToUpper(HashKey("signed-key")), SigningSecret: "secret"}
+	tests := []struct {
+		name           string
+		clients        []Client
+		headers        map[string]string
+		expectedCode   int
+		expectedClient *Client
+		expectedLog    string
+	}{
+		{
+			name:         "disabled",
+			headers:      map[string]string{},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:           "known key",
+			clients:        []Client{crm, signed},
+			headers:        map[string]string{"Authorization": "Bearer crm-key"},
+			expectedCode:   http.StatusOK,
+			expectedClient: &crm,
+		},
+		{
+			name:         "missing key",
+			clients:      []Client{crm},
+			headers:      map[string]string{},
+			expectedCode: http.StatusUnauthorized,
+			expectedLog:  "auth: unknown api key",
+		},
+		{
+			name:         "unknown key",
+			clients:      []Client{crm},
+			headers:      map[string]string{"Authorization": "Bearer other"},
+			expectedCode: http.StatusUnauthorized,
+			expectedLog:  "auth: unknown api key",
+		},
+		{
+			name:         "not bearer",
+			clients:      []Client{crm},
+			headers:      map[string]string{"Authorization": "crm-key"},
+			expectedCode: http.StatusUnauthorized,
+			expectedLog:  "auth: unknown api key",
+		},
+		{
+			name:           "signed",
+			clients:        []Client{crm, signed},
+			headers:        map[string]string{"Authorization": "Bearer signed-key", HeaderTimestamp: timestamp, HeaderSignature: signature.Sign("secret", timestamp, []byte(body))},
+			expectedCode:   http.StatusOK,
+			expectedClient: &signed,
+		},
+		{
+			name:         "signed, wrong signature",
+			clients:      []Client{signed},
+			headers:      map[string]string{"Authorization": "Bearer signed-key", HeaderTimestamp: timestamp, HeaderSignature: signature.Sign("other", timestamp, []byte(body))},
+			expectedCode: http.StatusUnauthorized,
+			expectedLog:  "auth: bad signature",
+		},
+		{
+			name:         "signed, without signature",
+			clients:      []Client{signed},
+			headers:      map[string]string{"Authorization": "Bearer signed-key"},
+			expectedCode: http.StatusUnauthorized,
+			expectedLog:  "auth: bad signature",
+		},
+		{
+			name:    "signed, old timestamp",
+			clients: []Client{signed},
+			headers: map[string]string{"Authorization": "Bearer signed-key", HeaderTimestamp: strconv.FormatInt(now.Unix()-301, 10),
+				HeaderSignature: signature.Sign("secret", strconv.FormatInt(now.Unix()-301, 10), []byte(body))},
+			expectedCode: http.StatusUnauthorized,
+			expectedLog:  "auth: bad signature",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			l := logger.NewMockLogger(ctrl)
+			if tt.expectedLog != "" {
+				l.EXPECT().Warn(tt.expectedLog, gomock.Any())
+			}
+			a := NewAuthenticator(tt.clients, 5*time.Minute, realtime.NewFake(now), l)
+			handler := a.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				client, ok := ClientFromContext(r.Context())
+				if tt.expectedClient == nil {
+					assert.False(t, ok)
+				} else {
+					assert.Equal(t, *tt.expectedClient, client)
+				}
+				// the body is still readable after the signature check.
+				read, _ := io.ReadAll(r.Body)
+				assert.Equal(t, body, string(read))
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/trigger", strings.NewReader(body))
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+			assert.Equal(t, tt.expectedCode, resp.Code)
+		})
+	}
+}
+
+func TestAuthenticator_AuthenticateAdmin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	l := logger.NewMockLogger(ctrl)
+	a := NewAuthenticator([]Client{
+		{ID: "crm", KeySHA256: HashKey("crm-key")},
+		{ID: "ops", KeySHA256: HashKey("ops-key"), Admin: true},
+	}, time.Minute, realtime.NewFake(time.Unix(1709464831, 0)), l)
+	handler := a.AuthenticateAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/pause", nil)
+	req.Header.Set("Authorization", "Bearer ops-key")
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	l.EXPECT().Warn("auth: admin is required", "client_id", "crm", "path", "/admin/pause")
+	req.Header.Set("Authorization", "Bearer crm-key")
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+}
+
+func TestAuthenticator_AuthenticateAdmin_Disabled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	l := logger.NewMockLogger(ctrl)
+	a := NewAuthenticator(nil, time.Minute, realtime.NewFake(time.Unix(1709464831, 0)), l)
+	handler := a.AuthenticateAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("admin handler shouldn't be reached")
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/pause", nil)
+	l.EXPECT().Warn("auth: admin is disabled without api clients", "remote_addr", req.RemoteAddr, "path", "/admin/pause")
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+}
@@ -1,14 +1,109 @@
 package call
 
+import "time"
+
 type ID string
 
 type Meta struct {
 	PhoneNumber    string
 	VirtualAgentID string
 	ID             ID
+	EnqueuedAt     time.Time // when the call was put to the end of the queue, for queue wait metric.
+	TraceParent    string    // W3C traceparent of the trigger span, workers continue the trace.
+	CallbackURL    string    // webhook is sent there when the call is completed.
+	Tenant         string    // webhooks are signed with the secret of the tenant.
+	ClientID       string    // API client, which triggered the call, empty if auth is disabled.
+	ExpiresAt      time.Time // the call isn't dialed after it, zero never expires.
+	Attempts       int       // failed attempts since the call was queued or replayed.
+	Failures       []Failure // the whole history, replay doesn't reset it.
+}
+
+// Failure is one failed attempt, the call wasn't dialed or the provider failed.
+type Failure struct {
+	At      time.Time `json:"at"`
+	Reason  string    `json:"reason"`
+	Code    int       `json:"code,omitempty"`
+	Outcome Outcome   `json:"outcome,omitempty"`
+}
+
+// DeadLetter is the call, which has failed too many times in a row. It isn't retried until replay.
+type DeadLetter struct {
+	Meta
+	DeadAt time.Time
+}
+
+// DeadLetterFilter selects dead letters, empty fields match any.
+type DeadLetterFilter struct {
+	IDs            []ID
+	VirtualAgentID string
+	Outcome        Outcome // outcome of the last failure.
+}
+
+func (f DeadLetterFilter) Match(d DeadLetter) bool {
+	if f.VirtualAgentID != "" && f.VirtualAgentID != d.VirtualAgentID {
+		return false
+	}
+	if f.Outcome != "" && (len(d.Failures) == 0 || d.Failures[len(d.Failures)-1].Outcome != f.Outcome) {
+		return false
+	}
+	if len(f.IDs) == 0 {
+		return true
+	}
+	for _, id := range f.IDs {
+		if id == d.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusRecord is a saved status with the call, persistent storages return them, so state, which is derived from statuses,
+// is restored after restart. Meta has ID, VirtualAgentID, ClientID and Tenant only.
+type StatusRecord struct {
+	Meta
+	Status  Status
+	SavedAt time.Time
+}
+
+// Expired is true if the call shouldn't be dialed anymore.
+func (m Meta) Expired(now time.Time) bool {
+	return !m.ExpiresAt.IsZero() && !now.Before(m.ExpiresAt)
 }
 
 type Body struct {
-	PhoneNumber    string `json:"phone_number"`
-	VirtualAgentID string `json:"virtual_agent_id"`
+	PhoneNumber    string     `json:"phone_number"`
+	VirtualAgentID string     `json:"virtual_agent_id"`
+	CallbackURL    string     `json:"callback_url,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	TTL            string     `json:"ttl,omitempty"` // duration like "30m", instead of expires_at.
+}
+
+// Result is a classified response of the originate API.
+type Result struct {
+	StatusCode int
+	Outcome    Outcome
+}
+
+// State is a lifecycle state of the call.
+type State string
+
+const (
+	StateQueued   State = "queued"
+	StateRetrying State = "retrying"
+	StateFinished State = "finished"
+	StateFailed   State = "failed"  // moved to dead letters.
+	StateExpired  State = "expired" // not dialed before expires_at.
+)
+
+// Terminal states aren't changed by workers anymore.
+func (s State) Terminal() bool {
+	return s == StateFinished || s == StateFailed || s == StateExpired
+}
+
+// Status is a stored status record of the call.
+type Status struct {
+	State   State   `json:"state"`
+	Code    int     `json:"code,omitempty"`
+	Outcome Outcome `json:"outcome,omitempty"`
+	Tenant  string  `json:"-"` // of the call, storages take it from Meta, statuses of other tenants aren't shown.
 }
@@ -0,0 +1,14 @@
+package call_test
+
+import (
+	"testing"
+
+	"test_trigger/internal/call"
+	"test_trigger/internal/call/storagetest"
+)
+
+func TestStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) call.Store {
+		return call.NewStorage()
+	})
+}
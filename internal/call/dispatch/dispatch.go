@@ -0,0 +1,197 @@
+package dispatch
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+//go:generate go run github.com/golang/mock/mockgen --source=dispatch.go --destination=dispatch_mock.go --package=dispatch
+
+// Counter counts queued calls of paused agents.
+type Counter interface {
+	QueueLengthOf(_ context.Context, virtualAgentIDs []string) (int, error)
+}
+
+type Mode string
+
+const (
+	ModeRunning  Mode = "running"
+	ModePaused   Mode = "paused"   // workers don't take calls, intake is open unless it's draining too.
+	ModeDraining Mode = "draining" // intake is closed, workers finish the queue.
+)
+
+// State is reported in /status and admin responses.
+type State struct {
+	Mode         Mode     `json:"mode"`
+	Accepting    bool     `json:"accepting"` // false while draining, pause doesn't open intake.
+	PausedAgents []string `json:"paused_agents"`
+	Parked       int      `json:"parked_calls"` // queued calls of paused agents, 0 if storage isn't reachable.
+}
+
+// closed is returned by nil Control, dispatching is never paused.
+var closed = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
+// Control pauses dispatching without losing queued calls, e.g. during a provider incident.
+// Workers wait on Resumed. Calls of paused virtual agents stay in the queue, workers skip them, so they don't block other agents.
+// Draining is kept apart from pausing, pause during drain doesn't open intake.
+// Methods are safe on nil Control, it is always running.
+type Control struct {
+	counter      Counter
+	mu           *sync.Mutex
+	paused       bool
+	draining     bool
+	resumed      chan struct{} // closed while dispatching isn't paused.
+	agents       map[string]struct{}
+	agentResumed chan struct{} // closed and replaced when a paused agent is resumed.
+}
+
+func NewControl(counter Counter) *Control {
+	return &Control{counter: counter, mu: &sync.Mutex{}, resumed: closed, agents: make(map[string]struct{}), agentResumed: make(chan struct{})}
+}
+
+// Pause stops dispatching of all calls, intake isn't changed.
+func (c *Control) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.paused {
+		c.resumed = make(chan struct{})
+	}
+	c.paused = true
+}
+
+// Resume continues dispatching and opens intake after Drain. Paused agents stay paused.
+func (c *Control) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.unpause()
+	c.draining = false
+}
+
+// Drain closes intake, workers continue until the queue is empty.
+func (c *Control) Drain() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.unpause()
+	c.draining = true
+}
+
+func (c *Control) unpause() {
+	if c.paused {
+		close(c.resumed)
+	}
+	c.paused = false
+}
+
+func (c *Control) Mode() Mode {
+	if c == nil {
+		return ModeRunning
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.mode()
+}
+
+func (c *Control) mode() Mode {
+	switch {
+	case c.paused:
+		return ModePaused
+	case c.draining:
+		return ModeDraining
+	}
+	return ModeRunning
+}
+
+// Resumed is closed while dispatching isn't paused.
+func (c *Control) Resumed() <-chan struct{} {
+	if c == nil {
+		return closed
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.resumed
+}
+
+// Accepting is false while draining, new calls should be rejected.
+func (c *Control) Accepting() bool {
+	if c == nil {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.draining
+}
+
+// PauseAgent makes workers skip calls of the virtual agent until ResumeAgent.
+func (c *Control) PauseAgent(virtualAgentID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.agents[virtualAgentID] = struct{}{}
+}
+
+// ResumeAgent lets workers take calls of the virtual agent, they have kept their place in the queue.
+func (c *Control) ResumeAgent(virtualAgentID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.agents[virtualAgentID]; !ok {
+		return
+	}
+	delete(c.agents, virtualAgentID)
+	close(c.agentResumed)
+	c.agentResumed = make(chan struct{})
+}
+
+// AgentResumed is closed when a paused agent is resumed, idle workers wake up for its calls.
+// It should be taken before Next, so a resume between them isn't missed. Nil Control never closes it.
+func (c *Control) AgentResumed() <-chan struct{} {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.agentResumed
+}
+
+// PausedAgents returns paused virtual agents sorted, nil if there are none.
+func (c *Control) PausedAgents() []string {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pausedAgents()
+}
+
+func (c *Control) pausedAgents() []string {
+	if len(c.agents) == 0 {
+		return nil
+	}
+	res := make([]string, 0, len(c.agents))
+	for agent := range c.agents {
+		res = append(res, agent)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func (c *Control) State() State {
+	if c == nil {
+		return State{Mode: ModeRunning, Accepting: true, PausedAgents: []string{}}
+	}
+	c.mu.Lock()
+	state := State{Mode: c.mode(), Accepting: !c.draining, PausedAgents: c.pausedAgents()}
+	c.mu.Unlock()
+	if state.PausedAgents == nil {
+		state.PausedAgents = []string{}
+		return state
+	}
+	// the storage isn't called under the lock, workers take paused agents meanwhile.
+	if parked, err := c.counter.QueueLengthOf(context.Background(), state.PausedAgents); err == nil {
+		state.Parked = parked
+	}
+	return state
+}
@@ -0,0 +1,98 @@
+package dispatch
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestControl_Pause(t *testing.T) {
+	c := NewControl(nil)
+	assert.Equal(t, ModeRunning, c.Mode())
+	assert.True(t, c.Accepting())
+	<-c.Resumed()
+
+	c.Pause()
+	c.Pause()
+	assert.Equal(t, ModePaused, c.Mode())
+	assert.True(t, c.Accepting())
+	resumed := c.Resumed()
+	select {
+	case <-resumed:
+		t.Fatal("resumed while paused")
+	default:
+	}
+
+	c.Resume()
+	<-resumed
+	assert.Equal(t, ModeRunning, c.Mode())
+	c.Resume()
+	<-c.Resumed()
+}
+
+func TestControl_Drain(t *testing.T) {
+	c := NewControl(nil)
+	c.Pause()
+	resumed := c.Resumed()
+	c.Drain()
+	// workers finish the queue, only intake is closed.
+	<-resumed
+	assert.Equal(t, ModeDraining, c.Mode())
+	assert.False(t, c.Accepting())
+
+	// pause during drain doesn't open intake.
+	c.Pause()
+	assert.Equal(t, ModePaused, c.Mode())
+	assert.False(t, c.Accepting())
+	assert.False(t, c.State().Accepting)
+
+	c.Resume()
+	assert.True(t, c.Accepting())
+	assert.Equal(t, ModeRunning, c.Mode())
+}
+
+func TestControl_Agents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	counter := NewMockCounter(ctrl)
+	c := NewControl(counter)
+	assert.Nil(t, c.PausedAgents())
+	assert.Equal(t, State{Mode: ModeRunning, Accepting: true, PausedAgents: []string{}}, c.State())
+
+	c.PauseAgent("b")
+	c.PauseAgent("a")
+	c.PauseAgent("a")
+	assert.Equal(t, []string{"a", "b"}, c.PausedAgents())
+	counter.EXPECT().QueueLengthOf(gomock.Any(), []string{"a", "b"}).Return(2, nil)
+	assert.Equal(t, State{Mode: ModeRunning, Accepting: true, PausedAgents: []string{"a", "b"}, Parked: 2}, c.State())
+	counter.EXPECT().QueueLengthOf(gomock.Any(), []string{"a", "b"}).Return(0, errors.New("test"))
+	assert.Equal(t, State{Mode: ModeRunning, Accepting: true, PausedAgents: []string{"a", "b"}}, c.State())
+
+	// idle workers are woken, when an agent is resumed.
+	resumed := c.AgentResumed()
+	c.ResumeAgent("unknown")
+	select {
+	case <-resumed:
+		t.Fatal("woken by the agent, which wasn't paused")
+	default:
+	}
+	c.ResumeAgent("a")
+	<-resumed
+	assert.Equal(t, []string{"b"}, c.PausedAgents())
+	select {
+	case <-c.AgentResumed():
+		t.Fatal("the next resume isn't waited")
+	default:
+	}
+}
+
+func TestControl_Nil(t *testing.T) {
+	var c *Control
+	assert.Equal(t, ModeRunning, c.Mode())
+	assert.True(t, c.Accepting())
+	assert.Nil(t, c.PausedAgents())
+	assert.Nil(t, c.AgentResumed())
+	assert.Equal(t, State{Mode: ModeRunning, Accepting: true, PausedAgents: []string{}}, c.State())
+	<-c.Resumed()
+}
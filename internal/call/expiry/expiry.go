@@ -0,0 +1,70 @@
+package expiry
+
+import (
+	"context"
+	"time"
+
+	"test_trigger/internal/call"
+	"test_trigger/internal/logger"
+	"test_trigger/internal/metrics"
+	"test_trigger/internal/realtime"
+)
+
+//go:generate go run github.com/golang/mock/mockgen --source=expiry.go --destination=expiry_mock.go --package=expiry
+
+type Storage interface {
+	RemoveExpired(_ context.Context, now time.Time) ([]call.Meta, error)
+}
+
+type StatusStorage interface {
+	SaveStatus(_ context.Context, status call.Status, meta call.Meta) error
+}
+
+// Sweeper removes expired calls from the queue, so they don't count towards queue length and admission.
+// Workers check expiry too, a call can expire between sweeps.
+type Sweeper struct {
+	storage       Storage
+	statusStorage StatusStorage
+	interval      time.Duration
+	clock         realtime.Time
+	logger        logger.Logger
+	metrics       *metrics.Calls
+}
+
+func NewSweeper(storage Storage, statusStorage StatusStorage, interval time.Duration, clock realtime.Time, logger logger.Logger, m *metrics.Calls) *Sweeper {
+	return &Sweeper{storage: storage, statusStorage: statusStorage, interval: interval, clock: clock, logger: logger, metrics: m}
+}
+
+// Run sweeps every interval until ctx is cancelled.
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := s.clock.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			s.Sweep(ctx)
+		}
+	}
+}
+
+// Sweep removes expired calls and saves their statuses, it returns the number of removed calls.
+func (s *Sweeper) Sweep(ctx context.Context) int {
+	expired, err := s.storage.RemoveExpired(ctx, s.clock.Now())
+	if err != nil {
+		s.logger.Error("sweep: RemoveExpired", "error", err)
+		return 0
+	}
+	for _, meta := range expired {
+		s.metrics.Expired()
+		// the call is already removed, a failed status is only logged.
+		if err := s.statusStorage.SaveStatus(ctx, call.Status{State: call.StateExpired}, meta); err != nil {
+			s.logger.Error("sweep: SaveStatus", "error", err, "call_id", string(meta.ID))
+		}
+	}
+	if len(expired) > 0 {
+		s.logger.Info("expired calls removed from the queue", "count", len(expired))
+	}
+	return len(expired)
+}
@@ -0,0 +1,89 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: expiry.go
+
+// Package expiry is a generated GoMock package.
+package expiry
+
+import (
+	context "context"
+	reflect "reflect"
+	call "test_trigger/internal/call"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockStorage is a mock of Storage interface.
+type MockStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockStorageMockRecorder
+}
+
+// MockStorageMockRecorder is the mock recorder for MockStorage.
+type MockStorageMockRecorder struct {
+	mock *MockStorage
+}
+
+// NewMockStorage creates a new mock instance.
+func NewMockStorage(ctrl *gomock.Controller) *MockStorage {
+	mock := &MockStorage{ctrl: ctrl}
+	mock.recorder = &MockStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
+	return m.recorder
+}
+
+// RemoveExpired mocks base method.
+func (m *MockStorage) RemoveExpired(arg0 context.Context, now time.Time) ([]call.Meta, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveExpired", arg0, now)
+	ret0, _ := ret[0].([]call.Meta)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveExpired indicates an expected call of RemoveExpired.
+func (mr *MockStorageMockRecorder) RemoveExpired(arg0, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveExpired", reflect.TypeOf((*MockStorage)(nil).RemoveExpired), arg0, now)
+}
+
+// MockStatusStorage is a mock of StatusStorage interface.
+type MockStatusStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockStatusStorageMockRecorder
+}
+
+// MockStatusStorageMockRecorder is the mock recorder for MockStatusStorage.
+type MockStatusStorageMockRecorder struct {
+	mock *MockStatusStorage
+}
+
+// NewMockStatusStorage creates a new mock instance.
+func NewMockStatusStorage(ctrl *gomock.Controller) *MockStatusStorage {
+	mock := &MockStatusStorage{ctrl: ctrl}
+	mock.recorder = &MockStatusStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStatusStorage) EXPECT() *MockStatusStorageMockRecorder {
+	return m.recorder
+}
+
+// SaveStatus mocks base method.
+func (m *MockStatusStorage) SaveStatus(arg0 context.Context, status call.Status, meta call.Meta) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveStatus", arg0, status, meta)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveStatus indicates an expected call of SaveStatus.
+func (mr *MockStatusStorageMockRecorder) SaveStatus(arg0, status, meta interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveStatus", reflect.TypeOf((*MockStatusStorage)(nil).SaveStatus), arg0, status, meta)
+}
@@ -0,0 +1,110 @@
+package expiry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"test_trigger/internal/call"
+	"test_trigger/internal/logger"
+	"test_trigger/internal/metrics"
+	"test_trigger/internal/realtime"
+)
+
+func TestSweeper_Sweep(t *testing.T) {
+	now := time.Unix(1709464831, 0)
+	testErr := errors.New("test")
+	expired := []call.Meta{{ID: "1", ExpiresAt: now}, {ID: "2", ExpiresAt: now.Add(-time.Hour)}}
+	tests := []struct {
+		name         string
+		expectedFunc func(storage *MockStorage, statusStorage *MockStatusStorage, l *logger.MockLogger)
+		expected     int
+	}{
+		{
+			name: "nothing expired",
+			expectedFunc: func(storage *MockStorage, statusStorage *MockStatusStorage, l *logger.MockLogger) {
+				storage.EXPECT().RemoveExpired(gomock.Any(), now).Return([]call.Meta{}, nil)
+			},
+		},
+		{
+			name: "expired calls",
+			expectedFunc: func(storage *MockStorage, statusStorage *MockStatusStorage, l *logger.MockLogger) {
+				storage.EXPECT().RemoveExpired(gomock.Any(), now).Return(expired, nil)
+				statusStorage.EXPECT().SaveStatus(gomock.Any(), call.Status{State: call.StateExpired}, expired[0]).Return(nil)
+				statusStorage.EXPECT().SaveStatus(gomock.Any(), call.Status{State: call.StateExpired}, expired[1]).Return(nil)
+				l.EXPECT().Info("expired calls removed from the queue", "count", 2)
+			},
+			expected: 2,
+		},
+		{
+			name: "SaveStatus fail",
+			expectedFunc: func(storage *MockStorage, statusStorage *MockStatusStorage, l *logger.MockLogger) {
+				storage.EXPECT().RemoveExpired(gomock.Any(), now).Return(expired, nil)
+				statusStorage.EXPECT().SaveStatus(gomock.Any(), call.Status{State: call.StateExpired}, expired[0]).Return(testErr)
+				l.EXPECT().Error("sweep: SaveStatus", "error", testErr, "call_id", "1")
+				statusStorage.EXPECT().SaveStatus(gomock.Any(), call.Status{State: call.StateExpired}, expired[1]).Return(nil)
+				l.EXPECT().Info("expired calls removed from the queue", "count", 2)
+			},
+			expected: 2,
+		},
+		{
+			name: "RemoveExpired fail",
+			expectedFunc: func(storage *MockStorage, statusStorage *MockStatusStorage, l *logger.MockLogger) {
+				storage.EXPECT().RemoveExpired(gomock.Any(), now).Return(nil, testErr)
+				l.EXPECT().Error("sweep: RemoveExpired", "error", testErr)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			storage := NewMockStorage(ctrl)
+			statusStorage := NewMockStatusStorage(ctrl)
+			l := logger.NewMockLogger(ctrl)
+			m := metrics.NewCalls(metrics.NewRegistry())
+			tt.expectedFunc(storage, statusStorage, l)
+
+			s := NewSweeper(storage, statusStorage, time.Second, realtime.NewFake(now), l, m)
+			assert.Equal(t, tt.expected, s.Sweep(context.Background()))
+			assert.Equal(t, uint64(tt.expected), m.Expirations.Value())
+		})
+	}
+}
+
+func TestSweeper_Run(t *testing.T) {
+	now := time.Unix(1709464831, 0)
+	clock := realtime.NewFake(now)
+	ctrl := gomock.NewController(t)
+	storage := call.NewStorage()
+	meta := call.Meta{ID: "1", PhoneNumber: "777", VirtualAgentID: "aaa", ExpiresAt: now.Add(1500 * time.Millisecond)}
+	assert.NoError(t, storage.AddToQueueBack(context.Background(), meta))
+	statusStorage := NewMockStatusStorage(ctrl)
+	l := logger.NewMockLogger(ctrl)
+	s := NewSweeper(storage, statusStorage, time.Second, clock, l, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(ctx)
+	}()
+	clock.BlockUntil(1)
+
+	// the first tick is before expiry.
+	clock.Advance(time.Second)
+	saved := make(chan struct{})
+	statusStorage.EXPECT().SaveStatus(gomock.Any(), call.Status{State: call.StateExpired}, meta).Return(nil)
+	l.EXPECT().Info("expired calls removed from the queue", "count", 1).Do(func(_ string, _ ...any) { close(saved) })
+	clock.Advance(time.Second)
+	<-saved
+	length, err := storage.QueueLength(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, length)
+
+	cancel()
+	<-done
+}
@@ -3,11 +3,16 @@ package call
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 )
 
 //go:generate go run github.com/golang/mock/mockgen --source=external.go --destination=external_mock.go --package=call
 
+// ErrNotSent means request didn't reach the provider(dns, connection refused, etc.), so it is safe to send it again.
+var ErrNotSent = errors.New("request wasn't sent")
+
 type HTTPWrapper interface {
 	MakePostRequest(ctx context.Context, url string, body []byte) ([]byte, int, error)
 }
@@ -16,13 +21,19 @@ type HTTPWrapper interface {
 type Client struct {
 	URL         string
 	HTTPWrapper HTTPWrapper
+	Classifier  *Classifier
 }
 
-func NewClient(URL string, HTTPWrapper HTTPWrapper) *Client {
-	return &Client{URL: URL, HTTPWrapper: HTTPWrapper}
+// NewClient uses DefaultOutcomeRules if classifier is nil.
+func NewClient(URL string, HTTPWrapper HTTPWrapper, classifier *Classifier) *Client {
+	if classifier == nil {
+		classifier = NewClassifier(DefaultOutcomeRules())
+	}
+	return &Client{URL: URL, HTTPWrapper: HTTPWrapper, Classifier: classifier}
 }
 
-func (c *Client) Call(ctx context.Context, phoneNumber, virtualAgentID string) (status int, err error) {
+// Call makes originate request and classifies the response.
+func (c *Client) Call(ctx context.Context, phoneNumber, virtualAgentID string) (Result, error) {
 	b := Body{
 		PhoneNumber:    phoneNumber,
 		VirtualAgentID: virtualAgentID,
@@ -32,13 +43,25 @@ func (c *Client) Call(ctx context.Context, phoneNumber, virtualAgentID string) (
 	// 2. add body builder and return, for example, channel/func instead of struct.
 	body, err := json.Marshal(b)
 	if err != nil {
-		return 0, fmt.Errorf("client call: %v", err)
+		return Result{}, fmt.Errorf("client call: %v", err)
 	}
 
-	_, status, err = c.HTTPWrapper.MakePostRequest(ctx, c.URL, body)
+	respBody, status, err := c.HTTPWrapper.MakePostRequest(ctx, c.URL, body)
 	if err != nil {
-		return 0, fmt.Errorf("client call: make request: %v", err)
+		if isNotSent(err) {
+			return Result{}, fmt.Errorf("client call: make request: %w: %v", ErrNotSent, err)
+		}
+		return Result{}, fmt.Errorf("client call: make request: %v", err)
 	}
 
-	return
+	return Result{StatusCode: status, Outcome: c.Classifier.Classify(status, respBody)}, nil
+}
+
+func isNotSent(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
 }
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"testing"
 
 	"github.com/golang/mock/gomock"
@@ -22,7 +24,7 @@ func TestClient_Call(t *testing.T) {
 	}
 	type expectedValues struct {
 		err    error
-		status int
+		result Result
 	}
 	tests := []struct {
 		name         string
@@ -49,7 +51,29 @@ func TestClient_Call(t *testing.T) {
 				wrapper.EXPECT().MakePostRequest(gomock.Any(), "google.com", val).Return([]byte{1}, 200, nil).Times(1)
 			},
 			expectedValues: expectedValues{
-				status: 200,
+				result: Result{StatusCode: 200, Outcome: OutcomeAnswered},
+				err:    nil,
+			},
+		},
+		{
+			name: "success, busy",
+			fields: fields{
+				URL: "google.com",
+			},
+			args: args{
+				ctx:            context.Background(),
+				phoneNumber:    "777-77-77",
+				virtualAgentID: "aaa-vvv-ddd",
+			},
+			expectedFunc: func(wrapper *MockHTTPWrapper) {
+				val, _ := json.Marshal(Body{
+					PhoneNumber:    "777-77-77",
+					VirtualAgentID: "aaa-vvv-ddd",
+				})
+				wrapper.EXPECT().MakePostRequest(gomock.Any(), "google.com", val).Return([]byte(`{"outcome":"busy"}`), 200, nil).Times(1)
+			},
+			expectedValues: expectedValues{
+				result: Result{StatusCode: 200, Outcome: OutcomeBusy},
 				err:    nil,
 			},
 		},
@@ -71,7 +95,7 @@ func TestClient_Call(t *testing.T) {
 				wrapper.EXPECT().MakePostRequest(gomock.Any(), "google.com", val).Return([]byte{1}, 403, errors.New("some err")).Times(1)
 			},
 			expectedValues: expectedValues{
-				status: 0,
+				result: Result{},
 				err:    fmt.Errorf("client call: make request: %v", errors.New("some err")),
 			},
 		},
@@ -83,24 +107,47 @@ func TestClient_Call(t *testing.T) {
 			c := &Client{
 				URL:         tt.fields.URL,
 				HTTPWrapper: httpClient,
+				Classifier:  NewClassifier(DefaultOutcomeRules()),
 			}
 			if tt.expectedFunc != nil {
 				tt.expectedFunc(httpClient)
 			}
 			ao := assert.New(t)
-			actualStatus, actualErr := c.Call(tt.args.ctx, tt.args.phoneNumber, tt.args.virtualAgentID)
-			ao.Equal(tt.expectedValues.status, actualStatus)
+			actualResult, actualErr := c.Call(tt.args.ctx, tt.args.phoneNumber, tt.args.virtualAgentID)
+			ao.Equal(tt.expectedValues.result, actualResult)
 			ao.Equal(tt.expectedValues.err, actualErr)
 		})
 	}
 }
 
+func TestClient_Call_NotSent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	httpClient := NewMockHTTPWrapper(ctrl)
+	c := NewClient("google.com", httpClient, NewClassifier(DefaultOutcomeRules()))
+	dialErr := &url.Error{Op: "Post", URL: "google.com", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+	httpClient.EXPECT().MakePostRequest(gomock.Any(), "google.com", gomock.Any()).Return(nil, 0, dialErr).Times(1)
+	httpClient.EXPECT().MakePostRequest(gomock.Any(), "google.com", gomock.Any()).Return(nil, 0, context.DeadlineExceeded).Times(1)
+
+	ao := assert.New(t)
+	_, err := c.Call(context.Background(), "777", "aaa")
+	ao.ErrorIs(err, ErrNotSent)
+	_, err = c.Call(context.Background(), "777", "aaa")
+	ao.NotErrorIs(err, ErrNotSent)
+}
+
 func TestNewClient(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	httpClient := NewMockHTTPWrapper(ctrl)
+	classifier := NewClassifier(DefaultOutcomeRules())
 	expected := &Client{
 		URL:         "google.com",
 		HTTPWrapper: httpClient,
+		Classifier:  classifier,
 	}
-	assert.Equal(t, expected, NewClient("google.com", httpClient))
+	assert.Equal(t, expected, NewClient("google.com", httpClient, classifier))
+}
+
+func TestNewClient_NilClassifier(t *testing.T) {
+	client := NewClient("google.com", nil, nil)
+	assert.Equal(t, NewClassifier(DefaultOutcomeRules()), client.Classifier)
 }
@@ -0,0 +1,116 @@
+package call
+
+import (
+	"bytes"
+)
+
+// Outcome is a typed result of the originate call, more precise than http status.
+type Outcome string
+
+const (
+	OutcomeUnknown       Outcome = "unknown"
+	OutcomeAnswered      Outcome = "answered"
+	OutcomeBusy          Outcome = "busy"
+	OutcomeNoAnswer      Outcome = "no_answer"
+	OutcomeVoicemail     Outcome = "voicemail"
+	OutcomeInvalidNumber Outcome = "invalid_number"
+	OutcomeProviderError Outcome = "provider_error"
+	OutcomeRateLimited   Outcome = "rate_limited"
+	// OutcomeAmbiguous - request could reach the provider, but the result is lost(timeout, 504, etc.).
+	OutcomeAmbiguous Outcome = "ambiguous"
+)
+
+// Known is false for outcomes, which aren't in the list above, e.g. typos in outcome rules.
+func (o Outcome) Known() bool {
+	switch o {
+	case OutcomeUnknown, OutcomeAnswered, OutcomeBusy, OutcomeNoAnswer, OutcomeVoicemail, OutcomeInvalidNumber,
+		OutcomeProviderError, OutcomeRateLimited, OutcomeAmbiguous:
+		return true
+	}
+	return false
+}
+
+// Retry describes what should be done with the call after the outcome.
+type Retry int
+
+const (
+	// RetryNone - call is finished(successfully or not), it shouldn't be dialed again.
+	RetryNone Retry = iota
+	// RetryNow - call wasn't really made, it should be dialed as soon as possible(front of the queue).
+	RetryNow
+	// RetryLater - call was made, but the person wasn't reached. Give them some time(back of the queue).
+	RetryLater
+)
+
+// Retry returns retry decision for the outcome.
+func (o Outcome) Retry() Retry {
+	switch o {
+	case OutcomeAnswered, OutcomeVoicemail, OutcomeInvalidNumber:
+		return RetryNone
+	case OutcomeAmbiguous:
+		// the person could have been dialed already, retry can lead to the second call.
+		return RetryNone
+	case OutcomeBusy, OutcomeNoAnswer:
+		return RetryLater
+	default:
+		// rate limited, provider errors and unknown statuses behave like before: front of the queue.
+		return RetryNow
+	}
+}
+
+// OutcomeRule maps status code range and optional body substring to Outcome.
+type OutcomeRule struct {
+	MinStatus    int     `yaml:"min_status" json:"min_status"`
+	MaxStatus    int     `yaml:"max_status" json:"max_status"`
+	BodyContains string  `yaml:"body_contains" json:"body_contains"` // case-insensitive, empty matches any body.
+	Outcome      Outcome `yaml:"outcome" json:"outcome"`
+}
+
+func (r OutcomeRule) match(status int, lowerBody []byte) bool {
+	if status < r.MinStatus || status > r.MaxStatus {
+		return false
+	}
+	if r.BodyContains == "" {
+		return true
+	}
+	return bytes.Contains(lowerBody, bytes.ToLower([]byte(r.BodyContains)))
+}
+
+// DefaultOutcomeRules returns mapping for the known /originate_call responses.
+// Order matters, the first matched rule wins.
+func DefaultOutcomeRules() []OutcomeRule {
+	return []OutcomeRule{
+		{MinStatus: 200, MaxStatus: 299, BodyContains: "voicemail", Outcome: OutcomeVoicemail},
+		{MinStatus: 200, MaxStatus: 299, BodyContains: "busy", Outcome: OutcomeBusy},
+		{MinStatus: 200, MaxStatus: 299, BodyContains: "no_answer", Outcome: OutcomeNoAnswer},
+		// 201/202 mean the provider accepted the call, retry would dial it twice.
+		{MinStatus: 200, MaxStatus: 299, Outcome: OutcomeAnswered},
+		{MinStatus: 429, MaxStatus: 429, Outcome: OutcomeRateLimited},
+		{MinStatus: 486, MaxStatus: 486, Outcome: OutcomeBusy},
+		{MinStatus: 408, MaxStatus: 408, Outcome: OutcomeNoAnswer},
+		{MinStatus: 480, MaxStatus: 480, Outcome: OutcomeNoAnswer},
+		{MinStatus: 404, MaxStatus: 404, Outcome: OutcomeInvalidNumber},
+		{MinStatus: 400, MaxStatus: 499, BodyContains: "invalid", Outcome: OutcomeInvalidNumber},
+		{MinStatus: 500, MaxStatus: 599, Outcome: OutcomeProviderError},
+	}
+}
+
+// Classifier maps originate responses to Outcome with configurable rules.
+type Classifier struct {
+	rules []OutcomeRule
+}
+
+func NewClassifier(rules []OutcomeRule) *Classifier {
+	return &Classifier{rules: rules}
+}
+
+// Classify returns outcome of the first matched rule, OutcomeUnknown otherwise.
+func (c *Classifier) Classify(status int, body []byte) Outcome {
+	lowerBody := bytes.ToLower(body)
+	for _, rule := range c.rules {
+		if rule.match(status, lowerBody) {
+			return rule.Outcome
+		}
+	}
+	return OutcomeUnknown
+}
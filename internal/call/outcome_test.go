@@ -0,0 +1,117 @@
+package call
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifier_Classify(t *testing.T) {
+	type args struct {
+		status int
+		body   []byte
+	}
+	tests := []struct {
+		name     string
+		rules    []OutcomeRule
+		args     args
+		expected Outcome
+	}{
+		{
+			name:     "answered",
+			rules:    DefaultOutcomeRules(),
+			args:     args{status: 200, body: []byte(`{}`)},
+			expected: OutcomeAnswered,
+		},
+		{
+			name:     "voicemail, body has higher priority",
+			rules:    DefaultOutcomeRules(),
+			args:     args{status: 200, body: []byte(`{"outcome":"VoiceMail"}`)},
+			expected: OutcomeVoicemail,
+		},
+		{
+			name:     "accepted is answered, it isn't retried",
+			rules:    DefaultOutcomeRules(),
+			args:     args{status: 202, body: []byte(`{"status":"queued"}`)},
+			expected: OutcomeAnswered,
+		},
+		{
+			name:     "rate limited",
+			rules:    DefaultOutcomeRules(),
+			args:     args{status: 429},
+			expected: OutcomeRateLimited,
+		},
+		{
+			name:     "busy by status",
+			rules:    DefaultOutcomeRules(),
+			args:     args{status: 486},
+			expected: OutcomeBusy,
+		},
+		{
+			name:     "invalid number by body",
+			rules:    DefaultOutcomeRules(),
+			args:     args{status: 422, body: []byte("invalid phone number")},
+			expected: OutcomeInvalidNumber,
+		},
+		{
+			name:     "provider error",
+			rules:    DefaultOutcomeRules(),
+			args:     args{status: 503},
+			expected: OutcomeProviderError,
+		},
+		{
+			name:     "unknown",
+			rules:    DefaultOutcomeRules(),
+			args:     args{status: 302},
+			expected: OutcomeUnknown,
+		},
+		{
+			name: "custom rules",
+			rules: []OutcomeRule{
+				{MinStatus: 200, MaxStatus: 200, BodyContains: "no-answer", Outcome: OutcomeNoAnswer},
+			},
+			args:     args{status: 200, body: []byte("result=no-answer")},
+			expected: OutcomeNoAnswer,
+		},
+		{
+			name:     "empty rules",
+			rules:    nil,
+			args:     args{status: 200},
+			expected: OutcomeUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClassifier(tt.rules)
+			assert.Equal(t, tt.expected, c.Classify(tt.args.status, tt.args.body))
+		})
+	}
+}
+
+func TestOutcome_Retry(t *testing.T) {
+	tests := []struct {
+		outcome  Outcome
+		expected Retry
+	}{
+		{outcome: OutcomeAnswered, expected: RetryNone},
+		{outcome: OutcomeVoicemail, expected: RetryNone},
+		{outcome: OutcomeInvalidNumber, expected: RetryNone},
+		{outcome: OutcomeAmbiguous, expected: RetryNone},
+		{outcome: OutcomeBusy, expected: RetryLater},
+		{outcome: OutcomeNoAnswer, expected: RetryLater},
+		{outcome: OutcomeRateLimited, expected: RetryNow},
+		{outcome: OutcomeProviderError, expected: RetryNow},
+		{outcome: OutcomeUnknown, expected: RetryNow},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.outcome), func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.outcome.Retry())
+		})
+	}
+}
+
+func TestOutcome_Known(t *testing.T) {
+	assert.True(t, OutcomeAmbiguous.Known())
+	assert.True(t, OutcomeUnknown.Known())
+	assert.False(t, Outcome("answerd").Known())
+}
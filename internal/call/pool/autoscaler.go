@@ -0,0 +1,145 @@
+package pool
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"test_trigger/internal/logger"
+	"test_trigger/internal/realtime"
+)
+
+//go:generate go run github.com/golang/mock/mockgen --source=autoscaler.go --destination=autoscaler_mock.go --package=pool
+
+type Resizer interface {
+	Size() int
+	Resize(workers int) error
+}
+
+// LatencySource is cumulative originate latency in seconds, metrics.Histogram.
+type LatencySource interface {
+	Sum() float64
+	Count() uint64
+}
+
+// Headroom is the sum of provider limits, router.Router.
+type Headroom interface {
+	Remaining() uint64
+	Rate() float64
+}
+
+// Autoscaler adjusts the number of workers. Every worker holds one call at a time and backs off for step when the limiter denies it,
+// so by Little's law rate * (latency + step) workers are enough to use the whole provider limit,
+// more workers only wait for the limiter.
+// The pool doesn't grow without a backlog or when the limiter has no headroom, it shrinks to the estimate otherwise.
+type Autoscaler struct {
+	pool       Resizer
+	queue      QueueLengthGetter
+	latency    LatencySource
+	headroom   Headroom
+	minWorkers int
+	maxWorkers int
+	step       time.Duration
+	interval   time.Duration
+	clock      realtime.Time
+	logger     logger.Logger
+
+	mu          *sync.Mutex
+	lastLatency float64 // seconds, the average of the last interval with finished calls.
+	lastSum     float64
+	lastCount   uint64
+}
+
+// NewAutoscaler - initialLatency is used until the first calls are finished.
+func NewAutoscaler(pool Resizer, queue QueueLengthGetter, latency LatencySource, headroom Headroom, minWorkers, maxWorkers int, step, initialLatency, interval time.Duration, clock realtime.Time, logger logger.Logger) *Autoscaler {
+	return &Autoscaler{
+		pool:        pool,
+		queue:       queue,
+		latency:     latency,
+		headroom:    headroom,
+		minWorkers:  minWorkers,
+		maxWorkers:  maxWorkers,
+		step:        step,
+		interval:    interval,
+		clock:       clock,
+		logger:      logger,
+		mu:          &sync.Mutex{},
+		lastLatency: initialLatency.Seconds(),
+		lastSum:     latency.Sum(),
+		lastCount:   latency.Count(),
+	}
+}
+
+// Resize changes the upper bound of workers, it's used on reload instead of Pool.Resize.
+func (a *Autoscaler) Resize(maxWorkers int) error {
+	if maxWorkers <= 0 {
+		return ErrWorkersCount
+	}
+	a.mu.Lock()
+	a.maxWorkers = maxWorkers
+	a.mu.Unlock()
+	if a.pool.Size() > maxWorkers {
+		return a.pool.Resize(maxWorkers)
+	}
+	return nil
+}
+
+// Run scales the pool every interval until ctx is cancelled.
+func (a *Autoscaler) Run(ctx context.Context) {
+	ticker := a.clock.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			if ctx.Err() != nil {
+				return
+			}
+			a.Scale(ctx)
+		}
+	}
+}
+
+// Scale resizes the pool once.
+func (a *Autoscaler) Scale(ctx context.Context) {
+	queueLength, err := a.queue.QueueLength(ctx)
+	if err != nil {
+		a.logger.Error("autoscaler: QueueLength", "error", err)
+		return
+	}
+	current := a.pool.Size()
+	latency := a.observeLatency()
+	remaining := a.headroom.Remaining()
+	desired := int(math.Ceil(a.headroom.Rate() * (latency + a.step.Seconds())))
+	if queueLength == 0 || remaining == 0 {
+		desired = min(desired, current)
+	}
+	// workers over the backlog would be idle.
+	desired = min(desired, current+queueLength)
+
+	a.mu.Lock()
+	desired = max(a.minWorkers, min(desired, a.maxWorkers))
+	a.mu.Unlock()
+	if desired == current {
+		return
+	}
+	if err = a.pool.Resize(desired); err != nil {
+		a.logger.Error("autoscaler: Resize", "error", err)
+		return
+	}
+	a.logger.Info("autoscaler: pool resized", "from", current, "to", desired, "queue_length", queueLength,
+		"latency", time.Duration(latency*float64(time.Second)), "limiter_remaining", remaining)
+}
+
+func (a *Autoscaler) observeLatency() float64 {
+	sum, count := a.latency.Sum(), a.latency.Count()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if count > a.lastCount {
+		a.lastLatency = (sum - a.lastSum) / float64(count-a.lastCount)
+	}
+	a.lastSum, a.lastCount = sum, count
+	return a.lastLatency
+}
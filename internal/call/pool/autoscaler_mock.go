@@ -0,0 +1,164 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: autoscaler.go
+
+// Package pool is a generated GoMock package.
+package pool
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockResizer is a mock of Resizer interface.
+type MockResizer struct {
+	ctrl     *gomock.Controller
+	recorder *MockResizerMockRecorder
+}
+
+// MockResizerMockRecorder is the mock recorder for MockResizer.
+type MockResizerMockRecorder struct {
+	mock *MockResizer
+}
+
+// NewMockResizer creates a new mock instance.
+func NewMockResizer(ctrl *gomock.Controller) *MockResizer {
+	mock := &MockResizer{ctrl: ctrl}
+	mock.recorder = &MockResizerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockResizer) EXPECT() *MockResizerMockRecorder {
+	return m.recorder
+}
+
+// Resize mocks base method.
+func (m *MockResizer) Resize(workers int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Resize", workers)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Resize indicates an expected call of Resize.
+func (mr *MockResizerMockRecorder) Resize(workers interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Resize", reflect.TypeOf((*MockResizer)(nil).Resize), workers)
+}
+
+// Size mocks base method.
+func (m *MockResizer) Size() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Size")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Size indicates an expected call of Size.
+func (mr *MockResizerMockRecorder) Size() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Size", reflect.TypeOf((*MockResizer)(nil).Size))
+}
+
+// MockLatencySource is a mock of LatencySource interface.
+type MockLatencySource struct {
+	ctrl     *gomock.Controller
+	recorder *MockLatencySourceMockRecorder
+}
+
+// MockLatencySourceMockRecorder is the mock recorder for MockLatencySource.
+type MockLatencySourceMockRecorder struct {
+	mock *MockLatencySource
+}
+
+// NewMockLatencySource creates a new mock instance.
+func NewMockLatencySource(ctrl *gomock.Controller) *MockLatencySource {
+	mock := &MockLatencySource{ctrl: ctrl}
+	mock.recorder = &MockLatencySourceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLatencySource) EXPECT() *MockLatencySourceMockRecorder {
+	return m.recorder
+}
+
+// Count mocks base method.
+func (m *MockLatencySource) Count() uint64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Count")
+	ret0, _ := ret[0].(uint64)
+	return ret0
+}
+
+// Count indicates an expected call of Count.
+func (mr *MockLatencySourceMockRecorder) Count() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Count", reflect.TypeOf((*MockLatencySource)(nil).Count))
+}
+
+// Sum mocks base method.
+func (m *MockLatencySource) Sum() float64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Sum")
+	ret0, _ := ret[0].(float64)
+	return ret0
+}
+
+// Sum indicates an expected call of Sum.
+func (mr *MockLatencySourceMockRecorder) Sum() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sum", reflect.TypeOf((*MockLatencySource)(nil).Sum))
+}
+
+// MockHeadroom is a mock of Headroom interface.
+type MockHeadroom struct {
+	ctrl     *gomock.Controller
+	recorder *MockHeadroomMockRecorder
+}
+
+// MockHeadroomMockRecorder is the mock recorder for MockHeadroom.
+type MockHeadroomMockRecorder struct {
+	mock *MockHeadroom
+}
+
+// NewMockHeadroom creates a new mock instance.
+func NewMockHeadroom(ctrl *gomock.Controller) *MockHeadroom {
+	mock := &MockHeadroom{ctrl: ctrl}
+	mock.recorder = &MockHeadroomMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHeadroom) EXPECT() *MockHeadroomMockRecorder {
+	return m.recorder
+}
+
+// Rate mocks base method.
+func (m *MockHeadroom) Rate() float64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rate")
+	ret0, _ := ret[0].(float64)
+	return ret0
+}
+
+// Rate indicates an expected call of Rate.
+func (mr *MockHeadroomMockRecorder) Rate() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rate", reflect.TypeOf((*MockHeadroom)(nil).Rate))
+}
+
+// Remaining mocks base method.
+func (m *MockHeadroom) Remaining() uint64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Remaining")
+	ret0, _ := ret[0].(uint64)
+	return ret0
+}
+
+// Remaining indicates an expected call of Remaining.
+func (mr *MockHeadroomMockRecorder) Remaining() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remaining", reflect.TypeOf((*MockHeadroom)(nil).Remaining))
+}
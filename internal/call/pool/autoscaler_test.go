@@ -0,0 +1,177 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"test_trigger/internal/logger"
+	"test_trigger/internal/realtime"
+)
+
+func TestAutoscaler_Scale(t *testing.T) {
+	testErr := errors.New("test")
+	type state struct {
+		size        int
+		queueLength int
+		sum         float64 // latency sum, it was 50 for 10 calls on start.
+		count       uint64
+		remaining   uint64
+	}
+	tests := []struct {
+		name         string
+		state        state
+		expectedFunc func(pool *MockResizer, l *logger.MockLogger)
+	}{
+		{
+			name:  "backlog, grow to rate * (latency + step)",
+			state: state{size: 2, queueLength: 100, sum: 50, count: 10, remaining: 25},
+			expectedFunc: func(pool *MockResizer, l *logger.MockLogger) {
+				// 2.5 calls per second * (4s latency + 1s step).
+				pool.EXPECT().Resize(13).Return(nil)
+				l.EXPECT().Info("autoscaler: pool resized", "from", 2, "to", 13, "queue_length", 100, "latency", 4*time.Second, "limiter_remaining", uint64(25))
+			},
+		},
+		{
+			name:  "latency is higher, grow",
+			state: state{size: 13, queueLength: 100, sum: 50 + 80, count: 20, remaining: 5},
+			expectedFunc: func(pool *MockResizer, l *logger.MockLogger) {
+				pool.EXPECT().Resize(20).Return(nil)
+				l.EXPECT().Info("autoscaler: pool resized", "from", 13, "to", 20, "queue_length", 100, "latency", 8*time.Second, "limiter_remaining", uint64(5))
+			},
+		},
+		{
+			name:  "no more than backlog",
+			state: state{size: 2, queueLength: 3, sum: 50, count: 10, remaining: 25},
+			expectedFunc: func(pool *MockResizer, l *logger.MockLogger) {
+				pool.EXPECT().Resize(5).Return(nil)
+				l.EXPECT().Info("autoscaler: pool resized", "from", 2, "to", 5, "queue_length", 3, "latency", 4*time.Second, "limiter_remaining", uint64(25))
+			},
+		},
+		{
+			name:         "limiter has no headroom, don't grow",
+			state:        state{size: 2, queueLength: 100, sum: 50, count: 10, remaining: 0},
+			expectedFunc: func(pool *MockResizer, l *logger.MockLogger) {},
+		},
+		{
+			name:  "latency is lower, shrink",
+			state: state{size: 20, queueLength: 0, sum: 50 + 10, count: 20, remaining: 25},
+			expectedFunc: func(pool *MockResizer, l *logger.MockLogger) {
+				pool.EXPECT().Resize(5).Return(nil)
+				l.EXPECT().Info("autoscaler: pool resized", "from", 20, "to", 5, "queue_length", 0, "latency", time.Second, "limiter_remaining", uint64(25))
+			},
+		},
+		{
+			name:  "no finished calls, initial latency, max bound",
+			state: state{size: 20, queueLength: 100, sum: 50, count: 10, remaining: 25},
+			expectedFunc: func(pool *MockResizer, l *logger.MockLogger) {
+				pool.EXPECT().Resize(13).Return(nil)
+				l.EXPECT().Info("autoscaler: pool resized", "from", 20, "to", 13, "queue_length", 100, "latency", 4*time.Second, "limiter_remaining", uint64(25))
+			},
+		},
+		{
+			name:  "min bound",
+			state: state{size: 1, queueLength: 0, sum: 50, count: 10, remaining: 25},
+			expectedFunc: func(pool *MockResizer, l *logger.MockLogger) {
+				pool.EXPECT().Resize(2).Return(nil)
+				l.EXPECT().Info("autoscaler: pool resized", "from", 1, "to", 2, "queue_length", 0, "latency", 4*time.Second, "limiter_remaining", uint64(25))
+			},
+		},
+		{
+			name:  "Resize error",
+			state: state{size: 2, queueLength: 100, sum: 50, count: 10, remaining: 25},
+			expectedFunc: func(pool *MockResizer, l *logger.MockLogger) {
+				pool.EXPECT().Resize(13).Return(testErr)
+				l.EXPECT().Error("autoscaler: Resize", "error", testErr)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			pool := NewMockResizer(ctrl)
+			queue := NewMockQueueLengthGetter(ctrl)
+			latency := NewMockLatencySource(ctrl)
+			headroom := NewMockHeadroom(ctrl)
+			l := logger.NewMockLogger(ctrl)
+			latency.EXPECT().Sum().Return(50.0)
+			latency.EXPECT().Count().Return(uint64(10))
+			a := NewAutoscaler(pool, queue, latency, headroom, 2, 20, time.Second, 4*time.Second, time.Second, realtime.NewFake(time.Unix(1709464831, 0)), l)
+
+			queue.EXPECT().QueueLength(gomock.Any()).Return(tt.state.queueLength, nil)
+			pool.EXPECT().Size().Return(tt.state.size)
+			latency.EXPECT().Sum().Return(tt.state.sum)
+			latency.EXPECT().Count().Return(tt.state.count)
+			headroom.EXPECT().Remaining().Return(tt.state.remaining)
+			headroom.EXPECT().Rate().Return(2.5)
+			tt.expectedFunc(pool, l)
+			a.Scale(context.Background())
+		})
+	}
+}
+
+func TestAutoscaler_Scale_QueueLengthError(t *testing.T) {
+	testErr := errors.New("test")
+	ctrl := gomock.NewController(t)
+	queue := NewMockQueueLengthGetter(ctrl)
+	latency := NewMockLatencySource(ctrl)
+	l := logger.NewMockLogger(ctrl)
+	latency.EXPECT().Sum().Return(0.0)
+	latency.EXPECT().Count().Return(uint64(0))
+	a := NewAutoscaler(NewMockResizer(ctrl), queue, latency, NewMockHeadroom(ctrl), 1, 10, time.Second, time.Second, time.Second, realtime.NewFake(time.Unix(1709464831, 0)), l)
+
+	queue.EXPECT().QueueLength(gomock.Any()).Return(0, testErr)
+	l.EXPECT().Error("autoscaler: QueueLength", "error", testErr)
+	a.Scale(context.Background())
+}
+
+func TestAutoscaler_Resize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	pool := NewMockResizer(ctrl)
+	latency := NewMockLatencySource(ctrl)
+	latency.EXPECT().Sum().Return(0.0)
+	latency.EXPECT().Count().Return(uint64(0))
+	a := NewAutoscaler(pool, nil, latency, nil, 1, 10, time.Second, time.Second, time.Second, nil, nil)
+
+	assert.Equal(t, ErrWorkersCount, a.Resize(0))
+	pool.EXPECT().Size().Return(5)
+	assert.NoError(t, a.Resize(20))
+	assert.Equal(t, 20, a.maxWorkers)
+	pool.EXPECT().Size().Return(5)
+	pool.EXPECT().Resize(3).Return(nil)
+	assert.NoError(t, a.Resize(3))
+}
+
+func TestAutoscaler_Run(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	pool := NewMockResizer(ctrl)
+	queue := NewMockQueueLengthGetter(ctrl)
+	latency := NewMockLatencySource(ctrl)
+	headroom := NewMockHeadroom(ctrl)
+	clock := realtime.NewFake(time.Unix(1709464831, 0))
+	latency.EXPECT().Sum().Return(0.0).AnyTimes()
+	latency.EXPECT().Count().Return(uint64(0)).AnyTimes()
+	a := NewAutoscaler(pool, queue, latency, headroom, 1, 10, time.Second, time.Second, 5*time.Second, clock, logger.NewMockLogger(ctrl))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	scaled := make(chan struct{})
+	queue.EXPECT().QueueLength(gomock.Any()).Return(0, nil)
+	pool.EXPECT().Size().Return(1)
+	headroom.EXPECT().Remaining().Return(uint64(1))
+	headroom.EXPECT().Rate().Return(1.0).Do(func() { close(scaled) })
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.Run(ctx)
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(4 * time.Second)
+	clock.Advance(time.Second)
+	<-scaled
+	cancel()
+	<-done
+}
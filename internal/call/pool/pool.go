@@ -3,17 +3,22 @@ package pool
 import (
 	"context"
 	"errors"
-	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"test_trigger/internal/call/dispatch"
 	"test_trigger/internal/call/worker"
 	"test_trigger/internal/logger"
+	"test_trigger/internal/realtime"
 )
 
 //go:generate go run github.com/golang/mock/mockgen --source=pool.go --destination=pool_mock.go --package=pool
 
-var ErrWorkersCount = errors.New("workers count should be greater than 0")
+var (
+	ErrWorkersCount = errors.New("workers count should be greater than 0")
+	ErrNotStarted   = errors.New("pool isn't started")
+)
 
 type WorkerCreator interface {
 	NewWorker() worker.Worker
@@ -29,10 +34,16 @@ type Pool struct {
 	WorkerCreator     WorkerCreator
 	QueueLengthGetter QueueLengthGetter
 	Logger            logger.Logger
+	Clock             realtime.Time
+	Control           *dispatch.Control
+	running           int64
+	ctx               context.Context      // parent of workers, set by Start.
+	stops             []context.CancelFunc // one per worker, the last started is stopped first.
+	mu                *sync.Mutex
 }
 
-func NewPool(workerCreator WorkerCreator, queueLengthGetter QueueLengthGetter, logger logger.Logger) *Pool {
-	return &Pool{WorkerCreator: workerCreator, QueueLengthGetter: queueLengthGetter, Logger: logger, wg: &sync.WaitGroup{}}
+func NewPool(workerCreator WorkerCreator, queueLengthGetter QueueLengthGetter, logger logger.Logger, clock realtime.Time, control *dispatch.Control) *Pool {
+	return &Pool{WorkerCreator: workerCreator, QueueLengthGetter: queueLengthGetter, Logger: logger, Clock: clock, Control: control, wg: &sync.WaitGroup{}, mu: &sync.Mutex{}}
 }
 
 // Start runs workers.
@@ -40,37 +51,84 @@ func (p *Pool) Start(ctx context.Context, maxWorkers int) error {
 	if maxWorkers <= 0 {
 		return ErrWorkersCount
 	}
-	for i := 1; i <= maxWorkers; i++ {
+	p.mu.Lock()
+	p.ctx = ctx
+	p.mu.Unlock()
+	return p.Resize(maxWorkers)
+}
+
+// Resize starts or stops workers, stopped workers finish their current call.
+func (p *Pool) Resize(workers int) error {
+	if workers <= 0 {
+		return ErrWorkersCount
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ctx == nil {
+		return ErrNotStarted
+	}
+	for len(p.stops) < workers {
+		ctx, stop := context.WithCancel(p.ctx)
+		p.stops = append(p.stops, stop)
 		w := p.WorkerCreator.NewWorker()
 		p.wg.Add(1)
-		go w.ProcessCalls(ctx, p.wg)
+		atomic.AddInt64(&p.running, 1)
+		go func() {
+			defer atomic.AddInt64(&p.running, -1)
+			w.ProcessCalls(ctx, p.wg)
+		}()
+	}
+	for len(p.stops) > workers {
+		p.stops[len(p.stops)-1]()
+		p.stops = p.stops[:len(p.stops)-1]
 	}
-
 	return nil
 }
 
+// Size returns the number of workers, stopped by Resize aren't counted even if they are finishing the call.
+func (p *Pool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.stops)
+}
+
+// Running returns the number of workers, which haven't returned from ProcessCalls yet.
+func (p *Pool) Running() int {
+	return int(atomic.LoadInt64(&p.running))
+}
+
 // Close stops workers, gives them time to finish all calls in the queue.
+// Paused workers don't take calls, so Close doesn't wait for them, the calls are left in the queue. Calls of paused agents too.
 func (p *Pool) Close(ctx context.Context, cancelFunc context.CancelFunc, recheckTime, closeTimeout time.Duration) {
-	ticker := time.NewTicker(recheckTime)
+	ticker := p.Clock.NewTicker(recheckTime)
 	defer ticker.Stop()
-	timeoutTicker := time.NewTicker(closeTimeout)
+	timeoutTicker := p.Clock.NewTicker(closeTimeout)
 	defer timeoutTicker.Stop()
 	p.Logger.Info("pool closure started")
 mainLoop:
 	for {
 		queueLength, err := p.QueueLengthGetter.QueueLength(ctx)
 		if err != nil {
-			p.Logger.Error(fmt.Errorf("pool close: %v", err))
+			p.Logger.Error("pool close: QueueLength", "error", err)
 			continue
 		}
 		if queueLength == 0 {
 			break
 		}
+		if p.Control.Mode() == dispatch.ModePaused {
+			p.Logger.Warn("dispatching is paused, calls are left in the queue", "queue_length", queueLength)
+			break
+		}
+		// workers skip calls of paused agents, there is nothing to wait for.
+		if parked := p.Control.State().Parked; parked >= queueLength {
+			p.Logger.Warn("calls of paused virtual agents are left in the queue", "parked_calls", parked)
+			break
+		}
 		select {
-		case <-ticker.C:
-			p.Logger.Info(fmt.Sprintf("%v calls should be processed", queueLength))
-		case <-timeoutTicker.C:
-			p.Logger.Info(fmt.Sprintf("%v calls should have been processed, but they weren't", queueLength))
+		case <-ticker.C():
+			p.Logger.Info("calls should be processed", "queue_length", queueLength)
+		case <-timeoutTicker.C():
+			p.Logger.Warn("calls should have been processed, but they weren't", "queue_length", queueLength)
 			break mainLoop
 		}
 	}
@@ -3,7 +3,6 @@ package pool
 import (
 	"context"
 	"errors"
-	"fmt"
 	"sync"
 	"testing"
 	"time"
@@ -11,8 +10,10 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 
+	"test_trigger/internal/call/dispatch"
 	"test_trigger/internal/call/worker"
 	"test_trigger/internal/logger"
+	"test_trigger/internal/realtime"
 )
 
 func TestNewPool(t *testing.T) {
@@ -20,18 +21,28 @@ func TestNewPool(t *testing.T) {
 	workerCreator := NewMockWorkerCreator(ctrl)
 	queueLengthGetter := NewMockQueueLengthGetter(ctrl)
 	loggerMock := logger.NewMockLogger(ctrl)
+	clock := realtime.NewFake(time.Unix(1709464831, 0))
 	expected := &Pool{
 		wg:                &sync.WaitGroup{},
 		WorkerCreator:     workerCreator,
 		QueueLengthGetter: queueLengthGetter,
 		Logger:            loggerMock,
+		Clock:             clock,
+		mu:                &sync.Mutex{},
 	}
-	assert.Equal(t, expected, NewPool(workerCreator, queueLengthGetter, loggerMock))
+	assert.Equal(t, expected, NewPool(workerCreator, queueLengthGetter, loggerMock, clock, nil))
 }
 
 func TestPool_Close(t *testing.T) {
+	paused := dispatch.NewControl(nil)
+	paused.Pause()
+	counter := dispatch.NewMockCounter(gomock.NewController(t))
+	counter.EXPECT().QueueLengthOf(gomock.Any(), []string{"a"}).Return(3, nil).AnyTimes()
+	agentPaused := dispatch.NewControl(counter)
+	agentPaused.PauseAgent("a")
 	type fields struct {
-		wg *sync.WaitGroup
+		wg      *sync.WaitGroup
+		control *dispatch.Control
 	}
 	type args struct {
 		ctx          context.Context
@@ -43,6 +54,7 @@ func TestPool_Close(t *testing.T) {
 		name         string
 		fields       fields
 		args         args
+		advance      time.Duration // virtual time, which should pass during Close.
 		expectedFunc func(ctx context.Context, creator *MockWorkerCreator, getter *MockQueueLengthGetter, mockLogger *logger.MockLogger)
 	}{
 		{
@@ -56,10 +68,11 @@ func TestPool_Close(t *testing.T) {
 				recheckTime:  time.Millisecond,
 				closeTimeout: time.Minute,
 			},
+			advance: time.Millisecond,
 			expectedFunc: func(ctx context.Context, creator *MockWorkerCreator, getter *MockQueueLengthGetter, mockLogger *logger.MockLogger) {
 				mockLogger.EXPECT().Info("pool closure started").Times(1)
 				getter.EXPECT().QueueLength(ctx).Return(1, nil).Times(1)
-				mockLogger.EXPECT().Info(fmt.Sprintf("%v calls should be processed", 1)).Times(1)
+				mockLogger.EXPECT().Info("calls should be processed", "queue_length", 1).Times(1)
 				getter.EXPECT().QueueLength(ctx).Return(0, nil).Times(1)
 				mockLogger.EXPECT().Info("pool closure finished").Times(1)
 			},
@@ -78,7 +91,7 @@ func TestPool_Close(t *testing.T) {
 			expectedFunc: func(ctx context.Context, creator *MockWorkerCreator, getter *MockQueueLengthGetter, mockLogger *logger.MockLogger) {
 				mockLogger.EXPECT().Info("pool closure started").Times(1)
 				getter.EXPECT().QueueLength(ctx).Return(1, errors.New("some err")).Times(1)
-				mockLogger.EXPECT().Error(fmt.Errorf("pool close: %v", errors.New("some err")))
+				mockLogger.EXPECT().Error("pool close: QueueLength", "error", errors.New("some err"))
 				getter.EXPECT().QueueLength(ctx).Return(0, nil).Times(1)
 				mockLogger.EXPECT().Info("pool closure finished").Times(1)
 			},
@@ -94,11 +107,53 @@ func TestPool_Close(t *testing.T) {
 				recheckTime:  time.Minute * 10,
 				closeTimeout: time.Millisecond,
 			},
+			advance: time.Millisecond,
 			expectedFunc: func(ctx context.Context, creator *MockWorkerCreator, getter *MockQueueLengthGetter, mockLogger *logger.MockLogger) {
 				mockLogger.EXPECT().Info("pool closure started").Times(1)
 				getter.EXPECT().QueueLength(ctx).Return(1, nil).Times(1)
 				mockLogger.EXPECT().Info("pool closure finished").Times(1)
-				mockLogger.EXPECT().Info(fmt.Sprintf("%v calls should have been processed, but they weren't", 1)).Times(1)
+				mockLogger.EXPECT().Warn("calls should have been processed, but they weren't", "queue_length", 1).Times(1)
+			},
+		},
+		{
+			name: "paused, don't wait for the queue",
+			fields: fields{
+				wg:      &sync.WaitGroup{},
+				control: paused,
+			},
+			args: args{
+				ctx:          context.Background(),
+				cancelFunc:   func() {},
+				recheckTime:  time.Millisecond,
+				closeTimeout: time.Minute,
+			},
+			expectedFunc: func(ctx context.Context, creator *MockWorkerCreator, getter *MockQueueLengthGetter, mockLogger *logger.MockLogger) {
+				mockLogger.EXPECT().Info("pool closure started").Times(1)
+				getter.EXPECT().QueueLength(ctx).Return(3, nil).Times(1)
+				mockLogger.EXPECT().Warn("dispatching is paused, calls are left in the queue", "queue_length", 3).Times(1)
+				mockLogger.EXPECT().Info("pool closure finished").Times(1)
+			},
+		},
+		{
+			name: "only calls of paused agents are left, don't wait for them",
+			fields: fields{
+				wg:      &sync.WaitGroup{},
+				control: agentPaused,
+			},
+			args: args{
+				ctx:          context.Background(),
+				cancelFunc:   func() {},
+				recheckTime:  time.Millisecond,
+				closeTimeout: time.Minute,
+			},
+			advance: time.Millisecond,
+			expectedFunc: func(ctx context.Context, creator *MockWorkerCreator, getter *MockQueueLengthGetter, mockLogger *logger.MockLogger) {
+				mockLogger.EXPECT().Info("pool closure started").Times(1)
+				getter.EXPECT().QueueLength(ctx).Return(4, nil).Times(1)
+				mockLogger.EXPECT().Info("calls should be processed", "queue_length", 4).Times(1)
+				getter.EXPECT().QueueLength(ctx).Return(3, nil).Times(1)
+				mockLogger.EXPECT().Warn("calls of paused virtual agents are left in the queue", "parked_calls", 3).Times(1)
+				mockLogger.EXPECT().Info("pool closure finished").Times(1)
 			},
 		},
 	}
@@ -108,16 +163,29 @@ func TestPool_Close(t *testing.T) {
 			workerCreator := NewMockWorkerCreator(ctrl)
 			queueLengthGetter := NewMockQueueLengthGetter(ctrl)
 			loggerMock := logger.NewMockLogger(ctrl)
+			clock := realtime.NewFake(time.Unix(1709464831, 0))
 			p := &Pool{
 				wg:                tt.fields.wg,
 				WorkerCreator:     workerCreator,
 				QueueLengthGetter: queueLengthGetter,
 				Logger:            loggerMock,
+				Clock:             clock,
+				Control:           tt.fields.control,
 			}
 			if tt.expectedFunc != nil {
 				tt.expectedFunc(tt.args.ctx, workerCreator, queueLengthGetter, loggerMock)
 			}
-			p.Close(tt.args.ctx, tt.args.cancelFunc, tt.args.recheckTime, tt.args.closeTimeout)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				p.Close(tt.args.ctx, tt.args.cancelFunc, tt.args.recheckTime, tt.args.closeTimeout)
+			}()
+			if tt.advance > 0 {
+				// Close can't finish without time, so it is safe to wait for its tickers.
+				clock.BlockUntil(2)
+				clock.Advance(tt.advance)
+			}
+			<-done
 		})
 	}
 }
@@ -154,13 +222,13 @@ func TestPool_Start(t *testing.T) {
 				creator.EXPECT().NewWorker().Times(1).Return(worker1)
 				creator.EXPECT().NewWorker().Times(1).Return(worker2)
 				creator.EXPECT().NewWorker().Times(1).Return(worker3)
-				worker1.EXPECT().ProcessCalls(ctx, group).Times(1).Do(func(_ context.Context, wg *sync.WaitGroup) {
+				worker1.EXPECT().ProcessCalls(gomock.Any(), group).Times(1).Do(func(_ context.Context, wg *sync.WaitGroup) {
 					wg.Done()
 				})
-				worker2.EXPECT().ProcessCalls(ctx, group).Times(1).Do(func(_ context.Context, wg *sync.WaitGroup) {
+				worker2.EXPECT().ProcessCalls(gomock.Any(), group).Times(1).Do(func(_ context.Context, wg *sync.WaitGroup) {
 					wg.Done()
 				})
-				worker3.EXPECT().ProcessCalls(ctx, group).Times(1).Do(func(_ context.Context, wg *sync.WaitGroup) {
+				worker3.EXPECT().ProcessCalls(gomock.Any(), group).Times(1).Do(func(_ context.Context, wg *sync.WaitGroup) {
 					wg.Done()
 				})
 			},
@@ -188,6 +256,7 @@ func TestPool_Start(t *testing.T) {
 				WorkerCreator:     workerCreator,
 				QueueLengthGetter: queueLengthGetter,
 				Logger:            loggerMock,
+				mu:                &sync.Mutex{},
 			}
 			if tt.expectedFunc != nil {
 				tt.expectedFunc(tt.args.ctx, ctrl, tt.fields.wg, workerCreator, queueLengthGetter, loggerMock)
@@ -198,3 +267,32 @@ func TestPool_Start(t *testing.T) {
 		})
 	}
 }
+
+func TestPool_Resize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	workerCreator := NewMockWorkerCreator(ctrl)
+	p := NewPool(workerCreator, NewMockQueueLengthGetter(ctrl), logger.NewMockLogger(ctrl), realtime.NewFake(time.Unix(1709464831, 0)), nil)
+	assert.Equal(t, ErrNotStarted, p.Resize(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := worker.NewMockWorker(ctrl)
+	workerCreator.EXPECT().NewWorker().Return(w).Times(4)
+	w.EXPECT().ProcessCalls(gomock.Any(), p.wg).Times(4).Do(func(ctx context.Context, wg *sync.WaitGroup) {
+		defer wg.Done()
+		<-ctx.Done()
+	})
+
+	assert.NoError(t, p.Start(ctx, 2))
+	assert.Equal(t, 2, p.Running())
+	assert.NoError(t, p.Resize(4))
+	assert.Equal(t, 4, p.Size())
+	assert.Equal(t, 4, p.Running())
+	assert.NoError(t, p.Resize(1))
+	assert.Equal(t, 1, p.Size())
+	assert.Eventually(t, func() bool { return p.Running() == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, ErrWorkersCount, p.Resize(0))
+
+	cancel()
+	p.wg.Wait()
+	assert.Eventually(t, func() bool { return p.Running() == 0 }, time.Second, time.Millisecond)
+}
@@ -0,0 +1,122 @@
+package call
+
+// minQueueSize is the smallest buffer, the queue isn't shrunk below it.
+const minQueueSize = 16
+
+// queue is a growable ring buffer deque of calls, push and pop on both ends are O(1) amortized.
+// Buffer length is a power of 2, so indexes wrap with a mask. It isn't safe for concurrent use, Storage locks it.
+type queue struct {
+	buf  []Meta
+	head int // index of the first call.
+	n    int
+}
+
+func newQueue(calls ...Meta) *queue {
+	q := &queue{}
+	for _, meta := range calls {
+		q.pushBack(meta)
+	}
+	return q
+}
+
+func (q *queue) len() int {
+	return q.n
+}
+
+// at returns index of the i-th call in buf.
+func (q *queue) at(i int) int {
+	return (q.head + i) & (len(q.buf) - 1)
+}
+
+func (q *queue) pushBack(meta Meta) {
+	q.grow()
+	q.buf[q.at(q.n)] = meta
+	q.n++
+}
+
+func (q *queue) pushFront(meta Meta) {
+	q.grow()
+	q.head = q.at(-1)
+	q.buf[q.head] = meta
+	q.n++
+}
+
+func (q *queue) popFront() (Meta, bool) {
+	if q.n == 0 {
+		return Meta{}, false
+	}
+	meta := q.buf[q.head]
+	// failures of the call aren't referenced by the buffer anymore.
+	q.buf[q.head] = Meta{}
+	q.head = q.at(1)
+	q.n--
+	q.shrink()
+	return meta, true
+}
+
+// removeFunc removes calls matched by f, others keep their order. It returns removed calls.
+func (q *queue) removeFunc(f func(Meta) bool) []Meta {
+	removed := make([]Meta, 0)
+	kept := 0
+	for i := 0; i < q.n; i++ {
+		meta := q.buf[q.at(i)]
+		if f(meta) {
+			removed = append(removed, meta)
+			continue
+		}
+		q.buf[q.at(kept)] = meta
+		kept++
+	}
+	for i := kept; i < q.n; i++ {
+		q.buf[q.at(i)] = Meta{}
+	}
+	q.n = kept
+	q.shrink()
+	return removed
+}
+
+// slice returns a copy of calls in order.
+func (q *queue) slice() []Meta {
+	res := make([]Meta, q.n)
+	q.copyTo(res)
+	return res
+}
+
+func (q *queue) grow() {
+	if q.n < len(q.buf) {
+		return
+	}
+	q.resize(max(minQueueSize, 2*len(q.buf)))
+}
+
+// shrink halves the buffer while it's a quarter full, so memory of a drained burst is released.
+// Not half full, otherwise push and pop around the boundary would resize every time.
+func (q *queue) shrink() {
+	size := len(q.buf)
+	for size > minQueueSize && q.n <= size/4 {
+		size /= 2
+	}
+	if size != len(q.buf) {
+		q.resize(size)
+	}
+}
+
+func (q *queue) resize(size int) {
+	buf := make([]Meta, size)
+	q.copyTo(buf)
+	q.buf, q.head = buf, 0
+}
+
+// copyTo copies calls in order, the buffer can wrap around.
+func (q *queue) copyTo(dst []Meta) {
+	if q.n == 0 {
+		return
+	}
+	tail := q.head + q.n
+	if tail <= len(q.buf) {
+		copy(dst, q.buf[q.head:tail])
+		return
+	}
+	n := copy(dst, q.buf[q.head:])
+	copy(dst[n:], q.buf[:tail-len(q.buf)])
+}
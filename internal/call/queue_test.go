@@ -0,0 +1,171 @@
+package call
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func metas(from, to int) []Meta {
+	res := make([]Meta, 0, to-from)
+	for i := from; i < to; i++ {
+		res = append(res, Meta{ID: ID(strconv.Itoa(i))})
+	}
+	return res
+}
+
+func TestQueue(t *testing.T) {
+	q := newQueue()
+	_, ok := q.popFront()
+	assert.False(t, ok)
+
+	// the head wraps around the buffer from both ends.
+	for _, meta := range metas(0, 10) {
+		q.pushBack(meta)
+	}
+	for _, meta := range metas(10, 15) {
+		q.pushFront(meta)
+	}
+	assert.Equal(t, minQueueSize, len(q.buf))
+	assert.Equal(t, append([]Meta{{ID: "14"}, {ID: "13"}, {ID: "12"}, {ID: "11"}, {ID: "10"}}, metas(0, 10)...), q.slice())
+
+	// the full buffer is doubled in order.
+	for _, meta := range metas(15, 20) {
+		q.pushBack(meta)
+	}
+	assert.Equal(t, 2*minQueueSize, len(q.buf))
+	assert.Equal(t, 20, q.len())
+	for _, id := range []ID{"14", "13", "12", "11", "10", "0"} {
+		meta, ok := q.popFront()
+		assert.True(t, ok)
+		assert.Equal(t, id, meta.ID)
+	}
+	assert.Equal(t, append(metas(1, 10), metas(15, 20)...), q.slice())
+}
+
+func TestQueue_Shrink(t *testing.T) {
+	q := newQueue(metas(0, 1000)...)
+	assert.Equal(t, 1024, len(q.buf))
+
+	// the buffer is halved while it's a quarter full.
+	for i := 0; i < 743; i++ {
+		q.popFront()
+	}
+	assert.Equal(t, 1024, len(q.buf))
+	q.popFront()
+	assert.Equal(t, 512, len(q.buf))
+	assert.Equal(t, metas(744, 1000), q.slice())
+
+	// removed calls aren't referenced by the buffer.
+	removed := q.removeFunc(func(meta Meta) bool { return meta.ID != "999" })
+	assert.Len(t, removed, 255)
+	assert.Equal(t, minQueueSize, len(q.buf))
+	assert.Equal(t, []Meta{{ID: "999"}}, q.slice())
+	for i, meta := range q.buf {
+		if i != q.head {
+			assert.Equal(t, Meta{}, meta)
+		}
+	}
+	q.popFront()
+	assert.Equal(t, minQueueSize, len(q.buf))
+	assert.Equal(t, make([]Meta, minQueueSize), q.buf)
+}
+
+// sliceQueue is the previous queue of Storage, it's kept for comparison.
+type sliceQueue struct {
+	calls []Meta
+}
+
+func (q *sliceQueue) pushBack(meta Meta) {
+	q.calls = append(q.calls, meta)
+}
+
+func (q *sliceQueue) pushFront(meta Meta) {
+	q.calls = append(q.calls, Meta{})
+	copy(q.calls[1:], q.calls)
+	q.calls[0] = meta
+}
+
+func (q *sliceQueue) popFront() (Meta, bool) {
+	if len(q.calls) == 0 {
+		return Meta{}, false
+	}
+	res := q.calls[0]
+	q.calls = q.calls[1:]
+	return res, true
+}
+
+type deque interface {
+	pushBack(meta Meta)
+	pushFront(meta Meta)
+	popFront() (Meta, bool)
+}
+
+const benchmarkQueued = 100000
+
+var deques = []struct {
+	name string
+	new  func() deque
+}{
+	{"ring", func() deque { return newQueue() }},
+	{"slice", func() deque { return &sliceQueue{calls: make([]Meta, 0)} }},
+}
+
+func filled(newDeque func() deque) deque {
+	q := newDeque()
+	for _, meta := range metas(0, benchmarkQueued) {
+		q.pushBack(meta)
+	}
+	return q
+}
+
+// BenchmarkQueue_Retry is a call taken and put back to the front, e.g. the limiter denied it.
+func BenchmarkQueue_Retry(b *testing.B) {
+	for _, d := range deques {
+		b.Run(d.name, func(b *testing.B) {
+			q := filled(d.new)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				meta, _ := q.popFront()
+				q.pushFront(meta)
+			}
+		})
+	}
+}
+
+// BenchmarkQueue_Steady is intake and dialing at the same rate.
+func BenchmarkQueue_Steady(b *testing.B) {
+	for _, d := range deques {
+		b.Run(d.name, func(b *testing.B) {
+			q := filled(d.new)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				meta, _ := q.popFront()
+				q.pushBack(meta)
+			}
+		})
+	}
+}
+
+// BenchmarkQueue_Burst fills and drains the queue, like a campaign.
+func BenchmarkQueue_Burst(b *testing.B) {
+	calls := metas(0, benchmarkQueued)
+	for _, d := range deques {
+		b.Run(d.name, func(b *testing.B) {
+			q := d.new()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for _, meta := range calls {
+					q.pushBack(meta)
+				}
+				for range calls {
+					q.popFront()
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"test_trigger/internal/call"
+	"test_trigger/internal/logger"
+	"test_trigger/internal/realtime"
+)
+
+//go:generate go run github.com/golang/mock/mockgen --source=router.go --destination=router_mock.go --package=router
+
+// ErrNoProvider is returned when there is no healthy provider with free capacity.
+var ErrNoProvider = errors.New("no available originate provider")
+
+// Caller sends request to one originate provider.
+type Caller interface {
+	Call(ctx context.Context, phoneNumber, virtualAgentID string) (call.Result, error)
+}
+
+// Limiter describes provider's rate limit.
+type Limiter interface {
+	Allow() bool
+	Remaining() uint64
+	Rate() float64
+}
+
+// Provider is one originate API with own limiter, weight and health state.
+type Provider struct {
+	Name    string
+	Caller  Caller
+	Limiter Limiter
+	Weight  int
+
+	currentWeight    int // smooth weighted round-robin state.
+	failures         int
+	unavailableUntil time.Time
+}
+
+func NewProvider(name string, caller Caller, limiter Limiter, weight int) *Provider {
+	return &Provider{Name: name, Caller: caller, Limiter: limiter, Weight: weight}
+}
+
+// ProviderState is a snapshot of the provider health.
+type ProviderState struct {
+	Name             string    `json:"name"`
+	Weight           int       `json:"weight"`
+	Healthy          bool      `json:"healthy"`
+	Failures         int       `json:"failures"`
+	UnavailableUntil time.Time `json:"unavailable_until,omitempty"`
+}
+
+// Router routes calls between providers, implements worker.ExternalCaller and worker.Limiter.
+// Rate and Remaining sum limiters of providers, so admission and autoscale see the whole capacity.
+// Failover happens only when it is known that the call wasn't dialed(connection errors, 429, 502, 503).
+// Other failures are ambiguous, the call is returned with call.OutcomeAmbiguous to avoid double dialing.
+type Router struct {
+	providers        []*Provider
+	preferences      map[string]string // virtual agent id -> provider name.
+	failureThreshold int
+	cooldown         time.Duration
+	rateLimitBackoff time.Duration
+	realTime         realtime.Time
+	logger           logger.Logger
+	mu               *sync.Mutex
+}
+
+func NewRouter(providers []*Provider, preferences map[string]string, failureThreshold int, cooldown, rateLimitBackoff time.Duration, t realtime.Time, logger logger.Logger) *Router {
+	if preferences == nil {
+		preferences = make(map[string]string)
+	}
+	return &Router{
+		providers:        providers,
+		preferences:      preferences,
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+		rateLimitBackoff: rateLimitBackoff,
+		realTime:         t,
+		logger:           logger,
+		mu:               &sync.Mutex{},
+	}
+}
+
+// Reload swaps providers, preferences and breaker settings atomically, calls in flight finish with the old provider.
+// Health state is kept for providers with the same name.
+func (r *Router) Reload(providers []*Provider, preferences map[string]string, failureThreshold int, cooldown, rateLimitBackoff time.Duration) {
+	if preferences == nil {
+		preferences = make(map[string]string)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old := make(map[string]*Provider, len(r.providers))
+	for _, p := range r.providers {
+		old[p.Name] = p
+	}
+	for _, p := range providers {
+		if prev, ok := old[p.Name]; ok {
+			p.currentWeight, p.failures, p.unavailableUntil = prev.currentWeight, prev.failures, prev.unavailableUntil
+		}
+	}
+	r.providers = providers
+	r.preferences = preferences
+	r.failureThreshold = failureThreshold
+	r.cooldown = cooldown
+	r.rateLimitBackoff = rateLimitBackoff
+}
+
+// Allow returns true if at least one healthy provider has free capacity.
+// It doesn't consume the limit, provider's limiter is consumed in Call.
+func (r *Router) Allow() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.realTime.Now()
+	for _, p := range r.providers {
+		if p.available(now) && p.Limiter.Remaining() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Remaining returns how many calls healthy providers allow right now, it doesn't consume them.
+func (r *Router) Remaining() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.realTime.Now()
+	res := uint64(0)
+	for _, p := range r.providers {
+		if p.available(now) {
+			res += p.Limiter.Remaining()
+		}
+	}
+	return res
+}
+
+// Rate returns calls per second of all providers, cooldowns are short, so they aren't subtracted.
+func (r *Router) Rate() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := 0.0
+	for _, p := range r.providers {
+		res += p.Limiter.Rate()
+	}
+	return res
+}
+
+// Healthy returns false if all providers are unavailable.
+func (r *Router) Healthy() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.realTime.Now()
+	for _, p := range r.providers {
+		if p.available(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// State returns providers snapshot.
+func (r *Router) State() []ProviderState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.realTime.Now()
+	res := make([]ProviderState, 0, len(r.providers))
+	for _, p := range r.providers {
+		res = append(res, ProviderState{
+			Name:             p.Name,
+			Weight:           p.Weight,
+			Healthy:          p.available(now),
+			Failures:         p.failures,
+			UnavailableUntil: p.unavailableUntil,
+		})
+	}
+	return res
+}
+
+// Call sends the call to the preferred or weighted provider, fails over to the next one if it is safe.
+func (r *Router) Call(ctx context.Context, phoneNumber, virtualAgentID string) (call.Result, error) {
+	// providers are read under the lock only, Reload swaps them concurrently.
+	tried := make(map[*Provider]struct{})
+	var lastResult *call.Result
+	for {
+		p := r.next(virtualAgentID, tried)
+		if p == nil {
+			if lastResult != nil {
+				return *lastResult, nil
+			}
+			return call.Result{}, ErrNoProvider
+		}
+		tried[p] = struct{}{}
+
+		result, err := p.Caller.Call(ctx, phoneNumber, virtualAgentID)
+		switch {
+		case err != nil && errors.Is(err, call.ErrNotSent):
+			r.logger.Warn("router: call isn't sent, failover", "provider", p.Name, "error", err)
+			r.markFailure(p)
+		case err != nil:
+			r.logger.Error("router: ambiguous failure", "provider", p.Name, "error", err)
+			r.markFailure(p)
+			return call.Result{Outcome: call.OutcomeAmbiguous}, nil
+		case result.StatusCode == http.StatusTooManyRequests:
+			r.markRateLimited(p)
+			lastResult = &result
+		case result.StatusCode == http.StatusBadGateway || result.StatusCode == http.StatusServiceUnavailable:
+			r.markFailure(p)
+			lastResult = &result
+		case result.StatusCode >= http.StatusInternalServerError:
+			r.markFailure(p)
+			return call.Result{StatusCode: result.StatusCode, Outcome: call.OutcomeAmbiguous}, nil
+		default:
+			r.markSuccess(p)
+			return result, nil
+		}
+	}
+}
+
+// next returns provider for the call and consumes its limit, nil if there are no available providers.
+func (r *Router) next(virtualAgentID string, tried map[*Provider]struct{}) *Provider {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.realTime.Now()
+	candidates := make([]*Provider, 0, len(r.providers))
+	for _, p := range r.providers {
+		if _, ok := tried[p]; ok || !p.available(now) {
+			continue
+		}
+		candidates = append(candidates, p)
+	}
+
+	if name, ok := r.preferences[virtualAgentID]; ok {
+		for _, p := range candidates {
+			if p.Name == name && p.Limiter.Allow() {
+				return p
+			}
+		}
+	}
+
+	for len(candidates) > 0 {
+		i := pickWeighted(candidates)
+		if candidates[i].Limiter.Allow() {
+			return candidates[i]
+		}
+		candidates = append(candidates[:i], candidates[i+1:]...)
+	}
+	return nil
+}
+
+// pickWeighted implements smooth weighted round-robin(like nginx), returns index of the chosen provider.
+func pickWeighted(candidates []*Provider) int {
+	total, best := 0, 0
+	for i, p := range candidates {
+		p.currentWeight += p.Weight
+		total += p.Weight
+		if p.currentWeight > candidates[best].currentWeight {
+			best = i
+		}
+	}
+	candidates[best].currentWeight -= total
+	return best
+}
+
+func (r *Router) markSuccess(p *Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p.failures = 0
+}
+
+// markFailure opens the breaker after failureThreshold consecutive failures.
+// After cooldown one more attempt is allowed, the next failure opens it again.
+func (r *Router) markFailure(p *Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p.failures++
+	if p.failures >= r.failureThreshold {
+		p.unavailableUntil = r.realTime.Now().Add(r.cooldown)
+	}
+}
+
+// markRateLimited - provider introduces substantial backoff after 429, there is no sense to send anything during it.
+func (r *Router) markRateLimited(p *Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p.unavailableUntil = r.realTime.Now().Add(r.rateLimitBackoff)
+}
+
+func (p *Provider) available(now time.Time) bool {
+	return !now.Before(p.unavailableUntil)
+}
@@ -0,0 +1,116 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: router.go
+
+// Package router is a generated GoMock package.
+package router
+
+import (
+	context "context"
+	reflect "reflect"
+	call "test_trigger/internal/call"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockCaller is a mock of Caller interface.
+type MockCaller struct {
+	ctrl     *gomock.Controller
+	recorder *MockCallerMockRecorder
+}
+
+// MockCallerMockRecorder is the mock recorder for MockCaller.
+type MockCallerMockRecorder struct {
+	mock *MockCaller
+}
+
+// NewMockCaller creates a new mock instance.
+func NewMockCaller(ctrl *gomock.Controller) *MockCaller {
+	mock := &MockCaller{ctrl: ctrl}
+	mock.recorder = &MockCallerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCaller) EXPECT() *MockCallerMockRecorder {
+	return m.recorder
+}
+
+// Call mocks base method.
+func (m *MockCaller) Call(ctx context.Context, phoneNumber, virtualAgentID string) (call.Result, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Call", ctx, phoneNumber, virtualAgentID)
+	ret0, _ := ret[0].(call.Result)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Call indicates an expected call of Call.
+func (mr *MockCallerMockRecorder) Call(ctx, phoneNumber, virtualAgentID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Call", reflect.TypeOf((*MockCaller)(nil).Call), ctx, phoneNumber, virtualAgentID)
+}
+
+// MockLimiter is a mock of Limiter interface.
+type MockLimiter struct {
+	ctrl     *gomock.Controller
+	recorder *MockLimiterMockRecorder
+}
+
+// MockLimiterMockRecorder is the mock recorder for MockLimiter.
+type MockLimiterMockRecorder struct {
+	mock *MockLimiter
+}
+
+// NewMockLimiter creates a new mock instance.
+func NewMockLimiter(ctrl *gomock.Controller) *MockLimiter {
+	mock := &MockLimiter{ctrl: ctrl}
+	mock.recorder = &MockLimiterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLimiter) EXPECT() *MockLimiterMockRecorder {
+	return m.recorder
+}
+
+// Allow mocks base method.
+func (m *MockLimiter) Allow() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Allow")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Allow indicates an expected call of Allow.
+func (mr *MockLimiterMockRecorder) Allow() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Allow", reflect.TypeOf((*MockLimiter)(nil).Allow))
+}
+
+// Rate mocks base method.
+func (m *MockLimiter) Rate() float64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rate")
+	ret0, _ := ret[0].(float64)
+	return ret0
+}
+
+// Rate indicates an expected call of Rate.
+func (mr *MockLimiterMockRecorder) Rate() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rate", reflect.TypeOf((*MockLimiter)(nil).Rate))
+}
+
+// Remaining mocks base method.
+func (m *MockLimiter) Remaining() uint64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Remaining")
+	ret0, _ := ret[0].(uint64)
+	return ret0
+}
+
+// Remaining indicates an expected call of Remaining.
+func (mr *MockLimiterMockRecorder) Remaining() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remaining", reflect.TypeOf((*MockLimiter)(nil).Remaining))
+}
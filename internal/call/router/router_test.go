@@ -0,0 +1,306 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"test_trigger/internal/call"
+	"test_trigger/internal/logger"
+	"test_trigger/internal/realtime"
+)
+
+var testNow = time.Unix(1709464831, 0)
+
+func TestRouter_Call(t *testing.T) {
+	type expectedValues struct {
+		result call.Result
+		err    error
+	}
+	tests := []struct {
+		name         string
+		preferences  map[string]string
+		expectedFunc func(ctx context.Context, first, second *MockCaller, firstLim, secondLim *MockLimiter, l *logger.MockLogger)
+		expectedValues
+	}{
+		{
+			name: "success, weighted provider",
+			expectedFunc: func(ctx context.Context, first, second *MockCaller, firstLim, secondLim *MockLimiter, l *logger.MockLogger) {
+				// second has bigger weight.
+				secondLim.EXPECT().Allow().Return(true).Times(1)
+				second.EXPECT().Call(ctx, "777", "aaa").Return(call.Result{StatusCode: 200, Outcome: call.OutcomeAnswered}, nil).Times(1)
+			},
+			expectedValues: expectedValues{
+				result: call.Result{StatusCode: 200, Outcome: call.OutcomeAnswered},
+			},
+		},
+		{
+			name:        "success, preferred provider",
+			preferences: map[string]string{"aaa": "first"},
+			expectedFunc: func(ctx context.Context, first, second *MockCaller, firstLim, secondLim *MockLimiter, l *logger.MockLogger) {
+				firstLim.EXPECT().Allow().Return(true).Times(1)
+				first.EXPECT().Call(ctx, "777", "aaa").Return(call.Result{StatusCode: 200, Outcome: call.OutcomeAnswered}, nil).Times(1)
+			},
+			expectedValues: expectedValues{
+				result: call.Result{StatusCode: 200, Outcome: call.OutcomeAnswered},
+			},
+		},
+		{
+			name: "limit exceeded, next provider",
+			expectedFunc: func(ctx context.Context, first, second *MockCaller, firstLim, secondLim *MockLimiter, l *logger.MockLogger) {
+				secondLim.EXPECT().Allow().Return(false).Times(1)
+				firstLim.EXPECT().Allow().Return(true).Times(1)
+				first.EXPECT().Call(ctx, "777", "aaa").Return(call.Result{StatusCode: 200, Outcome: call.OutcomeAnswered}, nil).Times(1)
+			},
+			expectedValues: expectedValues{
+				result: call.Result{StatusCode: 200, Outcome: call.OutcomeAnswered},
+			},
+		},
+		{
+			name: "503, failover",
+			expectedFunc: func(ctx context.Context, first, second *MockCaller, firstLim, secondLim *MockLimiter, l *logger.MockLogger) {
+				secondLim.EXPECT().Allow().Return(true).Times(1)
+				second.EXPECT().Call(ctx, "777", "aaa").Return(call.Result{StatusCode: 503, Outcome: call.OutcomeProviderError}, nil).Times(1)
+				firstLim.EXPECT().Allow().Return(true).Times(1)
+				first.EXPECT().Call(ctx, "777", "aaa").Return(call.Result{StatusCode: 200, Outcome: call.OutcomeAnswered}, nil).Times(1)
+			},
+			expectedValues: expectedValues{
+				result: call.Result{StatusCode: 200, Outcome: call.OutcomeAnswered},
+			},
+		},
+		{
+			name: "connection error, failover",
+			expectedFunc: func(ctx context.Context, first, second *MockCaller, firstLim, secondLim *MockLimiter, l *logger.MockLogger) {
+				err := fmt.Errorf("%w: refused", call.ErrNotSent)
+				secondLim.EXPECT().Allow().Return(true).Times(1)
+				second.EXPECT().Call(ctx, "777", "aaa").Return(call.Result{}, err).Times(1)
+				l.EXPECT().Warn("router: call isn't sent, failover", "provider", "second", "error", err).Times(1)
+				firstLim.EXPECT().Allow().Return(true).Times(1)
+				first.EXPECT().Call(ctx, "777", "aaa").Return(call.Result{StatusCode: 200, Outcome: call.OutcomeAnswered}, nil).Times(1)
+			},
+			expectedValues: expectedValues{
+				result: call.Result{StatusCode: 200, Outcome: call.OutcomeAnswered},
+			},
+		},
+		{
+			name: "timeout, ambiguous without failover",
+			expectedFunc: func(ctx context.Context, first, second *MockCaller, firstLim, secondLim *MockLimiter, l *logger.MockLogger) {
+				secondLim.EXPECT().Allow().Return(true).Times(1)
+				second.EXPECT().Call(ctx, "777", "aaa").Return(call.Result{}, context.DeadlineExceeded).Times(1)
+				l.EXPECT().Error("router: ambiguous failure", "provider", "second", "error", context.DeadlineExceeded).Times(1)
+			},
+			expectedValues: expectedValues{
+				result: call.Result{Outcome: call.OutcomeAmbiguous},
+			},
+		},
+		{
+			name: "504, ambiguous without failover",
+			expectedFunc: func(ctx context.Context, first, second *MockCaller, firstLim, secondLim *MockLimiter, l *logger.MockLogger) {
+				secondLim.EXPECT().Allow().Return(true).Times(1)
+				second.EXPECT().Call(ctx, "777", "aaa").Return(call.Result{StatusCode: 504, Outcome: call.OutcomeProviderError}, nil).Times(1)
+			},
+			expectedValues: expectedValues{
+				result: call.Result{StatusCode: 504, Outcome: call.OutcomeAmbiguous},
+			},
+		},
+		{
+			name: "all providers rate limited, last result",
+			expectedFunc: func(ctx context.Context, first, second *MockCaller, firstLim, secondLim *MockLimiter, l *logger.MockLogger) {
+				secondLim.EXPECT().Allow().Return(true).Times(1)
+				second.EXPECT().Call(ctx, "777", "aaa").Return(call.Result{StatusCode: 429, Outcome: call.OutcomeRateLimited}, nil).Times(1)
+				firstLim.EXPECT().Allow().Return(true).Times(1)
+				first.EXPECT().Call(ctx, "777", "aaa").Return(call.Result{StatusCode: 429, Outcome: call.OutcomeRateLimited}, nil).Times(1)
+			},
+			expectedValues: expectedValues{
+				result: call.Result{StatusCode: 429, Outcome: call.OutcomeRateLimited},
+			},
+		},
+		{
+			name: "no capacity",
+			expectedFunc: func(ctx context.Context, first, second *MockCaller, firstLim, secondLim *MockLimiter, l *logger.MockLogger) {
+				secondLim.EXPECT().Allow().Return(false).Times(1)
+				firstLim.EXPECT().Allow().Return(false).Times(1)
+			},
+			expectedValues: expectedValues{
+				err: ErrNoProvider,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			first, second := NewMockCaller(ctrl), NewMockCaller(ctrl)
+			firstLim, secondLim := NewMockLimiter(ctrl), NewMockLimiter(ctrl)
+			l := logger.NewMockLogger(ctrl)
+			mockTime := realtime.NewMockTime(ctrl)
+			mockTime.EXPECT().Now().Return(testNow).AnyTimes()
+			r := NewRouter([]*Provider{
+				NewProvider("first", first, firstLim, 1),
+				NewProvider("second", second, secondLim, 2),
+			}, tt.preferences, 3, time.Minute, 30*time.Second, mockTime, l)
+			ctx := context.Background()
+			if tt.expectedFunc != nil {
+				tt.expectedFunc(ctx, first, second, firstLim, secondLim, l)
+			}
+			ao := assert.New(t)
+			actual, err := r.Call(ctx, "777", "aaa")
+			ao.Equal(tt.expectedValues.result, actual)
+			ao.Equal(tt.expectedValues.err, err)
+		})
+	}
+}
+
+func TestRouter_Health(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	caller := NewMockCaller(ctrl)
+	lim := NewMockLimiter(ctrl)
+	l := logger.NewMockLogger(ctrl)
+	mockTime := realtime.NewMockTime(ctrl)
+	now := testNow
+	mockTime.EXPECT().Now().DoAndReturn(func() time.Time { return now }).AnyTimes()
+	r := NewRouter([]*Provider{NewProvider("first", caller, lim, 1)}, nil, 2, time.Minute, 30*time.Second, mockTime, l)
+	ao := assert.New(t)
+
+	lim.EXPECT().Allow().Return(true).Times(2)
+	caller.EXPECT().Call(gomock.Any(), "777", "aaa").Return(call.Result{StatusCode: 503, Outcome: call.OutcomeProviderError}, nil).Times(2)
+	_, _ = r.Call(context.Background(), "777", "aaa")
+	ao.True(r.Healthy())
+	_, _ = r.Call(context.Background(), "777", "aaa")
+	// breaker is open after 2 failures.
+	ao.False(r.Healthy())
+	ao.False(r.Allow())
+	ao.Equal([]ProviderState{{Name: "first", Weight: 1, Healthy: false, Failures: 2, UnavailableUntil: testNow.Add(time.Minute)}}, r.State())
+	_, err := r.Call(context.Background(), "777", "aaa")
+	ao.Equal(ErrNoProvider, err)
+
+	// half-open after cooldown.
+	now = now.Add(time.Minute)
+	lim.EXPECT().Remaining().Return(uint64(5)).Times(1)
+	ao.True(r.Allow())
+	lim.EXPECT().Allow().Return(true).Times(1)
+	caller.EXPECT().Call(gomock.Any(), "777", "aaa").Return(call.Result{StatusCode: 200, Outcome: call.OutcomeAnswered}, nil).Times(1)
+	_, _ = r.Call(context.Background(), "777", "aaa")
+	ao.Equal(0, r.State()[0].Failures)
+
+	// 429 makes provider unavailable for the backoff.
+	lim.EXPECT().Allow().Return(true).Times(1)
+	caller.EXPECT().Call(gomock.Any(), "777", "aaa").Return(call.Result{StatusCode: 429, Outcome: call.OutcomeRateLimited}, nil).Times(1)
+	_, _ = r.Call(context.Background(), "777", "aaa")
+	ao.False(r.Healthy())
+	now = now.Add(30 * time.Second)
+	ao.True(r.Healthy())
+}
+
+func TestPickWeighted(t *testing.T) {
+	providers := []*Provider{
+		{Name: "a", Weight: 5},
+		{Name: "b", Weight: 1},
+		{Name: "c", Weight: 1},
+	}
+	actual := make([]string, 0, 7)
+	for i := 0; i < 7; i++ {
+		actual = append(actual, providers[pickWeighted(providers)].Name)
+	}
+	assert.Equal(t, []string{"a", "a", "b", "a", "c", "a", "a"}, actual)
+}
+
+func TestNewRouter(t *testing.T) {
+	r := NewRouter(nil, nil, 1, time.Second, time.Second, nil, nil)
+	assert.NotNil(t, r.preferences)
+}
+
+func TestRouter_Reload(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	clock := realtime.NewFake(testNow)
+	oldCaller, newCaller := NewMockCaller(ctrl), NewMockCaller(ctrl)
+	lim := NewMockLimiter(ctrl)
+	r := NewRouter([]*Provider{NewProvider("default", oldCaller, lim, 1)}, nil, 1, time.Minute, time.Minute, clock, logger.NewMockLogger(ctrl))
+	ctx := context.Background()
+
+	lim.EXPECT().Allow().Return(true).AnyTimes()
+	oldCaller.EXPECT().Call(ctx, "777", "aaa").Return(call.Result{StatusCode: 502, Outcome: call.OutcomeProviderError}, nil)
+	_, _ = r.Call(ctx, "777", "aaa")
+	assert.False(t, r.Healthy())
+
+	// the new caller of the same provider is still in cooldown, the new provider is available.
+	r.Reload([]*Provider{NewProvider("default", newCaller, lim, 1), NewProvider("backup", newCaller, lim, 1)}, nil, 3, 2*time.Minute, time.Minute)
+	assert.Equal(t, []ProviderState{
+		{Name: "default", Weight: 1, Failures: 1, UnavailableUntil: testNow.Add(time.Minute)},
+		{Name: "backup", Weight: 1, Healthy: true},
+	}, r.State())
+
+	clock.Advance(time.Minute)
+	// failureThreshold is 3 now, the second failure doesn't open the breaker.
+	r.Reload([]*Provider{NewProvider("default", newCaller, lim, 1)}, nil, 3, 2*time.Minute, time.Minute)
+	newCaller.EXPECT().Call(ctx, "777", "aaa").Return(call.Result{StatusCode: 502, Outcome: call.OutcomeProviderError}, nil)
+	_, _ = r.Call(ctx, "777", "aaa")
+	assert.True(t, r.Healthy())
+	assert.Equal(t, 2, r.State()[0].Failures)
+}
+
+func TestRouter_ReloadPreferences(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	first, second := NewMockCaller(ctrl), NewMockCaller(ctrl)
+	lim := NewMockLimiter(ctrl)
+	lim.EXPECT().Allow().Return(true).AnyTimes()
+	r := NewRouter([]*Provider{NewProvider("first", first, lim, 1)}, map[string]string{"aaa": "first"}, 1, time.Minute, time.Minute, realtime.NewFake(testNow), logger.NewMockLogger(ctrl))
+	ctx := context.Background()
+
+	r.Reload([]*Provider{NewProvider("first", first, lim, 100), NewProvider("second", second, lim, 1)}, map[string]string{"aaa": "second"}, 1, time.Minute, time.Minute)
+	second.EXPECT().Call(ctx, "777", "aaa").Return(call.Result{StatusCode: 200, Outcome: call.OutcomeAnswered}, nil)
+	_, _ = r.Call(ctx, "777", "aaa")
+}
+
+func TestRouter_Capacity(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	caller := NewMockCaller(ctrl)
+	firstLim, secondLim := NewMockLimiter(ctrl), NewMockLimiter(ctrl)
+	r := NewRouter([]*Provider{NewProvider("first", caller, firstLim, 1), NewProvider("second", caller, secondLim, 1)}, nil, 1, time.Minute, time.Minute, realtime.NewFake(testNow), logger.NewMockLogger(ctrl))
+	ao := assert.New(t)
+
+	firstLim.EXPECT().Rate().Return(2.5).AnyTimes()
+	secondLim.EXPECT().Rate().Return(1.5).AnyTimes()
+	firstLim.EXPECT().Remaining().Return(uint64(3)).AnyTimes()
+	secondLim.EXPECT().Remaining().Return(uint64(4)).AnyTimes()
+	ao.Equal(4.0, r.Rate())
+	ao.Equal(uint64(7), r.Remaining())
+
+	// the provider in cooldown has no headroom now.
+	firstLim.EXPECT().Allow().Return(true)
+	caller.EXPECT().Call(gomock.Any(), "777", "aaa").Return(call.Result{StatusCode: 500, Outcome: call.OutcomeProviderError}, nil)
+	_, _ = r.Call(context.Background(), "777", "aaa")
+	ao.Equal(4.0, r.Rate())
+	ao.Equal(uint64(4), r.Remaining())
+}
+
+// TestRouter_ReloadDuringCall is for -race, calls are routed while providers are swapped.
+func TestRouter_ReloadDuringCall(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	caller := NewMockCaller(ctrl)
+	lim := NewMockLimiter(ctrl)
+	lim.EXPECT().Allow().Return(true).AnyTimes()
+	caller.EXPECT().Call(gomock.Any(), "777", "aaa").Return(call.Result{StatusCode: 200, Outcome: call.OutcomeAnswered}, nil).AnyTimes()
+	r := NewRouter([]*Provider{NewProvider("default", caller, lim, 1)}, nil, 1, time.Minute, time.Minute, realtime.NewFake(testNow), logger.NewMockLogger(ctrl))
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				result, err := r.Call(context.Background(), "777", "aaa")
+				assert.Nil(t, err)
+				assert.Equal(t, call.OutcomeAnswered, result.Outcome)
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		r.Reload([]*Provider{NewProvider("default", caller, lim, 1), NewProvider(fmt.Sprint("backup", i%3), caller, lim, 1)}, nil, 1, time.Minute, time.Minute)
+	}
+	wg.Wait()
+}
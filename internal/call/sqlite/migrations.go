@@ -0,0 +1,77 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// migrations are applied in order, version is the index + 1. Applied migrations are never changed, new ones are appended.
+var migrations = []string{
+	// 1: the queue and statuses.
+	`CREATE TABLE queue (
+		id               TEXT PRIMARY KEY,
+		position         INTEGER NOT NULL,
+		meta             TEXT NOT NULL,
+		expires_at       INTEGER,
+		leased_until     INTEGER,
+		virtual_agent_id TEXT NOT NULL
+	);
+	CREATE INDEX queue_position ON queue (position);
+	CREATE TABLE statuses (
+		id               TEXT PRIMARY KEY,
+		state            TEXT NOT NULL,
+		code             INTEGER NOT NULL,
+		outcome          TEXT NOT NULL,
+		tenant           TEXT NOT NULL,
+		virtual_agent_id TEXT NOT NULL,
+		client_id        TEXT NOT NULL,
+		saved_at         INTEGER NOT NULL
+	);`,
+	// 2: dead letters.
+	`CREATE TABLE dead_letters (
+		seq     INTEGER PRIMARY KEY AUTOINCREMENT,
+		id      TEXT NOT NULL,
+		dead_at INTEGER NOT NULL,
+		meta    TEXT NOT NULL
+	);
+	CREATE INDEX dead_letters_id ON dead_letters (id);`,
+}
+
+// Migrate applies pending migrations, every one in its own transaction. It returns the schema version.
+// A database of a newer version isn't opened, the old binary doesn't know its schema.
+func Migrate(ctx context.Context, db *sql.DB) (int, error) {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, applied_at INTEGER NOT NULL)`)
+	if err != nil {
+		return 0, err
+	}
+	var version int
+	err = db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	if version > len(migrations) {
+		return version, fmt.Errorf("schema version %d is newer than %d of the binary", version, len(migrations))
+	}
+	for ; version < len(migrations); version++ {
+		if err := migrate(ctx, db, version+1, migrations[version]); err != nil {
+			return version, fmt.Errorf("migration %d: %w", version+1, err)
+		}
+	}
+	return version, nil
+}
+
+func migrate(ctx context.Context, db *sql.DB, version int, migration string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, migration); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, applied_at) VALUES (?, strftime('%s', 'now'))`, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
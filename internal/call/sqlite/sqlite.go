@@ -0,0 +1,355 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // registers "sqlite" driver, it's pure Go, so cgo isn't needed.
+
+	"test_trigger/internal/call"
+	"test_trigger/internal/realtime"
+)
+
+// Storage keeps the queue, statuses and dead letters in a SQLite file, so queued calls survive restarts.
+//
+// Next leases the call instead of removing it: the row is kept until the worker saves a terminal status(the call is acknowledged)
+// or puts the call back to the queue. If the process dies, the lease expires and the call is taken again, it's the
+// equivalent of SELECT ... FOR UPDATE SKIP LOCKED, SQLite runs the UPDATE ... RETURNING atomically.
+// So a call can be dialed twice after a crash, but it isn't lost. The lease should be longer than an originate request.
+//
+// One connection is used, SQLite serializes writes anyway and busy errors between connections are avoided.
+type Storage struct {
+	db           *sql.DB
+	leaseTimeout time.Duration
+	clock        realtime.Time
+	ready        chan struct{}
+	stop         chan struct{}
+	done         chan struct{}
+}
+
+// Open opens or creates the database and migrates it. Ready is signaled every pollInterval,
+// so calls of expired leases and calls queued by other processes are taken by idle workers.
+func Open(ctx context.Context, path string, leaseTimeout, pollInterval time.Duration, clock realtime.Time) (*Storage, error) {
+	dsn := "file:" + path + "?" + url.Values{
+		"_pragma": {"busy_timeout(5000)", "journal_mode(WAL)", "synchronous(NORMAL)"},
+		"_txlock": {"immediate"},
+	}.Encode()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := Migrate(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	s := &Storage{
+		db:           db,
+		leaseTimeout: leaseTimeout,
+		clock:        clock,
+		ready:        make(chan struct{}, 1),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	go s.poll(pollInterval)
+	return s, nil
+}
+
+// Close stops polling and closes the database.
+func (s *Storage) Close() error {
+	close(s.stop)
+	<-s.done
+	return s.db.Close()
+}
+
+func (s *Storage) poll(interval time.Duration) {
+	defer close(s.done)
+	ticker := s.clock.NewTicker(interval)
+	defer ticker.Stop()
+	// calls queued before restart are taken right away.
+	s.signal()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C():
+			s.signal()
+		}
+	}
+}
+
+// Ready receives when the queue may have calls, like call.Storage.
+func (s *Storage) Ready() <-chan struct{} {
+	return s.ready
+}
+
+func (s *Storage) signal() {
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// AddToQueueBack adds meta to the end of the queue. The call taken by Next is moved and its lease is released.
+func (s *Storage) AddToQueueBack(ctx context.Context, meta call.Meta) error {
+	return s.enqueue(ctx, meta, `(SELECT COALESCE(MAX(position), 0) + 1 FROM queue)`)
+}
+
+// AddToQueueFront adds meta to the front of the queue. The call taken by Next is moved and its lease is released.
+func (s *Storage) AddToQueueFront(ctx context.Context, meta call.Meta) error {
+	return s.enqueue(ctx, meta, `(SELECT COALESCE(MIN(position), 0) - 1 FROM queue)`)
+}
+
+func (s *Storage) enqueue(ctx context.Context, meta call.Meta, position string) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO queue (id, position, meta, expires_at, virtual_agent_id) VALUES (?, `+position+`, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET position = excluded.position, meta = excluded.meta, expires_at = excluded.expires_at,
+			virtual_agent_id = excluded.virtual_agent_id, leased_until = NULL`,
+		string(meta.ID), string(data), nullTime(meta.ExpiresAt), meta.VirtualAgentID)
+	if err != nil {
+		return err
+	}
+	s.signal()
+	return nil
+}
+
+// Next leases the first call, which isn't leased or its lease has expired.
+// Calls of paused agents are skipped, they aren't leased and keep their place in the queue.
+func (s *Storage) Next(ctx context.Context, pausedAgents []string) (call.Meta, bool, error) {
+	now := s.clock.Now()
+	skip, args := "", []any{}
+	if len(pausedAgents) > 0 {
+		placeholders, ids := list(pausedAgents)
+		skip, args = " AND virtual_agent_id NOT IN "+placeholders, ids
+	}
+	var data string
+	err := s.db.QueryRowContext(ctx, `UPDATE queue SET leased_until = ?
+		WHERE id = (SELECT id FROM queue WHERE (leased_until IS NULL OR leased_until <= ?)`+skip+` ORDER BY position LIMIT 1)
+		RETURNING meta`, append([]any{now.Add(s.leaseTimeout).UnixNano(), now.UnixNano()}, args...)...).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return call.Meta{}, false, nil
+	}
+	if err != nil {
+		return call.Meta{}, false, err
+	}
+	meta := call.Meta{}
+	if err := json.Unmarshal([]byte(data), &meta); err != nil {
+		return call.Meta{}, false, err
+	}
+	// other workers take the rest.
+	s.signal()
+	return meta, true, nil
+}
+
+// QueueLength returns calls waiting in the queue, leased calls are in progress.
+func (s *Storage) QueueLength(ctx context.Context) (int, error) {
+	var length int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM queue WHERE leased_until IS NULL OR leased_until <= ?`, s.clock.Now().UnixNano()).Scan(&length)
+	return length, err
+}
+
+// QueueLengthOf returns the number of queued calls of the virtual agents, e.g. of paused ones.
+func (s *Storage) QueueLengthOf(ctx context.Context, virtualAgentIDs []string) (int, error) {
+	if len(virtualAgentIDs) == 0 {
+		return 0, nil
+	}
+	placeholders, ids := list(virtualAgentIDs)
+	var length int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM queue WHERE (leased_until IS NULL OR leased_until <= ?)
+		AND virtual_agent_id IN `+placeholders, append([]any{s.clock.Now().UnixNano()}, ids...)...).Scan(&length)
+	return length, err
+}
+
+// SaveStatus saves the status, a terminal status acknowledges the leased call, it's removed from the queue.
+func (s *Storage) SaveStatus(ctx context.Context, status call.Status, meta call.Meta) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	_, err = tx.ExecContext(ctx, `INSERT INTO statuses (id, state, code, outcome, tenant, virtual_agent_id, client_id, saved_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET state = excluded.state, code = excluded.code, outcome = excluded.outcome, tenant = excluded.tenant,
+			virtual_agent_id = excluded.virtual_agent_id, client_id = excluded.client_id, saved_at = excluded.saved_at`,
+		string(meta.ID), string(status.State), status.Code, string(status.Outcome), meta.Tenant, meta.VirtualAgentID, meta.ClientID, s.clock.Now().UnixNano())
+	if err != nil {
+		return err
+	}
+	if status.State.Terminal() {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM queue WHERE id = ?`, string(meta.ID)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Status returns status record of the call, false if the call is unknown.
+func (s *Storage) Status(ctx context.Context, id call.ID) (call.Status, bool, error) {
+	var state, outcome string
+	status := call.Status{}
+	err := s.db.QueryRowContext(ctx, `SELECT state, code, outcome, tenant FROM statuses WHERE id = ?`, string(id)).Scan(&state, &status.Code, &outcome, &status.Tenant)
+	if errors.Is(err, sql.ErrNoRows) {
+		return call.Status{}, false, nil
+	}
+	if err != nil {
+		return call.Status{}, false, err
+	}
+	status.State, status.Outcome = call.State(state), call.Outcome(outcome)
+	return status, true, nil
+}
+
+// Statuses returns all statuses in order they were saved, the mains restore retention and quotas from them on start.
+func (s *Storage) Statuses(ctx context.Context) ([]call.StatusRecord, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, state, code, outcome, tenant, virtual_agent_id, client_id, saved_at FROM statuses ORDER BY saved_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]call.StatusRecord, 0)
+	for rows.Next() {
+		var id, state, outcome string
+		var savedAt int64
+		record := call.StatusRecord{}
+		if err := rows.Scan(&id, &state, &record.Status.Code, &outcome, &record.Tenant, &record.VirtualAgentID, &record.ClientID, &savedAt); err != nil {
+			return nil, err
+		}
+		record.ID, record.Status.State, record.Status.Outcome = call.ID(id), call.State(state), call.Outcome(outcome)
+		record.Status.Tenant, record.SavedAt = record.Tenant, time.Unix(0, savedAt).UTC()
+		res = append(res, record)
+	}
+	return res, rows.Err()
+}
+
+// RemoveStatus removes the status record of the call, if it isn't changed since it was read.
+func (s *Storage) RemoveStatus(ctx context.Context, id call.ID, status call.Status) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM statuses WHERE id = ? AND state = ? AND code = ? AND outcome = ?`,
+		string(id), string(status.State), status.Code, string(status.Outcome))
+	if err != nil {
+		return false, err
+	}
+	removed, err := res.RowsAffected()
+	return removed > 0, err
+}
+
+// RemoveExpired removes calls expired by now from the queue and returns them in queue order. Leased calls are left to workers.
+func (s *Storage) RemoveExpired(ctx context.Context, now time.Time) ([]call.Meta, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	expired, err := queryMetas(ctx, tx, `SELECT meta FROM queue
+		WHERE expires_at <= ? AND (leased_until IS NULL OR leased_until <= ?) ORDER BY position`, now.UnixNano(), s.clock.Now().UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	for _, meta := range expired {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM queue WHERE id = ?`, string(meta.ID)); err != nil {
+			return nil, err
+		}
+	}
+	return expired, tx.Commit()
+}
+
+// AddDeadLetter adds the dead letter, the call is acknowledged by its failed status.
+func (s *Storage) AddDeadLetter(ctx context.Context, deadLetter call.DeadLetter) error {
+	data, err := json.Marshal(deadLetter.Meta)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO dead_letters (id, dead_at, meta) VALUES (?, ?, ?)`,
+		string(deadLetter.ID), deadLetter.DeadAt.UnixNano(), string(data))
+	return err
+}
+
+// DeadLetters returns matched dead letters, the oldest first.
+func (s *Storage) DeadLetters(ctx context.Context, filter call.DeadLetterFilter) ([]call.DeadLetter, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT dead_at, meta FROM dead_letters ORDER BY seq`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]call.DeadLetter, 0)
+	for rows.Next() {
+		d, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		if filter.Match(d) {
+			res = append(res, d)
+		}
+	}
+	return res, rows.Err()
+}
+
+// TakeDeadLetter removes the dead letter, false if it is unknown or taken already.
+func (s *Storage) TakeDeadLetter(ctx context.Context, id call.ID) (call.DeadLetter, bool, error) {
+	d, err := scanDeadLetter(s.db.QueryRowContext(ctx, `DELETE FROM dead_letters
+		WHERE seq = (SELECT seq FROM dead_letters WHERE id = ? ORDER BY seq LIMIT 1)
+		RETURNING dead_at, meta`, string(id)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return call.DeadLetter{}, false, nil
+	}
+	if err != nil {
+		return call.DeadLetter{}, false, err
+	}
+	return d, true, nil
+}
+
+// list returns placeholders of the ids like "(?, ?)" and their args.
+func list(ids []string) (string, []any) {
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	return "(?" + strings.Repeat(", ?", len(ids)-1) + ")", args
+}
+
+// nullTime stores zero time as NULL, e.g. the call, which never expires.
+func nullTime(t time.Time) sql.NullInt64 {
+	if t.IsZero() {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.UnixNano(), Valid: true}
+}
+
+func queryMetas(ctx context.Context, tx *sql.Tx, query string, args ...any) ([]call.Meta, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]call.Meta, 0)
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		meta := call.Meta{}
+		if err := json.Unmarshal([]byte(data), &meta); err != nil {
+			return nil, err
+		}
+		res = append(res, meta)
+	}
+	return res, rows.Err()
+}
+
+func scanDeadLetter(row interface{ Scan(dest ...any) error }) (call.DeadLetter, error) {
+	var deadAt int64
+	var data string
+	if err := row.Scan(&deadAt, &data); err != nil {
+		return call.DeadLetter{}, err
+	}
+	d := call.DeadLetter{DeadAt: time.Unix(0, deadAt).UTC()}
+	if err := json.Unmarshal([]byte(data), &d.Meta); err != nil {
+		return call.DeadLetter{}, err
+	}
+	return d, nil
+}
@@ -0,0 +1,194 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"test_trigger/internal/call"
+	"test_trigger/internal/call/storagetest"
+	"test_trigger/internal/realtime"
+)
+
+func open(t *testing.T, path string, clock realtime.Time) *Storage {
+	s, err := Open(context.Background(), path, time.Minute, time.Hour, clock)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func TestStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) call.Store {
+		return open(t, filepath.Join(t.TempDir(), "calls.db"), realtime.NewFake(time.Unix(1709464831, 0)))
+	})
+}
+
+func TestStorage_Lease(t *testing.T) {
+	ctx := context.Background()
+	clock := realtime.NewFake(time.Unix(1709464831, 0))
+	s := open(t, filepath.Join(t.TempDir(), "calls.db"), clock)
+	require.NoError(t, s.AddToQueueBack(ctx, call.Meta{ID: "1"}))
+	require.NoError(t, s.AddToQueueBack(ctx, call.Meta{ID: "2"}))
+
+	m, ok, err := s.Next(ctx, nil)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, call.ID("1"), m.ID)
+	length, err := s.QueueLength(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, length)
+
+	// the worker died, the lease expires and the call is taken again before the next one.
+	clock.Advance(time.Minute)
+	length, err = s.QueueLength(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, length)
+	m, _, err = s.Next(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, call.ID("1"), m.ID)
+
+	// non-terminal status keeps the lease, terminal status acknowledges the call.
+	require.NoError(t, s.SaveStatus(ctx, call.Status{State: call.StateRetrying}, m))
+	clock.Advance(time.Minute)
+	require.NoError(t, s.SaveStatus(ctx, call.Status{State: call.StateFinished, Code: 200}, m))
+	m, _, err = s.Next(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, call.ID("2"), m.ID)
+	clock.Advance(time.Minute)
+	m, _, err = s.Next(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, call.ID("2"), m.ID)
+	require.NoError(t, s.SaveStatus(ctx, call.Status{State: call.StateFailed}, m))
+	clock.Advance(time.Minute)
+	_, ok, err = s.Next(ctx, nil)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestStorage_Requeue(t *testing.T) {
+	ctx := context.Background()
+	clock := realtime.NewFake(time.Unix(1709464831, 0))
+	s := open(t, filepath.Join(t.TempDir(), "calls.db"), clock)
+	require.NoError(t, s.AddToQueueBack(ctx, call.Meta{ID: "1"}))
+	require.NoError(t, s.AddToQueueBack(ctx, call.Meta{ID: "2"}))
+	m, _, err := s.Next(ctx, nil)
+	require.NoError(t, err)
+
+	// the retried call replaces its leased row, it isn't duplicated.
+	m.Attempts++
+	require.NoError(t, s.AddToQueueBack(ctx, m))
+	length, err := s.QueueLength(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, length)
+	for _, expected := range []call.Meta{{ID: "2"}, {ID: "1", Attempts: 1}} {
+		m, ok, err := s.Next(ctx, nil)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, expected, m)
+	}
+
+	// the leased call isn't removed by the sweeper, the worker sees it's expired.
+	require.NoError(t, s.AddToQueueBack(ctx, call.Meta{ID: "3", ExpiresAt: clock.Now().UTC()}))
+	_, _, err = s.Next(ctx, nil)
+	require.NoError(t, err)
+	expired, err := s.RemoveExpired(ctx, clock.Now())
+	assert.NoError(t, err)
+	assert.Empty(t, expired)
+}
+
+func TestStorage_Reopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "calls.db")
+	clock := realtime.NewFake(time.Unix(1709464831, 0))
+	s, err := Open(ctx, path, time.Minute, time.Hour, clock)
+	require.NoError(t, err)
+	require.NoError(t, s.AddToQueueBack(ctx, call.Meta{ID: "1"}))
+	require.NoError(t, s.AddToQueueBack(ctx, call.Meta{ID: "2"}))
+	require.NoError(t, s.SaveStatus(ctx, call.Status{State: call.StateQueued}, call.Meta{ID: "2"}))
+	_, _, err = s.Next(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	// queued calls survive restart, the call in progress is taken after its lease.
+	s = open(t, path, clock)
+	select {
+	case <-s.Ready():
+	case <-time.After(time.Second):
+		t.Fatal("Ready isn't signaled on open")
+	}
+	status, ok, err := s.Status(ctx, "2")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, call.StateQueued, status.State)
+	m, _, err := s.Next(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, call.ID("2"), m.ID)
+	clock.Advance(time.Minute)
+	m, _, err = s.Next(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, call.ID("1"), m.ID)
+}
+
+func TestStorage_Statuses(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1709464831, 0).UTC()
+	clock := realtime.NewFake(now)
+	s := open(t, filepath.Join(t.TempDir(), "calls.db"), clock)
+	first := call.Meta{ID: "1", VirtualAgentID: "a", ClientID: "crm", Tenant: "acme", PhoneNumber: "777"}
+	second := call.Meta{ID: "2", VirtualAgentID: "b", ClientID: "erp", Tenant: "other"}
+	require.NoError(t, s.SaveStatus(ctx, call.Status{State: call.StateQueued}, first))
+	clock.Advance(time.Second)
+	require.NoError(t, s.SaveStatus(ctx, call.Status{State: call.StateQueued}, second))
+	clock.Advance(time.Second)
+	require.NoError(t, s.SaveStatus(ctx, call.Status{State: call.StateFinished, Code: 200, Outcome: call.OutcomeAnswered}, first))
+
+	// in order they were saved, the phone number isn't kept.
+	statuses, err := s.Statuses(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []call.StatusRecord{
+		{Meta: call.Meta{ID: "2", VirtualAgentID: "b", ClientID: "erp", Tenant: "other"}, Status: call.Status{State: call.StateQueued, Tenant: "other"}, SavedAt: now.Add(time.Second)},
+		{Meta: call.Meta{ID: "1", VirtualAgentID: "a", ClientID: "crm", Tenant: "acme"}, Status: call.Status{State: call.StateFinished, Code: 200, Outcome: call.OutcomeAnswered, Tenant: "acme"}, SavedAt: now.Add(2 * time.Second)},
+	}, statuses)
+}
+
+func TestStorage_Poll(t *testing.T) {
+	clock := realtime.NewFake(time.Unix(1709464831, 0))
+	s, err := Open(context.Background(), filepath.Join(t.TempDir(), "calls.db"), time.Minute, time.Second, clock)
+	require.NoError(t, err)
+	<-s.Ready()
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	<-s.Ready()
+	require.NoError(t, s.Close())
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "calls.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	version, err := Migrate(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, len(migrations), version)
+	// applied migrations are skipped.
+	version, err = Migrate(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, len(migrations), version)
+	var applied int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&applied))
+	assert.Equal(t, len(migrations), applied)
+
+	// the database of a newer binary isn't touched.
+	_, err = db.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, 0)`, len(migrations)+1)
+	require.NoError(t, err)
+	version, err = Migrate(ctx, db)
+	assert.EqualError(t, err, fmt.Sprintf("schema version %d is newer than %d of the binary", len(migrations)+1, len(migrations)))
+	assert.Equal(t, len(migrations)+1, version)
+}
@@ -0,0 +1,83 @@
+package call
+
+import "sync"
+
+// statusShardCount is a power of 2, so a shard is selected with a mask.
+const statusShardCount = 64
+
+// statusShards is the status map split by call id hash, every shard has its own lock.
+// Workers save statuses, API reads them and the handler queues calls without waiting for each other.
+type statusShards struct {
+	shards [statusShardCount]statusShard
+}
+
+type statusShard struct {
+	mu       sync.RWMutex
+	statuses map[ID]Status
+	_        [32]byte // shards don't share a cache line, 24 bytes of the lock + 8 of the map.
+}
+
+func newStatusShards(statuses map[ID]Status) *statusShards {
+	s := &statusShards{}
+	for i := range s.shards {
+		s.shards[i].statuses = make(map[ID]Status)
+	}
+	for id, status := range statuses {
+		s.shard(id).statuses[id] = status
+	}
+	return s
+}
+
+// shard selects by FNV-1a hash of the id, it doesn't allocate unlike hash/fnv.
+func (s *statusShards) shard(id ID) *statusShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(id); i++ {
+		h ^= uint32(id[i])
+		h *= 16777619
+	}
+	return &s.shards[h&(statusShardCount-1)]
+}
+
+func (s *statusShards) set(id ID, status Status) {
+	shard := s.shard(id)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	shard.statuses[id] = status
+}
+
+func (s *statusShards) get(id ID) (Status, bool) {
+	shard := s.shard(id)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	status, ok := shard.statuses[id]
+	return status, ok
+}
+
+// removeIf removes the status if it's still the given one, false otherwise.
+func (s *statusShards) removeIf(id ID, status Status) bool {
+	shard := s.shard(id)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	current, ok := shard.statuses[id]
+	// the tenant of the call doesn't change, it isn't compared.
+	status.Tenant = current.Tenant
+	if !ok || current != status {
+		return false
+	}
+	delete(shard.statuses, id)
+	return true
+}
+
+// all returns a copy of every status, shards are locked one by one.
+func (s *statusShards) all() map[ID]Status {
+	res := make(map[ID]Status)
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.RLock()
+		for id, status := range shard.statuses {
+			res[id] = status
+		}
+		shard.mu.RUnlock()
+	}
+	return res
+}
@@ -0,0 +1,182 @@
+package call
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusShards(t *testing.T) {
+	assert.Equal(t, uintptr(64), unsafe.Sizeof(statusShard{}))
+
+	s := newStatusShards(map[ID]Status{"1": {State: StateQueued}})
+	wg := &sync.WaitGroup{}
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				id := ID(strconv.Itoa(w*100 + i))
+				s.set(id, Status{State: StateFinished, Code: i})
+				status, ok := s.get(id)
+				assert.True(t, ok)
+				assert.Equal(t, i, status.Code)
+			}
+		}(w)
+	}
+	wg.Wait()
+	all := s.all()
+	assert.Len(t, all, 800)
+	assert.Equal(t, Status{State: StateFinished, Code: 1}, all["1"])
+
+	// ids are spread over shards.
+	for i := range s.shards {
+		assert.NotEmpty(t, s.shards[i].statuses, "shard %d", i)
+	}
+	_, ok := s.get("unknown")
+	assert.False(t, ok)
+}
+
+// globalLockStorage is the previous Storage, one mutex for the queue and statuses. It's kept for comparison.
+type globalLockStorage struct {
+	toProcess *queue
+	statuses  map[ID]Status
+	ready     chan struct{}
+	mu        *sync.Mutex
+}
+
+func (s *globalLockStorage) signal() {
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (s *globalLockStorage) AddToQueueBack(_ context.Context, meta Meta) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.toProcess.pushBack(meta)
+	s.signal()
+	return nil
+}
+
+func (s *globalLockStorage) Next(_ context.Context, _ []string) (Meta, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	meta, ok := s.toProcess.popFront()
+	if s.toProcess.len() > 0 {
+		s.signal()
+	}
+	return meta, ok, nil
+}
+
+func (s *globalLockStorage) SaveStatus(_ context.Context, status Status, meta Meta) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses[meta.ID] = status
+	return nil
+}
+
+func (s *globalLockStorage) Status(_ context.Context, id ID) (Status, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status, ok := s.statuses[id]
+	return status, ok, nil
+}
+
+type benchmarkStorage interface {
+	AddToQueueBack(_ context.Context, meta Meta) error
+	Next(_ context.Context, pausedAgents []string) (Meta, bool, error)
+	SaveStatus(_ context.Context, status Status, meta Meta) error
+	Status(_ context.Context, id ID) (Status, bool, error)
+}
+
+var benchmarkStorages = []struct {
+	name string
+	new  func() benchmarkStorage
+}{
+	{"global", func() benchmarkStorage {
+		return &globalLockStorage{toProcess: newQueue(), statuses: make(map[ID]Status), ready: make(chan struct{}, 1), mu: &sync.Mutex{}}
+	}},
+	{"sharded", func() benchmarkStorage { return NewStorage() }},
+}
+
+// benchmarkCalls are queued before the benchmark, so consumers and readers have calls from the start.
+const benchmarkCalls = 10000
+
+func prepared(newStorage func() benchmarkStorage) (benchmarkStorage, []ID) {
+	s := newStorage()
+	ids := make([]ID, benchmarkCalls)
+	for i := range ids {
+		ids[i] = ID(strconv.Itoa(i))
+		meta := Meta{ID: ids[i], PhoneNumber: "777", VirtualAgentID: "aaa"}
+		_ = s.SaveStatus(context.Background(), Status{State: StateQueued}, meta)
+		_ = s.AddToQueueBack(context.Background(), meta)
+	}
+	return s, ids
+}
+
+// BenchmarkStorage_Contention is the service under load: every goroutine is a producer, a consumer or a status reader in turn.
+// Producers save the status and queue the call like Trigger, consumers take a call and save its outcome like workers,
+// readers poll statuses like /calls/{id}. Run with -cpu 1,4,16 to see the contention.
+func BenchmarkStorage_Contention(b *testing.B) {
+	ctx := context.Background()
+	for _, bs := range benchmarkStorages {
+		b.Run(bs.name, func(b *testing.B) {
+			s, ids := prepared(bs.new)
+			var seq atomic.Uint64
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				// goroutines start at different calls, a shared counter would be contended itself.
+				n := seq.Add(1) * 7919
+				for pb.Next() {
+					n++
+					switch n % 3 {
+					case 0:
+						meta := Meta{ID: ids[n%benchmarkCalls], PhoneNumber: "777", VirtualAgentID: "aaa"}
+						_ = s.SaveStatus(ctx, Status{State: StateQueued}, meta)
+						_ = s.AddToQueueBack(ctx, meta)
+					case 1:
+						if meta, ok, _ := s.Next(ctx, nil); ok {
+							_ = s.SaveStatus(ctx, Status{State: StateFinished, Code: 200, Outcome: OutcomeAnswered}, meta)
+						}
+					default:
+						_, _, _ = s.Status(ctx, ids[n%benchmarkCalls])
+					}
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkStorage_StatusReads is status polling while workers save outcomes, the queue isn't used.
+func BenchmarkStorage_StatusReads(b *testing.B) {
+	ctx := context.Background()
+	for _, bs := range benchmarkStorages {
+		b.Run(bs.name, func(b *testing.B) {
+			s, ids := prepared(bs.new)
+			var seq atomic.Uint64
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				// goroutines start at different calls, a shared counter would be contended itself.
+				n := seq.Add(1) * 7919
+				for pb.Next() {
+					n++
+					id := ids[n%benchmarkCalls]
+					if n%4 == 0 {
+						_ = s.SaveStatus(ctx, Status{State: StateFinished, Code: 200, Outcome: OutcomeAnswered}, Meta{ID: id})
+						continue
+					}
+					_, _, _ = s.Status(ctx, id)
+				}
+			})
+		})
+	}
+}
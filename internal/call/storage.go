@@ -2,62 +2,258 @@ package call
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"time"
 )
 
+// Store is the whole storage surface, the memory Storage and the SQLite one implement it.
+// Consumers declare narrower interfaces, Store lets the mains choose the implementation.
+type Store interface {
+	Ready() <-chan struct{}
+	AddToQueueBack(_ context.Context, meta Meta) error
+	AddToQueueFront(_ context.Context, meta Meta) error
+	Next(_ context.Context, pausedAgents []string) (Meta, bool, error)
+	QueueLength(_ context.Context) (int, error)
+	QueueLengthOf(_ context.Context, virtualAgentIDs []string) (int, error)
+	RemoveExpired(_ context.Context, now time.Time) ([]Meta, error)
+	SaveStatus(_ context.Context, status Status, meta Meta) error
+	Status(_ context.Context, id ID) (Status, bool, error)
+	RemoveStatus(_ context.Context, id ID, status Status) (bool, error)
+	AddDeadLetter(_ context.Context, deadLetter DeadLetter) error
+	DeadLetters(_ context.Context, filter DeadLetterFilter) ([]DeadLetter, error)
+	TakeDeadLetter(_ context.Context, id ID) (DeadLetter, bool, error)
+}
+
 // Storage stores calls for processing.
-// Implementation can be with real database, buffered channel, ring buffer like in limiter, etc.
-// I've used slices for queue(not channel), since we always should respond fast regardless workers loading.
-// []Meta can be []*Meta. Can save some memory allocations, and will be possible to free memory earlier in the following way s[0]=nil, but it also has cons.
-// Ring buffer implementation doesn't fit within time frame.
+// Implementation can be with real database, buffered channel, etc.
+// The queue is a ring buffer(not channel), since we always should respond fast regardless workers loading,
+// retries are put to its front without copying the whole queue.
+// Calls of paused agents reached by Next are parked in per-agent queues, so they aren't scanned on every Next.
+// Parked calls were ahead of the whole queue, they are returned to its front when the agent is resumed.
+// Agents resumed at once are returned in the order they were parked, their calls aren't interleaved again.
+// Statuses are sharded apart from the queue, status writes and reads don't wait for the queue lock.
 // Context in input, error in output are for future implementation with database.
 type Storage struct {
-	toProcess []Meta
-	statuses  map[ID]int
-	mu        *sync.Mutex
+	toProcess   *queue
+	parked      map[string]*parkedQueue // virtual agent id -> calls of the paused agent.
+	parkings    uint64                  // counter of parked agents, orders them on resume.
+	statuses    *statusShards
+	deadLetters []DeadLetter // in order of death.
+	ready       chan struct{}
+	mu          *sync.Mutex // of the queue and dead letters.
 }
 
 func NewStorage() *Storage {
-	return &Storage{toProcess: make([]Meta, 0), statuses: make(map[ID]int), mu: &sync.Mutex{}}
+	return &Storage{toProcess: newQueue(), parked: make(map[string]*parkedQueue), statuses: newStatusShards(nil), deadLetters: make([]DeadLetter, 0), ready: make(chan struct{}, 1), mu: &sync.Mutex{}}
+}
+
+// Ready receives when the queue may have calls, idle workers wait on it instead of polling.
+// One signal wakes one worker, Next signals again while calls remain, so workers are woken one by one.
+func (s *Storage) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// signal doesn't block, one pending signal is enough since the woken worker passes it on.
+func (s *Storage) signal() {
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
 }
 
 // AddToQueueBack adds meta to the end of the queue.
 func (s *Storage) AddToQueueBack(_ context.Context, meta Meta) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.toProcess = append(s.toProcess, meta)
+	s.toProcess.pushBack(meta)
+	s.signal()
 	return nil
 }
 
+// AddToQueueFront adds meta to the front of the queue, or of the parked calls of its agent.
 func (s *Storage) AddToQueueFront(_ context.Context, meta Meta) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.toProcess = append(s.toProcess, Meta{})
-	copy(s.toProcess[1:], s.toProcess)
-	s.toProcess[0] = meta
+	if parked, ok := s.parked[meta.VirtualAgentID]; ok {
+		parked.pushFront(meta)
+		return nil
+	}
+	s.toProcess.pushFront(meta)
+	s.signal()
+	return nil
+}
+
+// Next takes the first call, calls of paused agents are skipped, they keep their place in the queue.
+// Each skipped call is moved to the parked queue of its agent once, so Next is O(1) amortized.
+func (s *Storage) Next(_ context.Context, pausedAgents []string) (Meta, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	paused := agentSet(pausedAgents)
+	s.unpark(paused)
+	for {
+		res, ok := s.toProcess.popFront()
+		if !ok {
+			return Meta{}, false, nil
+		}
+		if paused[res.VirtualAgentID] {
+			s.park(res)
+			continue
+		}
+		if s.toProcess.len() > 0 {
+			s.signal()
+		}
+		return res, true, nil
+	}
+}
+
+// parkedQueue is a queue of calls of one paused agent.
+type parkedQueue struct {
+	*queue
+	since uint64 // Storage.parkings when the first call was parked.
+}
+
+func (s *Storage) park(meta Meta) {
+	if s.parked == nil {
+		s.parked = make(map[string]*parkedQueue)
+	}
+	parked, ok := s.parked[meta.VirtualAgentID]
+	if !ok {
+		s.parkings++
+		parked = &parkedQueue{queue: newQueue(), since: s.parkings}
+		s.parked[meta.VirtualAgentID] = parked
+	}
+	parked.pushBack(meta)
+}
+
+// unpark returns parked calls of resumed agents to the front of the queue in their order.
+func (s *Storage) unpark(paused map[string]bool) {
+	resumed := make([]*parkedQueue, 0)
+	for agent, parked := range s.parked {
+		if !paused[agent] {
+			resumed = append(resumed, parked)
+			delete(s.parked, agent)
+		}
+	}
+	// the latest parked go to the front first, so the earliest end up at the head.
+	sort.Slice(resumed, func(i, j int) bool { return resumed[i].since > resumed[j].since })
+	for _, parked := range resumed {
+		for i := parked.len() - 1; i >= 0; i-- {
+			s.toProcess.pushFront(parked.buf[parked.at(i)])
+		}
+	}
+}
+
+// parkedLength returns the number of parked calls of all agents, it is called under the lock.
+func (s *Storage) parkedLength() int {
+	res := 0
+	for _, parked := range s.parked {
+		res += parked.len()
+	}
+	return res
+}
+
+func (s *Storage) SaveStatus(_ context.Context, status Status, meta Meta) error {
+	status.Tenant = meta.Tenant
+	s.statuses.set(meta.ID, status)
 	return nil
 }
 
-func (s *Storage) Next(_ context.Context) (Meta, bool, error) {
+// Status returns status record of the call, false if the call is unknown.
+func (s *Storage) Status(_ context.Context, id ID) (Status, bool, error) {
+	status, ok := s.statuses.get(id)
+	return status, ok, nil
+}
+
+// RemoveStatus removes the status record of the call, if it isn't changed since it was read.
+// A call replayed meanwhile keeps its new status.
+func (s *Storage) RemoveStatus(_ context.Context, id ID, status Status) (bool, error) {
+	return s.statuses.removeIf(id, status), nil
+}
+
+func (s *Storage) QueueLength(_ context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.toProcess.len() + s.parkedLength(), nil
+}
+
+// QueueLengthOf returns the number of queued calls of the virtual agents, e.g. of paused ones.
+func (s *Storage) QueueLengthOf(_ context.Context, virtualAgentIDs []string) (int, error) {
+	agents := agentSet(virtualAgentIDs)
+	if len(agents) == 0 {
+		return 0, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	length := 0
+	for agent := range agents {
+		if parked, ok := s.parked[agent]; ok {
+			length += parked.len()
+		}
+	}
+	for i := 0; i < s.toProcess.len(); i++ {
+		if agents[s.toProcess.buf[s.toProcess.at(i)].VirtualAgentID] {
+			length++
+		}
+	}
+	return length, nil
+}
+
+func agentSet(virtualAgentIDs []string) map[string]bool {
+	if len(virtualAgentIDs) == 0 {
+		return nil
+	}
+	res := make(map[string]bool, len(virtualAgentIDs))
+	for _, id := range virtualAgentIDs {
+		res[id] = true
+	}
+	return res
+}
+
+// RemoveExpired removes calls expired by now from the queue and parked calls, and returns them.
+func (s *Storage) RemoveExpired(_ context.Context, now time.Time) ([]Meta, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if len(s.toProcess) == 0 {
-		return Meta{}, false, nil
+	expired := func(meta Meta) bool { return meta.Expired(now) }
+	res := s.toProcess.removeFunc(expired)
+	for agent, parked := range s.parked {
+		res = append(res, parked.removeFunc(expired)...)
+		if parked.len() == 0 {
+			delete(s.parked, agent)
+		}
 	}
-	res := s.toProcess[0]
-	s.toProcess = s.toProcess[1:]
-	return res, true, nil
+	return res, nil
 }
 
-func (s *Storage) SaveStatus(_ context.Context, status int, meta Meta) error {
+func (s *Storage) AddDeadLetter(_ context.Context, deadLetter DeadLetter) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.statuses[meta.ID] = status
+	s.deadLetters = append(s.deadLetters, deadLetter)
 	return nil
 }
 
-func (s *Storage) QueueLength(_ context.Context) (int, error) {
+// DeadLetters returns matched dead letters, the oldest first.
+func (s *Storage) DeadLetters(_ context.Context, filter DeadLetterFilter) ([]DeadLetter, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	return len(s.toProcess), nil
+	res := make([]DeadLetter, 0)
+	for _, d := range s.deadLetters {
+		if filter.Match(d) {
+			res = append(res, d)
+		}
+	}
+	return res, nil
+}
+
+// TakeDeadLetter removes the dead letter, false if it is unknown or taken already.
+func (s *Storage) TakeDeadLetter(_ context.Context, id ID) (DeadLetter, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, d := range s.deadLetters {
+		if d.ID == id {
+			s.deadLetters = append(s.deadLetters[:i], s.deadLetters[i+1:]...)
+			return d, true, nil
+		}
+	}
+	return DeadLetter{}, false, nil
 }
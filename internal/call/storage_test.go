@@ -4,23 +4,53 @@ import (
 	"context"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewStorage(t *testing.T) {
+	actual := NewStorage()
+	assert.Equal(t, 1, cap(actual.ready))
+	// channels are compared by pointer.
+	actual.ready = nil
 	expected := &Storage{
-		toProcess: make([]Meta, 0),
-		statuses:  make(map[ID]int, 0),
-		mu:        &sync.Mutex{},
+		toProcess:   newQueue(),
+		parked:      make(map[string]*parkedQueue),
+		statuses:    newStatusShards(nil),
+		deadLetters: make([]DeadLetter, 0),
+		mu:          &sync.Mutex{},
 	}
-	assert.Equal(t, expected, NewStorage())
+	assert.Equal(t, expected, actual)
+}
+
+func TestStorage_Ready(t *testing.T) {
+	ctx := context.Background()
+	s := NewStorage()
+	assert.Len(t, s.Ready(), 0)
+
+	// signals don't pile up.
+	assert.NoError(t, s.AddToQueueBack(ctx, Meta{ID: "1"}))
+	assert.NoError(t, s.AddToQueueBack(ctx, Meta{ID: "2"}))
+	assert.Len(t, s.Ready(), 1)
+	<-s.Ready()
+
+	// the woken worker passes the signal on while calls remain.
+	_, _, _ = s.Next(ctx, nil)
+	assert.Len(t, s.Ready(), 1)
+	<-s.Ready()
+	_, _, _ = s.Next(ctx, nil)
+	assert.Len(t, s.Ready(), 0)
+
+	assert.NoError(t, s.AddToQueueFront(ctx, Meta{ID: "3"}))
+	assert.Len(t, s.Ready(), 1)
 }
 
 func TestStorage_AddToQueueBack(t *testing.T) {
 	type fields struct {
 		toProcess []Meta
-		statuses  map[ID]int
+		statuses  map[ID]Status
 		mu        *sync.Mutex
 	}
 	type args struct {
@@ -104,14 +134,14 @@ func TestStorage_AddToQueueBack(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &Storage{
-				toProcess: tt.fields.toProcess,
-				statuses:  tt.fields.statuses,
+				toProcess: newQueue(tt.fields.toProcess...),
+				statuses:  newStatusShards(tt.fields.statuses),
 				mu:        tt.fields.mu,
 			}
 			ao := assert.New(t)
 			actualErr := s.AddToQueueBack(tt.args.in0, tt.args.meta)
 			ao.Equal(tt.expectedValues.err, actualErr)
-			ao.Equal(tt.expectedValues.toProcess, s.toProcess)
+			ao.Equal(tt.expectedValues.toProcess, s.toProcess.slice())
 
 		})
 	}
@@ -120,7 +150,7 @@ func TestStorage_AddToQueueBack(t *testing.T) {
 func TestStorage_AddToQueueFront(t *testing.T) {
 	type fields struct {
 		toProcess []Meta
-		statuses  map[ID]int
+		statuses  map[ID]Status
 		mu        *sync.Mutex
 	}
 	type args struct {
@@ -204,14 +234,14 @@ func TestStorage_AddToQueueFront(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &Storage{
-				toProcess: tt.fields.toProcess,
-				statuses:  tt.fields.statuses,
+				toProcess: newQueue(tt.fields.toProcess...),
+				statuses:  newStatusShards(tt.fields.statuses),
 				mu:        tt.fields.mu,
 			}
 			ao := assert.New(t)
 			actualErr := s.AddToQueueFront(tt.args.in0, tt.args.meta)
 			ao.Equal(tt.expectedValues.err, actualErr)
-			ao.Equal(tt.expectedValues.toProcess, s.toProcess)
+			ao.Equal(tt.expectedValues.toProcess, s.toProcess.slice())
 
 		})
 	}
@@ -220,7 +250,7 @@ func TestStorage_AddToQueueFront(t *testing.T) {
 func TestStorage_Next(t *testing.T) {
 	type fields struct {
 		toProcess []Meta
-		statuses  map[ID]int
+		statuses  map[ID]Status
 		mu        *sync.Mutex
 	}
 	type args struct {
@@ -300,12 +330,12 @@ func TestStorage_Next(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &Storage{
-				toProcess: tt.fields.toProcess,
-				statuses:  tt.fields.statuses,
+				toProcess: newQueue(tt.fields.toProcess...),
+				statuses:  newStatusShards(tt.fields.statuses),
 				mu:        tt.fields.mu,
 			}
 			ao := assert.New(t)
-			actualMeta, actualExists, actualErr := s.Next(tt.args.in0)
+			actualMeta, actualExists, actualErr := s.Next(tt.args.in0, nil)
 			ao.Equal(tt.expectedValues.value, actualMeta)
 			ao.Equal(tt.expectedValues.exists, actualExists)
 			ao.Equal(tt.expectedValues.err, actualErr)
@@ -313,10 +343,46 @@ func TestStorage_Next(t *testing.T) {
 	}
 }
 
+func TestStorage_NextParked(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1709464831, 0)
+	s := NewStorage()
+	for _, m := range []Meta{{ID: "a1", VirtualAgentID: "a"}, {ID: "b1", VirtualAgentID: "b"}, {ID: "a2", VirtualAgentID: "a", ExpiresAt: now}} {
+		require.NoError(t, s.AddToQueueBack(ctx, m))
+	}
+	meta, ok, err := s.Next(ctx, []string{"a"})
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, ID("b1"), meta.ID)
+	_, ok, _ = s.Next(ctx, []string{"a"})
+	assert.False(t, ok)
+	// calls of the paused agent are parked, they aren't scanned by Next anymore.
+	assert.Equal(t, 0, s.toProcess.len())
+	assert.Equal(t, []Meta{{ID: "a1", VirtualAgentID: "a"}, {ID: "a2", VirtualAgentID: "a", ExpiresAt: now}}, s.parked["a"].slice())
+
+	// a retry of the paused agent keeps its place ahead of its parked calls.
+	require.NoError(t, s.AddToQueueFront(ctx, Meta{ID: "a0", VirtualAgentID: "a"}))
+	require.NoError(t, s.AddToQueueBack(ctx, Meta{ID: "b2", VirtualAgentID: "b"}))
+	expired, err := s.RemoveExpired(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, []Meta{{ID: "a2", VirtualAgentID: "a", ExpiresAt: now}}, expired)
+	length, _ := s.QueueLength(ctx)
+	assert.Equal(t, 3, length)
+	length, _ = s.QueueLengthOf(ctx, []string{"a"})
+	assert.Equal(t, 2, length)
+
+	for _, expected := range []ID{"a0", "a1", "b2"} {
+		meta, ok, _ = s.Next(ctx, nil)
+		assert.True(t, ok)
+		assert.Equal(t, expected, meta.ID)
+	}
+	assert.Empty(t, s.parked)
+}
+
 func TestStorage_QueueLength(t *testing.T) {
 	type fields struct {
 		toProcess []Meta
-		statuses  map[ID]int
+		statuses  map[ID]Status
 		mu        *sync.Mutex
 	}
 	type args struct {
@@ -349,8 +415,8 @@ func TestStorage_QueueLength(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &Storage{
-				toProcess: tt.fields.toProcess,
-				statuses:  tt.fields.statuses,
+				toProcess: newQueue(tt.fields.toProcess...),
+				statuses:  newStatusShards(tt.fields.statuses),
 				mu:        tt.fields.mu,
 			}
 			ao := assert.New(t)
@@ -364,16 +430,16 @@ func TestStorage_QueueLength(t *testing.T) {
 func TestStorage_SaveStatus(t *testing.T) {
 	type fields struct {
 		toProcess []Meta
-		statuses  map[ID]int
+		statuses  map[ID]Status
 		mu        *sync.Mutex
 	}
 	type args struct {
 		in0    context.Context
-		status int
+		status Status
 		meta   Meta
 	}
 	type expectedValues struct {
-		statuses map[ID]int
+		statuses map[ID]Status
 		err      error
 	}
 	tests := []struct {
@@ -385,12 +451,12 @@ func TestStorage_SaveStatus(t *testing.T) {
 		{
 			name: "simple success",
 			fields: fields{
-				statuses: make(map[ID]int),
+				statuses: make(map[ID]Status),
 				mu:       &sync.Mutex{},
 			},
 			args: args{
 				in0:    nil,
-				status: 200,
+				status: Status{Code: 200, Outcome: OutcomeAnswered},
 				meta: Meta{
 					PhoneNumber:    "777-777-77",
 					VirtualAgentID: "aaaa-bbbb-cccc-dddd",
@@ -398,8 +464,8 @@ func TestStorage_SaveStatus(t *testing.T) {
 				},
 			},
 			expectedValues: expectedValues{
-				statuses: map[ID]int{
-					"1": 200,
+				statuses: map[ID]Status{
+					"1": {Code: 200, Outcome: OutcomeAnswered},
 				},
 				err: nil,
 			},
@@ -407,14 +473,14 @@ func TestStorage_SaveStatus(t *testing.T) {
 		{
 			name: "success, rewrite value",
 			fields: fields{
-				statuses: map[ID]int{
-					"1": 200,
+				statuses: map[ID]Status{
+					"1": {Code: 200, Outcome: OutcomeAnswered},
 				},
 				mu: &sync.Mutex{},
 			},
 			args: args{
 				in0:    nil,
-				status: 499,
+				status: Status{Code: 486, Outcome: OutcomeBusy},
 				meta: Meta{
 					PhoneNumber:    "777-777-77",
 					VirtualAgentID: "aaaa-bbbb-cccc-dddd",
@@ -422,8 +488,8 @@ func TestStorage_SaveStatus(t *testing.T) {
 				},
 			},
 			expectedValues: expectedValues{
-				statuses: map[ID]int{
-					"1": 499,
+				statuses: map[ID]Status{
+					"1": {Code: 486, Outcome: OutcomeBusy},
 				},
 				err: nil,
 			},
@@ -432,14 +498,116 @@ func TestStorage_SaveStatus(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &Storage{
-				toProcess: tt.fields.toProcess,
-				statuses:  tt.fields.statuses,
+				toProcess: newQueue(tt.fields.toProcess...),
+				statuses:  newStatusShards(tt.fields.statuses),
 				mu:        tt.fields.mu,
 			}
 			ao := assert.New(t)
 			actualErr := s.SaveStatus(tt.args.in0, tt.args.status, tt.args.meta)
 			ao.Equal(tt.expectedValues.err, actualErr)
-			ao.Equal(tt.expectedValues.statuses, s.statuses)
+			ao.Equal(tt.expectedValues.statuses, s.statuses.all())
+		})
+	}
+}
+
+func TestStorage_Status(t *testing.T) {
+	s := &Storage{
+		statuses: newStatusShards(map[ID]Status{
+			"1": {State: StateFinished, Code: 200, Outcome: OutcomeAnswered},
+		}),
+	}
+	ao := assert.New(t)
+	actual, ok, err := s.Status(nil, "1")
+	ao.Equal(Status{State: StateFinished, Code: 200, Outcome: OutcomeAnswered}, actual)
+	ao.True(ok)
+	ao.Nil(err)
+
+	actual, ok, err = s.Status(nil, "2")
+	ao.Equal(Status{}, actual)
+	ao.False(ok)
+	ao.Nil(err)
+}
+
+func TestStorage_RemoveStatus(t *testing.T) {
+	ctx := context.Background()
+	s := NewStorage()
+	finished := Status{State: StateFinished, Code: 200, Outcome: OutcomeAnswered}
+	assert.NoError(t, s.SaveStatus(ctx, finished, Meta{ID: "1"}))
+	assert.NoError(t, s.SaveStatus(ctx, Status{State: StateQueued}, Meta{ID: "2"}))
+
+	// the status was changed after it was read.
+	removed, err := s.RemoveStatus(ctx, "2", Status{State: StateFailed})
+	assert.NoError(t, err)
+	assert.False(t, removed)
+	removed, err = s.RemoveStatus(ctx, "1", finished)
+	assert.NoError(t, err)
+	assert.True(t, removed)
+	removed, err = s.RemoveStatus(ctx, "1", finished)
+	assert.NoError(t, err)
+	assert.False(t, removed)
+	assert.Equal(t, map[ID]Status{"2": {State: StateQueued}}, s.statuses.all())
+}
+
+func TestStorage_DeadLetters(t *testing.T) {
+	ctx := context.Background()
+	s := NewStorage()
+	first := DeadLetter{Meta: Meta{ID: "1", VirtualAgentID: "a", Failures: []Failure{{Reason: "outcome", Code: 500, Outcome: OutcomeProviderError}}}}
+	second := DeadLetter{Meta: Meta{ID: "2", VirtualAgentID: "b", Failures: []Failure{{Reason: "connection refused"}}}}
+	third := DeadLetter{Meta: Meta{ID: "3", VirtualAgentID: "a"}}
+	for _, d := range []DeadLetter{first, second, third} {
+		assert.NoError(t, s.AddDeadLetter(ctx, d))
+	}
+
+	tests := []struct {
+		name     string
+		filter   DeadLetterFilter
+		expected []DeadLetter
+	}{
+		{"all", DeadLetterFilter{}, []DeadLetter{first, second, third}},
+		{"virtual agent", DeadLetterFilter{VirtualAgentID: "a"}, []DeadLetter{first, third}},
+		{"last outcome", DeadLetterFilter{Outcome: OutcomeProviderError}, []DeadLetter{first}},
+		{"ids", DeadLetterFilter{IDs: []ID{"3", "2"}}, []DeadLetter{second, third}},
+		{"ids and virtual agent", DeadLetterFilter{IDs: []ID{"2"}, VirtualAgentID: "a"}, []DeadLetter{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := s.DeadLetters(ctx, tt.filter)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, actual)
 		})
 	}
+
+	d, ok, err := s.TakeDeadLetter(ctx, "2")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, second, d)
+	_, ok, _ = s.TakeDeadLetter(ctx, "2")
+	assert.False(t, ok)
+	actual, _ := s.DeadLetters(ctx, DeadLetterFilter{})
+	assert.Equal(t, []DeadLetter{first, third}, actual)
+}
+
+func TestStorage_RemoveExpired(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1709464831, 0)
+	s := NewStorage()
+	calls := []Meta{
+		{ID: "1", ExpiresAt: now.Add(-time.Second)},
+		{ID: "2"},
+		{ID: "3", ExpiresAt: now},
+		{ID: "4", ExpiresAt: now.Add(time.Second)},
+	}
+	for _, meta := range calls {
+		assert.NoError(t, s.AddToQueueBack(ctx, meta))
+	}
+
+	expired, err := s.RemoveExpired(ctx, now)
+	assert.NoError(t, err)
+	assert.Equal(t, []Meta{calls[0], calls[2]}, expired)
+	assert.Equal(t, []Meta{calls[1], calls[3]}, s.toProcess.slice())
+	length, _ := s.QueueLength(ctx)
+	assert.Equal(t, 2, length)
+
+	expired, _ = s.RemoveExpired(ctx, now)
+	assert.Empty(t, expired)
 }
@@ -0,0 +1,270 @@
+// Package storagetest is the conformance suite of call.Store, every implementation runs it in its tests.
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"test_trigger/internal/call"
+)
+
+// Run runs the suite, newStore returns an empty store for every test.
+func Run(t *testing.T, newStore func(t *testing.T) call.Store) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s call.Store)
+	}{
+		{name: "empty", test: testEmpty},
+		{name: "order", test: testOrder},
+		{name: "paused agents", test: testPausedAgents},
+		{name: "ready", test: testReady},
+		{name: "statuses", test: testStatuses},
+		{name: "remove status", test: testRemoveStatus},
+		{name: "remove expired", test: testRemoveExpired},
+		{name: "dead letters", test: testDeadLetters},
+		{name: "concurrent next", test: testConcurrentNext},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore(t))
+		})
+	}
+}
+
+// meta has every field set, so a store loses nothing on the way. Times are UTC, they are compared as values.
+func meta(id call.ID) call.Meta {
+	at := time.Unix(1709464831, 0).UTC()
+	return call.Meta{
+		PhoneNumber:    "+1555" + string(id),
+		VirtualAgentID: "agent",
+		ID:             id,
+		EnqueuedAt:     at,
+		TraceParent:    "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		CallbackURL:    "https://crm.example.com/hook",
+		Tenant:         "acme",
+		ClientID:       "crm",
+		Attempts:       1,
+		Failures:       []call.Failure{{At: at, Reason: "busy", Code: 486, Outcome: call.OutcomeBusy}},
+	}
+}
+
+func next(t *testing.T, s call.Store) (call.ID, bool) {
+	m, ok, err := s.Next(context.Background(), nil)
+	require.NoError(t, err)
+	return m.ID, ok
+}
+
+func length(t *testing.T, s call.Store) int {
+	n, err := s.QueueLength(context.Background())
+	require.NoError(t, err)
+	return n
+}
+
+func testEmpty(t *testing.T, s call.Store) {
+	_, ok := next(t, s)
+	assert.False(t, ok)
+	assert.Equal(t, 0, length(t, s))
+	_, ok, err := s.Status(context.Background(), "1")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	dead, err := s.DeadLetters(context.Background(), call.DeadLetterFilter{})
+	assert.NoError(t, err)
+	assert.Empty(t, dead)
+}
+
+func testOrder(t *testing.T, s call.Store) {
+	ctx := context.Background()
+	require.NoError(t, s.AddToQueueBack(ctx, meta("1")))
+	require.NoError(t, s.AddToQueueBack(ctx, meta("2")))
+	require.NoError(t, s.AddToQueueFront(ctx, meta("3")))
+	assert.Equal(t, 3, length(t, s))
+
+	m, ok, err := s.Next(ctx, nil)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, meta("3"), m)
+	assert.Equal(t, 2, length(t, s))
+
+	// a failed call is retried first, a call retried later goes to the end.
+	require.NoError(t, s.AddToQueueFront(ctx, m))
+	id, _ := next(t, s)
+	assert.Equal(t, call.ID("3"), id)
+	id, _ = next(t, s)
+	assert.Equal(t, call.ID("1"), id)
+	require.NoError(t, s.AddToQueueBack(ctx, meta("1")))
+	for _, expected := range []call.ID{"2", "1"} {
+		id, ok := next(t, s)
+		assert.True(t, ok)
+		assert.Equal(t, expected, id)
+	}
+	_, ok = next(t, s)
+	assert.False(t, ok)
+	assert.Equal(t, 0, length(t, s))
+}
+
+func testPausedAgents(t *testing.T, s call.Store) {
+	ctx := context.Background()
+	for _, m := range []call.Meta{{ID: "a1", VirtualAgentID: "a"}, {ID: "b1", VirtualAgentID: "b"}, {ID: "a2", VirtualAgentID: "a"}, {ID: "b2", VirtualAgentID: "b"}} {
+		require.NoError(t, s.AddToQueueBack(ctx, m))
+	}
+	take := func(paused ...string) (call.ID, bool) {
+		m, ok, err := s.Next(ctx, paused)
+		require.NoError(t, err)
+		return m.ID, ok
+	}
+	id, ok := take("a")
+	assert.True(t, ok)
+	assert.Equal(t, call.ID("b1"), id)
+	_, ok = take("a", "b")
+	assert.False(t, ok)
+
+	// calls of paused agents stay in the queue.
+	assert.Equal(t, 3, length(t, s))
+	paused, err := s.QueueLengthOf(ctx, []string{"a"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, paused)
+	paused, err = s.QueueLengthOf(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, paused)
+
+	// the agent is resumed, its calls are taken in their order.
+	for _, expected := range []call.ID{"a1", "a2", "b2"} {
+		id, ok := take()
+		assert.True(t, ok)
+		assert.Equal(t, expected, id)
+	}
+}
+
+func testReady(t *testing.T, s call.Store) {
+	require.NoError(t, s.AddToQueueBack(context.Background(), meta("1")))
+	select {
+	case <-s.Ready():
+	case <-time.After(time.Second):
+		t.Fatal("Ready isn't signaled after the call is queued")
+	}
+}
+
+func testStatuses(t *testing.T, s call.Store) {
+	ctx := context.Background()
+	retrying := call.Status{State: call.StateRetrying, Code: 486, Outcome: call.OutcomeBusy}
+	require.NoError(t, s.SaveStatus(ctx, retrying, meta("1")))
+	status, ok, err := s.Status(ctx, "1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	// the tenant is taken from meta.
+	assert.Equal(t, call.Status{State: call.StateRetrying, Code: 486, Outcome: call.OutcomeBusy, Tenant: "acme"}, status)
+
+	finished := call.Status{State: call.StateFinished, Code: 200, Outcome: call.OutcomeAnswered}
+	require.NoError(t, s.SaveStatus(ctx, finished, meta("1")))
+	status, _, err = s.Status(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, call.Status{State: call.StateFinished, Code: 200, Outcome: call.OutcomeAnswered, Tenant: "acme"}, status)
+}
+
+func testRemoveStatus(t *testing.T, s call.Store) {
+	ctx := context.Background()
+	finished := call.Status{State: call.StateFinished, Code: 200, Outcome: call.OutcomeAnswered}
+	require.NoError(t, s.SaveStatus(ctx, finished, meta("1")))
+
+	// the status was changed since it was read.
+	removed, err := s.RemoveStatus(ctx, "1", call.Status{State: call.StateQueued})
+	assert.NoError(t, err)
+	assert.False(t, removed)
+	removed, err = s.RemoveStatus(ctx, "1", finished)
+	assert.NoError(t, err)
+	assert.True(t, removed)
+	_, ok, err := s.Status(ctx, "1")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	removed, err = s.RemoveStatus(ctx, "1", finished)
+	assert.NoError(t, err)
+	assert.False(t, removed)
+}
+
+func testRemoveExpired(t *testing.T, s call.Store) {
+	ctx := context.Background()
+	now := time.Unix(1709464831, 0).UTC()
+	for i, expiresAt := range []time.Time{now.Add(-time.Second), {}, now, now.Add(time.Second)} {
+		m := meta(call.ID(fmt.Sprint(i)))
+		m.ExpiresAt = expiresAt
+		require.NoError(t, s.AddToQueueBack(ctx, m))
+	}
+	expired, err := s.RemoveExpired(ctx, now)
+	assert.NoError(t, err)
+	ids := make([]call.ID, 0)
+	for _, m := range expired {
+		ids = append(ids, m.ID)
+	}
+	assert.Equal(t, []call.ID{"0", "2"}, ids)
+	assert.Equal(t, now, expired[1].ExpiresAt)
+	assert.Equal(t, 2, length(t, s))
+	expired, err = s.RemoveExpired(ctx, now)
+	assert.NoError(t, err)
+	assert.Empty(t, expired)
+}
+
+func testDeadLetters(t *testing.T, s call.Store) {
+	ctx := context.Background()
+	deadAt := time.Unix(1709464831, 0).UTC()
+	first := call.DeadLetter{Meta: meta("1"), DeadAt: deadAt}
+	second := call.DeadLetter{Meta: meta("2"), DeadAt: deadAt.Add(time.Minute)}
+	second.VirtualAgentID = "other"
+	require.NoError(t, s.AddDeadLetter(ctx, first))
+	require.NoError(t, s.AddDeadLetter(ctx, second))
+
+	dead, err := s.DeadLetters(ctx, call.DeadLetterFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, []call.DeadLetter{first, second}, dead)
+	dead, err = s.DeadLetters(ctx, call.DeadLetterFilter{VirtualAgentID: "other"})
+	assert.NoError(t, err)
+	assert.Equal(t, []call.DeadLetter{second}, dead)
+
+	d, ok, err := s.TakeDeadLetter(ctx, "1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, first, d)
+	_, ok, err = s.TakeDeadLetter(ctx, "1")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	dead, err = s.DeadLetters(ctx, call.DeadLetterFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, []call.DeadLetter{second}, dead)
+}
+
+// testConcurrentNext checks every call is taken exactly once by concurrent workers.
+func testConcurrentNext(t *testing.T, s call.Store) {
+	ctx := context.Background()
+	const calls, workers = 200, 8
+	for i := 0; i < calls; i++ {
+		require.NoError(t, s.AddToQueueBack(ctx, call.Meta{ID: call.ID(fmt.Sprint(i))}))
+	}
+	mu := &sync.Mutex{}
+	taken := make(map[call.ID]int)
+	wg := &sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				m, ok, err := s.Next(ctx, nil)
+				if !assert.NoError(t, err) || !ok {
+					return
+				}
+				mu.Lock()
+				taken[m.ID]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, taken, calls)
+	for id, n := range taken {
+		assert.Equal(t, 1, n, id)
+	}
+}
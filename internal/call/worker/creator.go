@@ -5,7 +5,11 @@ import (
 	"sync"
 	"time"
 
+	"test_trigger/internal/call/dispatch"
 	"test_trigger/internal/logger"
+	"test_trigger/internal/metrics"
+	"test_trigger/internal/realtime"
+	"test_trigger/internal/tracing"
 )
 
 //go:generate go run github.com/golang/mock/mockgen --source=creator.go --destination=creator_mock.go --package=worker
@@ -23,13 +27,19 @@ type Create struct {
 	Logger         logger.Logger
 	ExternalCaller ExternalCaller
 	StepTime       time.Duration
+	Clock          realtime.Time
+	Metrics        *metrics.Calls
+	Tracer         *tracing.Tracer
+	Control        *dispatch.Control
+	DeadLetters    DeadLetterStorage
+	MaxAttempts    int
 }
 
-func NewCreate(limiter Limiter, storage ProcessStorage, statusStorage StatusStorage, logger logger.Logger, externalCaller ExternalCaller, stepTime time.Duration) *Create {
-	return &Create{Limiter: limiter, Storage: storage, StatusStorage: statusStorage, Logger: logger, ExternalCaller: externalCaller, StepTime: stepTime}
+func NewCreate(limiter Limiter, storage ProcessStorage, statusStorage StatusStorage, logger logger.Logger, externalCaller ExternalCaller, stepTime time.Duration, clock realtime.Time, m *metrics.Calls, tracer *tracing.Tracer, control *dispatch.Control, deadLetters DeadLetterStorage, maxAttempts int) *Create {
+	return &Create{Limiter: limiter, Storage: storage, StatusStorage: statusStorage, Logger: logger, ExternalCaller: externalCaller, StepTime: stepTime, Clock: clock, Metrics: m, Tracer: tracer, Control: control, DeadLetters: deadLetters, MaxAttempts: maxAttempts}
 }
 
 // NewWorker returns Worker interface(not structure), since it should return only specific implementation.
 func (c *Create) NewWorker() Worker {
-	return NewWorker(c.Limiter, c.Storage, c.StatusStorage, c.Logger, c.ExternalCaller, c.StepTime)
+	return NewWorker(c.Limiter, c.Storage, c.StatusStorage, c.Logger, c.ExternalCaller, c.StepTime, c.Clock, c.Metrics, c.Tracer, c.Control, c.DeadLetters, c.MaxAttempts)
 }
@@ -2,31 +2,44 @@ package worker
 
 import (
 	"context"
-	"fmt"
-	"net/http"
+	"errors"
 	"sync"
 	"time"
 
 	"test_trigger/internal/call"
+	"test_trigger/internal/call/dispatch"
+	"test_trigger/internal/call/router"
 	"test_trigger/internal/logger"
+	"test_trigger/internal/metrics"
+	"test_trigger/internal/realtime"
+	"test_trigger/internal/tracing"
 )
 
 //go:generate go run github.com/golang/mock/mockgen --source=worker.go --destination=worker_mock.go --package=worker
 
 // ProcessStorage describes methods for interaction with queue.
 type ProcessStorage interface {
-	Next(_ context.Context) (call.Meta, bool, error)
+	// Next skips calls of paused agents, they stay in the queue.
+	Next(_ context.Context, pausedAgents []string) (call.Meta, bool, error)
 	AddToQueueFront(_ context.Context, meta call.Meta) error
+	AddToQueueBack(_ context.Context, meta call.Meta) error
+	// Ready receives when the queue may have calls.
+	Ready() <-chan struct{}
 }
 
 // StatusStorage describes methods for status storage.
 type StatusStorage interface {
-	SaveStatus(_ context.Context, status int, meta call.Meta) error
+	SaveStatus(_ context.Context, status call.Status, meta call.Meta) error
+}
+
+// DeadLetterStorage keeps calls, which have failed too many times.
+type DeadLetterStorage interface {
+	AddDeadLetter(_ context.Context, deadLetter call.DeadLetter) error
 }
 
 // ExternalCaller send request to external call API.
 type ExternalCaller interface {
-	Call(ctx context.Context, phoneNumber, virtualAgentID string) (status int, err error)
+	Call(ctx context.Context, phoneNumber, virtualAgentID string) (call.Result, error)
 }
 
 // Limiter describes limiter internal implementation.
@@ -41,71 +54,220 @@ type Async struct {
 	Logger         logger.Logger
 	ExternalCaller ExternalCaller
 	StepTime       time.Duration
+	Clock          realtime.Time
+	Metrics        *metrics.Calls
+	Tracer         *tracing.Tracer
+	Control        *dispatch.Control
+	DeadLetters    DeadLetterStorage
+	MaxAttempts    int // failed attempts before the call is moved to dead letters, 0 retries forever.
 }
 
-func NewWorker(limiter Limiter, storage ProcessStorage, statusStorage StatusStorage, logger logger.Logger, externalCaller ExternalCaller, stepTime time.Duration) *Async {
-	return &Async{Limiter: limiter, Storage: storage, StatusStorage: statusStorage, Logger: logger, ExternalCaller: externalCaller, StepTime: stepTime}
+func NewWorker(limiter Limiter, storage ProcessStorage, statusStorage StatusStorage, logger logger.Logger, externalCaller ExternalCaller, stepTime time.Duration, clock realtime.Time, m *metrics.Calls, tracer *tracing.Tracer, control *dispatch.Control, deadLetters DeadLetterStorage, maxAttempts int) *Async {
+	return &Async{Limiter: limiter, Storage: storage, StatusStorage: statusStorage, Logger: logger, ExternalCaller: externalCaller, StepTime: stepTime, Clock: clock, Metrics: m, Tracer: tracer, Control: control, DeadLetters: deadLetters, MaxAttempts: maxAttempts}
 }
 
+// Step is the result of ProcessOneCall, it says when the worker should take the next call.
+type Step int
+
+const (
+	StepDone    Step = iota // the call is processed, expired or dead-lettered, take the next one right away.
+	StepEmpty               // the queue is empty or has calls of paused agents only, wait for Storage.Ready or a resumed agent.
+	StepBackoff             // the call is returned to the front(limiter, errors), wait for StepTime to not spin on it.
+)
+
 // ProcessCalls process any available calls from ProcessStorage.
+// Idle workers sleep until Storage.Ready, busy workers take the next call right after the previous one.
+// Paused workers sleep until Control is resumed, the queue is kept.
 func (a *Async) ProcessCalls(ctx context.Context, wg *sync.WaitGroup) {
 	defer wg.Done()
-	ticker := time.NewTicker(a.StepTime)
-	defer ticker.Stop()
+	a.Metrics.WorkerStarted()
+	defer a.Metrics.WorkerStopped()
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			a.processOneCall(ctx)
+		case <-a.Control.Resumed():
+		}
+		// a new call shouldn't be started after cancellation, even if the queue isn't empty.
+		if ctx.Err() != nil {
+			return
+		}
+		// taken before Next, a resume between them wakes the worker.
+		agentResumed := a.Control.AgentResumed()
+		// cancellation stops the worker between calls, the call in flight isn't aborted, it can be dialed already.
+		switch a.ProcessOneCall(context.WithoutCancel(ctx)) {
+		case StepEmpty:
+			select {
+			case <-ctx.Done():
+				return
+			case <-a.Storage.Ready():
+			case <-agentResumed:
+			}
+		case StepBackoff:
+			timer := a.Clock.NewTimer(a.StepTime)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C():
+			}
 		}
 	}
 }
 
-func (a *Async) processOneCall(ctx context.Context) {
-	val, ok, err := a.Storage.Next(ctx)
+// ProcessOneCall takes one call from the queue and processes it synchronously.
+// Exported for simulations, which drive workers step by step.
+func (a *Async) ProcessOneCall(ctx context.Context) Step {
+	val, ok, err := a.Storage.Next(ctx, a.Control.PausedAgents())
 	if err != nil {
-		a.Logger.Error(fmt.Errorf("processOneCall: %v", err))
-		return
+		a.Logger.Error("processOneCall: Next", "error", err)
+		return StepBackoff
 	}
 	if !ok {
-		return
+		return StepEmpty
 	}
+	log := a.Logger.With("call_id", string(val.ID), "virtual_agent_id", val.VirtualAgentID)
+	// the sweeper removes expired calls periodically, this one could expire after the last sweep.
+	if val.Expired(a.Clock.Now()) {
+		a.processExpired(ctx, log, val)
+		return StepDone
+	}
+	traceCtx, span := a.startSpans(ctx, val)
+	defer span.Finish()
 
-	if !a.Limiter.Allow() {
-		a.processFail(ctx, val)
-		return
+	_, limiterSpan := a.Tracer.Start(traceCtx, tracing.KindInternal, "limiter")
+	allowed := a.Limiter.Allow()
+	limiterSpan.SetAttributes("allowed", allowed)
+	limiterSpan.Finish()
+	if !allowed {
+		a.processFail(ctx, log, val)
+		return StepBackoff
 	}
 
-	status, err := a.ExternalCaller.Call(ctx, val.PhoneNumber, val.VirtualAgentID)
+	startedAt := a.Clock.Now()
+	a.Metrics.OriginateStarted(startedAt.Sub(val.EnqueuedAt))
+	callCtx, originateSpan := a.Tracer.Start(traceCtx, tracing.KindClient, "originate")
+	result, err := a.ExternalCaller.Call(callCtx, val.PhoneNumber, val.VirtualAgentID)
+	originateSpan.SetAttributes("http.status_code", result.StatusCode, "outcome", string(result.Outcome))
+	originateSpan.RecordError(err)
+	originateSpan.Finish()
+	a.Metrics.OriginateFinished(result.StatusCode, string(result.Outcome), a.Clock.Now().Sub(startedAt))
 	if err != nil {
-		a.Logger.Error(err)
-		a.processFail(ctx, val)
-		return
+		log.Error("processOneCall: Call", "error", err)
+		// the provider wasn't dialed, like a limiter denial the call isn't failing.
+		if errors.Is(err, router.ErrNoProvider) || errors.Is(err, call.ErrNotSent) {
+			a.Metrics.Retry(metrics.RetryNow)
+			a.processFail(ctx, log, val)
+			return StepBackoff
+		}
+		return a.processFailure(ctx, log, val, call.Failure{At: a.Clock.Now(), Reason: err.Error(), Code: result.StatusCode, Outcome: result.Outcome})
 	}
 
-	err = a.StatusStorage.SaveStatus(ctx, status, val)
+	retry := result.Outcome.Retry()
+	state := call.StateFinished
+	if retry != call.RetryNone {
+		state = call.StateRetrying
+	}
+	err = a.StatusStorage.SaveStatus(ctx, call.Status{State: state, Code: result.StatusCode, Outcome: result.Outcome}, val)
 	if err != nil {
-		a.Logger.Error(err)
-		a.processFail(ctx, val)
-		return
+		log.Error("processOneCall: SaveStatus", "error", err)
+		return a.processFailure(ctx, log, val, call.Failure{At: a.Clock.Now(), Reason: "save status: " + err.Error(), Code: result.StatusCode, Outcome: result.Outcome})
 	}
 
-	if status != http.StatusOK {
-		a.Logger.Info(fmt.Sprintf("Status = %v instead of 200", status))
-		a.processFail(ctx, val)
+	log.Info("originate finished", "outcome", string(result.Outcome), "status", result.StatusCode, "state", string(state))
+	switch retry {
+	case call.RetryNow:
+		// 429 is the limit of the provider, the call itself isn't failing.
+		if result.Outcome == call.OutcomeRateLimited {
+			a.Metrics.Retry(metrics.RetryNow)
+			a.processFail(ctx, log, val)
+			return StepBackoff
+		}
+		return a.processFailure(ctx, log, val, call.Failure{At: a.Clock.Now(), Reason: string(result.Outcome), Code: result.StatusCode, Outcome: result.Outcome})
+	case call.RetryLater:
+		return a.processRetryLater(ctx, log, val, call.Failure{At: a.Clock.Now(), Reason: string(result.Outcome), Code: result.StatusCode, Outcome: result.Outcome})
 	}
+	return StepDone
+}
 
-	return
+// startSpans continues the trace of the trigger request, time in the queue is recorded as a separate span.
+func (a *Async) startSpans(ctx context.Context, val call.Meta) (context.Context, *tracing.Span) {
+	if parent, err := tracing.ParseTraceParent(val.TraceParent); err == nil {
+		ctx = tracing.ContextWithSpanContext(ctx, parent)
+	}
+	ctx, span := a.Tracer.Start(ctx, tracing.KindConsumer, "process call")
+	span.SetAttributes("call_id", string(val.ID), "virtual_agent_id", val.VirtualAgentID)
+	if !val.EnqueuedAt.IsZero() {
+		_, queueSpan := a.Tracer.StartAt(ctx, tracing.KindInternal, "queue wait", val.EnqueuedAt)
+		queueSpan.Finish()
+	}
+	return ctx, span
 }
 
-func (a *Async) processFail(ctx context.Context, val call.Meta) {
+func (a *Async) processFail(ctx context.Context, log logger.Logger, val call.Meta) {
 	err := a.Storage.AddToQueueFront(ctx, val)
 	// Weak place, since it is possible to lose call there.
 	// 1. Backoff approach can help.
 	// 2. (preferable) Can be solved with different Storage approach, like in Kafka. Read message, commit message(mark as processed) after processing.
 	// Even with commit/rollback implementation, will be possible to receive an error in some implementations and [lost data]/[process twice].
 	if err != nil {
-		a.Logger.Error(fmt.Errorf("processOneCall: %v", err))
+		log.Error("processFail: AddToQueueFront", "error", err)
+	}
+}
+
+// processFailure records the failure, the call is retried from the front of the queue until MaxAttempts in a row.
+func (a *Async) processFailure(ctx context.Context, log logger.Logger, val call.Meta, failure call.Failure) Step {
+	val.Attempts++
+	val.Failures = append(val.Failures, failure)
+	if a.MaxAttempts <= 0 || val.Attempts < a.MaxAttempts {
+		a.Metrics.Retry(metrics.RetryNow)
+		a.processFail(ctx, log, val)
+		return StepBackoff
+	}
+	return a.processDeadLetter(ctx, log, val, failure)
+}
+
+// processDeadLetter moves the call, which has no attempts left, to dead letters.
+func (a *Async) processDeadLetter(ctx context.Context, log logger.Logger, val call.Meta, failure call.Failure) Step {
+	err := a.DeadLetters.AddDeadLetter(ctx, call.DeadLetter{Meta: val, DeadAt: a.Clock.Now()})
+	if err != nil {
+		// the call isn't lost, it's retried until dead letters are available.
+		log.Error("processDeadLetter: AddDeadLetter", "error", err)
+		a.Metrics.Retry(metrics.RetryNow)
+		a.processFail(ctx, log, val)
+		return StepBackoff
+	}
+	a.Metrics.DeadLettered()
+	log.Warn("call moved to dead letters", "attempts", val.Attempts, "reason", failure.Reason)
+	err = a.StatusStorage.SaveStatus(ctx, call.Status{State: call.StateFailed, Code: failure.Code, Outcome: failure.Outcome}, val)
+	if err != nil {
+		log.Error("processDeadLetter: SaveStatus", "error", err)
+	}
+	return StepDone
+}
+
+// processExpired discards the call without dialing.
+func (a *Async) processExpired(ctx context.Context, log logger.Logger, val call.Meta) {
+	a.Metrics.Expired()
+	log.Info("call expired, it isn't dialed")
+	if err := a.StatusStorage.SaveStatus(ctx, call.Status{State: call.StateExpired}, val); err != nil {
+		log.Error("processExpired: SaveStatus", "error", err)
+	}
+}
+
+// processRetryLater puts the call to the end of the queue, person should have time to become available.
+// The attempt is counted, a number, which never answers, is moved to dead letters after MaxAttempts.
+func (a *Async) processRetryLater(ctx context.Context, log logger.Logger, val call.Meta, failure call.Failure) Step {
+	val.Attempts++
+	val.Failures = append(val.Failures, failure)
+	if a.MaxAttempts > 0 && val.Attempts >= a.MaxAttempts {
+		return a.processDeadLetter(ctx, log, val, failure)
+	}
+	a.Metrics.Retry(metrics.RetryLater)
+	val.EnqueuedAt = a.Clock.Now()
+	err := a.Storage.AddToQueueBack(ctx, val)
+	if err != nil {
+		log.Error("processRetryLater: AddToQueueBack", "error", err)
 	}
+	return StepDone
 }
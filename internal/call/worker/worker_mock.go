@@ -35,6 +35,20 @@ func (m *MockProcessStorage) EXPECT() *MockProcessStorageMockRecorder {
 	return m.recorder
 }
 
+// AddToQueueBack mocks base method.
+func (m *MockProcessStorage) AddToQueueBack(arg0 context.Context, meta call.Meta) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddToQueueBack", arg0, meta)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddToQueueBack indicates an expected call of AddToQueueBack.
+func (mr *MockProcessStorageMockRecorder) AddToQueueBack(arg0, meta interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddToQueueBack", reflect.TypeOf((*MockProcessStorage)(nil).AddToQueueBack), arg0, meta)
+}
+
 // AddToQueueFront mocks base method.
 func (m *MockProcessStorage) AddToQueueFront(arg0 context.Context, meta call.Meta) error {
 	m.ctrl.T.Helper()
@@ -50,9 +64,9 @@ func (mr *MockProcessStorageMockRecorder) AddToQueueFront(arg0, meta interface{}
 }
 
 // Next mocks base method.
-func (m *MockProcessStorage) Next(arg0 context.Context) (call.Meta, bool, error) {
+func (m *MockProcessStorage) Next(arg0 context.Context, pausedAgents []string) (call.Meta, bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Next", arg0)
+	ret := m.ctrl.Call(m, "Next", arg0, pausedAgents)
 	ret0, _ := ret[0].(call.Meta)
 	ret1, _ := ret[1].(bool)
 	ret2, _ := ret[2].(error)
@@ -60,9 +74,23 @@ func (m *MockProcessStorage) Next(arg0 context.Context) (call.Meta, bool, error)
 }
 
 // Next indicates an expected call of Next.
-func (mr *MockProcessStorageMockRecorder) Next(arg0 interface{}) *gomock.Call {
+func (mr *MockProcessStorageMockRecorder) Next(arg0, pausedAgents interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Next", reflect.TypeOf((*MockProcessStorage)(nil).Next), arg0, pausedAgents)
+}
+
+// Ready mocks base method.
+func (m *MockProcessStorage) Ready() <-chan struct{} {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ready")
+	ret0, _ := ret[0].(<-chan struct{})
+	return ret0
+}
+
+// Ready indicates an expected call of Ready.
+func (mr *MockProcessStorageMockRecorder) Ready() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Next", reflect.TypeOf((*MockProcessStorage)(nil).Next), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ready", reflect.TypeOf((*MockProcessStorage)(nil).Ready))
 }
 
 // MockStatusStorage is a mock of StatusStorage interface.
@@ -89,7 +117,7 @@ func (m *MockStatusStorage) EXPECT() *MockStatusStorageMockRecorder {
 }
 
 // SaveStatus mocks base method.
-func (m *MockStatusStorage) SaveStatus(arg0 context.Context, status int, meta call.Meta) error {
+func (m *MockStatusStorage) SaveStatus(arg0 context.Context, status call.Status, meta call.Meta) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "SaveStatus", arg0, status, meta)
 	ret0, _ := ret[0].(error)
@@ -102,6 +130,43 @@ func (mr *MockStatusStorageMockRecorder) SaveStatus(arg0, status, meta interface
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveStatus", reflect.TypeOf((*MockStatusStorage)(nil).SaveStatus), arg0, status, meta)
 }
 
+// MockDeadLetterStorage is a mock of DeadLetterStorage interface.
+type MockDeadLetterStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockDeadLetterStorageMockRecorder
+}
+
+// MockDeadLetterStorageMockRecorder is the mock recorder for MockDeadLetterStorage.
+type MockDeadLetterStorageMockRecorder struct {
+	mock *MockDeadLetterStorage
+}
+
+// NewMockDeadLetterStorage creates a new mock instance.
+func NewMockDeadLetterStorage(ctrl *gomock.Controller) *MockDeadLetterStorage {
+	mock := &MockDeadLetterStorage{ctrl: ctrl}
+	mock.recorder = &MockDeadLetterStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDeadLetterStorage) EXPECT() *MockDeadLetterStorageMockRecorder {
+	return m.recorder
+}
+
+// AddDeadLetter mocks base method.
+func (m *MockDeadLetterStorage) AddDeadLetter(arg0 context.Context, deadLetter call.DeadLetter) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddDeadLetter", arg0, deadLetter)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddDeadLetter indicates an expected call of AddDeadLetter.
+func (mr *MockDeadLetterStorageMockRecorder) AddDeadLetter(arg0, deadLetter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDeadLetter", reflect.TypeOf((*MockDeadLetterStorage)(nil).AddDeadLetter), arg0, deadLetter)
+}
+
 // MockExternalCaller is a mock of ExternalCaller interface.
 type MockExternalCaller struct {
 	ctrl     *gomock.Controller
@@ -126,10 +191,10 @@ func (m *MockExternalCaller) EXPECT() *MockExternalCallerMockRecorder {
 }
 
 // Call mocks base method.
-func (m *MockExternalCaller) Call(ctx context.Context, phoneNumber, virtualAgentID string) (int, error) {
+func (m *MockExternalCaller) Call(ctx context.Context, phoneNumber, virtualAgentID string) (call.Result, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Call", ctx, phoneNumber, virtualAgentID)
-	ret0, _ := ret[0].(int)
+	ret0, _ := ret[0].(call.Result)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand"
+	"net/http"
 	"sync"
 	"testing"
 	"time"
@@ -12,7 +14,12 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"test_trigger/internal/call"
+	"test_trigger/internal/call/dispatch"
+	"test_trigger/internal/call/router"
 	"test_trigger/internal/logger"
+	"test_trigger/internal/metrics"
+	"test_trigger/internal/realtime"
+	"test_trigger/internal/tracing"
 )
 
 func TestAsync_ProcessCalls(t *testing.T) {
@@ -38,19 +45,20 @@ func TestAsync_ProcessCalls(t *testing.T) {
 				wg: &sync.WaitGroup{},
 			},
 			expectedFunc: func(ctx context.Context, cancelFunc context.CancelFunc, limiter *MockLimiter, storage *MockProcessStorage, statusStorage *MockStatusStorage, l *logger.MockLogger, caller *MockExternalCaller) {
-				storage.EXPECT().Next(ctx).Return(call.Meta{
+				storage.EXPECT().Next(ctx, nil).Return(call.Meta{
 					PhoneNumber:    "777",
 					VirtualAgentID: "aaa",
 					ID:             "1",
 				}, true, nil).Times(1)
 
 				limiter.EXPECT().Allow().Return(true).Times(1)
-				caller.EXPECT().Call(ctx, "777", "aaa").Return(200, nil).Times(1)
-				statusStorage.EXPECT().SaveStatus(ctx, 200, call.Meta{
+				caller.EXPECT().Call(ctx, "777", "aaa").Return(call.Result{StatusCode: 200, Outcome: call.OutcomeAnswered}, nil).Times(1)
+				l.EXPECT().Info("originate finished", "outcome", "answered", "status", 200, "state", "finished").Times(1)
+				statusStorage.EXPECT().SaveStatus(ctx, call.Status{State: call.StateFinished, Code: 200, Outcome: call.OutcomeAnswered}, call.Meta{
 					PhoneNumber:    "777",
 					VirtualAgentID: "aaa",
 					ID:             "1",
-				}).Return(nil).Times(1).Do(func(_ context.Context, _ int, _ call.Meta) {
+				}).Return(nil).Times(1).Do(func(_ context.Context, _ call.Status, _ call.Meta) {
 					cancelFunc()
 				},
 				)
@@ -66,15 +74,15 @@ func TestAsync_ProcessCalls(t *testing.T) {
 				wg: &sync.WaitGroup{},
 			},
 			expectedFunc: func(ctx context.Context, cancelFunc context.CancelFunc, limiter *MockLimiter, storage *MockProcessStorage, statusStorage *MockStatusStorage, l *logger.MockLogger, caller *MockExternalCaller) {
-				storage.EXPECT().Next(ctx).Return(call.Meta{
+				storage.EXPECT().Next(ctx, nil).Return(call.Meta{
 					PhoneNumber:    "777",
 					VirtualAgentID: "aaa",
 					ID:             "1",
-				}, true, errors.New("some err")).Times(1).Do(func(_ context.Context) {
+				}, true, errors.New("some err")).Times(1).Do(func(_ context.Context, _ []string) {
 					cancelFunc()
 				},
 				)
-				l.EXPECT().Error(fmt.Errorf("processOneCall: %v", errors.New("some err")))
+				l.EXPECT().Error("processOneCall: Next", "error", errors.New("some err"))
 			},
 		},
 		{
@@ -86,14 +94,15 @@ func TestAsync_ProcessCalls(t *testing.T) {
 				wg: &sync.WaitGroup{},
 			},
 			expectedFunc: func(ctx context.Context, cancelFunc context.CancelFunc, limiter *MockLimiter, storage *MockProcessStorage, statusStorage *MockStatusStorage, l *logger.MockLogger, caller *MockExternalCaller) {
-				storage.EXPECT().Next(ctx).Return(call.Meta{
+				storage.EXPECT().Next(ctx, nil).Return(call.Meta{
 					PhoneNumber:    "777",
 					VirtualAgentID: "aaa",
 					ID:             "1",
-				}, false, nil).Times(1).Do(func(_ context.Context) {
+				}, false, nil).Times(1).Do(func(_ context.Context, _ []string) {
 					cancelFunc()
 				},
 				)
+				storage.EXPECT().Ready().Return(make(chan struct{}))
 			},
 		},
 		{
@@ -105,7 +114,7 @@ func TestAsync_ProcessCalls(t *testing.T) {
 				wg: &sync.WaitGroup{},
 			},
 			expectedFunc: func(ctx context.Context, cancelFunc context.CancelFunc, limiter *MockLimiter, storage *MockProcessStorage, statusStorage *MockStatusStorage, l *logger.MockLogger, caller *MockExternalCaller) {
-				storage.EXPECT().Next(ctx).Return(call.Meta{
+				storage.EXPECT().Next(ctx, nil).Return(call.Meta{
 					PhoneNumber:    "777",
 					VirtualAgentID: "aaa",
 					ID:             "1",
@@ -130,7 +139,7 @@ func TestAsync_ProcessCalls(t *testing.T) {
 				wg: &sync.WaitGroup{},
 			},
 			expectedFunc: func(ctx context.Context, cancelFunc context.CancelFunc, limiter *MockLimiter, storage *MockProcessStorage, statusStorage *MockStatusStorage, l *logger.MockLogger, caller *MockExternalCaller) {
-				storage.EXPECT().Next(ctx).Return(call.Meta{
+				storage.EXPECT().Next(ctx, nil).Return(call.Meta{
 					PhoneNumber:    "777",
 					VirtualAgentID: "aaa",
 					ID:             "1",
@@ -144,7 +153,7 @@ func TestAsync_ProcessCalls(t *testing.T) {
 				}).Return(errors.New("some err")).Times(1).Do(func(_ context.Context, _ call.Meta) {
 					cancelFunc()
 				})
-				l.EXPECT().Error(fmt.Errorf("processOneCall: %v", errors.New("some err")))
+				l.EXPECT().Error("processFail: AddToQueueFront", "error", errors.New("some err"))
 			},
 		},
 		{
@@ -156,19 +165,21 @@ func TestAsync_ProcessCalls(t *testing.T) {
 				wg: &sync.WaitGroup{},
 			},
 			expectedFunc: func(ctx context.Context, cancelFunc context.CancelFunc, limiter *MockLimiter, storage *MockProcessStorage, statusStorage *MockStatusStorage, l *logger.MockLogger, caller *MockExternalCaller) {
-				storage.EXPECT().Next(ctx).Return(call.Meta{
+				storage.EXPECT().Next(ctx, nil).Return(call.Meta{
 					PhoneNumber:    "777",
 					VirtualAgentID: "aaa",
 					ID:             "1",
 				}, true, nil).Times(1)
 
 				limiter.EXPECT().Allow().Return(true).Times(1)
-				caller.EXPECT().Call(ctx, "777", "aaa").Return(200, errors.New("some err")).Times(1)
-				l.EXPECT().Error(errors.New("some err")).Times(1)
+				caller.EXPECT().Call(ctx, "777", "aaa").Return(call.Result{}, errors.New("some err")).Times(1)
+				l.EXPECT().Error("processOneCall: Call", "error", errors.New("some err")).Times(1)
 				storage.EXPECT().AddToQueueFront(ctx, call.Meta{
 					PhoneNumber:    "777",
 					VirtualAgentID: "aaa",
 					ID:             "1",
+					Attempts:       1,
+					Failures:       []call.Failure{{At: time.Unix(1709464831, 0), Reason: "some err"}},
 				}).Return(nil).Times(1).Do(func(_ context.Context, _ call.Meta) {
 					cancelFunc()
 				})
@@ -184,19 +195,19 @@ func TestAsync_ProcessCalls(t *testing.T) {
 				wg: &sync.WaitGroup{},
 			},
 			expectedFunc: func(ctx context.Context, cancelFunc context.CancelFunc, limiter *MockLimiter, storage *MockProcessStorage, statusStorage *MockStatusStorage, l *logger.MockLogger, caller *MockExternalCaller) {
-				storage.EXPECT().Next(ctx).Return(call.Meta{
+				storage.EXPECT().Next(ctx, nil).Return(call.Meta{
 					PhoneNumber:    "777",
 					VirtualAgentID: "aaa",
 					ID:             "1",
 				}, true, nil).Times(1)
 
 				limiter.EXPECT().Allow().Return(true).Times(1)
-				caller.EXPECT().Call(ctx, "777", "aaa").Return(200, nil).Times(1)
-				statusStorage.EXPECT().SaveStatus(ctx, 200, call.Meta{
+				caller.EXPECT().Call(ctx, "777", "aaa").Return(call.Result{StatusCode: 200, Outcome: call.OutcomeAnswered}, nil).Times(1)
+				statusStorage.EXPECT().SaveStatus(ctx, call.Status{State: call.StateFinished, Code: 200, Outcome: call.OutcomeAnswered}, call.Meta{
 					PhoneNumber:    "777",
 					VirtualAgentID: "aaa",
 					ID:             "1",
-				}).Return(errors.New("some err")).Times(1).Do(func(_ context.Context, _ int, _ call.Meta) {
+				}).Return(errors.New("some err")).Times(1).Do(func(_ context.Context, _ call.Status, _ call.Meta) {
 					cancelFunc()
 				},
 				)
@@ -204,13 +215,15 @@ func TestAsync_ProcessCalls(t *testing.T) {
 					PhoneNumber:    "777",
 					VirtualAgentID: "aaa",
 					ID:             "1",
+					Attempts:       1,
+					Failures:       []call.Failure{{At: time.Unix(1709464831, 0), Reason: "save status: some err", Code: 200, Outcome: call.OutcomeAnswered}},
 				}).Return(nil).Times(1)
-				l.EXPECT().Error(errors.New("some err")).Times(1)
+				l.EXPECT().Error("processOneCall: SaveStatus", "error", errors.New("some err")).Times(1)
 
 			},
 		},
 		{
-			name: "status != 200",
+			name: "rate limited, retry now",
 			fields: fields{
 				StepTime: time.Millisecond,
 			},
@@ -218,20 +231,19 @@ func TestAsync_ProcessCalls(t *testing.T) {
 				wg: &sync.WaitGroup{},
 			},
 			expectedFunc: func(ctx context.Context, cancelFunc context.CancelFunc, limiter *MockLimiter, storage *MockProcessStorage, statusStorage *MockStatusStorage, l *logger.MockLogger, caller *MockExternalCaller) {
-				storage.EXPECT().Next(ctx).Return(call.Meta{
+				storage.EXPECT().Next(ctx, nil).Return(call.Meta{
 					PhoneNumber:    "777",
 					VirtualAgentID: "aaa",
 					ID:             "1",
 				}, true, nil).Times(1)
-
 				limiter.EXPECT().Allow().Return(true).Times(1)
-				caller.EXPECT().Call(ctx, "777", "aaa").Return(429, nil).Times(1)
-				l.EXPECT().Info("Status = 429 instead of 200").Times(1)
-				statusStorage.EXPECT().SaveStatus(ctx, 429, call.Meta{
+				caller.EXPECT().Call(ctx, "777", "aaa").Return(call.Result{StatusCode: 429, Outcome: call.OutcomeRateLimited}, nil).Times(1)
+				l.EXPECT().Info("originate finished", "outcome", "rate_limited", "status", 429, "state", "retrying").Times(1)
+				statusStorage.EXPECT().SaveStatus(ctx, call.Status{State: call.StateRetrying, Code: 429, Outcome: call.OutcomeRateLimited}, call.Meta{
 					PhoneNumber:    "777",
 					VirtualAgentID: "aaa",
 					ID:             "1",
-				}).Return(nil).Times(1).Do(func(_ context.Context, _ int, _ call.Meta) {
+				}).Return(nil).Times(1).Do(func(_ context.Context, _ call.Status, _ call.Meta) {
 					cancelFunc()
 				},
 				)
@@ -240,7 +252,68 @@ func TestAsync_ProcessCalls(t *testing.T) {
 					VirtualAgentID: "aaa",
 					ID:             "1",
 				}).Return(nil).Times(1)
-
+			},
+		},
+		{
+			name: "busy, retry later",
+			fields: fields{
+				StepTime: time.Millisecond,
+			},
+			args: args{
+				wg: &sync.WaitGroup{},
+			},
+			expectedFunc: func(ctx context.Context, cancelFunc context.CancelFunc, limiter *MockLimiter, storage *MockProcessStorage, statusStorage *MockStatusStorage, l *logger.MockLogger, caller *MockExternalCaller) {
+				storage.EXPECT().Next(ctx, nil).Return(call.Meta{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					ID:             "1",
+				}, true, nil).Times(1)
+				limiter.EXPECT().Allow().Return(true).Times(1)
+				caller.EXPECT().Call(ctx, "777", "aaa").Return(call.Result{StatusCode: 486, Outcome: call.OutcomeBusy}, nil).Times(1)
+				l.EXPECT().Info("originate finished", "outcome", "busy", "status", 486, "state", "retrying").Times(1)
+				statusStorage.EXPECT().SaveStatus(ctx, call.Status{State: call.StateRetrying, Code: 486, Outcome: call.OutcomeBusy}, call.Meta{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					ID:             "1",
+				}).Return(nil).Times(1).Do(func(_ context.Context, _ call.Status, _ call.Meta) {
+					cancelFunc()
+				},
+				)
+				storage.EXPECT().AddToQueueBack(ctx, call.Meta{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					ID:             "1",
+					EnqueuedAt:     time.Unix(1709464831, 0), // the first call is taken right away.
+					Attempts:       1,
+					Failures:       []call.Failure{{At: time.Unix(1709464831, 0), Reason: "busy", Code: 486, Outcome: call.OutcomeBusy}},
+				}).Return(nil).Times(1)
+			},
+		},
+		{
+			name: "invalid number, no retry",
+			fields: fields{
+				StepTime: time.Millisecond,
+			},
+			args: args{
+				wg: &sync.WaitGroup{},
+			},
+			expectedFunc: func(ctx context.Context, cancelFunc context.CancelFunc, limiter *MockLimiter, storage *MockProcessStorage, statusStorage *MockStatusStorage, l *logger.MockLogger, caller *MockExternalCaller) {
+				storage.EXPECT().Next(ctx, nil).Return(call.Meta{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					ID:             "1",
+				}, true, nil).Times(1)
+				limiter.EXPECT().Allow().Return(true).Times(1)
+				caller.EXPECT().Call(ctx, "777", "aaa").Return(call.Result{StatusCode: 404, Outcome: call.OutcomeInvalidNumber}, nil).Times(1)
+				l.EXPECT().Info("originate finished", "outcome", "invalid_number", "status", 404, "state", "finished").Times(1)
+				statusStorage.EXPECT().SaveStatus(ctx, call.Status{State: call.StateFinished, Code: 404, Outcome: call.OutcomeInvalidNumber}, call.Meta{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					ID:             "1",
+				}).Return(nil).Times(1).Do(func(_ context.Context, _ call.Status, _ call.Meta) {
+					cancelFunc()
+				},
+				)
 			},
 		},
 		{
@@ -252,33 +325,35 @@ func TestAsync_ProcessCalls(t *testing.T) {
 				wg: &sync.WaitGroup{},
 			},
 			expectedFunc: func(ctx context.Context, cancelFunc context.CancelFunc, limiter *MockLimiter, storage *MockProcessStorage, statusStorage *MockStatusStorage, l *logger.MockLogger, caller *MockExternalCaller) {
-				storage.EXPECT().Next(ctx).Return(call.Meta{
+				storage.EXPECT().Next(ctx, nil).Return(call.Meta{
 					PhoneNumber:    "777",
 					VirtualAgentID: "aaa",
 					ID:             "1",
 				}, true, nil).Times(1)
 
 				limiter.EXPECT().Allow().Return(true).Times(1)
-				caller.EXPECT().Call(ctx, "777", "aaa").Return(200, nil).Times(1)
-				statusStorage.EXPECT().SaveStatus(ctx, 200, call.Meta{
+				caller.EXPECT().Call(ctx, "777", "aaa").Return(call.Result{StatusCode: 200, Outcome: call.OutcomeAnswered}, nil).Times(1)
+				l.EXPECT().Info("originate finished", "outcome", "answered", "status", 200, "state", "finished").Times(1)
+				statusStorage.EXPECT().SaveStatus(ctx, call.Status{State: call.StateFinished, Code: 200, Outcome: call.OutcomeAnswered}, call.Meta{
 					PhoneNumber:    "777",
 					VirtualAgentID: "aaa",
 					ID:             "1",
 				}).Return(nil).Times(1)
 
-				storage.EXPECT().Next(ctx).Return(call.Meta{
+				storage.EXPECT().Next(ctx, nil).Return(call.Meta{
 					PhoneNumber:    "888",
 					VirtualAgentID: "bbb",
 					ID:             "2",
 				}, true, nil).Times(1)
 
 				limiter.EXPECT().Allow().Return(true).Times(1)
-				caller.EXPECT().Call(ctx, "888", "bbb").Return(200, nil).Times(1)
-				statusStorage.EXPECT().SaveStatus(ctx, 200, call.Meta{
+				caller.EXPECT().Call(ctx, "888", "bbb").Return(call.Result{StatusCode: 200, Outcome: call.OutcomeAnswered}, nil).Times(1)
+				l.EXPECT().Info("originate finished", "outcome", "answered", "status", 200, "state", "finished").Times(1)
+				statusStorage.EXPECT().SaveStatus(ctx, call.Status{State: call.StateFinished, Code: 200, Outcome: call.OutcomeAnswered}, call.Meta{
 					PhoneNumber:    "888",
 					VirtualAgentID: "bbb",
 					ID:             "2",
-				}).Return(nil).Times(1).Do(func(_ context.Context, _ int, _ call.Meta) {
+				}).Return(nil).Times(1).Do(func(_ context.Context, _ call.Status, _ call.Meta) {
 					cancelFunc()
 				},
 				)
@@ -293,6 +368,9 @@ func TestAsync_ProcessCalls(t *testing.T) {
 			statusStorage := NewMockStatusStorage(ctrl)
 			l := logger.NewMockLogger(ctrl)
 			caller := NewMockExternalCaller(ctrl)
+			clock := realtime.NewFake(time.Unix(1709464831, 0))
+			// child loggers are checked in TestAsync_ProcessOneCall_Metrics.
+			l.EXPECT().With("call_id", gomock.Any(), "virtual_agent_id", gomock.Any()).Return(l).AnyTimes()
 			a := &Async{
 				Limiter:        limiter,
 				Storage:        storage,
@@ -300,20 +378,273 @@ func TestAsync_ProcessCalls(t *testing.T) {
 				Logger:         l,
 				ExternalCaller: caller,
 				StepTime:       tt.fields.StepTime,
+				Clock:          clock,
 			}
 			ctx, cancelFunc := context.WithCancel(context.Background())
 			defer cancelFunc()
 			tt.args.ctx = ctx
 			if tt.expectedFunc != nil {
-				tt.expectedFunc(tt.args.ctx, cancelFunc, limiter, storage, statusStorage, l, caller)
+				tt.expectedFunc(context.WithoutCancel(tt.args.ctx), cancelFunc, limiter, storage, statusStorage, l, caller)
 			}
 			tt.args.wg.Add(1)
-			a.ProcessCalls(tt.args.ctx, tt.args.wg)
+			done := make(chan struct{})
+			go func() {
+				// deferred, since gomock failure exits the goroutine.
+				defer close(done)
+				a.ProcessCalls(tt.args.ctx, tt.args.wg)
+			}()
+			// every case cancels ctx during the first call, the worker shouldn't wait after it.
+			<-done
 			tt.args.wg.Wait()
 		})
 	}
 }
 
+func TestAsync_ProcessCalls_Wait(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	storage := NewMockProcessStorage(ctrl)
+	l := logger.NewMockLogger(ctrl)
+	clock := realtime.NewFake(time.Unix(1709464831, 0))
+	a := &Async{Storage: storage, Logger: l, StepTime: time.Second, Clock: clock}
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+	testErr := errors.New("test")
+
+	ready := make(chan struct{})
+	waiting := make(chan struct{}, 1)
+	storage.EXPECT().Ready().Return(ready).Do(func() { waiting <- struct{}{} }).AnyTimes()
+	gomock.InOrder(
+		storage.EXPECT().Next(gomock.Any(), nil).Return(call.Meta{}, false, nil),
+		storage.EXPECT().Next(gomock.Any(), nil).Return(call.Meta{}, false, testErr),
+		storage.EXPECT().Next(gomock.Any(), nil).Return(call.Meta{}, false, nil).Do(func(_ context.Context, _ []string) { cancelFunc() }),
+	)
+	l.EXPECT().Error("processOneCall: Next", "error", testErr)
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.ProcessCalls(ctx, wg)
+	}()
+	// the queue is empty, the worker sleeps until Ready without a timer.
+	<-waiting
+	assert.Equal(t, 0, clock.Waiters())
+	ready <- struct{}{}
+	// Next failed, the worker backs off for StepTime.
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	<-done
+	wg.Wait()
+}
+
+func TestAsync_ProcessCalls_Paused(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	storage := NewMockProcessStorage(ctrl)
+	l := logger.NewMockLogger(ctrl)
+	control := dispatch.NewControl(nil)
+	control.Pause()
+	control.PauseAgent("aaa")
+	a := &Async{Storage: storage, Logger: l, StepTime: time.Second, Clock: realtime.NewFake(time.Unix(1709464831, 0)), Control: control}
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	waiting := make(chan struct{}, 1)
+	// calls of the paused agent are skipped by the storage, they stay in the queue.
+	gomock.InOrder(
+		storage.EXPECT().Next(gomock.Any(), []string{"aaa"}).Return(call.Meta{}, false, nil),
+		storage.EXPECT().Next(gomock.Any(), nil).Return(call.Meta{}, false, nil).Do(func(_ context.Context, _ []string) { cancelFunc() }),
+	)
+	storage.EXPECT().Ready().Return(make(chan struct{})).Do(func() { waiting <- struct{}{} }).Times(2)
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.ProcessCalls(ctx, wg)
+	}()
+	// mocks fail on Next, if the worker doesn't wait for Resume.
+	control.Resume()
+	// the idle worker is woken by the resumed agent, not by Ready.
+	<-waiting
+	control.ResumeAgent("aaa")
+	<-done
+	wg.Wait()
+}
+
+func TestAsync_ProcessOneCall_Expired(t *testing.T) {
+	now := time.Unix(1709464831, 0)
+	testErr := errors.New("test")
+	for _, saveErr := range []error{nil, testErr} {
+		ctrl := gomock.NewController(t)
+		storage := NewMockProcessStorage(ctrl)
+		statusStorage := NewMockStatusStorage(ctrl)
+		l := logger.NewMockLogger(ctrl)
+		m := metrics.NewCalls(metrics.NewRegistry())
+		// the limiter and the caller aren't used, mocks fail otherwise.
+		a := NewWorker(NewMockLimiter(ctrl), storage, statusStorage, l, NewMockExternalCaller(ctrl), time.Second, realtime.NewFake(now), m, nil, nil, nil, 0)
+
+		meta := call.Meta{PhoneNumber: "777", VirtualAgentID: "aaa", ID: "1", ExpiresAt: now}
+		storage.EXPECT().Next(gomock.Any(), nil).Return(meta, true, nil)
+		l.EXPECT().With("call_id", "1", "virtual_agent_id", "aaa").Return(l)
+		l.EXPECT().Info("call expired, it isn't dialed")
+		statusStorage.EXPECT().SaveStatus(gomock.Any(), call.Status{State: call.StateExpired}, meta).Return(saveErr)
+		if saveErr != nil {
+			l.EXPECT().Error("processExpired: SaveStatus", "error", saveErr)
+		}
+
+		assert.Equal(t, StepDone, a.ProcessOneCall(context.Background()))
+		assert.Equal(t, uint64(1), m.Expirations.Value())
+	}
+}
+
+func TestAsync_processFailure(t *testing.T) {
+	now := time.Unix(1709464831, 0)
+	testErr := errors.New("test")
+	failure := call.Failure{At: now, Reason: "provider_error", Code: 500, Outcome: call.OutcomeProviderError}
+	old := call.Failure{At: now.Add(-time.Minute), Reason: "connection refused"}
+	tests := []struct {
+		name         string
+		maxAttempts  int
+		meta         call.Meta
+		expectedFunc func(storage *MockProcessStorage, statusStorage *MockStatusStorage, deadLetters *MockDeadLetterStorage, l *logger.MockLogger)
+		expectedStep Step
+	}{
+		{
+			name:        "retry from the front",
+			maxAttempts: 3,
+			meta:        call.Meta{ID: "1", Attempts: 1, Failures: []call.Failure{old}},
+			expectedFunc: func(storage *MockProcessStorage, statusStorage *MockStatusStorage, deadLetters *MockDeadLetterStorage, l *logger.MockLogger) {
+				storage.EXPECT().AddToQueueFront(gomock.Any(), call.Meta{ID: "1", Attempts: 2, Failures: []call.Failure{old, failure}}).Return(nil)
+			},
+			expectedStep: StepBackoff,
+		},
+		{
+			name:        "max attempts, dead letter",
+			maxAttempts: 3,
+			meta:        call.Meta{ID: "1", Attempts: 2, Failures: []call.Failure{old}},
+			expectedFunc: func(storage *MockProcessStorage, statusStorage *MockStatusStorage, deadLetters *MockDeadLetterStorage, l *logger.MockLogger) {
+				dead := call.Meta{ID: "1", Attempts: 3, Failures: []call.Failure{old, failure}}
+				deadLetters.EXPECT().AddDeadLetter(gomock.Any(), call.DeadLetter{Meta: dead, DeadAt: now}).Return(nil)
+				l.EXPECT().Warn("call moved to dead letters", "attempts", 3, "reason", "provider_error")
+				statusStorage.EXPECT().SaveStatus(gomock.Any(), call.Status{State: call.StateFailed, Code: 500, Outcome: call.OutcomeProviderError}, dead).Return(testErr)
+				l.EXPECT().Error("processDeadLetter: SaveStatus", "error", testErr)
+			},
+			expectedStep: StepDone,
+		},
+		{
+			name:        "dead letters fail, retry",
+			maxAttempts: 1,
+			meta:        call.Meta{ID: "1"},
+			expectedFunc: func(storage *MockProcessStorage, statusStorage *MockStatusStorage, deadLetters *MockDeadLetterStorage, l *logger.MockLogger) {
+				deadLetters.EXPECT().AddDeadLetter(gomock.Any(), gomock.Any()).Return(testErr)
+				l.EXPECT().Error("processDeadLetter: AddDeadLetter", "error", testErr)
+				storage.EXPECT().AddToQueueFront(gomock.Any(), call.Meta{ID: "1", Attempts: 1, Failures: []call.Failure{failure}}).Return(nil)
+			},
+			expectedStep: StepBackoff,
+		},
+		{
+			name:        "no max attempts, retry forever",
+			maxAttempts: 0,
+			meta:        call.Meta{ID: "1", Attempts: 100},
+			expectedFunc: func(storage *MockProcessStorage, statusStorage *MockStatusStorage, deadLetters *MockDeadLetterStorage, l *logger.MockLogger) {
+				storage.EXPECT().AddToQueueFront(gomock.Any(), call.Meta{ID: "1", Attempts: 101, Failures: []call.Failure{failure}}).Return(nil)
+			},
+			expectedStep: StepBackoff,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			storage := NewMockProcessStorage(ctrl)
+			statusStorage := NewMockStatusStorage(ctrl)
+			deadLetters := NewMockDeadLetterStorage(ctrl)
+			l := logger.NewMockLogger(ctrl)
+			tt.expectedFunc(storage, statusStorage, deadLetters, l)
+			a := &Async{Storage: storage, StatusStorage: statusStorage, DeadLetters: deadLetters, MaxAttempts: tt.maxAttempts, Logger: l, Clock: realtime.NewFake(now)}
+			assert.Equal(t, tt.expectedStep, a.processFailure(context.Background(), l, tt.meta, failure))
+		})
+	}
+}
+
+func TestAsync_processRetryLater(t *testing.T) {
+	now := time.Unix(1709464831, 0)
+	testErr := errors.New("test")
+	failure := call.Failure{At: now, Reason: "no_answer", Code: 480, Outcome: call.OutcomeNoAnswer}
+	tests := []struct {
+		name         string
+		maxAttempts  int
+		meta         call.Meta
+		expectedFunc func(storage *MockProcessStorage, statusStorage *MockStatusStorage, deadLetters *MockDeadLetterStorage, l *logger.MockLogger)
+	}{
+		{
+			name:        "retry from the back",
+			maxAttempts: 3,
+			meta:        call.Meta{ID: "1", Attempts: 1},
+			expectedFunc: func(storage *MockProcessStorage, statusStorage *MockStatusStorage, deadLetters *MockDeadLetterStorage, l *logger.MockLogger) {
+				storage.EXPECT().AddToQueueBack(gomock.Any(), call.Meta{ID: "1", EnqueuedAt: now, Attempts: 2, Failures: []call.Failure{failure}}).Return(testErr)
+				l.EXPECT().Error("processRetryLater: AddToQueueBack", "error", testErr)
+			},
+		},
+		{
+			name:        "max attempts, dead letter",
+			maxAttempts: 3,
+			meta:        call.Meta{ID: "1", Attempts: 2},
+			expectedFunc: func(storage *MockProcessStorage, statusStorage *MockStatusStorage, deadLetters *MockDeadLetterStorage, l *logger.MockLogger) {
+				dead := call.Meta{ID: "1", Attempts: 3, Failures: []call.Failure{failure}}
+				deadLetters.EXPECT().AddDeadLetter(gomock.Any(), call.DeadLetter{Meta: dead, DeadAt: now}).Return(nil)
+				l.EXPECT().Warn("call moved to dead letters", "attempts", 3, "reason", "no_answer")
+				statusStorage.EXPECT().SaveStatus(gomock.Any(), call.Status{State: call.StateFailed, Code: 480, Outcome: call.OutcomeNoAnswer}, dead).Return(nil)
+			},
+		},
+		{
+			name:        "no max attempts, retry forever",
+			maxAttempts: 0,
+			meta:        call.Meta{ID: "1", Attempts: 100},
+			expectedFunc: func(storage *MockProcessStorage, statusStorage *MockStatusStorage, deadLetters *MockDeadLetterStorage, l *logger.MockLogger) {
+				storage.EXPECT().AddToQueueBack(gomock.Any(), call.Meta{ID: "1", EnqueuedAt: now, Attempts: 101, Failures: []call.Failure{failure}}).Return(nil)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			storage := NewMockProcessStorage(ctrl)
+			statusStorage := NewMockStatusStorage(ctrl)
+			deadLetters := NewMockDeadLetterStorage(ctrl)
+			l := logger.NewMockLogger(ctrl)
+			tt.expectedFunc(storage, statusStorage, deadLetters, l)
+			a := &Async{Storage: storage, StatusStorage: statusStorage, DeadLetters: deadLetters, MaxAttempts: tt.maxAttempts, Logger: l, Clock: realtime.NewFake(now)}
+			assert.Equal(t, StepDone, a.processRetryLater(context.Background(), l, tt.meta, failure))
+		})
+	}
+}
+
+func TestAsync_ProcessOneCall_NotDialed(t *testing.T) {
+	for _, callErr := range []error{router.ErrNoProvider, fmt.Errorf("client call: make request: %w: refused", call.ErrNotSent)} {
+		ctrl := gomock.NewController(t)
+		storage := NewMockProcessStorage(ctrl)
+		limiter := NewMockLimiter(ctrl)
+		caller := NewMockExternalCaller(ctrl)
+		l := logger.NewMockLogger(ctrl)
+		m := metrics.NewCalls(metrics.NewRegistry())
+		// dead letters aren't used, max attempts aren't reached by calls, which weren't dialed.
+		a := NewWorker(limiter, storage, NewMockStatusStorage(ctrl), l, caller, time.Second, realtime.NewFake(time.Unix(1709464831, 0)), m, nil, nil, NewMockDeadLetterStorage(ctrl), 1)
+
+		meta := call.Meta{PhoneNumber: "777", VirtualAgentID: "aaa", ID: "1"}
+		storage.EXPECT().Next(gomock.Any(), nil).Return(meta, true, nil)
+		l.EXPECT().With("call_id", "1", "virtual_agent_id", "aaa").Return(l)
+		limiter.EXPECT().Allow().Return(true)
+		caller.EXPECT().Call(gomock.Any(), "777", "aaa").Return(call.Result{}, callErr)
+		l.EXPECT().Error("processOneCall: Call", "error", callErr)
+		// the attempt isn't counted.
+		storage.EXPECT().AddToQueueFront(gomock.Any(), meta).Return(nil)
+
+		assert.Equal(t, StepBackoff, a.ProcessOneCall(context.Background()))
+	}
+}
+
 // TODO add tests.
 func TestAsync_processFail(t *testing.T) {
 	type fields struct {
@@ -343,13 +674,13 @@ func TestAsync_processFail(t *testing.T) {
 				ExternalCaller: tt.fields.ExternalCaller,
 				StepTime:       tt.fields.StepTime,
 			}
-			a.processFail(tt.args.ctx, tt.args.val)
+			a.processFail(tt.args.ctx, tt.fields.Logger, tt.args.val)
 		})
 	}
 }
 
 // TODO add tests or hide behind a new interface.
-func TestAsync_processOneCall(t *testing.T) {
+func TestAsync_ProcessOneCall(t *testing.T) {
 	type fields struct {
 		Limiter        Limiter
 		Storage        ProcessStorage
@@ -376,11 +707,100 @@ func TestAsync_processOneCall(t *testing.T) {
 				ExternalCaller: tt.fields.ExternalCaller,
 				StepTime:       tt.fields.StepTime,
 			}
-			a.processOneCall(tt.args.ctx)
+			a.ProcessOneCall(tt.args.ctx)
 		})
 	}
 }
 
+func TestAsync_ProcessOneCall_Metrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	limiter := NewMockLimiter(ctrl)
+	storage := NewMockProcessStorage(ctrl)
+	statusStorage := NewMockStatusStorage(ctrl)
+	l := logger.NewMockLogger(ctrl)
+	caller := NewMockExternalCaller(ctrl)
+	clock := realtime.NewFake(time.Unix(1709464831, 0))
+	registry := metrics.NewRegistry()
+	m := metrics.NewCalls(registry)
+	a := NewWorker(limiter, storage, statusStorage, l, caller, time.Second, clock, m, nil, nil, nil, 0)
+
+	ctx := context.Background()
+	meta := call.Meta{PhoneNumber: "777", VirtualAgentID: "aaa", ID: "1", EnqueuedAt: clock.Now().Add(-3 * time.Second)}
+	result := call.Result{StatusCode: http.StatusTooManyRequests, Outcome: call.OutcomeRateLimited}
+	storage.EXPECT().Next(ctx, nil).Return(meta, true, nil)
+	limiter.EXPECT().Allow().Return(true)
+	caller.EXPECT().Call(ctx, "777", "aaa").DoAndReturn(func(_ context.Context, _, _ string) (call.Result, error) {
+		assert.Equal(t, int64(1), m.InFlight.Value())
+		clock.Advance(2 * time.Second)
+		return result, nil
+	})
+	statusStorage.EXPECT().SaveStatus(ctx, call.Status{State: call.StateRetrying, Code: result.StatusCode, Outcome: result.Outcome}, meta).Return(nil)
+	l.EXPECT().With("call_id", "1", "virtual_agent_id", "aaa").Return(l)
+	l.EXPECT().Info("originate finished", "outcome", "rate_limited", "status", 429, "state", "retrying")
+	storage.EXPECT().AddToQueueFront(ctx, meta).Return(nil)
+
+	a.ProcessOneCall(ctx)
+
+	ao := assert.New(t)
+	ao.Equal(int64(0), m.InFlight.Value())
+	ao.Equal(uint64(1), m.Originate.Value("429"))
+	ao.Equal(uint64(1), m.Outcomes.Value(string(call.OutcomeRateLimited)))
+	ao.Equal(uint64(1), m.RateLimited.Value())
+	ao.Equal(uint64(1), m.Retries.Value(metrics.RetryNow))
+	ao.Equal(uint64(1), m.QueueWait.Count())
+	ao.Contains(registry.String(), "originate_latency_seconds_sum 2\n")
+}
+
+type spanRecorder struct {
+	spans []*tracing.Span
+}
+
+func (r *spanRecorder) ExportSpan(span *tracing.Span) {
+	r.spans = append(r.spans, span)
+}
+
+func TestAsync_ProcessOneCall_Tracing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	limiter := NewMockLimiter(ctrl)
+	storage := NewMockProcessStorage(ctrl)
+	statusStorage := NewMockStatusStorage(ctrl)
+	l := logger.NewMockLogger(ctrl)
+	caller := NewMockExternalCaller(ctrl)
+	clock := realtime.NewFake(time.Unix(1709464831, 0))
+	rec := &spanRecorder{}
+	tracer := tracing.NewTracer(rec, clock, rand.New(rand.NewSource(1)))
+	a := NewWorker(limiter, storage, statusStorage, l, caller, time.Second, clock, nil, tracer, nil, nil, 0)
+
+	ctx := context.Background()
+	parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	meta := call.Meta{PhoneNumber: "777", VirtualAgentID: "aaa", ID: "1", EnqueuedAt: clock.Now().Add(-3 * time.Second), TraceParent: parent}
+	storage.EXPECT().Next(ctx, nil).Return(meta, true, nil)
+	l.EXPECT().With("call_id", "1", "virtual_agent_id", "aaa").Return(l)
+	limiter.EXPECT().Allow().Return(true)
+	caller.EXPECT().Call(gomock.Any(), "777", "aaa").DoAndReturn(func(callCtx context.Context, _, _ string) (call.Result, error) {
+		// http_wrapper injects this span context into the originate request.
+		sc := tracing.SpanContextFromContext(callCtx)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+		clock.Advance(2 * time.Second)
+		return call.Result{StatusCode: http.StatusOK, Outcome: call.OutcomeAnswered}, nil
+	})
+	statusStorage.EXPECT().SaveStatus(ctx, gomock.Any(), meta).Return(nil)
+	l.EXPECT().Info("originate finished", "outcome", "answered", "status", 200, "state", "finished")
+
+	a.ProcessOneCall(ctx)
+
+	names := make([]string, 0, len(rec.spans))
+	for _, span := range rec.spans {
+		names = append(names, span.Name)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.Context.TraceID.String())
+	}
+	assert.Equal(t, []string{"queue wait", "limiter", "originate", "process call"}, names)
+	assert.Equal(t, 3*time.Second, rec.spans[0].End.Sub(rec.spans[0].Start))
+	assert.Equal(t, 2*time.Second, rec.spans[2].End.Sub(rec.spans[2].Start))
+	assert.Equal(t, "00f067aa0ba902b7", rec.spans[3].Parent.String())
+	assert.Equal(t, rec.spans[3].Context.SpanID, rec.spans[2].Parent)
+}
+
 func TestNewWorker(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	limiter := NewMockLimiter(ctrl)
@@ -388,6 +808,7 @@ func TestNewWorker(t *testing.T) {
 	statusStorage := NewMockStatusStorage(ctrl)
 	l := logger.NewMockLogger(ctrl)
 	caller := NewMockExternalCaller(ctrl)
+	clock := realtime.NewFake(time.Unix(1709464831, 0))
 	expected := &Async{
 		Limiter:        limiter,
 		Storage:        storage,
@@ -395,7 +816,8 @@ func TestNewWorker(t *testing.T) {
 		Logger:         l,
 		ExternalCaller: caller,
 		StepTime:       time.Second,
+		Clock:          clock,
 	}
 
-	assert.Equal(t, expected, NewWorker(limiter, storage, statusStorage, l, caller, time.Second))
+	assert.Equal(t, expected, NewWorker(limiter, storage, statusStorage, l, caller, time.Second, clock, nil, nil, nil, nil, 0))
 }
@@ -0,0 +1,493 @@
+package config
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"test_trigger/internal/auth"
+	"test_trigger/internal/call"
+	"test_trigger/internal/logger"
+)
+
+// EnvPrefix is the prefix of environment variables: max_workers is TRIGGER_MAX_WORKERS.
+const EnvPrefix = "TRIGGER_"
+
+// Config is shared by trigger cmds. Every field is set by yaml key, TRIGGER_ env variable and flag:
+// max_workers, TRIGGER_MAX_WORKERS, -max-workers. Durations are strings like "500ms" everywhere.
+// Fields with reload tag are applied on reload, others need restart. Fields with secret tag are masked in Diff.
+// Maps are "key=value,key=value" in env and flags, lists are JSON.
+type Config struct {
+	Port                   string             `yaml:"port" help:"listen address"`
+	MaxWorkers             int                `yaml:"max_workers" help:"number of workers" reload:"true"`
+	WorkerStepTime         time.Duration      `yaml:"worker_step_time" help:"worker backoff after the limiter denies a call or an error"`
+	MaxAttempts            int                `yaml:"max_attempts" help:"failed attempts before the call is moved to dead letters, busy/no answer count too, 0 retries forever"`
+	MaxQueueDepth          int                `yaml:"max_queue_depth" help:"new calls are rejected with 503 if the queue is longer, 0 is unlimited"`
+	AdmissionMaxWait       time.Duration      `yaml:"admission_max_wait" help:"new calls are rejected with 503 if they would be dialed later, 0 is unlimited"`
+	CallTTL                time.Duration      `yaml:"call_ttl" help:"calls without expires_at and ttl aren't dialed later, 0 is never"`
+	ExpirySweepInterval    time.Duration      `yaml:"expiry_sweep_interval" help:"how often expired calls are removed from the queue"`
+	StoragePath            string             `yaml:"storage_path" help:"SQLite file of the queue, statuses and dead letters, memory if empty"`
+	StorageLeaseTimeout    time.Duration      `yaml:"storage_lease_timeout" help:"a call taken by the worker, which died, is taken again after it"`
+	StoragePollInterval    time.Duration      `yaml:"storage_poll_interval" help:"how often idle workers check SQLite for expired leases"`
+	Autoscale              bool               `yaml:"autoscale" help:"resize the pool between min_workers and max_workers"`
+	MinWorkers             int                `yaml:"min_workers" help:"min number of workers for autoscale"`
+	AutoscaleInterval      time.Duration      `yaml:"autoscale_interval" help:"how often the pool is resized"`
+	AutoscaleLatency       time.Duration      `yaml:"autoscale_latency" help:"expected originate latency until calls are finished"`
+	PoolRecheckTime        time.Duration      `yaml:"pool_recheck_time" help:"how often the queue is checked on shutdown"`
+	PoolCloseTimeout       time.Duration      `yaml:"pool_close_timeout" help:"max time to process the queue on shutdown"`
+	ReadHeaderTimeout      time.Duration      `yaml:"read_header_timeout" help:"http server read header timeout"`
+	ReadTimeout            time.Duration      `yaml:"read_timeout" help:"http server read timeout"`
+	WriteTimeout           time.Duration      `yaml:"write_timeout" help:"http server write timeout"`
+	ShutdownTimeout        time.Duration      `yaml:"shutdown_timeout" help:"http server shutdown timeout"`
+	ReadinessDrainDelay    time.Duration      `yaml:"readiness_drain_delay" help:"time between readiness false and http server stop"`
+	BreakerGrace           time.Duration      `yaml:"breaker_grace" help:"readiness fails if all providers are unavailable longer"`
+	LimiterSize            uint64             `yaml:"limiter_size" help:"limiter window in seconds" reload:"true"`
+	LimiterLimit           uint64             `yaml:"limiter_limit" help:"originate requests per limiter window" reload:"true"`
+	RouterFailureThreshold int                `yaml:"router_failure_threshold" help:"failures in a row before provider cooldown" reload:"true"`
+	RouterCooldown         time.Duration      `yaml:"router_cooldown" help:"provider cooldown after failures" reload:"true"`
+	RateLimitBackoff       time.Duration      `yaml:"rate_limit_backoff" help:"provider backoff after 429" reload:"true"`
+	OriginateURL           string             `yaml:"originate_url" help:"originate call endpoint of the provider" reload:"true"`
+	OriginateTimeout       time.Duration      `yaml:"originate_timeout" help:"originate request timeout, depends on real call duration" reload:"true"`
+	Providers              []Provider         `yaml:"providers" help:"originate providers with own limiters, one provider of originate_url and limiter_size/limit if empty" reload:"true"`
+	AgentPreferences       map[string]string  `yaml:"agent_preferences" help:"provider name by virtual agent id, other agents are routed by weight" reload:"true"`
+	OutcomeRules           []call.OutcomeRule `yaml:"outcome_rules" help:"provider responses to outcomes, the first matched rule wins, the built-in rules are used if empty" reload:"true"`
+	APIClients             []auth.Client      `yaml:"api_clients" help:"API clients with key hashes and allowed virtual agents, auth is disabled if empty" secret:"true"`
+	AuthMaxSkew            time.Duration      `yaml:"auth_max_skew" help:"max difference between the signature timestamp and the server time"`
+	QuotaRate              float64            `yaml:"quota_rate" help:"/trigger requests per second of every API client, 0 is unlimited"`
+	QuotaBurst             int                `yaml:"quota_burst" help:"requests above quota_rate after idle time, quota_rate rounded up if 0"`
+	QuotaMaxQueued         int                `yaml:"quota_max_queued" help:"not completed calls of every API client, 0 is unlimited"`
+	QuotaRetryAfter        time.Duration      `yaml:"quota_retry_after" help:"Retry-After, when quota_max_queued is exceeded"`
+	WebhookSecret          string             `yaml:"webhook_secret" help:"signing secret of webhooks for tenants without own secret" secret:"true"`
+	WebhookTenantSecrets   map[string]string  `yaml:"webhook_tenant_secrets" help:"signing secrets of webhooks by tenant" secret:"true"`
+	WebhookTimeout         time.Duration      `yaml:"webhook_timeout" help:"webhook request timeout"`
+	WebhookMaxAttempts     int                `yaml:"webhook_max_attempts" help:"webhook delivery attempts before it's dropped"`
+	WebhookBackoff         time.Duration      `yaml:"webhook_backoff" help:"first webhook retry delay, doubled after every attempt"`
+	WebhookMaxBackoff      time.Duration      `yaml:"webhook_max_backoff" help:"max webhook retry delay"`
+	WebhookLogSize         int                `yaml:"webhook_log_size" help:"delivery attempts kept for /admin/webhooks"`
+	WebhookSenders         int                `yaml:"webhook_senders" help:"webhooks sent concurrently"`
+	WebhookFlushTimeout    time.Duration      `yaml:"webhook_flush_timeout" help:"time to send pending webhooks on shutdown, after the pool is closed"`
+	StatusMaxAge           time.Duration      `yaml:"status_max_age" help:"statuses of completed calls are evicted after it, 0 keeps them"`
+	StatusMaxCount         int                `yaml:"status_max_count" help:"statuses of completed calls kept, the oldest are evicted, 0 is unlimited"`
+	StatusEvictionInterval time.Duration      `yaml:"status_eviction_interval" help:"how often statuses are evicted"`
+	StatusArchive          string             `yaml:"status_archive" help:"file or http(s) URL, evicted statuses are written there, dropped if empty"`
+	StatusArchiveTimeout   time.Duration      `yaml:"status_archive_timeout" help:"request timeout of the status archive URL"`
+	EventsLogSize          int                `yaml:"events_log_size" help:"status events kept for Last-Event-ID resumption"`
+	EventsBuffer           int                `yaml:"events_buffer" help:"events buffered per stream, slower clients are disconnected"`
+	EventsHeartbeat        time.Duration      `yaml:"events_heartbeat" help:"comment sent to idle streams, keeps proxies from closing them"`
+	LogLevel               string             `yaml:"log_level" help:"debug, info, warn or error"`
+	LogFormat              string             `yaml:"log_format" help:"json or logfmt"`
+	ServiceName            string             `yaml:"service_name" help:"service name of spans"`
+	OTLPTracesURL          string             `yaml:"otlp_traces_url" help:"e.g. http://localhost:4318/v1/traces, spans are dropped if empty"`
+	OTLPFlushInterval      time.Duration      `yaml:"otlp_flush_interval" help:"how often spans are sent"`
+	OTLPMaxSpans           int                `yaml:"otlp_max_spans" help:"max buffered spans"`
+	OTLPTimeout            time.Duration      `yaml:"otlp_timeout" help:"otlp request timeout"`
+}
+
+// Outcomes returns outcome_rules, the built-in rules if they are empty.
+func (c Config) Outcomes() []call.OutcomeRule {
+	if len(c.OutcomeRules) == 0 {
+		return call.DefaultOutcomeRules()
+	}
+	return c.OutcomeRules
+}
+
+// Provider is one originate provider of the router.
+type Provider struct {
+	Name         string `yaml:"name" json:"name"`
+	URL          string `yaml:"url" json:"url"`
+	Weight       int    `yaml:"weight" json:"weight"`
+	LimiterSize  uint64 `yaml:"limiter_size" json:"limiter_size"` // window in seconds.
+	LimiterLimit uint64 `yaml:"limiter_limit" json:"limiter_limit"`
+}
+
+// DefaultProvider is the name of the provider of originate_url, when providers are empty.
+const DefaultProvider = "default"
+
+// Default returns values, which were constants of cmds.
+func Default() Config {
+	return Config{
+		Port:                   ":8328",
+		MaxWorkers:             30,
+		WorkerStepTime:         500 * time.Millisecond,
+		MaxAttempts:            10,
+		MaxQueueDepth:          100000,
+		CallTTL:                24 * time.Hour,
+		ExpirySweepInterval:    10 * time.Second,
+		StorageLeaseTimeout:    15 * time.Minute,
+		StoragePollInterval:    time.Second,
+		MinWorkers:             1,
+		AutoscaleInterval:      5 * time.Second,
+		AutoscaleLatency:       5 * time.Second,
+		PoolRecheckTime:        3 * time.Second,
+		PoolCloseTimeout:       10 * time.Minute,
+		ReadHeaderTimeout:      20 * time.Second,
+		ReadTimeout:            time.Minute,
+		WriteTimeout:           2 * time.Minute,
+		ShutdownTimeout:        30 * time.Second,
+		ReadinessDrainDelay:    5 * time.Second,
+		BreakerGrace:           5 * time.Minute,
+		LimiterSize:            10,
+		LimiterLimit:           25,
+		RouterFailureThreshold: 5,
+		RouterCooldown:         30 * time.Second,
+		RateLimitBackoff:       30 * time.Second, // provider introduces ~30s backoff after 429.
+		OriginateURL:           "https://google.com",
+		OriginateTimeout:       10 * time.Minute,
+		AuthMaxSkew:            5 * time.Minute,
+		QuotaRetryAfter:        10 * time.Second,
+		WebhookTimeout:         10 * time.Second,
+		WebhookMaxAttempts:     8,
+		WebhookBackoff:         time.Second,
+		WebhookMaxBackoff:      5 * time.Minute,
+		WebhookLogSize:         1000,
+		WebhookSenders:         4,
+		WebhookFlushTimeout:    10 * time.Second,
+		StatusMaxAge:           24 * time.Hour,
+		StatusMaxCount:         1000000,
+		StatusEvictionInterval: time.Minute,
+		StatusArchiveTimeout:   10 * time.Second,
+		EventsLogSize:          10000,
+		EventsBuffer:           256,
+		EventsHeartbeat:        15 * time.Second,
+		LogLevel:               "info",
+		LogFormat:              logger.FormatJSON,
+		ServiceName:            "test_trigger",
+		OTLPFlushInterval:      5 * time.Second,
+		OTLPMaxSpans:           10000,
+		OTLPTimeout:            10 * time.Second,
+	}
+}
+
+// Load merges defaults, file, environment and flags, the later source wins.
+// The file is set by -config or TRIGGER_CONFIG, YAML and JSON are accepted since JSON is YAML.
+// flag.ErrHelp is returned for -h.
+func Load(args []string, getenv func(string) string, output io.Writer) (Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("trigger", flag.ContinueOnError)
+	fs.SetOutput(output)
+	configPath := fs.String("config", getenv(EnvPrefix+"CONFIG"), "path to YAML or JSON config file")
+	flags := make(map[string]*rawFlag)
+	for _, f := range fields(&cfg) {
+		flags[f.name] = &rawFlag{isBool: f.value.Kind() == reflect.Bool}
+		fs.Var(flags[f.name], f.flag, fmt.Sprintf("%s (%s%s, default %v)", f.help, EnvPrefix, strings.ToUpper(f.name), f.value.Interface()))
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if *configPath != "" {
+		data, err := os.ReadFile(*configPath)
+		if err != nil {
+			return Config{}, fmt.Errorf("read config: %v", err)
+		}
+		if err = Parse(data, &cfg); err != nil {
+			return Config{}, fmt.Errorf("parse config %s: %v", *configPath, err)
+		}
+	}
+
+	for _, f := range fields(&cfg) {
+		env := EnvPrefix + strings.ToUpper(f.name)
+		if raw := getenv(env); raw != "" {
+			if err := f.set(raw); err != nil {
+				return Config{}, fmt.Errorf("env %s: %v", env, err)
+			}
+		}
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, f := range fields(&cfg) {
+		if set[f.flag] {
+			if err := f.set(flags[f.name].value); err != nil {
+				return Config{}, fmt.Errorf("flag -%s: %v", f.flag, err)
+			}
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// rawFlag keeps the flag value to set it after the file and env, bool flags are given without a value like -autoscale.
+type rawFlag struct {
+	value  string
+	isBool bool
+}
+
+func (r *rawFlag) String() string {
+	if r == nil {
+		return ""
+	}
+	return r.value
+}
+
+func (r *rawFlag) Set(value string) error {
+	r.value = value
+	return nil
+}
+
+func (r *rawFlag) IsBoolFlag() bool {
+	return r.isBool
+}
+
+// Parse decodes YAML or JSON into cfg, unknown keys are errors, usually they are typos.
+func Parse(data []byte, cfg *Config) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// Validate returns all problems at once, not only the first one.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	check(c.Port != "", "port can't be empty")
+	check(c.MaxWorkers > 0, "max_workers should be greater than 0, got %v", c.MaxWorkers)
+	check(c.MaxAttempts >= 0, "max_attempts can't be negative, got %v", c.MaxAttempts)
+	check(c.MaxQueueDepth >= 0, "max_queue_depth can't be negative, got %v", c.MaxQueueDepth)
+	check(c.MinWorkers > 0 && c.MinWorkers <= c.MaxWorkers, "min_workers should be in [1, max_workers], got %v", c.MinWorkers)
+	check(c.LimiterSize > 0, "limiter_size should be greater than 0")
+	check(c.LimiterLimit > 0, "limiter_limit should be greater than 0")
+	check(c.RouterFailureThreshold > 0, "router_failure_threshold should be greater than 0, got %v", c.RouterFailureThreshold)
+	names := make(map[string]bool)
+	for i, p := range c.Providers {
+		check(p.Name != "", "providers[%d]: name can't be empty", i)
+		check(!names[p.Name], "providers[%d]: name %q is duplicated", i, p.Name)
+		check(isHTTPURL(p.URL), "providers[%d]: url should be http(s) URL, got %q", i, p.URL)
+		check(p.Weight > 0, "providers[%d]: weight should be greater than 0, got %v", i, p.Weight)
+		check(p.LimiterSize > 0 && p.LimiterLimit > 0, "providers[%d]: limiter_size and limiter_limit should be greater than 0", i)
+		names[p.Name] = true
+	}
+	if len(c.Providers) == 0 {
+		names[DefaultProvider] = true
+	}
+	for agent, name := range c.AgentPreferences {
+		check(names[name], "agent_preferences: provider %q of %q is unknown", name, agent)
+	}
+	for i, rule := range c.OutcomeRules {
+		check(rule.MinStatus >= 100 && rule.MinStatus <= rule.MaxStatus && rule.MaxStatus <= 599,
+			"outcome_rules[%d]: min_status and max_status should be in [100, 599] in order, got %v and %v", i, rule.MinStatus, rule.MaxStatus)
+		check(rule.Outcome.Known(), "outcome_rules[%d]: outcome %q is unknown", i, rule.Outcome)
+	}
+	ids, hashes := make(map[string]bool), make(map[string]bool)
+	for i, client := range c.APIClients {
+		hash, err := hex.DecodeString(client.KeySHA256)
+		check(client.ID != "", "api_clients[%d]: id can't be empty", i)
+		check(!ids[client.ID], "api_clients[%d]: id %q is duplicated", i, client.ID)
+		check(err == nil && len(hash) == 32, "api_clients[%d]: key_sha256 should be hex SHA-256 of the key", i)
+		check(!hashes[strings.ToLower(client.KeySHA256)], "api_clients[%d]: key_sha256 is duplicated", i)
+		check(client.Rate >= 0 && client.Burst >= 0 && client.MaxQueued >= 0, "api_clients[%d]: rate, burst and max_queued can't be negative", i)
+		ids[client.ID], hashes[strings.ToLower(client.KeySHA256)] = true, true
+	}
+	check(c.QuotaRate >= 0 && c.QuotaBurst >= 0 && c.QuotaMaxQueued >= 0, "quota_rate, quota_burst and quota_max_queued can't be negative")
+	check(c.WebhookMaxAttempts > 0, "webhook_max_attempts should be greater than 0, got %v", c.WebhookMaxAttempts)
+	check(c.WebhookLogSize >= 0, "webhook_log_size can't be negative, got %v", c.WebhookLogSize)
+	check(c.WebhookSenders > 0, "webhook_senders should be greater than 0, got %v", c.WebhookSenders)
+	check(c.WebhookBackoff <= c.WebhookMaxBackoff, "webhook_backoff can't be greater than webhook_max_backoff, got %v", c.WebhookBackoff)
+	check(c.StatusMaxCount >= 0, "status_max_count can't be negative, got %v", c.StatusMaxCount)
+	check(c.EventsLogSize >= 0, "events_log_size can't be negative, got %v", c.EventsLogSize)
+	check(c.EventsBuffer > 0, "events_buffer should be greater than 0, got %v", c.EventsBuffer)
+	check(c.OTLPMaxSpans > 0, "otlp_max_spans should be greater than 0, got %v", c.OTLPMaxSpans)
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"worker_step_time", c.WorkerStepTime},
+		{"expiry_sweep_interval", c.ExpirySweepInterval},
+		{"storage_lease_timeout", c.StorageLeaseTimeout},
+		{"storage_poll_interval", c.StoragePollInterval},
+		{"autoscale_interval", c.AutoscaleInterval},
+		{"autoscale_latency", c.AutoscaleLatency},
+		{"pool_recheck_time", c.PoolRecheckTime},
+		{"pool_close_timeout", c.PoolCloseTimeout},
+		{"read_header_timeout", c.ReadHeaderTimeout},
+		{"read_timeout", c.ReadTimeout},
+		{"write_timeout", c.WriteTimeout},
+		{"shutdown_timeout", c.ShutdownTimeout},
+		{"originate_timeout", c.OriginateTimeout},
+		{"auth_max_skew", c.AuthMaxSkew},
+		{"quota_retry_after", c.QuotaRetryAfter},
+		{"webhook_timeout", c.WebhookTimeout},
+		{"webhook_backoff", c.WebhookBackoff},
+		{"webhook_flush_timeout", c.WebhookFlushTimeout},
+		{"status_eviction_interval", c.StatusEvictionInterval},
+		{"status_archive_timeout", c.StatusArchiveTimeout},
+		{"events_heartbeat", c.EventsHeartbeat},
+		{"otlp_flush_interval", c.OTLPFlushInterval},
+		{"otlp_timeout", c.OTLPTimeout},
+	} {
+		check(d.value > 0, "%s should be greater than 0, got %v", d.name, d.value)
+	}
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"admission_max_wait", c.AdmissionMaxWait},
+		{"call_ttl", c.CallTTL},
+		{"status_max_age", c.StatusMaxAge},
+		{"readiness_drain_delay", c.ReadinessDrainDelay},
+		{"breaker_grace", c.BreakerGrace},
+		{"router_cooldown", c.RouterCooldown},
+		{"rate_limit_backoff", c.RateLimitBackoff},
+	} {
+		check(d.value >= 0, "%s can't be negative, got %v", d.name, d.value)
+	}
+	// a call is dialed twice, if its lease expires during originate.
+	check(c.StoragePath == "" || c.StorageLeaseTimeout > c.OriginateTimeout,
+		"storage_lease_timeout should be greater than originate_timeout, got %v and %v", c.StorageLeaseTimeout, c.OriginateTimeout)
+	check(isHTTPURL(c.OriginateURL), "originate_url should be http(s) URL, got %q", c.OriginateURL)
+	check(c.OTLPTracesURL == "" || isHTTPURL(c.OTLPTracesURL), "otlp_traces_url should be empty or http(s) URL, got %q", c.OTLPTracesURL)
+	_, err := logger.ParseLevel(c.LogLevel)
+	check(err == nil, "log_level: %v", err)
+	check(c.LogFormat == logger.FormatJSON || c.LogFormat == logger.FormatLogfmt, "log_format should be json or logfmt, got %q", c.LogFormat)
+	return errors.Join(errs...)
+}
+
+func isHTTPURL(input string) bool {
+	u, err := url.Parse(input)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Change is one changed field, values are formatted like in flags.
+type Change struct {
+	Key        string `json:"key"`
+	Old        string `json:"old"`
+	New        string `json:"new"`
+	Reloadable bool   `json:"reloadable"`
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Key, c.Old, c.New)
+}
+
+// Diff returns changed fields in the order of Config.
+func Diff(old, new Config) []Change {
+	oldFields, newFields := fields(&old), fields(&new)
+	res := make([]Change, 0)
+	for i, f := range oldFields {
+		oldValue, newValue := fmt.Sprint(f.value.Interface()), fmt.Sprint(newFields[i].value.Interface())
+		if oldValue != newValue {
+			if f.secret {
+				oldValue, newValue = masked, masked
+			}
+			res = append(res, Change{Key: f.name, Old: oldValue, New: newValue, Reloadable: f.reloadable})
+		}
+	}
+	return res
+}
+
+const masked = "***"
+
+// Reload returns c with reloadable fields from loaded, other fields need restart and are kept.
+func (c Config) Reload(loaded Config) Config {
+	res := c
+	loadedFields := fields(&loaded)
+	for i, f := range fields(&res) {
+		if f.reloadable {
+			f.value.Set(loadedFields[i].value)
+		}
+	}
+	return res
+}
+
+type field struct {
+	name       string // yaml key.
+	flag       string
+	help       string
+	reloadable bool
+	secret     bool
+	value      reflect.Value
+}
+
+func fields(cfg *Config) []field {
+	v := reflect.ValueOf(cfg).Elem()
+	res := make([]field, 0, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Tag.Get("yaml")
+		res = append(res, field{
+			name:       name,
+			flag:       strings.ReplaceAll(name, "_", "-"),
+			help:       v.Type().Field(i).Tag.Get("help"),
+			reloadable: v.Type().Field(i).Tag.Get("reload") == "true",
+			secret:     v.Type().Field(i).Tag.Get("secret") == "true",
+			value:      v.Field(i),
+		})
+	}
+	return res
+}
+
+var (
+	durationType  = reflect.TypeOf(time.Duration(0))
+	stringMapType = reflect.TypeOf(map[string]string{})
+)
+
+func (f field) set(raw string) error {
+	switch {
+	case f.value.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(d))
+	case f.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		f.value.SetBool(b)
+	case f.value.Kind() == reflect.String:
+		f.value.SetString(raw)
+	case f.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(n))
+	case f.value.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		f.value.SetFloat(n)
+	case f.value.Kind() == reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.value.SetUint(n)
+	case f.value.Kind() == reflect.Slice:
+		return json.Unmarshal([]byte(raw), f.value.Addr().Interface())
+	case f.value.Type() == stringMapType:
+		m := make(map[string]string)
+		for _, pair := range strings.Split(raw, ",") {
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok || key == "" {
+				return fmt.Errorf("%q should be key=value", pair)
+			}
+			m[key] = value
+		}
+		f.value.Set(reflect.ValueOf(m))
+	default:
+		return fmt.Errorf("unsupported type %v", f.value.Type())
+	}
+	return nil
+}
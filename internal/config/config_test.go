@@ -0,0 +1,245 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"test_trigger/internal/auth"
+	"test_trigger/internal/call"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, os.WriteFile(yamlPath, []byte("max_workers: 10\nworker_step_time: 100ms\noriginate_url: http://file\nlimiter_limit: 50\n"), 0o600))
+	jsonPath := filepath.Join(dir, "config.json")
+	assert.NoError(t, os.WriteFile(jsonPath, []byte(`{"port": ":9000", "router_cooldown": "1m"}`), 0o600))
+	rulesPath := filepath.Join(dir, "rules.yaml")
+	assert.NoError(t, os.WriteFile(rulesPath, []byte("outcome_rules:\n  - {min_status: 200, max_status: 299, body_contains: machine, outcome: voicemail}\n"), 0o600))
+	typoPath := filepath.Join(dir, "typo.yaml")
+	assert.NoError(t, os.WriteFile(typoPath, []byte("max_worker: 10\n"), 0o600))
+
+	tests := []struct {
+		name         string
+		args         []string
+		env          map[string]string
+		expectedFunc func(cfg *Config)
+		expectedErr  string
+	}{
+		{
+			name:         "defaults",
+			expectedFunc: func(cfg *Config) {},
+		},
+		{
+			name: "yaml file",
+			args: []string{"-config", yamlPath},
+			expectedFunc: func(cfg *Config) {
+				cfg.MaxWorkers = 10
+				cfg.WorkerStepTime = 100 * time.Millisecond
+				cfg.OriginateURL = "http://file"
+				cfg.LimiterLimit = 50
+			},
+		},
+		{
+			name: "json file from env",
+			env:  map[string]string{"TRIGGER_CONFIG": jsonPath},
+			expectedFunc: func(cfg *Config) {
+				cfg.Port = ":9000"
+				cfg.RouterCooldown = time.Minute
+			},
+		},
+		{
+			name: "env overrides file, flags override env",
+			args: []string{"-config", yamlPath, "-max-workers", "3", "-log-format", "logfmt", "-autoscale", "-quota-rate", "2.5"},
+			env:  map[string]string{"TRIGGER_MAX_WORKERS": "5", "TRIGGER_ORIGINATE_URL": "http://env", "TRIGGER_LIMITER_LIMIT": "7"},
+			expectedFunc: func(cfg *Config) {
+				cfg.MaxWorkers = 3
+				cfg.WorkerStepTime = 100 * time.Millisecond
+				cfg.OriginateURL = "http://env"
+				cfg.LimiterLimit = 7
+				cfg.LogFormat = "logfmt"
+				cfg.Autoscale = true
+				cfg.QuotaRate = 2.5
+			},
+		},
+		{
+			name: "map from env",
+			env:  map[string]string{"TRIGGER_WEBHOOK_TENANT_SECRETS": "a=s1,b=s=2"},
+			expectedFunc: func(cfg *Config) {
+				cfg.WebhookTenantSecrets = map[string]string{"a": "s1", "b": "s=2"}
+			},
+		},
+		{
+			name: "list from env",
+			env:  map[string]string{"TRIGGER_API_CLIENTS": `[{"id":"crm","key_sha256":"` + auth.HashKey("key") + `","virtual_agents":["a"]}]`},
+			expectedFunc: func(cfg *Config) {
+				cfg.APIClients = []auth.Client{{ID: "crm", KeySHA256: auth.HashKey("key"), VirtualAgents: []string{"a"}}}
+			},
+		},
+		{
+			name: "providers from env",
+			env: map[string]string{
+				"TRIGGER_PROVIDERS":         `[{"name":"a","url":"http://a","weight":2,"limiter_size":10,"limiter_limit":25},{"name":"b","url":"http://b","weight":1,"limiter_size":1,"limiter_limit":3}]`,
+				"TRIGGER_AGENT_PREFERENCES": "agent=b",
+			},
+			expectedFunc: func(cfg *Config) {
+				cfg.Providers = []Provider{{Name: "a", URL: "http://a", Weight: 2, LimiterSize: 10, LimiterLimit: 25}, {Name: "b", URL: "http://b", Weight: 1, LimiterSize: 1, LimiterLimit: 3}}
+				cfg.AgentPreferences = map[string]string{"agent": "b"}
+			},
+		},
+		{
+			name: "providers validation",
+			env: map[string]string{
+				"TRIGGER_PROVIDERS":         `[{"name":"a","url":"http://a","weight":1,"limiter_size":10,"limiter_limit":25},{"name":"a","url":"a","limiter_size":10}]`,
+				"TRIGGER_AGENT_PREFERENCES": "agent=default",
+			},
+			expectedErr: "providers[1]: name \"a\" is duplicated\nproviders[1]: url should be http(s) URL, got \"a\"\nproviders[1]: weight should be greater than 0, got 0\n" +
+				"providers[1]: limiter_size and limiter_limit should be greater than 0\nagent_preferences: provider \"default\" of \"agent\" is unknown",
+		},
+		{
+			name:         "preference of the default provider",
+			env:          map[string]string{"TRIGGER_AGENT_PREFERENCES": "agent=default"},
+			expectedFunc: func(cfg *Config) { cfg.AgentPreferences = map[string]string{"agent": "default"} },
+		},
+		{
+			name: "outcome rules from file",
+			args: []string{"-config", rulesPath},
+			expectedFunc: func(cfg *Config) {
+				cfg.OutcomeRules = []call.OutcomeRule{{MinStatus: 200, MaxStatus: 299, BodyContains: "machine", Outcome: call.OutcomeVoicemail}}
+			},
+		},
+		{
+			name:        "outcome rules validation",
+			env:         map[string]string{"TRIGGER_OUTCOME_RULES": `[{"min_status":300,"max_status":200,"outcome":"answered"},{"min_status":200,"max_status":200,"outcome":"answerd"}]`},
+			expectedErr: "outcome_rules[0]: min_status and max_status should be in [100, 599] in order, got 300 and 200\noutcome_rules[1]: outcome \"answerd\" is unknown",
+		},
+		{
+			name:        "api clients validation",
+			env:         map[string]string{"TRIGGER_API_CLIENTS": `[{"id":"crm","key_sha256":"` + auth.HashKey("key") + `"},{"id":"crm","key_sha256":"` + auth.HashKey("key") + `"},{"key_sha256":"key"}]`},
+			expectedErr: "api_clients[1]: id \"crm\" is duplicated\napi_clients[1]: key_sha256 is duplicated\napi_clients[2]: id can't be empty\napi_clients[2]: key_sha256 should be hex SHA-256 of the key",
+		},
+		{
+			name:        "bad map flag",
+			args:        []string{"-webhook-tenant-secrets", "a"},
+			expectedErr: `flag -webhook-tenant-secrets: "a" should be key=value`,
+		},
+		{
+			name:        "unknown key in file",
+			args:        []string{"-config", typoPath},
+			expectedErr: "parse config " + typoPath + ": yaml: unmarshal errors:\n  line 1: field max_worker not found in type config.Config",
+		},
+		{
+			name:        "missing file",
+			args:        []string{"-config", filepath.Join(dir, "missing.yaml")},
+			expectedErr: "read config: open " + filepath.Join(dir, "missing.yaml") + ": no such file or directory",
+		},
+		{
+			name:        "bad env",
+			env:         map[string]string{"TRIGGER_WORKER_STEP_TIME": "fast"},
+			expectedErr: `env TRIGGER_WORKER_STEP_TIME: time: invalid duration "fast"`,
+		},
+		{
+			name:        "bad flag",
+			args:        []string{"-limiter-size", "-1"},
+			expectedErr: `flag -limiter-size: strconv.ParseUint: parsing "-1": invalid syntax`,
+		},
+		{
+			name: "bool flag overrides env",
+			args: []string{"-autoscale=false"},
+			env:  map[string]string{"TRIGGER_AUTOSCALE": "true"},
+			expectedFunc: func(cfg *Config) {
+				cfg.Autoscale = false
+			},
+		},
+		{
+			name:        "bad bool flag",
+			args:        []string{"-autoscale=maybe"},
+			expectedErr: `flag -autoscale: strconv.ParseBool: parsing "maybe": invalid syntax`,
+		},
+		{
+			name:        "unknown flag",
+			args:        []string{"-max-worker", "1"},
+			expectedErr: "flag provided but not defined: -max-worker",
+		},
+		{
+			name:        "storage lease shorter than originate",
+			args:        []string{"-storage-path", "calls.db", "-storage-lease-timeout", "1m", "-originate-timeout", "1m"},
+			expectedErr: "storage_lease_timeout should be greater than originate_timeout, got 1m0s and 1m0s",
+		},
+		{
+			name: "validation",
+			args: []string{"-max-workers", "0", "-originate-url", "google.com", "-log-level", "trace", "-shutdown-timeout", "0s"},
+			expectedErr: "max_workers should be greater than 0, got 0\nmin_workers should be in [1, max_workers], got 1\nshutdown_timeout should be greater than 0, got 0s\n" +
+				"originate_url should be http(s) URL, got \"google.com\"\nlog_level: unknown log level \"trace\"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string { return tt.env[key] }
+			cfg, err := Load(tt.args, getenv, io.Discard)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			expected := Default()
+			tt.expectedFunc(&expected)
+			assert.Equal(t, expected, cfg)
+		})
+	}
+}
+
+func TestLoad_Help(t *testing.T) {
+	_, err := Load([]string{"-h"}, func(string) string { return "" }, io.Discard)
+	assert.ErrorIs(t, err, flag.ErrHelp)
+}
+
+func TestDefault_Valid(t *testing.T) {
+	assert.NoError(t, Default().Validate())
+}
+
+func TestDiff(t *testing.T) {
+	old := Default()
+	new := Default()
+	assert.Empty(t, Diff(old, new))
+
+	new.MaxWorkers = 10
+	new.Port = ":9000"
+	new.RouterCooldown = time.Minute
+	new.WebhookSecret = "secret"
+	changes := Diff(old, new)
+	assert.Equal(t, []Change{
+		{Key: "port", Old: ":8328", New: ":9000"},
+		{Key: "max_workers", Old: "30", New: "10", Reloadable: true},
+		{Key: "router_cooldown", Old: "30s", New: "1m0s", Reloadable: true},
+		{Key: "webhook_secret", Old: "***", New: "***"},
+	}, changes)
+	assert.Equal(t, "max_workers: 30 -> 10", changes[1].String())
+}
+
+func TestConfig_Reload(t *testing.T) {
+	running := Default()
+	loaded := Default()
+	loaded.MaxWorkers = 10
+	loaded.OriginateURL = "http://new"
+	loaded.Port = ":9000"
+
+	expected := Default()
+	expected.MaxWorkers = 10
+	expected.OriginateURL = "http://new"
+	assert.Equal(t, expected, running.Reload(loaded))
+	assert.Equal(t, Default(), running)
+}
+
+func TestConfig_Outcomes(t *testing.T) {
+	cfg := Default()
+	assert.Equal(t, call.DefaultOutcomeRules(), cfg.Outcomes())
+	cfg.OutcomeRules = []call.OutcomeRule{{MinStatus: 200, MaxStatus: 200, Outcome: call.OutcomeAnswered}}
+	assert.Equal(t, cfg.OutcomeRules, cfg.Outcomes())
+}
@@ -0,0 +1,194 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"test_trigger/internal/call"
+	"test_trigger/internal/metrics"
+	"test_trigger/internal/realtime"
+)
+
+//go:generate go run github.com/golang/mock/mockgen --source=events.go --destination=events_mock.go --package=events
+
+type StatusStorage interface {
+	SaveStatus(_ context.Context, status call.Status, meta call.Meta) error
+	Status(_ context.Context, id call.ID) (call.Status, bool, error)
+}
+
+// Event is a state transition of the call. IDs grow by one, they are used as Last-Event-ID.
+type Event struct {
+	ID             uint64       `json:"id"`
+	CallID         string       `json:"call_id"`
+	VirtualAgentID string       `json:"virtual_agent_id"`
+	State          call.State   `json:"state"`
+	Code           int          `json:"code,omitempty"`
+	Outcome        call.Outcome `json:"outcome,omitempty"`
+	At             time.Time    `json:"at"`
+	Tenant         string       `json:"-"`
+}
+
+// Filter selects events of subscription, empty fields match everything.
+type Filter struct {
+	CallID         string
+	VirtualAgentID string
+	Tenant         string // set for authenticated clients, events of other tenants aren't streamed.
+}
+
+func (f Filter) Match(e Event) bool {
+	return (f.CallID == "" || f.CallID == e.CallID) && (f.VirtualAgentID == "" || f.VirtualAgentID == e.VirtualAgentID) &&
+		(f.Tenant == "" || f.Tenant == e.Tenant)
+}
+
+// Subscription receives events until it's closed by Unsubscribe or dropped, since it didn't keep up.
+type Subscription struct {
+	filter Filter
+	events chan Event
+}
+
+func (s *Subscription) C() <-chan Event {
+	return s.events
+}
+
+// Broker keeps the last events for resumption and fans out new ones to subscribers.
+// Publish never blocks: subscriber with full buffer is dropped, the client reconnects with Last-Event-ID
+// and gets missed events from the log.
+type Broker struct {
+	bufferSize int
+	clock      realtime.Time
+	metrics    *metrics.Calls
+
+	mu          *sync.Mutex
+	seq         uint64
+	log         []Event // ring buffer, next is the oldest event when it's full.
+	next        int
+	logSize     int
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+func NewBroker(logSize, bufferSize int, clock realtime.Time, m *metrics.Calls) *Broker {
+	return &Broker{
+		bufferSize:  bufferSize,
+		clock:       clock,
+		metrics:     m,
+		mu:          &sync.Mutex{},
+		log:         make([]Event, 0, logSize),
+		logSize:     logSize,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+func (b *Broker) Publish(status call.Status, meta call.Meta) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	event := Event{
+		ID:             b.seq,
+		CallID:         string(meta.ID),
+		VirtualAgentID: meta.VirtualAgentID,
+		State:          status.State,
+		Code:           status.Code,
+		Outcome:        status.Outcome,
+		At:             b.clock.Now(),
+		Tenant:         meta.Tenant,
+	}
+	b.record(event)
+	for s := range b.subscribers {
+		if !s.filter.Match(event) {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+			delete(b.subscribers, s)
+			close(s.events)
+			b.metrics.StreamDropped()
+		}
+	}
+}
+
+func (b *Broker) record(event Event) {
+	if b.logSize <= 0 {
+		return
+	}
+	if len(b.log) < b.logSize {
+		b.log = append(b.log, event)
+		return
+	}
+	b.log[b.next] = event
+	b.next = (b.next + 1) % b.logSize
+}
+
+// Subscribe returns events after lastID from the log and the subscription for new ones, nothing is missed between them.
+// Events, which were pushed out of the log, are skipped. lastID from the previous run of the process is ignored,
+// the whole log is returned then.
+func (b *Broker) Subscribe(filter Filter, lastID uint64) (*Subscription, []Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if lastID > b.seq {
+		lastID = 0
+	}
+	backlog := make([]Event, 0)
+	for i := range b.log {
+		event := b.log[(b.next+i)%len(b.log)]
+		if event.ID > lastID && filter.Match(event) {
+			backlog = append(backlog, event)
+		}
+	}
+	s := &Subscription{filter: filter, events: make(chan Event, b.bufferSize)}
+	if b.closed {
+		// the server is shutting down, the stream ends after the backlog.
+		close(s.events)
+		return s, backlog
+	}
+	b.subscribers[s] = struct{}{}
+	return s, backlog
+}
+
+func (b *Broker) Unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[s]; ok {
+		delete(b.subscribers, s)
+		close(s.events)
+	}
+}
+
+// Close closes all subscriptions and the new ones, streams are finished, so they don't block the server shutdown.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subscribers {
+		delete(b.subscribers, s)
+		close(s.events)
+	}
+}
+
+// Subscribers returns the number of open streams.
+func (b *Broker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// Recorder saves statuses and publishes them as events.
+type Recorder struct {
+	StatusStorage
+	broker *Broker
+}
+
+func NewRecorder(statusStorage StatusStorage, broker *Broker) *Recorder {
+	return &Recorder{StatusStorage: statusStorage, broker: broker}
+}
+
+// SaveStatus publishes only saved statuses, so /calls/{id} never lags behind the stream.
+func (r *Recorder) SaveStatus(ctx context.Context, status call.Status, meta call.Meta) error {
+	if err := r.StatusStorage.SaveStatus(ctx, status, meta); err != nil {
+		return err
+	}
+	r.broker.Publish(status, meta)
+	return nil
+}
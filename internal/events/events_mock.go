@@ -0,0 +1,66 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: events.go
+
+// Package events is a generated GoMock package.
+package events
+
+import (
+	context "context"
+	reflect "reflect"
+	call "test_trigger/internal/call"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockStatusStorage is a mock of StatusStorage interface.
+type MockStatusStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockStatusStorageMockRecorder
+}
+
+// MockStatusStorageMockRecorder is the mock recorder for MockStatusStorage.
+type MockStatusStorageMockRecorder struct {
+	mock *MockStatusStorage
+}
+
+// NewMockStatusStorage creates a new mock instance.
+func NewMockStatusStorage(ctrl *gomock.Controller) *MockStatusStorage {
+	mock := &MockStatusStorage{ctrl: ctrl}
+	mock.recorder = &MockStatusStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStatusStorage) EXPECT() *MockStatusStorageMockRecorder {
+	return m.recorder
+}
+
+// SaveStatus mocks base method.
+func (m *MockStatusStorage) SaveStatus(arg0 context.Context, status call.Status, meta call.Meta) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveStatus", arg0, status, meta)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveStatus indicates an expected call of SaveStatus.
+func (mr *MockStatusStorageMockRecorder) SaveStatus(arg0, status, meta interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveStatus", reflect.TypeOf((*MockStatusStorage)(nil).SaveStatus), arg0, status, meta)
+}
+
+// Status mocks base method.
+func (m *MockStatusStorage) Status(arg0 context.Context, id call.ID) (call.Status, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Status", arg0, id)
+	ret0, _ := ret[0].(call.Status)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Status indicates an expected call of Status.
+func (mr *MockStatusStorageMockRecorder) Status(arg0, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Status", reflect.TypeOf((*MockStatusStorage)(nil).Status), arg0, id)
+}
@@ -0,0 +1,127 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"test_trigger/internal/call"
+	"test_trigger/internal/metrics"
+	"test_trigger/internal/realtime"
+)
+
+func ids(events []Event) []uint64 {
+	res := make([]uint64, 0, len(events))
+	for _, e := range events {
+		res = append(res, e.ID)
+	}
+	return res
+}
+
+func TestBroker_Subscribe(t *testing.T) {
+	now := time.Unix(1709464831, 0)
+	b := NewBroker(3, 10, realtime.NewFake(now), nil)
+	for i, agent := range []string{"a", "b", "a", "b"} {
+		b.Publish(call.Status{State: call.StateQueued}, call.Meta{ID: call.ID(string(rune('1' + i))), VirtualAgentID: agent})
+	}
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		lastID   uint64
+		expected []uint64
+	}{
+		// the first event is pushed out of the log.
+		{"all", Filter{}, 0, []uint64{2, 3, 4}},
+		{"after last id", Filter{}, 2, []uint64{3, 4}},
+		{"by agent", Filter{VirtualAgentID: "b"}, 0, []uint64{2, 4}},
+		{"by call", Filter{CallID: "3"}, 0, []uint64{3}},
+		{"up to date", Filter{}, 4, []uint64{}},
+		{"id of previous run", Filter{}, 100, []uint64{2, 3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, backlog := b.Subscribe(tt.filter, tt.lastID)
+			defer b.Unsubscribe(s)
+			assert.Equal(t, tt.expected, ids(backlog))
+		})
+	}
+	assert.Equal(t, 0, b.Subscribers())
+}
+
+func TestBroker_Publish(t *testing.T) {
+	now := time.Unix(1709464831, 0)
+	m := metrics.NewCalls(metrics.NewRegistry())
+	b := NewBroker(10, 2, realtime.NewFake(now), m)
+	all, _ := b.Subscribe(Filter{}, 0)
+	agent, _ := b.Subscribe(Filter{VirtualAgentID: "a"}, 0)
+	assert.Equal(t, 2, b.Subscribers())
+
+	b.Publish(call.Status{State: call.StateFinished, Code: 200, Outcome: call.OutcomeAnswered}, call.Meta{ID: "1", VirtualAgentID: "a"})
+	assert.Equal(t, Event{ID: 1, CallID: "1", VirtualAgentID: "a", State: call.StateFinished, Code: 200, Outcome: call.OutcomeAnswered, At: now}, <-agent.C())
+	b.Publish(call.Status{State: call.StateQueued}, call.Meta{ID: "2", VirtualAgentID: "b"})
+	b.Publish(call.Status{State: call.StateQueued}, call.Meta{ID: "3", VirtualAgentID: "b"})
+
+	// the buffer of 2 is full, the slow subscriber is dropped after buffered events.
+	assert.Equal(t, 1, b.Subscribers())
+	received := make([]Event, 0)
+	for e := range all.C() {
+		received = append(received, e)
+	}
+	assert.Equal(t, []uint64{1, 2}, ids(received))
+	assert.Equal(t, uint64(1), m.SlowConsumers.Value())
+
+	b.Unsubscribe(agent)
+	b.Unsubscribe(agent)
+	_, ok := <-agent.C()
+	assert.False(t, ok)
+	assert.Equal(t, 0, b.Subscribers())
+}
+
+func TestBroker_Close(t *testing.T) {
+	b := NewBroker(10, 2, realtime.NewFake(time.Unix(1709464831, 0)), nil)
+	b.Publish(call.Status{State: call.StateQueued}, call.Meta{ID: "1"})
+	open, _ := b.Subscribe(Filter{}, 0)
+	b.Close()
+	_, ok := <-open.C()
+	assert.False(t, ok)
+	assert.Equal(t, 0, b.Subscribers())
+
+	// streams opened during shutdown get the backlog only.
+	late, backlog := b.Subscribe(Filter{}, 0)
+	assert.Equal(t, []uint64{1}, ids(backlog))
+	_, ok = <-late.C()
+	assert.False(t, ok)
+	assert.Equal(t, 0, b.Subscribers())
+	// the closed subscription isn't closed twice.
+	b.Unsubscribe(open)
+	b.Unsubscribe(late)
+}
+
+func TestRecorder_SaveStatus(t *testing.T) {
+	testErr := errors.New("test")
+	ctrl := gomock.NewController(t)
+	statusStorage := NewMockStatusStorage(ctrl)
+	b := NewBroker(10, 10, realtime.NewFake(time.Unix(1709464831, 0)), nil)
+	r := NewRecorder(statusStorage, b)
+	s, _ := b.Subscribe(Filter{}, 0)
+
+	meta := call.Meta{ID: "1", VirtualAgentID: "a"}
+	statusStorage.EXPECT().SaveStatus(gomock.Any(), call.Status{State: call.StateQueued}, meta).Return(testErr)
+	assert.Equal(t, testErr, r.SaveStatus(context.Background(), call.Status{State: call.StateQueued}, meta))
+	statusStorage.EXPECT().SaveStatus(gomock.Any(), call.Status{State: call.StateRetrying}, meta).Return(nil)
+	assert.NoError(t, r.SaveStatus(context.Background(), call.Status{State: call.StateRetrying}, meta))
+
+	// only the saved status is published.
+	b.Unsubscribe(s)
+	received := make([]Event, 0)
+	for e := range s.C() {
+		received = append(received, e)
+	}
+	assert.Len(t, received, 1)
+	assert.Equal(t, call.StateRetrying, received[0].State)
+}
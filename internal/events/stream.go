@@ -0,0 +1,123 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"test_trigger/internal/auth"
+	"test_trigger/internal/logger"
+	"test_trigger/internal/realtime"
+)
+
+// HeaderLastEventID is sent by EventSource on reconnect.
+const HeaderLastEventID = "Last-Event-ID"
+
+// Handler streams events as Server-Sent Events.
+type Handler struct {
+	broker    *Broker
+	heartbeat time.Duration
+	clock     realtime.Time
+	logger    logger.Logger
+}
+
+func NewHandler(broker *Broker, heartbeat time.Duration, clock realtime.Time, logger logger.Logger) *Handler {
+	return &Handler{broker: broker, heartbeat: heartbeat, clock: clock, logger: logger}
+}
+
+// Route sends /calls/events and /calls/{id}/events to Stream, other /calls/ paths to next.
+func (h *Handler) Route(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/events") {
+			h.Stream(w, r)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Stream writes events until the client goes away, path is /calls/events or /calls/{id}/events, both accept ?virtual_agent_id=.
+// The stream is closed if the client doesn't keep up, it should reconnect with Last-Event-ID.
+func (h *Handler) Stream(w http.ResponseWriter, r *http.Request) {
+	filter := Filter{VirtualAgentID: r.URL.Query().Get("virtual_agent_id")}
+	if client, ok := auth.ClientFromContext(r.Context()); ok {
+		filter.Tenant = client.TenantID()
+	}
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/calls/"), "events")
+	if path != "" {
+		callID, ok := strings.CutSuffix(path, "/")
+		if !ok || callID == "" || strings.Contains(callID, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		filter.CallID = callID
+	}
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var lastID uint64
+	if raw := r.Header.Get(HeaderLastEventID); raw != "" {
+		var err error
+		if lastID, err = strconv.ParseUint(raw, 10, 64); err != nil {
+			http.Error(w, "Last-Event-ID should be an event id", http.StatusBadRequest)
+			return
+		}
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.logger.Error("events: streaming isn't supported by the response writer")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	// the server write timeout would close long streams.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	sub, backlog := h.broker.Subscribe(filter, lastID)
+	defer h.broker.Unsubscribe(sub)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	for _, event := range backlog {
+		if err := h.write(w, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := h.clock.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C():
+			// comments are ignored by EventSource, they keep proxies from closing idle connections.
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-sub.C():
+			if !ok {
+				return
+			}
+			if err := h.write(w, event); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func (h *Handler) write(w http.ResponseWriter, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		h.logger.Error("events: marshall", "error", err)
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\ndata: %s\n\n", event.ID, data)
+	return err
+}
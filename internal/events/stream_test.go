@@ -0,0 +1,147 @@
+package events
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"test_trigger/internal/auth"
+	"test_trigger/internal/call"
+	"test_trigger/internal/realtime"
+)
+
+// readEvent reads lines until the empty line, which ends the event.
+func readEvent(t *testing.T, r *bufio.Reader) string {
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		if line == "\n" {
+			return strings.Join(lines, "")
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestHandler_Stream(t *testing.T) {
+	now := time.Unix(1709464831, 0).UTC()
+	clock := realtime.NewFake(now)
+	b := NewBroker(10, 10, clock, nil)
+	b.Publish(call.Status{State: call.StateQueued}, call.Meta{ID: "1", VirtualAgentID: "a"})
+	b.Publish(call.Status{State: call.StateQueued}, call.Meta{ID: "2", VirtualAgentID: "b"})
+	h := NewHandler(b, time.Second, clock, nil)
+	server := httptest.NewServer(h.Route(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/calls/events?virtual_agent_id=a", nil)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	r := bufio.NewReader(resp.Body)
+	assert.Equal(t, "id: 1\ndata: {\"id\":1,\"call_id\":\"1\",\"virtual_agent_id\":\"a\",\"state\":\"queued\",\"at\":\"2024-03-03T11:20:31Z\"}\n", readEvent(t, r))
+
+	b.Publish(call.Status{State: call.StateQueued}, call.Meta{ID: "3", VirtualAgentID: "b"})
+	b.Publish(call.Status{State: call.StateFinished, Code: 200, Outcome: call.OutcomeAnswered}, call.Meta{ID: "1", VirtualAgentID: "a"})
+	assert.Equal(t, "id: 4\ndata: {\"id\":4,\"call_id\":\"1\",\"virtual_agent_id\":\"a\",\"state\":\"finished\",\"code\":200,\"outcome\":\"answered\",\"at\":\"2024-03-03T11:20:31Z\"}\n", readEvent(t, r))
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	assert.Equal(t, ": heartbeat\n", readEvent(t, r))
+}
+
+func TestHandler_Stream_Tenant(t *testing.T) {
+	now := time.Unix(1709464831, 0).UTC()
+	clock := realtime.NewFake(now)
+	b := NewBroker(10, 10, clock, nil)
+	b.Publish(call.Status{State: call.StateQueued}, call.Meta{ID: "1", VirtualAgentID: "a", Tenant: "other"})
+	b.Publish(call.Status{State: call.StateQueued}, call.Meta{ID: "2", VirtualAgentID: "a", Tenant: "acme"})
+	h := NewHandler(b, time.Second, clock, nil)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.Stream(w, r.WithContext(auth.ContextWithClient(r.Context(), auth.Client{ID: "crm", Tenant: "acme"})))
+	}))
+	defer server.Close()
+
+	// events of other tenants aren't streamed, even for a call id.
+	for _, path := range []string{"/calls/events", "/calls/1/events"} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		r := bufio.NewReader(resp.Body)
+		b.Publish(call.Status{State: call.StateFailed}, call.Meta{ID: "1", VirtualAgentID: "a", Tenant: "other"})
+		b.Publish(call.Status{State: call.StateFinished}, call.Meta{ID: "1", VirtualAgentID: "a", Tenant: "acme"})
+		if path == "/calls/events" {
+			assert.Contains(t, readEvent(t, r), `"call_id":"2"`)
+		}
+		event := readEvent(t, r)
+		assert.Contains(t, event, `"call_id":"1"`)
+		assert.Contains(t, event, `"state":"finished"`)
+		resp.Body.Close()
+	}
+}
+
+func TestHandler_Stream_LastEventID(t *testing.T) {
+	clock := realtime.NewFake(time.Unix(1709464831, 0))
+	b := NewBroker(10, 10, clock, nil)
+	for _, id := range []call.ID{"1", "2", "1"} {
+		b.Publish(call.Status{State: call.StateQueued}, call.Meta{ID: id, VirtualAgentID: "a"})
+	}
+	server := httptest.NewServer(http.HandlerFunc(NewHandler(b, time.Second, clock, nil).Stream))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/calls/1/events", nil)
+	req.Header.Set(HeaderLastEventID, "1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.True(t, strings.HasPrefix(readEvent(t, bufio.NewReader(resp.Body)), "id: 3\n"))
+}
+
+func TestHandler_Stream_Errors(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		lastEventID  string
+		expectedCode int
+	}{
+		{"unknown path", http.MethodGet, "/calls/1/2/events", "", http.StatusNotFound},
+		{"empty call id", http.MethodGet, "/calls//events", "", http.StatusNotFound},
+		{"method not allowed", http.MethodPost, "/calls/events", "", http.StatusMethodNotAllowed},
+		{"bad last event id", http.MethodGet, "/calls/events", "abc", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := realtime.NewFake(time.Unix(1709464831, 0))
+			h := NewHandler(NewBroker(10, 10, clock, nil), time.Second, clock, nil)
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set(HeaderLastEventID, tt.lastEventID)
+			resp := httptest.NewRecorder()
+			h.Stream(resp, req)
+			assert.Equal(t, tt.expectedCode, resp.Code)
+		})
+	}
+}
+
+func TestHandler_Route(t *testing.T) {
+	clock := realtime.NewFake(time.Unix(1709464831, 0))
+	h := NewHandler(NewBroker(10, 10, clock, nil), time.Second, clock, nil)
+	route := h.Route(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	resp := httptest.NewRecorder()
+	route(resp, httptest.NewRequest(http.MethodGet, "/calls/1", nil))
+	assert.Equal(t, http.StatusTeapot, resp.Code)
+	resp = httptest.NewRecorder()
+	route(resp, httptest.NewRequest(http.MethodPost, "/calls/1/events", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+}
@@ -3,42 +3,108 @@ package internal
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"test_trigger/internal/admission"
+	"test_trigger/internal/auth"
 	"test_trigger/internal/call"
+	"test_trigger/internal/call/dispatch"
+	"test_trigger/internal/http_wrapper"
 	"test_trigger/internal/logger"
+	"test_trigger/internal/metrics"
+	"test_trigger/internal/realtime"
+	"test_trigger/internal/tracing"
 )
 
 //go:generate go run github.com/golang/mock/mockgen --source=handler.go --destination=handler_mock.go --package=internal
 
-// CallSaver is responsible for saving calls for later processing.
+// CallSaver is responsible for saving calls for later processing, admission.Controller limits the queue depth.
 type CallSaver interface {
 	AddToQueueBack(_ context.Context, meta call.Meta) error
 }
 
+// StatusStorage is responsible for status records of calls.
+type StatusStorage interface {
+	SaveStatus(_ context.Context, status call.Status, meta call.Meta) error
+	Status(_ context.Context, id call.ID) (call.Status, bool, error)
+}
+
+// CallbackChecker tells whether webhooks of the tenant can be signed.
+type CallbackChecker interface {
+	CanNotify(tenant string) bool
+}
+
+// Admitter projects when a new call is dialed and rejects it if the queue is too long.
+type Admitter interface {
+	Admit(ctx context.Context) (admission.Decision, error)
+}
+
 // TriggerResponse response struct for /trigger request.
 type TriggerResponse struct {
+	CallID          string     `json:"call_id"`
+	EstimatedDialAt *time.Time `json:"estimated_dial_at,omitempty"` // nil without admission control.
+}
+
+// StatusResponse response struct for /calls/{id} request.
+type StatusResponse struct {
 	CallID string `json:"call_id"`
+	call.Status
 }
 
 // Server is responsible for handling requests.
 type Server struct {
-	callSaver CallSaver
-	getUUID   func() string // decided to save time there.
-	logger    logger.Logger
+	callSaver     CallSaver
+	statusStorage StatusStorage
+	getUUID       func() string // decided to save time there.
+	realTime      realtime.Time
+	logger        logger.Logger
+	metrics       *metrics.Calls
+	tracer        *tracing.Tracer
+	control       *dispatch.Control
+	callbacks     CallbackChecker // nil if webhooks aren't configured.
+	admission     Admitter        // nil accepts every call.
+	defaultTTL    time.Duration   // for calls without expires_at and ttl, 0 is never.
 }
 
-func NewServer(callSaver CallSaver, getUUID func() string, logger logger.Logger) *Server {
-	return &Server{callSaver: callSaver, getUUID: getUUID, logger: logger}
+func NewServer(callSaver CallSaver, statusStorage StatusStorage, getUUID func() string, t realtime.Time, logger logger.Logger, m *metrics.Calls, tracer *tracing.Tracer, control *dispatch.Control, callbacks CallbackChecker, admitter Admitter, defaultTTL time.Duration) *Server {
+	return &Server{callSaver: callSaver, statusStorage: statusStorage, getUUID: getUUID, realTime: t, logger: logger, metrics: m, tracer: tracer, control: control, callbacks: callbacks, admission: admitter, defaultTTL: defaultTTL}
 }
 
 // Trigger processes http request, save correct body to storage for later processing.
 func (s *Server) Trigger(w http.ResponseWriter, r *http.Request) {
+	accepted := false
+	defer func() {
+		if accepted {
+			s.metrics.TriggerAccepted()
+		} else {
+			s.metrics.TriggerRejected()
+		}
+	}()
+
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
+	// drain finishes the queue, new calls would keep it from finishing.
+	if !s.control.Accepting() {
+		http.Error(w, "intake is closed, the queue is draining", http.StatusServiceUnavailable)
+		return
+	}
+
+	traceCtx := r.Context()
+	if parent, err := tracing.ParseTraceParent(r.Header.Get(tracing.HeaderTraceParent)); err == nil {
+		traceCtx = tracing.ContextWithSpanContext(traceCtx, parent)
+	}
+	traceCtx, span := s.tracer.Start(traceCtx, tracing.KindServer, "trigger")
+	defer span.Finish()
 
 	callBody := &call.Body{}
 	err := json.NewDecoder(r.Body).Decode(callBody)
@@ -51,32 +117,182 @@ func (s *Server) Trigger(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "virtual_agent_id or phone_number can't be empty", http.StatusBadRequest)
 		return
 	}
+	// only authenticated clients have a tenant, webhooks of others are signed with the default secret.
+	tenant := ""
+	client, authenticated := auth.ClientFromContext(r.Context())
+	if authenticated {
+		if !client.Allowed(callBody.VirtualAgentID) {
+			http.Error(w, "virtual_agent_id isn't allowed for the client", http.StatusForbidden)
+			return
+		}
+		tenant = client.TenantID()
+	}
+	if callBody.CallbackURL != "" {
+		if u, err := url.Parse(callBody.CallbackURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			http.Error(w, "callback_url should be http(s) URL", http.StatusBadRequest)
+			return
+		} else if !publicHost(u.Hostname()) {
+			http.Error(w, "callback_url should be public", http.StatusBadRequest)
+			return
+		}
+		// unsigned webhooks can't be verified by the receiver, so they aren't sent at all.
+		if s.callbacks == nil || !s.callbacks.CanNotify(tenant) {
+			http.Error(w, "callback_url can't be used, there is no webhook secret for the tenant", http.StatusBadRequest)
+			return
+		}
+	}
+	now := s.realTime.Now()
+	expiresAt, err := s.expiresAt(callBody, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var dialAt *time.Time
+	if s.admission != nil {
+		decision, err := s.admission.Admit(r.Context())
+		if err != nil {
+			s.logger.Error("trigger: Admit", "error", err)
+			span.RecordError(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if decision.Reason != "" {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(decision.RetryAfter.Seconds()))))
+			http.Error(w, fmt.Sprintf("%s, estimated wait %v", decision.Reason, decision.Wait.Round(time.Second)), http.StatusServiceUnavailable)
+			return
+		}
+		dialAt = &decision.DialAt
+	}
 	callID := s.getUUID()
-	err = s.callSaver.AddToQueueBack(r.Context(), call.Meta{
+	log := s.logger.With("call_id", callID, "virtual_agent_id", callBody.VirtualAgentID)
+	if authenticated {
+		log = log.With("client_id", client.ID)
+	}
+	span.SetAttributes("call_id", callID, "virtual_agent_id", callBody.VirtualAgentID)
+	meta := call.Meta{
 		PhoneNumber:    callBody.PhoneNumber,
 		VirtualAgentID: callBody.VirtualAgentID,
 		ID:             call.ID(callID),
-	})
+		EnqueuedAt:     now,
+		ExpiresAt:      expiresAt,
+		TraceParent:    tracing.SpanContextFromContext(traceCtx).TraceParent(),
+		CallbackURL:    callBody.CallbackURL,
+		Tenant:         tenant,
+		ClientID:       client.ID,
+	}
+	// status is saved before the call is queued, otherwise it could rewrite status from a worker.
+	err = s.statusStorage.SaveStatus(r.Context(), call.Status{State: call.StateQueued}, meta)
 	if err != nil {
-		s.logger.Error(fmt.Errorf("trigger: AddToQueueBack: %v", err))
+		log.Error("trigger: SaveStatus", "error", err)
+		span.RecordError(err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	err = s.callSaver.AddToQueueBack(r.Context(), meta)
+	var full *admission.QueueFullError
+	if errors.As(err, &full) {
+		// the queued status is saved already, the call won't be dialed, so it's completed, its quota slot is free.
+		if err := s.statusStorage.SaveStatus(r.Context(), call.Status{State: call.StateExpired}, meta); err != nil {
+			log.Error("trigger: SaveStatus", "error", err)
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(full.RetryAfter.Seconds()))))
+		http.Error(w, full.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		log.Error("trigger: AddToQueueBack", "error", err)
+		span.RecordError(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	// the call is queued, it will be processed even if the response isn't delivered.
+	accepted = true
+	log.Info("call queued")
 
-	resp := TriggerResponse{CallID: callID}
+	resp := TriggerResponse{CallID: callID, EstimatedDialAt: dialAt}
 	respBody, err := json.Marshal(resp)
 	if err != nil {
-		s.logger.Error(fmt.Errorf("trigger: marshall: %v", err))
+		log.Error("trigger: marshall", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
 	_, err = w.Write(respBody)
 	if err != nil {
-		s.logger.Error(fmt.Errorf("trigger: write bytes: %w", err))
+		log.Error("trigger: write bytes", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 }
+
+// expiresAt returns when the call expires by the body or the default ttl, zero time is never.
+func (s *Server) expiresAt(body *call.Body, now time.Time) (time.Time, error) {
+	switch {
+	case body.ExpiresAt != nil && body.TTL != "":
+		return time.Time{}, errors.New("expires_at and ttl can't be used together")
+	case body.ExpiresAt != nil:
+		if !body.ExpiresAt.After(now) {
+			return time.Time{}, errors.New("expires_at should be in the future")
+		}
+		return *body.ExpiresAt, nil
+	case body.TTL != "":
+		ttl, err := time.ParseDuration(body.TTL)
+		if err != nil || ttl <= 0 {
+			return time.Time{}, errors.New("ttl should be a positive duration like 30m")
+		}
+		return now.Add(ttl), nil
+	case s.defaultTTL > 0:
+		return now.Add(s.defaultTTL), nil
+	}
+	return time.Time{}, nil
+}
+
+// CallStatus returns status record of the call, path is /calls/{id}.
+func (s *Server) CallStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	callID := strings.TrimPrefix(r.URL.Path, "/calls/")
+	if callID == "" || strings.Contains(callID, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	status, ok, err := s.statusStorage.Status(r.Context(), call.ID(callID))
+	if err != nil {
+		s.logger.Error("call status", "call_id", callID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	// calls of other tenants are unknown for the client.
+	if client, authenticated := auth.ClientFromContext(r.Context()); !ok || authenticated && client.TenantID() != status.Tenant {
+		http.NotFound(w, r)
+		return
+	}
+
+	respBody, err := json.Marshal(StatusResponse{CallID: callID, Status: status})
+	if err != nil {
+		s.logger.Error("call status: marshall", "call_id", callID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(respBody)
+	if err != nil {
+		s.logger.Error("call status: write bytes", "call_id", callID, "error", err)
+	}
+}
+
+// publicHost rejects obviously internal hosts early, names are resolved and checked by the webhook client on delivery.
+func publicHost(host string) bool {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return http_wrapper.Public(addr)
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	return host != "localhost" && !strings.HasSuffix(host, ".localhost")
+}
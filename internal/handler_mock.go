@@ -7,6 +7,7 @@ package internal
 import (
 	context "context"
 	reflect "reflect"
+	admission "test_trigger/internal/admission"
 	call "test_trigger/internal/call"
 
 	gomock "github.com/golang/mock/gomock"
@@ -48,3 +49,131 @@ func (mr *MockCallSaverMockRecorder) AddToQueueBack(arg0, meta interface{}) *gom
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddToQueueBack", reflect.TypeOf((*MockCallSaver)(nil).AddToQueueBack), arg0, meta)
 }
+
+// MockStatusStorage is a mock of StatusStorage interface.
+type MockStatusStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockStatusStorageMockRecorder
+}
+
+// MockStatusStorageMockRecorder is the mock recorder for MockStatusStorage.
+type MockStatusStorageMockRecorder struct {
+	mock *MockStatusStorage
+}
+
+// NewMockStatusStorage creates a new mock instance.
+func NewMockStatusStorage(ctrl *gomock.Controller) *MockStatusStorage {
+	mock := &MockStatusStorage{ctrl: ctrl}
+	mock.recorder = &MockStatusStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStatusStorage) EXPECT() *MockStatusStorageMockRecorder {
+	return m.recorder
+}
+
+// SaveStatus mocks base method.
+func (m *MockStatusStorage) SaveStatus(arg0 context.Context, status call.Status, meta call.Meta) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveStatus", arg0, status, meta)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveStatus indicates an expected call of SaveStatus.
+func (mr *MockStatusStorageMockRecorder) SaveStatus(arg0, status, meta interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveStatus", reflect.TypeOf((*MockStatusStorage)(nil).SaveStatus), arg0, status, meta)
+}
+
+// Status mocks base method.
+func (m *MockStatusStorage) Status(arg0 context.Context, id call.ID) (call.Status, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Status", arg0, id)
+	ret0, _ := ret[0].(call.Status)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Status indicates an expected call of Status.
+func (mr *MockStatusStorageMockRecorder) Status(arg0, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Status", reflect.TypeOf((*MockStatusStorage)(nil).Status), arg0, id)
+}
+
+// MockCallbackChecker is a mock of CallbackChecker interface.
+type MockCallbackChecker struct {
+	ctrl     *gomock.Controller
+	recorder *MockCallbackCheckerMockRecorder
+}
+
+// MockCallbackCheckerMockRecorder is the mock recorder for MockCallbackChecker.
+type MockCallbackCheckerMockRecorder struct {
+	mock *MockCallbackChecker
+}
+
+// NewMockCallbackChecker creates a new mock instance.
+func NewMockCallbackChecker(ctrl *gomock.Controller) *MockCallbackChecker {
+	mock := &MockCallbackChecker{ctrl: ctrl}
+	mock.recorder = &MockCallbackCheckerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCallbackChecker) EXPECT() *MockCallbackCheckerMockRecorder {
+	return m.recorder
+}
+
+// CanNotify mocks base method.
+func (m *MockCallbackChecker) CanNotify(tenant string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CanNotify", tenant)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// CanNotify indicates an expected call of CanNotify.
+func (mr *MockCallbackCheckerMockRecorder) CanNotify(tenant interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CanNotify", reflect.TypeOf((*MockCallbackChecker)(nil).CanNotify), tenant)
+}
+
+// MockAdmitter is a mock of Admitter interface.
+type MockAdmitter struct {
+	ctrl     *gomock.Controller
+	recorder *MockAdmitterMockRecorder
+}
+
+// MockAdmitterMockRecorder is the mock recorder for MockAdmitter.
+type MockAdmitterMockRecorder struct {
+	mock *MockAdmitter
+}
+
+// NewMockAdmitter creates a new mock instance.
+func NewMockAdmitter(ctrl *gomock.Controller) *MockAdmitter {
+	mock := &MockAdmitter{ctrl: ctrl}
+	mock.recorder = &MockAdmitterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAdmitter) EXPECT() *MockAdmitterMockRecorder {
+	return m.recorder
+}
+
+// Admit mocks base method.
+func (m *MockAdmitter) Admit(ctx context.Context) (admission.Decision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Admit", ctx)
+	ret0, _ := ret[0].(admission.Decision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Admit indicates an expected call of Admit.
+func (mr *MockAdmitterMockRecorder) Admit(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Admit", reflect.TypeOf((*MockAdmitter)(nil).Admit), ctx)
+}
@@ -2,19 +2,27 @@ package internal
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 
+	"test_trigger/internal/admission"
+	"test_trigger/internal/auth"
 	"test_trigger/internal/call"
+	"test_trigger/internal/call/dispatch"
 	"test_trigger/internal/logger"
+	"test_trigger/internal/metrics"
+	"test_trigger/internal/realtime"
+	"test_trigger/internal/tracing"
 )
 
 func BuildTestReq(method, path string, body interface{}) (*http.Request, *httptest.ResponseRecorder) {
@@ -29,8 +37,14 @@ func BuildTestReq(method, path string, body interface{}) (*http.Request, *httpte
 }
 
 func TestServer_Trigger(t *testing.T) {
+	now := time.Unix(1709464831, 0)
+	// decoded json time is in UTC.
+	expiresAt := now.Add(2 * time.Hour).UTC()
 	type fields struct {
-		getUUID func() string
+		getUUID    func() string
+		draining   bool
+		client     *auth.Client
+		defaultTTL time.Duration
 	}
 	type args struct {
 		method, path string
@@ -40,9 +54,10 @@ func TestServer_Trigger(t *testing.T) {
 		name           string
 		fields         fields
 		args           args
-		expectedFunc   func(saver *MockCallSaver, l *logger.MockLogger)
+		expectedFunc   func(saver *MockCallSaver, statusStorage *MockStatusStorage, l *logger.MockLogger)
 		expectedStatus int
 		expectedBody   string
+		accepted       bool
 	}{
 		{
 			name:   "method not allowed",
@@ -55,6 +70,21 @@ func TestServer_Trigger(t *testing.T) {
 			expectedStatus: http.StatusMethodNotAllowed,
 			expectedBody:   "",
 		},
+		{
+			name:   "draining, intake is closed",
+			fields: fields{draining: true},
+			args: args{
+				method: http.MethodPost,
+				path:   "/",
+				body: call.Body{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+				},
+			},
+			expectedFunc:   nil,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   "intake is closed, the queue is draining\n",
+		},
 		{
 			name:   "failed body",
 			fields: fields{},
@@ -82,6 +112,274 @@ func TestServer_Trigger(t *testing.T) {
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   "virtual_agent_id or phone_number can't be empty\n",
 		},
+		{
+			name:   "failed, callback_url isn't url",
+			fields: fields{},
+			args: args{
+				method: http.MethodPost,
+				path:   "/",
+				body: call.Body{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					CallbackURL:    "crm/hook",
+				},
+			},
+			expectedFunc:   nil,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "callback_url should be http(s) URL\n",
+		},
+		{
+			name:   "failed, callback_url is loopback",
+			fields: fields{},
+			args: args{
+				method: http.MethodPost,
+				path:   "/",
+				body: call.Body{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					CallbackURL:    "http://127.0.0.1:8080/hook",
+				},
+			},
+			expectedFunc:   nil,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "callback_url should be public\n",
+		},
+		{
+			name:   "failed, callback_url is localhost",
+			fields: fields{},
+			args: args{
+				method: http.MethodPost,
+				path:   "/",
+				body: call.Body{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					CallbackURL:    "http://localhost/hook",
+				},
+			},
+			expectedFunc:   nil,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "callback_url should be public\n",
+		},
+		{
+			name:   "failed, callback_url is IPv6 loopback",
+			fields: fields{},
+			args: args{
+				method: http.MethodPost,
+				path:   "/",
+				body: call.Body{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					CallbackURL:    "http://[::1]/hook",
+				},
+			},
+			expectedFunc:   nil,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "callback_url should be public\n",
+		},
+		{
+			name:   "failed, callback_url is 10/8",
+			fields: fields{},
+			args: args{
+				method: http.MethodPost,
+				path:   "/",
+				body: call.Body{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					CallbackURL:    "https://10.0.0.5/hook",
+				},
+			},
+			expectedFunc:   nil,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "callback_url should be public\n",
+		},
+		{
+			name:   "failed, callback_url is 172.16/12",
+			fields: fields{},
+			args: args{
+				method: http.MethodPost,
+				path:   "/",
+				body: call.Body{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					CallbackURL:    "https://172.20.1.1/hook",
+				},
+			},
+			expectedFunc:   nil,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "callback_url should be public\n",
+		},
+		{
+			name:   "failed, callback_url is 192.168/16",
+			fields: fields{},
+			args: args{
+				method: http.MethodPost,
+				path:   "/",
+				body: call.Body{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					CallbackURL:    "https://192.168.0.10/hook",
+				},
+			},
+			expectedFunc:   nil,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "callback_url should be public\n",
+		},
+		{
+			name:   "failed, callback_url is link-local metadata",
+			fields: fields{},
+			args: args{
+				method: http.MethodPost,
+				path:   "/",
+				body: call.Body{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					CallbackURL:    "http://169.254.169.254/latest/meta-data",
+				},
+			},
+			expectedFunc:   nil,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "callback_url should be public\n",
+		},
+		{
+			name:   "failed, callback_url is unspecified",
+			fields: fields{},
+			args: args{
+				method: http.MethodPost,
+				path:   "/",
+				body: call.Body{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					CallbackURL:    "http://0.0.0.0/hook",
+				},
+			},
+			expectedFunc:   nil,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "callback_url should be public\n",
+		},
+		{
+			name:   "failed, callback_url is IPv6 private",
+			fields: fields{},
+			args: args{
+				method: http.MethodPost,
+				path:   "/",
+				body: call.Body{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					CallbackURL:    "http://[fd12::1]/hook",
+				},
+			},
+			expectedFunc:   nil,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "callback_url should be public\n",
+		},
+		{
+			name:   "failed, no webhook secret for the tenant",
+			fields: fields{client: &auth.Client{ID: "crm", Tenant: "other", VirtualAgents: []string{"aaa"}}},
+			args: args{
+				method: http.MethodPost,
+				path:   "/",
+				body: call.Body{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					CallbackURL:    "https://crm/hook",
+				},
+			},
+			expectedFunc:   nil,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "callback_url can't be used, there is no webhook secret for the tenant\n",
+		},
+		{
+			name:   "failed, virtual agent isn't allowed for the client",
+			fields: fields{client: &auth.Client{ID: "crm", VirtualAgents: []string{"bbb"}}},
+			args: args{
+				method: http.MethodPost,
+				path:   "/",
+				body: call.Body{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+				},
+			},
+			expectedFunc:   nil,
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   "virtual_agent_id isn't allowed for the client\n",
+		},
+		{
+			name:   "failed, expires_at and ttl",
+			fields: fields{},
+			args: args{
+				method: http.MethodPost,
+				path:   "/",
+				body: call.Body{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					ExpiresAt:      &expiresAt,
+					TTL:            "1h",
+				},
+			},
+			expectedFunc:   nil,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "expires_at and ttl can't be used together\n",
+		},
+		{
+			name:   "failed, expires_at in the past",
+			fields: fields{},
+			args: args{
+				method: http.MethodPost,
+				path:   "/",
+				body: call.Body{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					ExpiresAt:      &now,
+				},
+			},
+			expectedFunc:   nil,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "expires_at should be in the future\n",
+		},
+		{
+			name:   "failed, bad ttl",
+			fields: fields{},
+			args: args{
+				method: http.MethodPost,
+				path:   "/",
+				body: call.Body{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					TTL:            "-1h",
+				},
+			},
+			expectedFunc:   nil,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "ttl should be a positive duration like 30m\n",
+		},
+		{
+			name: "failed, save status",
+			fields: fields{
+				getUUID: func() string {
+					return "1"
+				},
+			},
+			args: args{
+				method: http.MethodPost,
+				path:   "/",
+				body: call.Body{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+				},
+			},
+			expectedFunc: func(saver *MockCallSaver, statusStorage *MockStatusStorage, l *logger.MockLogger) {
+				l.EXPECT().With("call_id", "1", "virtual_agent_id", "aaa").Return(l)
+				statusStorage.EXPECT().SaveStatus(gomock.Any(), call.Status{State: call.StateQueued}, call.Meta{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					ID:             "1",
+					EnqueuedAt:     now,
+				}).Return(errors.New("some err"))
+				l.EXPECT().Error("trigger: SaveStatus", "error", errors.New("some err"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "",
+		},
 		{
 			name: "failed, save to storage",
 			fields: fields{
@@ -97,13 +395,21 @@ func TestServer_Trigger(t *testing.T) {
 					VirtualAgentID: "aaa",
 				},
 			},
-			expectedFunc: func(saver *MockCallSaver, l *logger.MockLogger) {
+			expectedFunc: func(saver *MockCallSaver, statusStorage *MockStatusStorage, l *logger.MockLogger) {
+				l.EXPECT().With("call_id", "1", "virtual_agent_id", "aaa").Return(l)
+				statusStorage.EXPECT().SaveStatus(gomock.Any(), call.Status{State: call.StateQueued}, call.Meta{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					ID:             "1",
+					EnqueuedAt:     now,
+				}).Return(nil)
 				saver.EXPECT().AddToQueueBack(gomock.Any(), call.Meta{
 					PhoneNumber:    "777",
 					VirtualAgentID: "aaa",
 					ID:             "1",
+					EnqueuedAt:     now,
 				}).Return(errors.New("some err"))
-				l.EXPECT().Error(fmt.Errorf("trigger: AddToQueueBack: %v", errors.New("some err")))
+				l.EXPECT().Error("trigger: AddToQueueBack", "error", errors.New("some err"))
 			},
 			expectedStatus: http.StatusInternalServerError,
 			expectedBody:   "",
@@ -123,33 +429,439 @@ func TestServer_Trigger(t *testing.T) {
 					VirtualAgentID: "aaa",
 				},
 			},
-			expectedFunc: func(saver *MockCallSaver, l *logger.MockLogger) {
+			expectedFunc: func(saver *MockCallSaver, statusStorage *MockStatusStorage, l *logger.MockLogger) {
+				l.EXPECT().With("call_id", "1", "virtual_agent_id", "aaa").Return(l)
+				statusStorage.EXPECT().SaveStatus(gomock.Any(), call.Status{State: call.StateQueued}, call.Meta{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					ID:             "1",
+					EnqueuedAt:     now,
+				}).Return(nil)
 				saver.EXPECT().AddToQueueBack(gomock.Any(), call.Meta{
 					PhoneNumber:    "777",
 					VirtualAgentID: "aaa",
 					ID:             "1",
+					EnqueuedAt:     now,
 				}).Return(nil)
+				l.EXPECT().Info("call queued")
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   `{"call_id":"1"}`,
+			accepted:       true,
+		},
+		{
+			name: "success, with ttl",
+			fields: fields{
+				getUUID: func() string {
+					return "1"
+				},
+				defaultTTL: 24 * time.Hour,
+			},
+			args: args{
+				method: http.MethodPost,
+				path:   "/",
+				body: call.Body{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					TTL:            "30m",
+				},
+			},
+			expectedFunc: func(saver *MockCallSaver, statusStorage *MockStatusStorage, l *logger.MockLogger) {
+				meta := call.Meta{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					ID:             "1",
+					EnqueuedAt:     now,
+					ExpiresAt:      now.Add(30 * time.Minute),
+				}
+				l.EXPECT().With("call_id", "1", "virtual_agent_id", "aaa").Return(l)
+				statusStorage.EXPECT().SaveStatus(gomock.Any(), call.Status{State: call.StateQueued}, meta).Return(nil)
+				saver.EXPECT().AddToQueueBack(gomock.Any(), meta).Return(nil)
+				l.EXPECT().Info("call queued")
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"call_id":"1"}`,
+			accepted:       true,
+		},
+		{
+			name: "success, with expires_at",
+			fields: fields{
+				getUUID: func() string {
+					return "1"
+				},
+				defaultTTL: 24 * time.Hour,
+			},
+			args: args{
+				method: http.MethodPost,
+				path:   "/",
+				body: call.Body{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					ExpiresAt:      &expiresAt,
+				},
+			},
+			expectedFunc: func(saver *MockCallSaver, statusStorage *MockStatusStorage, l *logger.MockLogger) {
+				meta := call.Meta{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					ID:             "1",
+					EnqueuedAt:     now,
+					ExpiresAt:      expiresAt,
+				}
+				l.EXPECT().With("call_id", "1", "virtual_agent_id", "aaa").Return(l)
+				statusStorage.EXPECT().SaveStatus(gomock.Any(), call.Status{State: call.StateQueued}, meta).Return(nil)
+				saver.EXPECT().AddToQueueBack(gomock.Any(), meta).Return(nil)
+				l.EXPECT().Info("call queued")
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"call_id":"1"}`,
+			accepted:       true,
+		},
+		{
+			name: "success, default ttl",
+			fields: fields{
+				getUUID: func() string {
+					return "1"
+				},
+				defaultTTL: 24 * time.Hour,
+			},
+			args: args{
+				method: http.MethodPost,
+				path:   "/",
+				body: call.Body{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+				},
+			},
+			expectedFunc: func(saver *MockCallSaver, statusStorage *MockStatusStorage, l *logger.MockLogger) {
+				meta := call.Meta{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					ID:             "1",
+					EnqueuedAt:     now,
+					ExpiresAt:      now.Add(24 * time.Hour),
+				}
+				l.EXPECT().With("call_id", "1", "virtual_agent_id", "aaa").Return(l)
+				statusStorage.EXPECT().SaveStatus(gomock.Any(), call.Status{State: call.StateQueued}, meta).Return(nil)
+				saver.EXPECT().AddToQueueBack(gomock.Any(), meta).Return(nil)
+				l.EXPECT().Info("call queued")
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"call_id":"1"}`,
+			accepted:       true,
+		},
+		{
+			name: "success, with callback",
+			fields: fields{
+				getUUID: func() string {
+					return "1"
+				},
+			},
+			args: args{
+				method: http.MethodPost,
+				path:   "/",
+				body: call.Body{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					CallbackURL:    "https://crm/hook",
+				},
+			},
+			expectedFunc: func(saver *MockCallSaver, statusStorage *MockStatusStorage, l *logger.MockLogger) {
+				meta := call.Meta{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					ID:             "1",
+					EnqueuedAt:     now,
+					CallbackURL:    "https://crm/hook",
+				}
+				l.EXPECT().With("call_id", "1", "virtual_agent_id", "aaa").Return(l)
+				statusStorage.EXPECT().SaveStatus(gomock.Any(), call.Status{State: call.StateQueued}, meta).Return(nil)
+				saver.EXPECT().AddToQueueBack(gomock.Any(), meta).Return(nil)
+				l.EXPECT().Info("call queued")
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"call_id":"1"}`,
+			accepted:       true,
+		},
+		{
+			name: "success, authenticated client",
+			fields: fields{
+				getUUID: func() string {
+					return "1"
+				},
+				client: &auth.Client{ID: "crm", Tenant: "tenant", VirtualAgents: []string{"aaa"}},
+			},
+			args: args{
+				method: http.MethodPost,
+				path:   "/",
+				body: call.Body{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					CallbackURL:    "https://crm/hook",
+				},
+			},
+			expectedFunc: func(saver *MockCallSaver, statusStorage *MockStatusStorage, l *logger.MockLogger) {
+				meta := call.Meta{
+					PhoneNumber:    "777",
+					VirtualAgentID: "aaa",
+					ID:             "1",
+					EnqueuedAt:     now,
+					CallbackURL:    "https://crm/hook",
+					Tenant:         "tenant",
+					ClientID:       "crm",
+				}
+				l.EXPECT().With("call_id", "1", "virtual_agent_id", "aaa").Return(l)
+				l.EXPECT().With("client_id", "crm").Return(l)
+				statusStorage.EXPECT().SaveStatus(gomock.Any(), call.Status{State: call.StateQueued}, meta).Return(nil)
+				saver.EXPECT().AddToQueueBack(gomock.Any(), meta).Return(nil)
+				l.EXPECT().Info("call queued")
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"call_id":"1"}`,
+			accepted:       true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			callSaver := NewMockCallSaver(ctrl)
+			statusStorage := NewMockStatusStorage(ctrl)
+			callbacks := NewMockCallbackChecker(ctrl)
+			callbacks.EXPECT().CanNotify(gomock.Any()).DoAndReturn(func(tenant string) bool { return tenant != "other" }).AnyTimes()
 			l := logger.NewMockLogger(ctrl)
+			m := metrics.NewCalls(metrics.NewRegistry())
+			control := dispatch.NewControl(nil)
+			if tt.fields.draining {
+				control.Drain()
+			}
 			s := &Server{
-				callSaver: callSaver,
-				getUUID:   tt.fields.getUUID,
-				logger:    l,
+				callSaver:     callSaver,
+				statusStorage: statusStorage,
+				getUUID:       tt.fields.getUUID,
+				realTime:      realtime.NewFake(now),
+				logger:        l,
+				metrics:       m,
+				control:       control,
+				callbacks:     callbacks,
+				defaultTTL:    tt.fields.defaultTTL,
 			}
 			if tt.expectedFunc != nil {
-				tt.expectedFunc(callSaver, l)
+				tt.expectedFunc(callSaver, statusStorage, l)
 			}
 			ao := assert.New(t)
 			testReq, response := BuildTestReq(tt.args.method, tt.args.path, tt.args.body)
+			if tt.fields.client != nil {
+				testReq = testReq.WithContext(auth.ContextWithClient(testReq.Context(), *tt.fields.client))
+			}
+			s.Trigger(response, testReq)
+			ao.Equal(tt.expectedStatus, response.Code)
+			ao.Equal(tt.expectedBody, response.Body.String())
+			if tt.accepted {
+				ao.Equal(uint64(1), m.Triggers.Value("accepted"))
+			} else {
+				ao.Equal(uint64(1), m.Triggers.Value("rejected"))
+			}
+		})
+	}
+}
+
+func TestServer_Trigger_Admission(t *testing.T) {
+	now := time.Unix(1709464831, 0).UTC()
+	testErr := errors.New("test")
+	tests := []struct {
+		name               string
+		decision           admission.Decision
+		admitErr           error
+		addErr             error
+		expectedStatus     int
+		expectedBody       string
+		expectedRetryAfter string
+	}{
+		{
+			name:           "accepted",
+			decision:       admission.Decision{Wait: 90 * time.Second, DialAt: now.Add(90 * time.Second)},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"call_id":"1","estimated_dial_at":"2024-03-03T11:22:01Z"}`,
+		},
+		{
+			name:               "wait is too long",
+			decision:           admission.Decision{Reason: admission.ReasonWait, Wait: 3*time.Hour + 400*time.Millisecond, RetryAfter: 1200 * time.Millisecond},
+			expectedStatus:     http.StatusServiceUnavailable,
+			expectedBody:       "estimated wait is too long, estimated wait 3h0m0s\n",
+			expectedRetryAfter: "2",
+		},
+		{
+			name:               "queue is full",
+			decision:           admission.Decision{Wait: 90 * time.Second, DialAt: now.Add(90 * time.Second)},
+			addErr:             &admission.QueueFullError{RetryAfter: 1200 * time.Millisecond},
+			expectedStatus:     http.StatusServiceUnavailable,
+			expectedBody:       "queue is full\n",
+			expectedRetryAfter: "2",
+		},
+		{
+			name:           "admit error",
+			admitErr:       testErr,
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			callSaver := NewMockCallSaver(ctrl)
+			statusStorage := NewMockStatusStorage(ctrl)
+			admitter := NewMockAdmitter(ctrl)
+			admitter.EXPECT().Admit(gomock.Any()).Return(tt.decision, tt.admitErr)
+			l := logger.NewMockLogger(ctrl)
+			if tt.admitErr != nil {
+				l.EXPECT().Error("trigger: Admit", "error", tt.admitErr)
+			}
+			if tt.expectedStatus == http.StatusOK || tt.addErr != nil {
+				l.EXPECT().With("call_id", "1", "virtual_agent_id", "aaa").Return(l)
+				statusStorage.EXPECT().SaveStatus(gomock.Any(), call.Status{State: call.StateQueued}, gomock.Any()).Return(nil)
+				callSaver.EXPECT().AddToQueueBack(gomock.Any(), gomock.Any()).Return(tt.addErr)
+			}
+			if tt.expectedStatus == http.StatusOK {
+				l.EXPECT().Info("call queued")
+			}
+			if tt.addErr != nil {
+				// the call isn't dialed, its status is completed.
+				statusStorage.EXPECT().SaveStatus(gomock.Any(), call.Status{State: call.StateExpired}, gomock.Any()).Return(nil)
+			}
+			s := NewServer(callSaver, statusStorage, func() string { return "1" }, realtime.NewFake(now), l, nil, nil, nil, nil, admitter, 0)
+
+			testReq, response := BuildTestReq(http.MethodPost, "/trigger", call.Body{PhoneNumber: "777", VirtualAgentID: "aaa"})
 			s.Trigger(response, testReq)
+			assert.Equal(t, tt.expectedStatus, response.Code)
+			assert.Equal(t, tt.expectedBody, response.Body.String())
+			assert.Equal(t, tt.expectedRetryAfter, response.Header().Get("Retry-After"))
+		})
+	}
+}
+
+func TestServer_Trigger_TraceParent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	callSaver := NewMockCallSaver(ctrl)
+	statusStorage := NewMockStatusStorage(ctrl)
+	l := logger.NewMockLogger(ctrl)
+	clock := realtime.NewFake(time.Unix(1709464831, 0))
+	tracer := tracing.NewTracer(tracing.NewNop(), clock, rand.New(rand.NewSource(1)))
+	s := NewServer(callSaver, statusStorage, func() string { return "1" }, clock, l, nil, tracer, nil, nil, nil, 0)
+
+	incoming := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	l.EXPECT().With("call_id", "1", "virtual_agent_id", "aaa").Return(l)
+	l.EXPECT().Info("call queued")
+	statusStorage.EXPECT().SaveStatus(gomock.Any(), call.Status{State: call.StateQueued}, gomock.Any()).Return(nil)
+	var queued call.Meta
+	callSaver.EXPECT().AddToQueueBack(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, meta call.Meta) error {
+		queued = meta
+		return nil
+	})
+
+	testReq, response := BuildTestReq(http.MethodPost, "/trigger", call.Body{PhoneNumber: "777", VirtualAgentID: "aaa"})
+	testReq.Header.Set(tracing.HeaderTraceParent, incoming)
+	s.Trigger(response, testReq)
+
+	assert.Equal(t, http.StatusOK, response.Code)
+	parent, _ := tracing.ParseTraceParent(incoming)
+	sc, err := tracing.ParseTraceParent(queued.TraceParent)
+	assert.NoError(t, err)
+	// the worker continues the trace as a child of the trigger span.
+	assert.Equal(t, parent.TraceID, sc.TraceID)
+	assert.NotEqual(t, parent.SpanID, sc.SpanID)
+}
+
+func TestServer_CallStatus(t *testing.T) {
+	tests := []struct {
+		name           string
+		method, path   string
+		client         *auth.Client
+		expectedFunc   func(statusStorage *MockStatusStorage, l *logger.MockLogger)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "method not allowed",
+			method:         http.MethodPost,
+			path:           "/calls/1",
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedBody:   "",
+		},
+		{
+			name:           "empty id",
+			method:         http.MethodGet,
+			path:           "/calls/",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "404 page not found\n",
+		},
+		{
+			name:   "unknown call",
+			method: http.MethodGet,
+			path:   "/calls/1",
+			expectedFunc: func(statusStorage *MockStatusStorage, l *logger.MockLogger) {
+				statusStorage.EXPECT().Status(gomock.Any(), call.ID("1")).Return(call.Status{}, false, nil)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "404 page not found\n",
+		},
+		{
+			name:   "storage error",
+			method: http.MethodGet,
+			path:   "/calls/1",
+			expectedFunc: func(statusStorage *MockStatusStorage, l *logger.MockLogger) {
+				statusStorage.EXPECT().Status(gomock.Any(), call.ID("1")).Return(call.Status{}, false, errors.New("some err"))
+				l.EXPECT().Error("call status", "call_id", "1", "error", errors.New("some err"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "",
+		},
+		{
+			name:   "success",
+			method: http.MethodGet,
+			path:   "/calls/1",
+			expectedFunc: func(statusStorage *MockStatusStorage, l *logger.MockLogger) {
+				statusStorage.EXPECT().Status(gomock.Any(), call.ID("1")).Return(call.Status{State: call.StateFinished, Code: 200, Outcome: call.OutcomeAnswered}, true, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"call_id":"1","state":"finished","code":200,"outcome":"answered"}`,
+		},
+		{
+			name:   "call of the tenant",
+			method: http.MethodGet,
+			path:   "/calls/1",
+			client: &auth.Client{ID: "crm", Tenant: "acme"},
+			expectedFunc: func(statusStorage *MockStatusStorage, l *logger.MockLogger) {
+				statusStorage.EXPECT().Status(gomock.Any(), call.ID("1")).Return(call.Status{State: call.StateQueued, Tenant: "acme"}, true, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"call_id":"1","state":"queued"}`,
+		},
+		{
+			name:   "call of another tenant is unknown",
+			method: http.MethodGet,
+			path:   "/calls/1",
+			client: &auth.Client{ID: "crm", Tenant: "acme"},
+			expectedFunc: func(statusStorage *MockStatusStorage, l *logger.MockLogger) {
+				statusStorage.EXPECT().Status(gomock.Any(), call.ID("1")).Return(call.Status{State: call.StateQueued, Tenant: "other"}, true, nil)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "404 page not found\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			statusStorage := NewMockStatusStorage(ctrl)
+			l := logger.NewMockLogger(ctrl)
+			s := NewServer(NewMockCallSaver(ctrl), statusStorage, nil, realtime.NewFake(time.Unix(1709464831, 0)), l, nil, nil, nil, nil, nil, 0)
+			if tt.expectedFunc != nil {
+				tt.expectedFunc(statusStorage, l)
+			}
+			ao := assert.New(t)
+			testReq, response := BuildTestReq(tt.method, tt.path, nil)
+			if tt.client != nil {
+				testReq = testReq.WithContext(auth.ContextWithClient(testReq.Context(), *tt.client))
+			}
+			s.CallStatus(response, testReq)
 			ao.Equal(tt.expectedStatus, response.Code)
 			ao.Equal(tt.expectedBody, response.Body.String())
 		})
@@ -0,0 +1,204 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"test_trigger/internal/call/dispatch"
+	"test_trigger/internal/call/router"
+	"test_trigger/internal/logger"
+	"test_trigger/internal/realtime"
+)
+
+//go:generate go run github.com/golang/mock/mockgen --source=health.go --destination=health_mock.go --package=health
+
+type QueueLengthGetter interface {
+	QueueLength(_ context.Context) (int, error)
+}
+
+type WorkersCounter interface {
+	Running() int
+}
+
+type Providers interface {
+	Healthy() bool
+	State() []router.ProviderState
+}
+
+type LimiterState interface {
+	Remaining() uint64
+}
+
+type DispatchState interface {
+	State() dispatch.State
+}
+
+// CheckOK is the result of the passed check, otherwise the result is a reason of the failure.
+const CheckOK = "ok"
+
+// ReadyResponse response struct for /readyz request.
+type ReadyResponse struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+// StatusResponse response struct for /status request.
+type StatusResponse struct {
+	ReadyResponse
+	Uptime    string                 `json:"uptime"`
+	Workers   int                    `json:"workers"`
+	Queue     *int                   `json:"queue_length,omitempty"` // nil if storage isn't reachable.
+	Limiter   uint64                 `json:"limiter_remaining"`
+	Providers []router.ProviderState `json:"providers"`
+	Dispatch  dispatch.State         `json:"dispatch"`
+}
+
+// Checker serves probes of the orchestrator and detailed status for humans.
+// Providers are unavailable for some time after failures or 429, it's normal, so readiness fails only
+// if all of them are unavailable longer than breakerGrace.
+type Checker struct {
+	queue        QueueLengthGetter
+	workers      WorkersCounter
+	providers    Providers
+	limiter      LimiterState
+	control      DispatchState
+	breakerGrace time.Duration
+	realTime     realtime.Time
+	logger       logger.Logger
+	startedAt    time.Time
+
+	mu             *sync.Mutex
+	shuttingDown   bool
+	unhealthySince time.Time
+}
+
+func NewChecker(queue QueueLengthGetter, workers WorkersCounter, providers Providers, limiter LimiterState, control DispatchState, breakerGrace time.Duration, t realtime.Time, logger logger.Logger) *Checker {
+	return &Checker{
+		queue:        queue,
+		workers:      workers,
+		providers:    providers,
+		limiter:      limiter,
+		control:      control,
+		breakerGrace: breakerGrace,
+		realTime:     t,
+		logger:       logger,
+		startedAt:    t.Now(),
+		mu:           &sync.Mutex{},
+	}
+}
+
+// SetShuttingDown makes readiness false, it should be called before the http server is stopped,
+// so the orchestrator stops sending traffic while connections are still accepted.
+func (c *Checker) SetShuttingDown() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shuttingDown = true
+}
+
+// Healthz is liveness probe, the process is alive if it can answer.
+func (c *Checker) Healthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(CheckOK))
+}
+
+// Readyz is readiness probe, 503 if any check fails.
+func (c *Checker) Readyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	resp, _ := c.ready(r.Context())
+	c.writeJSON(w, resp.Ready, resp)
+}
+
+// Status returns checks together with pool, queue, limiter, providers and dispatch state.
+func (c *Checker) Status(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	ready, queueLength := c.ready(r.Context())
+	resp := StatusResponse{
+		ReadyResponse: ready,
+		Uptime:        c.realTime.Now().Sub(c.startedAt).String(),
+		Workers:       c.workers.Running(),
+		Queue:         queueLength,
+		Limiter:       c.limiter.Remaining(),
+		Providers:     c.providers.State(),
+		Dispatch:      c.control.State(),
+	}
+	c.writeJSON(w, resp.Ready, resp)
+}
+
+func (c *Checker) ready(ctx context.Context) (ReadyResponse, *int) {
+	resp := ReadyResponse{Ready: true, Checks: make(map[string]string, 4)}
+	fail := func(check, reason string) {
+		resp.Ready = false
+		resp.Checks[check] = reason
+	}
+	c.mu.Lock()
+	shuttingDown := c.shuttingDown
+	c.mu.Unlock()
+
+	resp.Checks["shutdown"] = CheckOK
+	if shuttingDown {
+		fail("shutdown", "shutting down")
+	}
+
+	var queueLength *int
+	resp.Checks["storage"] = CheckOK
+	if length, err := c.queue.QueueLength(ctx); err != nil {
+		c.logger.Error("readiness: QueueLength", "error", err)
+		fail("storage", err.Error())
+	} else {
+		queueLength = &length
+	}
+
+	resp.Checks["workers"] = CheckOK
+	if c.workers.Running() == 0 {
+		fail("workers", "no running workers")
+	}
+
+	resp.Checks["providers"] = CheckOK
+	if !c.providersHealthy() {
+		fail("providers", "all providers are unavailable")
+	}
+	return resp, queueLength
+}
+
+// providersHealthy returns false if providers have been unavailable longer than breakerGrace.
+func (c *Checker) providersHealthy() bool {
+	healthy := c.providers.Healthy()
+	now := c.realTime.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if healthy {
+		c.unhealthySince = time.Time{}
+		return true
+	}
+	if c.unhealthySince.IsZero() {
+		c.unhealthySince = now
+	}
+	return now.Sub(c.unhealthySince) < c.breakerGrace
+}
+
+func (c *Checker) writeJSON(w http.ResponseWriter, ok bool, resp interface{}) {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		c.logger.Error("health: marshall", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,214 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: health.go
+
+// Package health is a generated GoMock package.
+package health
+
+import (
+	context "context"
+	reflect "reflect"
+	dispatch "test_trigger/internal/call/dispatch"
+	router "test_trigger/internal/call/router"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockQueueLengthGetter is a mock of QueueLengthGetter interface.
+type MockQueueLengthGetter struct {
+	ctrl     *gomock.Controller
+	recorder *MockQueueLengthGetterMockRecorder
+}
+
+// MockQueueLengthGetterMockRecorder is the mock recorder for MockQueueLengthGetter.
+type MockQueueLengthGetterMockRecorder struct {
+	mock *MockQueueLengthGetter
+}
+
+// NewMockQueueLengthGetter creates a new mock instance.
+func NewMockQueueLengthGetter(ctrl *gomock.Controller) *MockQueueLengthGetter {
+	mock := &MockQueueLengthGetter{ctrl: ctrl}
+	mock.recorder = &MockQueueLengthGetterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockQueueLengthGetter) EXPECT() *MockQueueLengthGetterMockRecorder {
+	return m.recorder
+}
+
+// QueueLength mocks base method.
+func (m *MockQueueLengthGetter) QueueLength(arg0 context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueueLength", arg0)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueueLength indicates an expected call of QueueLength.
+func (mr *MockQueueLengthGetterMockRecorder) QueueLength(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueueLength", reflect.TypeOf((*MockQueueLengthGetter)(nil).QueueLength), arg0)
+}
+
+// MockWorkersCounter is a mock of WorkersCounter interface.
+type MockWorkersCounter struct {
+	ctrl     *gomock.Controller
+	recorder *MockWorkersCounterMockRecorder
+}
+
+// MockWorkersCounterMockRecorder is the mock recorder for MockWorkersCounter.
+type MockWorkersCounterMockRecorder struct {
+	mock *MockWorkersCounter
+}
+
+// NewMockWorkersCounter creates a new mock instance.
+func NewMockWorkersCounter(ctrl *gomock.Controller) *MockWorkersCounter {
+	mock := &MockWorkersCounter{ctrl: ctrl}
+	mock.recorder = &MockWorkersCounterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWorkersCounter) EXPECT() *MockWorkersCounterMockRecorder {
+	return m.recorder
+}
+
+// Running mocks base method.
+func (m *MockWorkersCounter) Running() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Running")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Running indicates an expected call of Running.
+func (mr *MockWorkersCounterMockRecorder) Running() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Running", reflect.TypeOf((*MockWorkersCounter)(nil).Running))
+}
+
+// MockProviders is a mock of Providers interface.
+type MockProviders struct {
+	ctrl     *gomock.Controller
+	recorder *MockProvidersMockRecorder
+}
+
+// MockProvidersMockRecorder is the mock recorder for MockProviders.
+type MockProvidersMockRecorder struct {
+	mock *MockProviders
+}
+
+// NewMockProviders creates a new mock instance.
+func NewMockProviders(ctrl *gomock.Controller) *MockProviders {
+	mock := &MockProviders{ctrl: ctrl}
+	mock.recorder = &MockProvidersMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockProviders) EXPECT() *MockProvidersMockRecorder {
+	return m.recorder
+}
+
+// Healthy mocks base method.
+func (m *MockProviders) Healthy() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Healthy")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Healthy indicates an expected call of Healthy.
+func (mr *MockProvidersMockRecorder) Healthy() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Healthy", reflect.TypeOf((*MockProviders)(nil).Healthy))
+}
+
+// State mocks base method.
+func (m *MockProviders) State() []router.ProviderState {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "State")
+	ret0, _ := ret[0].([]router.ProviderState)
+	return ret0
+}
+
+// State indicates an expected call of State.
+func (mr *MockProvidersMockRecorder) State() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "State", reflect.TypeOf((*MockProviders)(nil).State))
+}
+
+// MockLimiterState is a mock of LimiterState interface.
+type MockLimiterState struct {
+	ctrl     *gomock.Controller
+	recorder *MockLimiterStateMockRecorder
+}
+
+// MockLimiterStateMockRecorder is the mock recorder for MockLimiterState.
+type MockLimiterStateMockRecorder struct {
+	mock *MockLimiterState
+}
+
+// NewMockLimiterState creates a new mock instance.
+func NewMockLimiterState(ctrl *gomock.Controller) *MockLimiterState {
+	mock := &MockLimiterState{ctrl: ctrl}
+	mock.recorder = &MockLimiterStateMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLimiterState) EXPECT() *MockLimiterStateMockRecorder {
+	return m.recorder
+}
+
+// Remaining mocks base method.
+func (m *MockLimiterState) Remaining() uint64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Remaining")
+	ret0, _ := ret[0].(uint64)
+	return ret0
+}
+
+// Remaining indicates an expected call of Remaining.
+func (mr *MockLimiterStateMockRecorder) Remaining() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remaining", reflect.TypeOf((*MockLimiterState)(nil).Remaining))
+}
+
+// MockDispatchState is a mock of DispatchState interface.
+type MockDispatchState struct {
+	ctrl     *gomock.Controller
+	recorder *MockDispatchStateMockRecorder
+}
+
+// MockDispatchStateMockRecorder is the mock recorder for MockDispatchState.
+type MockDispatchStateMockRecorder struct {
+	mock *MockDispatchState
+}
+
+// NewMockDispatchState creates a new mock instance.
+func NewMockDispatchState(ctrl *gomock.Controller) *MockDispatchState {
+	mock := &MockDispatchState{ctrl: ctrl}
+	mock.recorder = &MockDispatchStateMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDispatchState) EXPECT() *MockDispatchStateMockRecorder {
+	return m.recorder
+}
+
+// State mocks base method.
+func (m *MockDispatchState) State() dispatch.State {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "State")
+	ret0, _ := ret[0].(dispatch.State)
+	return ret0
+}
+
+// State indicates an expected call of State.
+func (mr *MockDispatchStateMockRecorder) State() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "State", reflect.TypeOf((*MockDispatchState)(nil).State))
+}
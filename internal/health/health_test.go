@@ -0,0 +1,162 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"test_trigger/internal/call/dispatch"
+	"test_trigger/internal/call/router"
+	"test_trigger/internal/logger"
+	"test_trigger/internal/realtime"
+)
+
+func TestChecker_Healthz(t *testing.T) {
+	c := &Checker{}
+	resp := httptest.NewRecorder()
+	c.Healthz(resp, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "ok", resp.Body.String())
+
+	resp = httptest.NewRecorder()
+	c.Healthz(resp, httptest.NewRequest(http.MethodPost, "/healthz", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+}
+
+func TestChecker_Readyz(t *testing.T) {
+	testErr := errors.New("test")
+	tests := []struct {
+		name           string
+		shuttingDown   bool
+		expectedFunc   func(queue *MockQueueLengthGetter, workers *MockWorkersCounter, providers *MockProviders, l *logger.MockLogger)
+		expectedCode   int
+		expectedChecks map[string]string
+	}{
+		{
+			name: "ready",
+			expectedFunc: func(queue *MockQueueLengthGetter, workers *MockWorkersCounter, providers *MockProviders, l *logger.MockLogger) {
+				queue.EXPECT().QueueLength(gomock.Any()).Return(3, nil)
+				workers.EXPECT().Running().Return(2)
+				providers.EXPECT().Healthy().Return(true)
+			},
+			expectedCode:   http.StatusOK,
+			expectedChecks: map[string]string{"shutdown": "ok", "storage": "ok", "workers": "ok", "providers": "ok"},
+		},
+		{
+			name:         "shutting down",
+			shuttingDown: true,
+			expectedFunc: func(queue *MockQueueLengthGetter, workers *MockWorkersCounter, providers *MockProviders, l *logger.MockLogger) {
+				queue.EXPECT().QueueLength(gomock.Any()).Return(0, nil)
+				workers.EXPECT().Running().Return(2)
+				providers.EXPECT().Healthy().Return(true)
+			},
+			expectedCode:   http.StatusServiceUnavailable,
+			expectedChecks: map[string]string{"shutdown": "shutting down", "storage": "ok", "workers": "ok", "providers": "ok"},
+		},
+		{
+			name: "storage error, no workers",
+			expectedFunc: func(queue *MockQueueLengthGetter, workers *MockWorkersCounter, providers *MockProviders, l *logger.MockLogger) {
+				queue.EXPECT().QueueLength(gomock.Any()).Return(0, testErr)
+				l.EXPECT().Error("readiness: QueueLength", "error", testErr)
+				workers.EXPECT().Running().Return(0)
+				providers.EXPECT().Healthy().Return(true)
+			},
+			expectedCode:   http.StatusServiceUnavailable,
+			expectedChecks: map[string]string{"shutdown": "ok", "storage": "test", "workers": "no running workers", "providers": "ok"},
+		},
+		{
+			name: "providers are unavailable, but grace isn't over",
+			expectedFunc: func(queue *MockQueueLengthGetter, workers *MockWorkersCounter, providers *MockProviders, l *logger.MockLogger) {
+				queue.EXPECT().QueueLength(gomock.Any()).Return(0, nil)
+				workers.EXPECT().Running().Return(1)
+				providers.EXPECT().Healthy().Return(false)
+			},
+			expectedCode:   http.StatusOK,
+			expectedChecks: map[string]string{"shutdown": "ok", "storage": "ok", "workers": "ok", "providers": "ok"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			queue := NewMockQueueLengthGetter(ctrl)
+			workers := NewMockWorkersCounter(ctrl)
+			providers := NewMockProviders(ctrl)
+			l := logger.NewMockLogger(ctrl)
+			tt.expectedFunc(queue, workers, providers, l)
+			c := NewChecker(queue, workers, providers, NewMockLimiterState(ctrl), nil, time.Minute, realtime.NewFake(time.Unix(1709464831, 0)), l)
+			if tt.shuttingDown {
+				c.SetShuttingDown()
+			}
+
+			resp := httptest.NewRecorder()
+			c.Readyz(resp, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			assert.Equal(t, tt.expectedCode, resp.Code)
+			res := ReadyResponse{}
+			assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+			assert.Equal(t, tt.expectedCode == http.StatusOK, res.Ready)
+			assert.Equal(t, tt.expectedChecks, res.Checks)
+		})
+	}
+}
+
+func TestChecker_providersHealthy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	providers := NewMockProviders(ctrl)
+	clock := realtime.NewFake(time.Unix(1709464831, 0))
+	c := NewChecker(nil, nil, providers, nil, nil, time.Minute, clock, nil)
+
+	providers.EXPECT().Healthy().Return(false).Times(3)
+	assert.True(t, c.providersHealthy())
+	clock.Advance(59 * time.Second)
+	assert.True(t, c.providersHealthy())
+	clock.Advance(time.Second)
+	assert.False(t, c.providersHealthy())
+
+	// recovery resets the grace.
+	providers.EXPECT().Healthy().Return(true)
+	assert.True(t, c.providersHealthy())
+	providers.EXPECT().Healthy().Return(false)
+	assert.True(t, c.providersHealthy())
+}
+
+func TestChecker_Status(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	queue := NewMockQueueLengthGetter(ctrl)
+	workers := NewMockWorkersCounter(ctrl)
+	providers := NewMockProviders(ctrl)
+	lim := NewMockLimiterState(ctrl)
+	control := NewMockDispatchState(ctrl)
+	clock := realtime.NewFake(time.Unix(1709464831, 0))
+	c := NewChecker(queue, workers, providers, lim, control, time.Minute, clock, logger.NewMockLogger(ctrl))
+	clock.Advance(90 * time.Second)
+
+	queue.EXPECT().QueueLength(gomock.Any()).Return(5, nil)
+	workers.EXPECT().Running().Return(3).Times(2)
+	providers.EXPECT().Healthy().Return(true)
+	providers.EXPECT().State().Return([]router.ProviderState{{Name: "default", Weight: 1, Healthy: true}})
+	lim.EXPECT().Remaining().Return(uint64(20))
+	control.EXPECT().State().Return(dispatch.State{Mode: dispatch.ModePaused, PausedAgents: []string{"a"}, Parked: 2})
+
+	resp := httptest.NewRecorder()
+	c.Status(resp, httptest.NewRequest(http.MethodGet, "/status", nil))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+	res := StatusResponse{}
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+	length := 5
+	assert.Equal(t, StatusResponse{
+		ReadyResponse: ReadyResponse{Ready: true, Checks: map[string]string{"shutdown": "ok", "storage": "ok", "workers": "ok", "providers": "ok"}},
+		Uptime:        "1m30s",
+		Workers:       3,
+		Queue:         &length,
+		Limiter:       20,
+		Providers:     []router.ProviderState{{Name: "default", Weight: 1, Healthy: true}},
+		Dispatch:      dispatch.State{Mode: dispatch.ModePaused, PausedAgents: []string{"a"}, Parked: 2},
+	}, res)
+}
@@ -6,6 +6,8 @@ import (
 	"io"
 	"net/http"
 	"time"
+
+	"test_trigger/internal/tracing"
 )
 
 // Client implements http request logic.
@@ -22,11 +24,46 @@ func NewClient(requestTimeout time.Duration) *Client {
 
 // MakePostRequest sends http POST request with body.
 func (c *Client) MakePostRequest(ctx context.Context, url string, body []byte) ([]byte, int, error) {
+	return c.MakePostRequestWithHeaders(ctx, url, body, nil)
+}
+
+// MakePostRequestWithHeaders sends http POST request with body and additional headers, e.g. signature.
+func (c *Client) MakePostRequestWithHeaders(ctx context.Context, url string, body []byte, headers map[string]string) ([]byte, int, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(body))
 	if err != nil {
 		return nil, 0, err
 	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	return c.do(req)
+}
+
+// MakeGetRequest sends http GET request.
+func (c *Client) MakeGetRequest(ctx context.Context, url string) ([]byte, int, error) {
+	return c.MakeGetRequestWithHeaders(ctx, url, nil)
+}
+
+// MakeGetRequestWithHeaders sends http GET request with additional headers, e.g. authorization.
+func (c *Client) MakeGetRequestWithHeaders(ctx context.Context, url string, headers map[string]string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	return c.do(req)
+}
 
+func (c *Client) do(req *http.Request) ([]byte, int, error) {
+	// the current span from ctx becomes a parent for the span of the receiver.
+	if sc := tracing.SpanContextFromContext(req.Context()); sc.IsValid() {
+		req.Header.Set(tracing.HeaderTraceParent, sc.TraceParent())
+	}
 	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, 0, err
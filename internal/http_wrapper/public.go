@@ -0,0 +1,47 @@
+package http_wrapper
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrNotPublic is returned, when the host of the url resolves to an address, which isn't public.
+var ErrNotPublic = errors.New("address isn't public")
+
+// NewPublicClient is NewClient, which connects only to public addresses, it's used for urls given by API clients.
+// Addresses are checked after resolution, so a host can't be pointed to internal services by DNS.
+func NewPublicClient(requestTimeout time.Duration) *Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: dialPublic}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// a proxy would be dialed instead of the host.
+	transport.Proxy = nil
+	return &Client{client: &http.Client{Timeout: requestTimeout, Transport: transport}}
+}
+
+func dialPublic(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !Public(addr) {
+		return fmt.Errorf("%w: %s", ErrNotPublic, addr)
+	}
+	return nil
+}
+
+// Public is false for loopback, private, link-local(cloud metadata is there), multicast and unspecified addresses.
+func Public(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() && !addr.IsLoopback() && !addr.IsPrivate() && !addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() && !addr.IsInterfaceLocalMulticast() && !addr.IsMulticast() && !addr.IsUnspecified()
+}
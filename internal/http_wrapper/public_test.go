@@ -0,0 +1,56 @@
+package http_wrapper
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublic(t *testing.T) {
+	tests := []struct {
+		addr     string
+		expected bool
+	}{
+		{"8.8.8.8", true},
+		{"2001:4860:4860::8888", true},
+		{"127.0.0.1", false},        // loopback.
+		{"127.1.2.3", false},        // loopback.
+		{"::1", false},              // loopback.
+		{"10.1.2.3", false},         // private.
+		{"172.16.0.1", false},       // private.
+		{"172.31.255.255", false},   // private.
+		{"192.168.1.1", false},      // private.
+		{"fd00::1", false},          // private.
+		{"169.254.169.254", false},  // link-local, cloud metadata.
+		{"fe80::1", false},          // link-local.
+		{"0.0.0.0", false},          // unspecified.
+		{"::", false},               // unspecified.
+		{"224.0.0.1", false},        // multicast.
+		{"::ffff:127.0.0.1", false}, // mapped loopback.
+		{"::ffff:10.0.0.1", false},  // mapped private.
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, Public(netip.MustParseAddr(tt.addr)), tt.addr)
+	}
+}
+
+func TestNewPublicClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("loopback server shouldn't be reached")
+	}))
+	defer server.Close()
+
+	// localhost is resolved, the address is refused before connection.
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	for _, url := range []string{server.URL, "http://localhost:" + port} {
+		_, _, err := NewPublicClient(time.Second).MakePostRequest(context.Background(), url, []byte("{}"))
+		assert.True(t, errors.Is(err, ErrNotPublic), err)
+	}
+}
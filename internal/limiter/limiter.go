@@ -42,6 +42,45 @@ func (s *SlidingWindow) Allow() bool {
 	return true
 }
 
+// Remaining returns how many requests will be allowed right now, it doesn't consume them.
+func (s *SlidingWindow) Remaining() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refreshCurrentState(s.RealTime.Now().Unix())
+	if s.counter >= s.limit {
+		return 0
+	}
+	return s.limit - s.counter
+}
+
+// Rate returns the limit per second.
+func (s *SlidingWindow) Rate() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return float64(s.limit) / float64(s.size)
+}
+
+// Resize changes the window and the limit, requests of the last seconds are kept, so resize doesn't reset the limit.
+// Seconds older than the new window are dropped.
+func (s *SlidingWindow) Resize(size uint64, limit uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	currentTime := s.RealTime.Now().Unix()
+	s.refreshCurrentState(currentTime)
+	newSize := int64(size)
+	newStart := s.windowStart
+	if currentTime-newSize+1 > newStart {
+		newStart = currentTime - newSize + 1
+	}
+	entries := make([]uint64, newSize)
+	counter := uint64(0)
+	for t := newStart; t <= currentTime; t++ {
+		entries[t%newSize] = s.entries[t%s.size]
+		counter += entries[t%newSize]
+	}
+	s.size, s.limit, s.windowStart, s.entries, s.counter = newSize, limit, newStart, entries, counter
+}
+
 func (s *SlidingWindow) refreshCurrentState(currentTime int64) {
 	toDelete := currentTime - s.windowStart - s.size + 1
 	if toDelete <= 0 {
@@ -64,3 +103,27 @@ func (s *SlidingWindow) refreshCurrentState(currentTime int64) {
 		s.entries[localHead+i] = 0
 	}
 }
+
+// Named keeps limiters of providers by name, a provider rebuilt on reload keeps the window history of its limiter.
+type Named struct {
+	RealTime realtime.Time
+	limiters map[string]*SlidingWindow
+	mu       *sync.Mutex
+}
+
+func NewNamed(t realtime.Time) *Named {
+	return &Named{RealTime: t, limiters: make(map[string]*SlidingWindow), mu: &sync.Mutex{}}
+}
+
+// Get returns the limiter of the name resized to size and limit, it's created on the first call.
+func (n *Named) Get(name string, size uint64, limit uint64) *SlidingWindow {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if s, ok := n.limiters[name]; ok {
+		s.Resize(size, limit)
+		return s
+	}
+	s := NewSlidingWindow(size, limit, n.RealTime)
+	n.limiters[name] = s
+	return s
+}
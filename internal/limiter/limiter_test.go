@@ -217,6 +217,115 @@ func TestSlidingWindow_Allow(t *testing.T) {
 	}
 }
 
+func TestSlidingWindow_Remaining(t *testing.T) {
+	type fields struct {
+		windowStart int64
+		counter     uint64
+		entries     []uint64
+	}
+	tests := []struct {
+		name     string
+		fields   fields
+		now      time.Time
+		expected uint64
+	}{
+		{
+			name: "empty window",
+			fields: fields{
+				windowStart: 1709464830,
+				counter:     0,
+				entries:     make([]uint64, 10),
+			},
+			now:      time.Unix(1709464831, 0),
+			expected: 10,
+		},
+		{
+			name: "limit exceeded",
+			fields: fields{
+				windowStart: 1709464830,
+				counter:     10,
+				entries:     []uint64{5, 5, 0, 0, 0, 0, 0, 0, 0, 0},
+			},
+			now:      time.Unix(1709464831, 0),
+			expected: 0,
+		},
+		{
+			name: "part of the window expired",
+			fields: fields{
+				windowStart: 1709464830,
+				counter:     10,
+				entries:     []uint64{5, 5, 0, 0, 0, 0, 0, 0, 0, 0},
+			},
+			now:      time.Unix(1709464840, 0),
+			expected: 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockTime := realtime.NewMockTime(ctrl)
+			mockTime.EXPECT().Now().Return(tt.now).Times(1)
+			s := &SlidingWindow{
+				RealTime:    mockTime,
+				size:        10,
+				limit:       10,
+				windowStart: tt.fields.windowStart,
+				counter:     tt.fields.counter,
+				entries:     tt.fields.entries,
+				mu:          &sync.Mutex{},
+			}
+			assert.Equal(t, tt.expected, s.Remaining())
+			// Remaining doesn't consume the limit.
+			assert.Equal(t, tt.expected, s.limit-s.counter)
+		})
+	}
+}
+
 // TODO add tests.
 func TestSlidingWindow_refreshCurrentState(t *testing.T) {
 }
+
+func TestSlidingWindow_Resize(t *testing.T) {
+	clock := realtime.NewFake(time.Unix(1709464830, 0))
+	s := NewSlidingWindow(10, 10, clock)
+	// 2 requests per second during 5 seconds.
+	for i := 0; i < 5; i++ {
+		assert.True(t, s.Allow())
+		assert.True(t, s.Allow())
+		clock.Advance(time.Second)
+	}
+	clock.Advance(-time.Second)
+	assert.Equal(t, uint64(0), s.Remaining())
+
+	// history is kept, only the limit is raised.
+	s.Resize(10, 15)
+	assert.Equal(t, 1.5, s.Rate())
+	assert.Equal(t, uint64(5), s.Remaining())
+
+	// the last 3 seconds are kept.
+	s.Resize(3, 10)
+	assert.Equal(t, uint64(4), s.Remaining())
+	clock.Advance(time.Second)
+	assert.Equal(t, uint64(6), s.Remaining())
+
+	// growing keeps everything, requests stay in the longer window.
+	s.Resize(20, 10)
+	assert.Equal(t, uint64(6), s.Remaining())
+	clock.Advance(17 * time.Second)
+	assert.Equal(t, uint64(6), s.Remaining())
+	clock.Advance(time.Second)
+	assert.Equal(t, uint64(8), s.Remaining())
+}
+
+func TestNamed_Get(t *testing.T) {
+	n := NewNamed(realtime.NewFake(time.Unix(1709464830, 0)))
+	first := n.Get("first", 10, 2)
+	assert.True(t, first.Allow())
+
+	// the same limiter with the history is returned for the name.
+	resized := n.Get("first", 10, 5)
+	assert.Same(t, first, resized)
+	assert.Equal(t, uint64(4), resized.Remaining())
+
+	assert.Equal(t, uint64(3), n.Get("second", 10, 3).Remaining())
+}
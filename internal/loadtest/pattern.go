@@ -0,0 +1,111 @@
+package loadtest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	PatternConstant = "constant"
+	PatternBurst    = "burst"
+	PatternRamp     = "ramp"
+)
+
+// PatternConfig describes /trigger traffic.
+type PatternConfig struct {
+	Name          string
+	Rate          float64 // requests per second, start rate for ramp.
+	RampTo        float64 // final rate for ramp.
+	BurstSize     int
+	BurstInterval time.Duration
+	Duration      time.Duration
+}
+
+// Schedule returns offsets from the start for every request.
+func Schedule(cfg PatternConfig) ([]time.Duration, error) {
+	if cfg.Duration <= 0 {
+		return nil, errors.New("duration should be greater than 0")
+	}
+	switch cfg.Name {
+	case PatternConstant:
+		if cfg.Rate <= 0 {
+			return nil, errors.New("rate should be greater than 0")
+		}
+		return rampSchedule(cfg.Rate, cfg.Rate, cfg.Duration), nil
+	case PatternRamp:
+		if cfg.Rate < 0 || cfg.RampTo < 0 || cfg.Rate+cfg.RampTo == 0 {
+			return nil, errors.New("rate and ramp-to should be positive")
+		}
+		return rampSchedule(cfg.Rate, cfg.RampTo, cfg.Duration), nil
+	case PatternBurst:
+		if cfg.BurstSize <= 0 || cfg.BurstInterval <= 0 {
+			return nil, errors.New("burst size and interval should be greater than 0")
+		}
+		res := make([]time.Duration, 0)
+		for start := time.Duration(0); start < cfg.Duration; start += cfg.BurstInterval {
+			for i := 0; i < cfg.BurstSize; i++ {
+				res = append(res, start)
+			}
+		}
+		return res, nil
+	default:
+		return nil, fmt.Errorf("unknown pattern %q", cfg.Name)
+	}
+}
+
+// rampSchedule - rate changes linearly from "from" to "to", constant pattern is a ramp with from == to.
+// Requests count up to t is N(t) = from*t + (to-from)*t^2/(2*duration), the k-th request is sent when N(t) = k.
+func rampSchedule(from, to float64, duration time.Duration) []time.Duration {
+	d := duration.Seconds()
+	a := (to - from) / (2 * d)
+	res := make([]time.Duration, 0, int((from+to)/2*d)+1)
+	for k := 0.0; ; k++ {
+		var t float64
+		if a == 0 {
+			t = k / from
+		} else {
+			t = (-from + math.Sqrt(from*from+4*a*k)) / (2 * a)
+		}
+		if t >= d || math.IsNaN(t) {
+			return res
+		}
+		res = append(res, time.Duration(t*float64(time.Second)))
+	}
+}
+
+// Percentiles of latencies.
+type Percentiles struct {
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+// NewPercentiles calculates nearest-rank percentiles, input is sorted inside.
+func NewPercentiles(values []time.Duration) Percentiles {
+	if len(values) == 0 {
+		return Percentiles{}
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	return Percentiles{
+		P50: percentile(values, 0.5),
+		P90: percentile(values, 0.9),
+		P99: percentile(values, 0.99),
+		Max: values[len(values)-1],
+	}
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+func (p Percentiles) String() string {
+	return fmt.Sprintf("p50=%v p90=%v p99=%v max=%v", p.P50, p.P90, p.P99, p.Max)
+}
@@ -0,0 +1,72 @@
+package loadtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedule(t *testing.T) {
+	tests := []struct {
+		name          string
+		cfg           PatternConfig
+		expectedLen   int
+		expectedFirst []time.Duration
+		isErr         bool
+	}{
+		{
+			name:          "constant",
+			cfg:           PatternConfig{Name: PatternConstant, Rate: 2, Duration: 10 * time.Second},
+			expectedLen:   20,
+			expectedFirst: []time.Duration{0, 500 * time.Millisecond, time.Second},
+		},
+		{
+			name:          "burst",
+			cfg:           PatternConfig{Name: PatternBurst, BurstSize: 3, BurstInterval: 5 * time.Second, Duration: 10 * time.Second},
+			expectedLen:   6,
+			expectedFirst: []time.Duration{0, 0, 0, 5 * time.Second},
+		},
+		{
+			name:          "ramp from 0 to 10",
+			cfg:           PatternConfig{Name: PatternRamp, Rate: 0, RampTo: 10, Duration: 10 * time.Second},
+			expectedLen:   50,
+			expectedFirst: []time.Duration{0, 1414213562, 2 * time.Second},
+		},
+		{
+			name:  "unknown",
+			cfg:   PatternConfig{Name: "zigzag", Rate: 1, Duration: time.Second},
+			isErr: true,
+		},
+		{
+			name:  "zero rate",
+			cfg:   PatternConfig{Name: PatternConstant, Duration: time.Second},
+			isErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := Schedule(tt.cfg)
+			ao := assert.New(t)
+			ao.Equal(tt.isErr, err != nil)
+			ao.Len(actual, tt.expectedLen)
+			for i, expected := range tt.expectedFirst {
+				ao.InDelta(expected, actual[i], float64(time.Microsecond))
+			}
+		})
+	}
+}
+
+func TestNewPercentiles(t *testing.T) {
+	values := make([]time.Duration, 0, 100)
+	for i := 100; i > 0; i-- {
+		values = append(values, time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(t, Percentiles{
+		P50: 50 * time.Millisecond,
+		P90: 90 * time.Millisecond,
+		P99: 99 * time.Millisecond,
+		Max: 100 * time.Millisecond,
+	}, NewPercentiles(values))
+	assert.Equal(t, Percentiles{}, NewPercentiles(nil))
+}
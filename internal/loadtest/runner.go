@@ -0,0 +1,352 @@
+package loadtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"test_trigger/internal"
+	"test_trigger/internal/auth"
+	"test_trigger/internal/call"
+	"test_trigger/internal/logger"
+	"test_trigger/internal/realtime"
+	"test_trigger/internal/signature"
+	"test_trigger/internal/simulator"
+)
+
+//go:generate go run github.com/golang/mock/mockgen --source=runner.go --destination=runner_mock.go --package=loadtest
+
+const (
+	// maxInFlightTriggers limits concurrent /trigger requests, bursts shouldn't exhaust local sockets.
+	maxInFlightTriggers = 64
+	// maxInFlightPolls limits concurrent /calls/{id} requests, one poll of thousands of pending calls shouldn't take seconds.
+	maxInFlightPolls = 32
+)
+
+type HTTPWrapper interface {
+	MakePostRequestWithHeaders(ctx context.Context, url string, body []byte, headers map[string]string) ([]byte, int, error)
+	MakeGetRequest(ctx context.Context, url string) ([]byte, int, error)
+	MakeGetRequestWithHeaders(ctx context.Context, url string, headers map[string]string) ([]byte, int, error)
+}
+
+// Config describes one load test run.
+type Config struct {
+	Target           string // base URL of the trigger server.
+	APIKey           string // optional, sent as "Authorization: Bearer <key>" to the trigger server.
+	SigningSecret    string // optional signing secret of the API client.
+	ProviderStatsURL string // optional GET /stats of provider simulator.
+	Pattern          PatternConfig
+	PollInterval     time.Duration
+	PollTimeout      time.Duration // how long to wait for results after the last request.
+	VirtualAgentID   string
+	ProviderLimit    uint64
+	ProviderWindow   time.Duration
+}
+
+// Report is a result of the load test.
+type Report struct {
+	Sent                  int
+	Accepted              int
+	Rejected              int
+	SendDuration          time.Duration
+	TriggerLatency        Percentiles
+	EndToEndLatency       Percentiles
+	Finished              int
+	Failed                int // moved to dead letters.
+	Expired               int // not dialed before expiry.
+	Unfinished            int
+	Outcomes              map[call.Outcome]int // of completed calls, failed ones have the outcome of the last attempt.
+	RateLimitedCalls      int                  // calls which were seen with rate_limited status.
+	ProviderStats         *simulator.Stats
+	OriginateThroughput   float64 // per second.
+	TheoreticalThroughput float64 // per second.
+}
+
+type pendingCall struct {
+	sentAt      time.Time
+	rateLimited bool
+}
+
+// Runner fires /trigger traffic and polls /calls/{id} for results.
+type Runner struct {
+	cfg         Config
+	httpWrapper HTTPWrapper
+	realTime    realtime.Time
+	logger      logger.Logger
+
+	mu             *sync.Mutex
+	pending        map[string]*pendingCall
+	report         Report
+	triggerLatency []time.Duration
+	endToEnd       []time.Duration
+	firstSent      time.Time
+	lastFinished   time.Time
+}
+
+func NewRunner(cfg Config, httpWrapper HTTPWrapper, t realtime.Time, logger logger.Logger) *Runner {
+	return &Runner{
+		cfg:         cfg,
+		httpWrapper: httpWrapper,
+		realTime:    t,
+		logger:      logger,
+		mu:          &sync.Mutex{},
+		pending:     make(map[string]*pendingCall),
+		report:      Report{Outcomes: make(map[call.Outcome]int)},
+	}
+}
+
+// Run sends requests according to the pattern, then waits for results.
+func (r *Runner) Run(ctx context.Context) (Report, error) {
+	schedule, err := Schedule(r.cfg.Pattern)
+	if err != nil {
+		return Report{}, fmt.Errorf("run: %v", err)
+	}
+
+	sendDone := make(chan struct{})
+	pollDone := make(chan struct{})
+	go func() {
+		defer close(pollDone)
+		r.poll(ctx, sendDone)
+	}()
+
+	start := r.realTime.Now()
+	r.firstSent = start
+	inFlight := make(chan struct{}, maxInFlightTriggers)
+	wg := &sync.WaitGroup{}
+sendLoop:
+	for i, offset := range schedule {
+		if wait := start.Add(offset).Sub(r.realTime.Now()); wait > 0 {
+			timer := r.realTime.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				break sendLoop
+			case <-timer.C():
+			}
+		}
+		inFlight <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-inFlight }()
+			r.trigger(ctx, i)
+		}(i)
+	}
+	wg.Wait()
+	r.mu.Lock()
+	r.report.SendDuration = r.realTime.Now().Sub(start)
+	r.mu.Unlock()
+	close(sendDone)
+	<-pollDone
+
+	return r.buildReport(ctx), nil
+}
+
+func (r *Runner) trigger(ctx context.Context, i int) {
+	body, _ := json.Marshal(call.Body{
+		PhoneNumber:    fmt.Sprintf("+4470%08d", i),
+		VirtualAgentID: r.cfg.VirtualAgentID,
+	})
+	sentAt := r.realTime.Now()
+	respBody, status, err := r.httpWrapper.MakePostRequestWithHeaders(ctx, r.cfg.Target+"/trigger", body, r.headers(body))
+	latency := r.realTime.Now().Sub(sentAt)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.report.Sent++
+	if err != nil || status != http.StatusOK {
+		r.report.Rejected++
+		return
+	}
+	resp := internal.TriggerResponse{}
+	if err = json.Unmarshal(respBody, &resp); err != nil || resp.CallID == "" {
+		r.report.Rejected++
+		return
+	}
+	r.report.Accepted++
+	r.triggerLatency = append(r.triggerLatency, latency)
+	r.pending[resp.CallID] = &pendingCall{sentAt: sentAt}
+}
+
+// poll checks pending calls every PollInterval until all are finished, or PollTimeout after sending.
+func (r *Runner) poll(ctx context.Context, sendDone <-chan struct{}) {
+	ticker := r.realTime.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+	var deadline <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline:
+			return
+		case <-sendDone:
+			sendDone = nil
+			timer := r.realTime.NewTimer(r.cfg.PollTimeout)
+			defer timer.Stop()
+			deadline = timer.C()
+		case <-ticker.C():
+			left := r.pollOnce(ctx)
+			if left == 0 && sendDone == nil {
+				return
+			}
+		}
+	}
+}
+
+// pollOnce returns number of still pending calls.
+func (r *Runner) pollOnce(ctx context.Context) int {
+	r.mu.Lock()
+	ids := make([]string, 0, len(r.pending))
+	for id := range r.pending {
+		ids = append(ids, id)
+	}
+	r.mu.Unlock()
+
+	inFlight := make(chan struct{}, maxInFlightPolls)
+	wg := &sync.WaitGroup{}
+	for _, id := range ids {
+		inFlight <- struct{}{}
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			defer func() { <-inFlight }()
+			r.pollCall(ctx, id)
+		}(id)
+	}
+	wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.pending)
+}
+
+func (r *Runner) pollCall(ctx context.Context, id string) {
+	respBody, status, err := r.httpWrapper.MakeGetRequestWithHeaders(ctx, r.cfg.Target+"/calls/"+id, r.headers(nil))
+	if err != nil || status != http.StatusOK {
+		return
+	}
+	resp := internal.StatusResponse{}
+	if err = json.Unmarshal(respBody, &resp); err != nil {
+		return
+	}
+	r.observe(id, resp.Status, r.realTime.Now())
+}
+
+// headers authenticate requests to the trigger server like an API client, there are none without an API key.
+func (r *Runner) headers(body []byte) map[string]string {
+	if r.cfg.APIKey == "" {
+		return nil
+	}
+	headers := map[string]string{"Authorization": "Bearer " + r.cfg.APIKey}
+	if r.cfg.SigningSecret != "" {
+		timestamp := strconv.FormatInt(r.realTime.Now().Unix(), 10)
+		headers[auth.HeaderTimestamp] = timestamp
+		headers[auth.HeaderSignature] = signature.Sign(r.cfg.SigningSecret, timestamp, body)
+	}
+	return headers
+}
+
+func (r *Runner) observe(id string, status call.Status, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.pending[id]
+	if !ok {
+		return
+	}
+	if status.Outcome == call.OutcomeRateLimited {
+		p.rateLimited = true
+	}
+	if !status.State.Terminal() {
+		return
+	}
+	delete(r.pending, id)
+	switch status.State {
+	case call.StateFailed:
+		r.report.Failed++
+	case call.StateExpired:
+		r.report.Expired++
+	default:
+		r.report.Finished++
+	}
+	if status.Outcome != "" {
+		r.report.Outcomes[status.Outcome]++
+	}
+	if p.rateLimited {
+		r.report.RateLimitedCalls++
+	}
+	r.endToEnd = append(r.endToEnd, now.Sub(p.sentAt))
+	r.lastFinished = now
+}
+
+func (r *Runner) buildReport(ctx context.Context) Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := r.report
+	res.Unfinished = len(r.pending)
+	for _, p := range r.pending {
+		if p.rateLimited {
+			res.RateLimitedCalls++
+		}
+	}
+	res.TriggerLatency = NewPercentiles(r.triggerLatency)
+	res.EndToEndLatency = NewPercentiles(r.endToEnd)
+	if r.cfg.ProviderWindow > 0 {
+		res.TheoreticalThroughput = float64(r.cfg.ProviderLimit) / r.cfg.ProviderWindow.Seconds()
+	}
+
+	if stats, err := r.providerStats(ctx); err != nil {
+		r.logger.Error("provider stats", "error", err)
+	} else if stats != nil {
+		res.ProviderStats = stats
+		if elapsed := stats.LastRequestAt.Sub(stats.FirstRequestAt).Seconds(); elapsed > 0 {
+			res.OriginateThroughput = float64(stats.Requests-stats.RateLimited) / elapsed
+		}
+		return res
+	}
+	// without provider stats throughput is estimated with finished calls, retries are not visible there.
+	if elapsed := r.lastFinished.Sub(r.firstSent).Seconds(); elapsed > 0 {
+		res.OriginateThroughput = float64(res.Finished) / elapsed
+	}
+	return res
+}
+
+func (r *Runner) providerStats(ctx context.Context) (*simulator.Stats, error) {
+	if r.cfg.ProviderStatsURL == "" {
+		return nil, nil
+	}
+	respBody, status, err := r.httpWrapper.MakeGetRequest(ctx, r.cfg.ProviderStatsURL)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("status %v", status)
+	}
+	stats := &simulator.Stats{}
+	if err = json.Unmarshal(respBody, stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func (r Report) String() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "sent: %v, accepted: %v, rejected: %v, send duration: %v\n", r.Sent, r.Accepted, r.Rejected, r.SendDuration.Round(time.Millisecond))
+	if r.SendDuration > 0 {
+		fmt.Fprintf(b, "accepted rate: %.2f/s\n", float64(r.Accepted)/r.SendDuration.Seconds())
+	}
+	fmt.Fprintf(b, "trigger latency: %v\n", r.TriggerLatency)
+	fmt.Fprintf(b, "end-to-end latency: %v\n", r.EndToEndLatency)
+	fmt.Fprintf(b, "finished: %v, failed: %v, expired: %v, unfinished: %v, outcomes: %v\n", r.Finished, r.Failed, r.Expired, r.Unfinished, r.Outcomes)
+	fmt.Fprintf(b, "originate throughput: %.2f/s of theoretical %.2f/s\n", r.OriginateThroughput, r.TheoreticalThroughput)
+	if r.ProviderStats != nil {
+		fmt.Fprintf(b, "429 from provider: %v of %v requests\n", r.ProviderStats.RateLimited, r.ProviderStats.Requests)
+	} else {
+		fmt.Fprintf(b, "calls observed with 429: %v\n", r.RateLimitedCalls)
+	}
+	return b.String()
+}
@@ -0,0 +1,83 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: runner.go
+
+// Package loadtest is a generated GoMock package.
+package loadtest
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockHTTPWrapper is a mock of HTTPWrapper interface.
+type MockHTTPWrapper struct {
+	ctrl     *gomock.Controller
+	recorder *MockHTTPWrapperMockRecorder
+}
+
+// MockHTTPWrapperMockRecorder is the mock recorder for MockHTTPWrapper.
+type MockHTTPWrapperMockRecorder struct {
+	mock *MockHTTPWrapper
+}
+
+// NewMockHTTPWrapper creates a new mock instance.
+func NewMockHTTPWrapper(ctrl *gomock.Controller) *MockHTTPWrapper {
+	mock := &MockHTTPWrapper{ctrl: ctrl}
+	mock.recorder = &MockHTTPWrapperMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHTTPWrapper) EXPECT() *MockHTTPWrapperMockRecorder {
+	return m.recorder
+}
+
+// MakeGetRequest mocks base method.
+func (m *MockHTTPWrapper) MakeGetRequest(ctx context.Context, url string) ([]byte, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MakeGetRequest", ctx, url)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// MakeGetRequest indicates an expected call of MakeGetRequest.
+func (mr *MockHTTPWrapperMockRecorder) MakeGetRequest(ctx, url interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeGetRequest", reflect.TypeOf((*MockHTTPWrapper)(nil).MakeGetRequest), ctx, url)
+}
+
+// MakeGetRequestWithHeaders mocks base method.
+func (m *MockHTTPWrapper) MakeGetRequestWithHeaders(ctx context.Context, url string, headers map[string]string) ([]byte, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MakeGetRequestWithHeaders", ctx, url, headers)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// MakeGetRequestWithHeaders indicates an expected call of MakeGetRequestWithHeaders.
+func (mr *MockHTTPWrapperMockRecorder) MakeGetRequestWithHeaders(ctx, url, headers interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeGetRequestWithHeaders", reflect.TypeOf((*MockHTTPWrapper)(nil).MakeGetRequestWithHeaders), ctx, url, headers)
+}
+
+// MakePostRequestWithHeaders mocks base method.
+func (m *MockHTTPWrapper) MakePostRequestWithHeaders(ctx context.Context, url string, body []byte, headers map[string]string) ([]byte, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MakePostRequestWithHeaders", ctx, url, body, headers)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// MakePostRequestWithHeaders indicates an expected call of MakePostRequestWithHeaders.
+func (mr *MockHTTPWrapperMockRecorder) MakePostRequestWithHeaders(ctx, url, body, headers interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakePostRequestWithHeaders", reflect.TypeOf((*MockHTTPWrapper)(nil).MakePostRequestWithHeaders), ctx, url, body, headers)
+}
@@ -0,0 +1,124 @@
+package loadtest
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"test_trigger/internal/auth"
+	"test_trigger/internal/call"
+	"test_trigger/internal/logger"
+	"test_trigger/internal/realtime"
+	"test_trigger/internal/signature"
+)
+
+func TestRunner_Run(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	httpWrapper := NewMockHTTPWrapper(ctrl)
+	l := logger.NewMockLogger(ctrl)
+	r := NewRunner(Config{
+		Target:           "http://trigger",
+		ProviderStatsURL: "http://provider/stats",
+		Pattern:          PatternConfig{Name: PatternConstant, Rate: 100, Duration: 20 * time.Millisecond},
+		PollInterval:     time.Millisecond,
+		PollTimeout:      time.Second,
+		VirtualAgentID:   "aaa",
+		ProviderLimit:    25,
+		ProviderWindow:   10 * time.Second,
+	}, httpWrapper, realtime.NewRealTime(time.Now), l)
+
+	httpWrapper.EXPECT().MakePostRequestWithHeaders(gomock.Any(), "http://trigger/trigger", []byte(`{"phone_number":"+447000000000","virtual_agent_id":"aaa"}`), nil).
+		Return([]byte(`{"call_id":"1"}`), 200, nil).Times(1)
+	httpWrapper.EXPECT().MakePostRequestWithHeaders(gomock.Any(), "http://trigger/trigger", []byte(`{"phone_number":"+447000000001","virtual_agent_id":"aaa"}`), nil).
+		Return([]byte("busy"), 503, nil).Times(1)
+	gomock.InOrder(
+		httpWrapper.EXPECT().MakeGetRequestWithHeaders(gomock.Any(), "http://trigger/calls/1", nil).
+			Return([]byte(`{"call_id":"1","state":"retrying","code":429,"outcome":"rate_limited"}`), 200, nil).Times(1),
+		httpWrapper.EXPECT().MakeGetRequestWithHeaders(gomock.Any(), "http://trigger/calls/1", nil).
+			Return([]byte(`{"call_id":"1","state":"finished","code":200,"outcome":"answered"}`), 200, nil).Times(1),
+	)
+	httpWrapper.EXPECT().MakeGetRequest(gomock.Any(), "http://provider/stats").
+		Return([]byte(`{"requests":3,"rate_limited":1,"first_request_at":"2024-03-03T10:00:00Z","last_request_at":"2024-03-03T10:00:01Z"}`), 200, nil).Times(1)
+
+	report, err := r.Run(context.Background())
+	ao := assert.New(t)
+	ao.Nil(err)
+	ao.Equal(2, report.Sent)
+	ao.Equal(1, report.Accepted)
+	ao.Equal(1, report.Rejected)
+	ao.Equal(1, report.Finished)
+	ao.Equal(0, report.Unfinished)
+	ao.Equal(map[call.Outcome]int{call.OutcomeAnswered: 1}, report.Outcomes)
+	ao.Equal(1, report.RateLimitedCalls)
+	ao.Equal(uint64(1), report.ProviderStats.RateLimited)
+	ao.Equal(2.0, report.OriginateThroughput)
+	ao.Equal(2.5, report.TheoreticalThroughput)
+}
+
+func TestRunner_headers(t *testing.T) {
+	clock := realtime.NewFake(time.Unix(1709464831, 0))
+	assert.Nil(t, NewRunner(Config{}, nil, clock, nil).headers([]byte("{}")))
+	assert.Equal(t, map[string]string{"Authorization": "Bearer key"}, NewRunner(Config{APIKey: "key"}, nil, clock, nil).headers([]byte("{}")))
+
+	headers := NewRunner(Config{APIKey: "key", SigningSecret: "secret"}, nil, clock, nil).headers([]byte("{}"))
+	ao := assert.New(t)
+	ao.Equal("Bearer key", headers["Authorization"])
+	ao.Equal("1709464831", headers[auth.HeaderTimestamp])
+	ao.Equal(signature.Sign("secret", "1709464831", []byte("{}")), headers[auth.HeaderSignature])
+}
+
+func TestRunner_pollOnce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	httpWrapper := NewMockHTTPWrapper(ctrl)
+	clock := realtime.NewFake(time.Unix(1709464831, 0))
+	r := NewRunner(Config{Target: "http://trigger"}, httpWrapper, clock, nil)
+	for i := 0; i < 2*maxInFlightPolls; i++ {
+		r.pending[strconv.Itoa(i)] = &pendingCall{sentAt: clock.Now().Add(-time.Second)}
+	}
+	// the first ones are polled concurrently, none of them returns before all are in flight.
+	started := make(chan struct{})
+	inFlight := int32(0)
+	httpWrapper.EXPECT().MakeGetRequestWithHeaders(gomock.Any(), gomock.Any(), nil).
+		DoAndReturn(func(_ context.Context, url string, _ map[string]string) ([]byte, int, error) {
+			if atomic.AddInt32(&inFlight, 1) == maxInFlightPolls {
+				close(started)
+			}
+			<-started
+			id := strings.TrimPrefix(url, "http://trigger/calls/")
+			if id == "0" {
+				return []byte(`{"call_id":"0","state":"queued"}`), 200, nil
+			}
+			return []byte(`{"call_id":"` + id + `","state":"finished","code":200,"outcome":"answered"}`), 200, nil
+		}).Times(2 * maxInFlightPolls)
+
+	ao := assert.New(t)
+	ao.Equal(1, r.pollOnce(context.Background()))
+	ao.Equal(2*maxInFlightPolls-1, r.report.Finished)
+	ao.Equal(time.Second, r.endToEnd[0])
+}
+
+func TestRunner_Observe(t *testing.T) {
+	now := time.Now()
+	r := NewRunner(Config{}, nil, realtime.NewFake(now), nil)
+	for _, id := range []string{"1", "2", "3", "4"} {
+		r.pending[id] = &pendingCall{sentAt: now}
+	}
+	r.observe("1", call.Status{State: call.StateFinished, Code: 200, Outcome: call.OutcomeAnswered}, now)
+	r.observe("2", call.Status{State: call.StateFailed, Code: 503, Outcome: call.OutcomeProviderError}, now)
+	r.observe("3", call.Status{State: call.StateExpired}, now)
+	r.observe("4", call.Status{State: call.StateRetrying, Code: 486, Outcome: call.OutcomeBusy}, now)
+
+	ao := assert.New(t)
+	ao.Equal(1, r.report.Finished)
+	ao.Equal(1, r.report.Failed)
+	ao.Equal(1, r.report.Expired)
+	ao.Equal(map[call.Outcome]int{call.OutcomeAnswered: 1, call.OutcomeProviderError: 1}, r.report.Outcomes)
+	ao.Len(r.pending, 1)
+	ao.Len(r.endToEnd, 3)
+}
@@ -1,28 +1,205 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 //go:generate go run github.com/golang/mock/mockgen --source=logger.go --destination=logger_mock.go --package=logger
 
 // Logger can be defined just once, since it is low level interface, which will be imported in many places.
+// kv are key-value pairs: l.Error("trigger: SaveStatus", "error", err).
 type Logger interface {
-	Error(args ...interface{})
-	Info(input string)
+	Debug(msg string, kv ...interface{})
+	Info(msg string, kv ...interface{})
+	Warn(msg string, kv ...interface{})
+	Error(msg string, kv ...interface{})
+	// With returns child logger, kv are added to every record.
+	With(kv ...interface{}) Logger
 }
 
-type Simple struct {
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+// ParseLevel parses debug, info, warn or error.
+func ParseLevel(input string) (Level, error) {
+	for l := LevelDebug; l <= LevelError; l++ {
+		if strings.EqualFold(input, l.String()) {
+			return l, nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", input)
+}
+
+const (
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+)
+
+// Structured writes one line per record in JSON or logfmt format.
+// Keys order is stable: time, level, msg, fields of parents, fields of the record.
+type Structured struct {
+	out    io.Writer
+	level  Level
+	format string
+	now    func() time.Time
+	fields []interface{}
+	mu     *sync.Mutex // shared with children, lines shouldn't interleave.
+}
+
+func NewStructured(out io.Writer, level Level, format string, now func() time.Time) (*Structured, error) {
+	if format != FormatJSON && format != FormatLogfmt {
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+	return &Structured{out: out, level: level, format: format, now: now, mu: &sync.Mutex{}}, nil
+}
+
+// NewStdout is a Structured logger for cmds, level and format are usually taken from flags.
+func NewStdout(level, format string) (*Structured, error) {
+	l, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	return NewStructured(os.Stdout, l, format, time.Now)
+}
+
+func (s *Structured) Debug(msg string, kv ...interface{}) {
+	s.log(LevelDebug, msg, kv)
+}
+
+func (s *Structured) Info(msg string, kv ...interface{}) {
+	s.log(LevelInfo, msg, kv)
+}
+
+func (s *Structured) Warn(msg string, kv ...interface{}) {
+	s.log(LevelWarn, msg, kv)
 }
 
-func NewSimple() *Simple {
-	return &Simple{}
+func (s *Structured) Error(msg string, kv ...interface{}) {
+	s.log(LevelError, msg, kv)
 }
 
-func (s *Simple) Info(input string) {
-	fmt.Println(input)
+func (s *Structured) With(kv ...interface{}) Logger {
+	child := *s
+	child.fields = append(append(make([]interface{}, 0, len(s.fields)+len(kv)), s.fields...), kv...)
+	return &child
 }
 
-func (s *Simple) Error(args ...interface{}) {
-	fmt.Println(args...)
+func (s *Structured) log(level Level, msg string, kv []interface{}) {
+	if level < s.level {
+		return
+	}
+	pairs := []interface{}{"time", s.now().UTC().Format(time.RFC3339Nano), "level", level.String(), "msg", msg}
+	pairs = append(pairs, s.fields...)
+	pairs = append(pairs, kv...)
+	if len(pairs)%2 != 0 {
+		// value without key isn't dropped, it can be the most important part.
+		pairs = append(pairs[:len(pairs)-1], "!BADKEY", pairs[len(pairs)-1])
+	}
+
+	b := &strings.Builder{}
+	if s.format == FormatJSON {
+		writeJSON(b, pairs)
+	} else {
+		writeLogfmt(b, pairs)
+	}
+	b.WriteByte('\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, _ = io.WriteString(s.out, b.String())
+}
+
+func writeJSON(b *strings.Builder, pairs []interface{}) {
+	b.WriteByte('{')
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(fmt.Sprint(pairs[i]))
+		b.Write(key)
+		b.WriteByte(':')
+		value, err := json.Marshal(jsonValue(pairs[i+1]))
+		if err != nil {
+			value, _ = json.Marshal(fmt.Sprint(pairs[i+1]))
+		}
+		b.Write(value)
+	}
+	b.WriteByte('}')
+}
+
+func jsonValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case error:
+		return val.Error()
+	case time.Duration:
+		return val.String()
+	case fmt.Stringer:
+		return val.String()
+	default:
+		return v
+	}
+}
+
+func writeLogfmt(b *strings.Builder, pairs []interface{}) {
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(logfmtValue(fmt.Sprint(pairs[i])))
+		b.WriteByte('=')
+		b.WriteString(logfmtValue(fmt.Sprint(pairs[i+1])))
+	}
+}
+
+// logfmtValue quotes values with spaces, quotes or '='.
+func logfmtValue(v string) string {
+	if v == "" || strings.ContainsAny(v, " =\"\t\n") {
+		return strconv.Quote(v)
+	}
+	return v
+}
+
+// Nop drops everything, useful for simulations.
+type Nop struct{}
+
+func NewNop() *Nop {
+	return &Nop{}
+}
+
+func (n *Nop) Debug(_ string, _ ...interface{}) {}
+
+func (n *Nop) Info(_ string, _ ...interface{}) {}
+
+func (n *Nop) Warn(_ string, _ ...interface{}) {}
+
+func (n *Nop) Error(_ string, _ ...interface{}) {}
+
+func (n *Nop) With(_ ...interface{}) Logger {
+	return n
 }
@@ -0,0 +1,91 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStructured(t *testing.T) {
+	now := func() time.Time { return time.Date(2024, 3, 3, 11, 20, 31, 0, time.UTC) }
+	tests := []struct {
+		name     string
+		format   string
+		level    Level
+		log      func(l Logger)
+		expected string
+	}{
+		{
+			name:   "json with child fields",
+			format: FormatJSON,
+			level:  LevelInfo,
+			log: func(l Logger) {
+				l.With("call_id", "1", "virtual_agent_id", "aaa").Error("processOneCall: Call", "error", errors.New("some err"), "status", 429)
+			},
+			expected: `{"time":"2024-03-03T11:20:31Z","level":"error","msg":"processOneCall: Call","call_id":"1","virtual_agent_id":"aaa","error":"some err","status":429}` + "\n",
+		},
+		{
+			name:   "logfmt quotes values",
+			format: FormatLogfmt,
+			level:  LevelInfo,
+			log: func(l Logger) {
+				l.Warn("calls should be processed", "queue_length", 3, "wait", 1500*time.Millisecond, "empty", "")
+			},
+			expected: `time=2024-03-03T11:20:31Z level=warn msg="calls should be processed" queue_length=3 wait=1.5s empty=""` + "\n",
+		},
+		{
+			name:   "below level is skipped",
+			format: FormatLogfmt,
+			level:  LevelInfo,
+			log: func(l Logger) {
+				l.Debug("hidden")
+				l.Info("shown")
+			},
+			expected: "time=2024-03-03T11:20:31Z level=info msg=shown\n",
+		},
+		{
+			name:   "value without key",
+			format: FormatJSON,
+			level:  LevelDebug,
+			log: func(l Logger) {
+				l.Debug("odd", "key", "value", "lost")
+			},
+			expected: `{"time":"2024-03-03T11:20:31Z","level":"debug","msg":"odd","key":"value","!BADKEY":"lost"}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			l, err := NewStructured(out, tt.level, tt.format, now)
+			require.NoError(t, err)
+			tt.log(l)
+			assert.Equal(t, tt.expected, out.String())
+		})
+	}
+}
+
+func TestStructured_WithDoesNotChangeParent(t *testing.T) {
+	out := &bytes.Buffer{}
+	l, err := NewStructured(out, LevelInfo, FormatLogfmt, func() time.Time { return time.Unix(0, 0) })
+	require.NoError(t, err)
+	_ = l.With("call_id", "1")
+	l.Info("parent")
+	assert.Equal(t, "time=1970-01-01T00:00:00Z level=info msg=parent\n", out.String())
+}
+
+func TestNewStructured_UnknownFormat(t *testing.T) {
+	_, err := NewStructured(&bytes.Buffer{}, LevelInfo, "xml", time.Now)
+	assert.Error(t, err)
+}
+
+func TestParseLevel(t *testing.T) {
+	l, err := ParseLevel("WARN")
+	assert.NoError(t, err)
+	assert.Equal(t, LevelWarn, l)
+	_, err = ParseLevel("verbose")
+	assert.Error(t, err)
+}
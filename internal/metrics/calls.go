@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	RetryNow   = "now"
+	RetryLater = "later"
+)
+
+// Calls are metrics of the trigger service. Methods of nil *Calls do nothing, so metrics are optional in tests.
+type Calls struct {
+	Triggers         *CounterVec
+	Originate        *CounterVec
+	Outcomes         *CounterVec
+	Retries          *CounterVec
+	RateLimited      *Counter
+	OriginateLatency *Histogram
+	QueueWait        *Histogram
+	InFlight         *Gauge
+	ActiveWorkers    *Gauge
+	DeadLetters      *Counter
+	Webhooks         *CounterVec
+	SlowConsumers    *Counter
+	QuotaRejections  *CounterVec
+	Expirations      *Counter
+	Evictions        *Counter
+	ArchiveFailures  *Counter
+}
+
+func NewCalls(r *Registry) *Calls {
+	return &Calls{
+		Triggers:         r.NewCounterVec("trigger_requests_total", "Trigger requests by result.", "result"),
+		Originate:        r.NewCounterVec("originate_requests_total", "Originate requests by response status, 0 means no response.", "status"),
+		Outcomes:         r.NewCounterVec("originate_outcomes_total", "Classified originate outcomes.", "outcome"),
+		Retries:          r.NewCounterVec("call_retries_total", "Calls put back to the queue after originate, by retry type.", "type"),
+		RateLimited:      r.NewCounter("originate_rate_limited_total", "Originate requests rejected with 429."),
+		OriginateLatency: r.NewHistogram("originate_latency_seconds", "Originate request latency.", DefaultBuckets),
+		QueueWait:        r.NewHistogram("queue_wait_seconds", "Time from queueing to originate request.", DefaultBuckets),
+		InFlight:         r.NewGauge("originate_in_flight", "Originate requests in flight."),
+		ActiveWorkers:    r.NewGauge("workers_active", "Running workers."),
+		DeadLetters:      r.NewCounter("dead_letters_total", "Calls moved to dead letters after max attempts."),
+		Webhooks:         r.NewCounterVec("webhook_attempts_total", "Webhook delivery attempts by result.", "result"),
+		Expirations:      r.NewCounter("calls_expired_total", "Calls, which weren't dialed before expires_at."),
+		Evictions:        r.NewCounter("statuses_evicted_total", "Statuses of completed calls removed by retention."),
+		ArchiveFailures:  r.NewCounter("status_archive_failures_total", "Failed archivals of evicted statuses, they are retried."),
+		QuotaRejections:  r.NewCounterVec("quota_rejections_total", "Trigger requests rejected by client quotas, by quota.", "quota"),
+		SlowConsumers:    r.NewCounter("event_streams_dropped_total", "Event streams closed, since the client didn't keep up."),
+	}
+}
+
+func (c *Calls) TriggerAccepted() {
+	if c == nil {
+		return
+	}
+	c.Triggers.Inc("accepted")
+}
+
+func (c *Calls) TriggerRejected() {
+	if c == nil {
+		return
+	}
+	c.Triggers.Inc("rejected")
+}
+
+// OriginateStarted should be followed by OriginateFinished.
+func (c *Calls) OriginateStarted(queueWait time.Duration) {
+	if c == nil {
+		return
+	}
+	c.InFlight.Inc()
+	c.QueueWait.Observe(queueWait.Seconds())
+}
+
+// OriginateFinished - status is 0 if there is no response.
+func (c *Calls) OriginateFinished(status int, outcome string, latency time.Duration) {
+	if c == nil {
+		return
+	}
+	c.InFlight.Dec()
+	c.Originate.Inc(strconv.Itoa(status))
+	if outcome != "" {
+		c.Outcomes.Inc(outcome)
+	}
+	if status == http.StatusTooManyRequests {
+		c.RateLimited.Inc()
+	}
+	c.OriginateLatency.Observe(latency.Seconds())
+}
+
+func (c *Calls) Retry(kind string) {
+	if c == nil {
+		return
+	}
+	c.Retries.Inc(kind)
+}
+
+func (c *Calls) WorkerStarted() {
+	if c == nil {
+		return
+	}
+	c.ActiveWorkers.Inc()
+}
+
+func (c *Calls) WorkerStopped() {
+	if c == nil {
+		return
+	}
+	c.ActiveWorkers.Dec()
+}
+
+func (c *Calls) DeadLettered() {
+	if c == nil {
+		return
+	}
+	c.DeadLetters.Inc()
+}
+
+func (c *Calls) WebhookAttempt(result string) {
+	if c == nil {
+		return
+	}
+	c.Webhooks.Inc(result)
+}
+
+func (c *Calls) StreamDropped() {
+	if c == nil {
+		return
+	}
+	c.SlowConsumers.Inc()
+}
+
+func (c *Calls) QuotaRejected(quota string) {
+	if c == nil {
+		return
+	}
+	c.QuotaRejections.Inc(quota)
+}
+
+func (c *Calls) Expired() {
+	if c == nil {
+		return
+	}
+	c.Expirations.Inc()
+}
+
+func (c *Calls) StatusEvicted() {
+	if c == nil {
+		return
+	}
+	c.Evictions.Inc()
+}
+
+func (c *Calls) ArchiveFailed() {
+	if c == nil {
+		return
+	}
+	c.ArchiveFailures.Inc()
+}
@@ -0,0 +1,262 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Prometheus text exposition format, written by hand to avoid client_golang.
+// https://prometheus.io/docs/instrumenting/exposition_formats/
+
+// DefaultBuckets are histogram buckets in seconds, originate calls can take 5-10s, queue wait even more.
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 7.5, 10, 15, 30, 60, 120, 300}
+
+type collector interface {
+	write(b *strings.Builder)
+}
+
+// Registry keeps metrics in registration order and renders them.
+type Registry struct {
+	collectors []collector
+	mu         *sync.Mutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{mu: &sync.Mutex{}}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// NewCounter creates and registers a counter.
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{name: name, help: help}
+	r.register(c)
+	return c
+}
+
+// NewCounterVec creates and registers a counter with one label.
+func (r *Registry) NewCounterVec(name, help, label string) *CounterVec {
+	c := &CounterVec{name: name, help: help, label: label, values: make(map[string]*uint64), mu: &sync.Mutex{}}
+	r.register(c)
+	return c
+}
+
+// NewGauge creates and registers a gauge.
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{name: name, help: help}
+	r.register(g)
+	return g
+}
+
+// NewGaugeFunc registers a gauge, which value is taken on every scrape.
+func (r *Registry) NewGaugeFunc(name, help string, f func() float64) {
+	r.register(&gaugeFunc{name: name, help: help, f: f})
+}
+
+// NewHistogram creates and registers a histogram, buckets should be sorted.
+func (r *Registry) NewHistogram(name, help string, buckets []float64) *Histogram {
+	h := &Histogram{name: name, help: help, buckets: buckets, counts: make([]uint64, len(buckets)), mu: &sync.Mutex{}}
+	r.register(h)
+	return h
+}
+
+// String renders all metrics in text format.
+func (r *Registry) String() string {
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+	b := &strings.Builder{}
+	for _, c := range collectors {
+		c.write(b)
+	}
+	return b.String()
+}
+
+// ServeHTTP is /metrics handler.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write([]byte(r.String()))
+}
+
+// Counter only goes up.
+type Counter struct {
+	name, help string
+	value      uint64
+}
+
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.value, 1)
+}
+
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.value)
+}
+
+func (c *Counter) write(b *strings.Builder) {
+	writeHeader(b, c.name, c.help, "counter")
+	fmt.Fprintf(b, "%s %d\n", c.name, c.Value())
+}
+
+// CounterVec is a set of counters split by one label.
+type CounterVec struct {
+	name, help, label string
+	values            map[string]*uint64
+	mu                *sync.Mutex
+}
+
+func (c *CounterVec) Inc(labelValue string) {
+	c.mu.Lock()
+	v, ok := c.values[labelValue]
+	if !ok {
+		v = new(uint64)
+		c.values[labelValue] = v
+	}
+	c.mu.Unlock()
+	atomic.AddUint64(v, 1)
+}
+
+func (c *CounterVec) Value(labelValue string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.values[labelValue]
+	if !ok {
+		return 0
+	}
+	return atomic.LoadUint64(v)
+}
+
+func (c *CounterVec) write(b *strings.Builder) {
+	writeHeader(b, c.name, c.help, "counter")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	labels := make([]string, 0, len(c.values))
+	for l := range c.values {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+	for _, l := range labels {
+		fmt.Fprintf(b, "%s{%s=\"%s\"} %d\n", c.name, c.label, escape(l), atomic.LoadUint64(c.values[l]))
+	}
+}
+
+// Gauge goes up and down.
+type Gauge struct {
+	name, help string
+	value      int64
+}
+
+func (g *Gauge) Set(v int64) {
+	atomic.StoreInt64(&g.value, v)
+}
+
+func (g *Gauge) Inc() {
+	atomic.AddInt64(&g.value, 1)
+}
+
+func (g *Gauge) Dec() {
+	atomic.AddInt64(&g.value, -1)
+}
+
+func (g *Gauge) Value() int64 {
+	return atomic.LoadInt64(&g.value)
+}
+
+func (g *Gauge) write(b *strings.Builder) {
+	writeHeader(b, g.name, g.help, "gauge")
+	fmt.Fprintf(b, "%s %d\n", g.name, g.Value())
+}
+
+type gaugeFunc struct {
+	name, help string
+	f          func() float64
+}
+
+func (g *gaugeFunc) write(b *strings.Builder) {
+	writeHeader(b, g.name, g.help, "gauge")
+	fmt.Fprintf(b, "%s %s\n", g.name, formatFloat(g.f()))
+}
+
+// Histogram counts observations in cumulative buckets.
+type Histogram struct {
+	name, help string
+	buckets    []float64
+	counts     []uint64 // not cumulative, summed on write.
+	count      uint64
+	sum        float64
+	mu         *sync.Mutex
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.count++
+	h.sum += v
+	i := sort.SearchFloat64s(h.buckets, v)
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+}
+
+// Count returns number of observations.
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// Sum returns sum of observations, with Count it gives the average between two moments.
+func (h *Histogram) Sum() float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.sum
+}
+
+func (h *Histogram) write(b *strings.Builder) {
+	writeHeader(b, h.name, h.help, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var cumulative uint64
+	for i, le := range h.buckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(b, "%s_bucket{le=\"%s\"} %d\n", h.name, formatFloat(le), cumulative)
+	}
+	fmt.Fprintf(b, "%s_bucket{le=\"+Inf\"} %d\n", h.name, h.count)
+	fmt.Fprintf(b, "%s_sum %s\n", h.name, formatFloat(h.sum))
+	fmt.Fprintf(b, "%s_count %d\n", h.name, h.count)
+}
+
+func writeHeader(b *strings.Builder, name, help, kind string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(v string) string {
+	return labelEscaper.Replace(v)
+}
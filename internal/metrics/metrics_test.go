@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_String(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("requests_total", "Requests.")
+	vec := r.NewCounterVec("responses_total", "Responses by status.", "status")
+	g := r.NewGauge("in_flight", "In flight.")
+	r.NewGaugeFunc("remaining", "Remaining.", func() float64 { return 2.5 })
+	h := r.NewHistogram("latency_seconds", "Latency.", []float64{0.5, 1})
+
+	c.Inc()
+	c.Inc()
+	vec.Inc("500")
+	vec.Inc("200")
+	vec.Inc("a\"b")
+	g.Inc()
+	g.Inc()
+	g.Dec()
+	h.Observe(0.2)
+	h.Observe(0.5)
+	h.Observe(3)
+
+	expected := `# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total 2
+# HELP responses_total Responses by status.
+# TYPE responses_total counter
+responses_total{status="200"} 1
+responses_total{status="500"} 1
+responses_total{status="a\"b"} 1
+# HELP in_flight In flight.
+# TYPE in_flight gauge
+in_flight 1
+# HELP remaining Remaining.
+# TYPE remaining gauge
+remaining 2.5
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.5"} 2
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 3.7
+latency_seconds_count 3
+`
+	assert.Equal(t, expected, r.String())
+	assert.Equal(t, uint64(3), h.Count())
+	assert.InDelta(t, 3.7, h.Sum(), 1e-9)
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("requests_total", "Requests.").Inc()
+
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", resp.Header().Get("Content-Type"))
+	assert.Contains(t, resp.Body.String(), "requests_total 1\n")
+
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+}
+
+func Test_formatFloat(t *testing.T) {
+	assert.Equal(t, "+Inf", formatFloat(math.Inf(1)))
+	assert.Equal(t, "NaN", formatFloat(math.NaN()))
+	assert.Equal(t, "0.05", formatFloat(0.05))
+}
+
+func TestCalls_Nil(t *testing.T) {
+	var c *Calls
+	assert.NotPanics(t, func() {
+		c.TriggerAccepted()
+		c.TriggerRejected()
+		c.OriginateStarted(time.Second)
+		c.OriginateFinished(200, "answered", time.Second)
+		c.Retry(RetryNow)
+		c.WorkerStarted()
+		c.WorkerStopped()
+		c.DeadLettered()
+		c.WebhookAttempt("delivered")
+		c.StreamDropped()
+		c.QuotaRejected("rate")
+		c.Expired()
+		c.StatusEvicted()
+		c.ArchiveFailed()
+	})
+}
@@ -0,0 +1,186 @@
+package quota
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"test_trigger/internal/auth"
+	"test_trigger/internal/call"
+	"test_trigger/internal/logger"
+	"test_trigger/internal/metrics"
+	"test_trigger/internal/realtime"
+)
+
+//go:generate go run github.com/golang/mock/mockgen --source=quota.go --destination=quota_mock.go --package=quota
+
+// Quotas of rejected requests, they are metric labels.
+const (
+	QuotaRate   = "rate"
+	QuotaQueued = "queued"
+)
+
+type StatusStorage interface {
+	SaveStatus(_ context.Context, status call.Status, meta call.Meta) error
+	Status(_ context.Context, id call.ID) (call.Status, bool, error)
+}
+
+// Limits of a client, zero is unlimited.
+type Limits struct {
+	Rate      float64 // requests per second.
+	Burst     int     // requests above rate after idle time, rate rounded up if it's 0.
+	MaxQueued int     // calls, which aren't completed yet.
+}
+
+// Quotas limits intake of every API client, so one client can't fill the queue for others.
+// It's a token bucket per client for requests and a count of its outstanding calls, the outbound limiter is not involved.
+// Without auth all requests are of one anonymous client.
+type Quotas struct {
+	defaults   Limits
+	retryAfter time.Duration // for max queued, when a call completes is unknown.
+	clock      realtime.Time
+	logger     logger.Logger
+	metrics    *metrics.Calls
+
+	mu      *sync.Mutex
+	clients map[string]*usage // by client id.
+}
+
+type usage struct {
+	tokens      float64
+	updated     time.Time
+	outstanding map[call.ID]struct{}
+	reserved    int // requests in flight, they will be outstanding calls.
+}
+
+func NewQuotas(defaults Limits, retryAfter time.Duration, clock realtime.Time, logger logger.Logger, m *metrics.Calls) *Quotas {
+	return &Quotas{
+		defaults:   defaults,
+		retryAfter: retryAfter,
+		clock:      clock,
+		logger:     logger,
+		metrics:    m,
+		mu:         &sync.Mutex{},
+		clients:    make(map[string]*usage),
+	}
+}
+
+// limits of the client from the context, zero values are defaults.
+func (q *Quotas) limits(client auth.Client) Limits {
+	limits := Limits{Rate: client.Rate, Burst: client.Burst, MaxQueued: client.MaxQueued}
+	if limits.Rate == 0 {
+		limits.Rate = q.defaults.Rate
+	}
+	if limits.Burst == 0 {
+		limits.Burst = q.defaults.Burst
+	}
+	if limits.Burst == 0 {
+		limits.Burst = int(math.Ceil(limits.Rate))
+	}
+	if limits.MaxQueued == 0 {
+		limits.MaxQueued = q.defaults.MaxQueued
+	}
+	return limits
+}
+
+func (q *Quotas) usage(clientID string) *usage {
+	u, ok := q.clients[clientID]
+	if !ok {
+		u = &usage{tokens: math.Inf(1), outstanding: make(map[call.ID]struct{})}
+		q.clients[clientID] = u
+	}
+	return u
+}
+
+// Limit passes requests within quotas to next, others get 429 with Retry-After. It goes after auth.Authenticate.
+func (q *Quotas) Limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, _ := auth.ClientFromContext(r.Context())
+		quota, retryAfter := q.reserve(client)
+		if quota != "" {
+			q.metrics.QuotaRejected(quota)
+			q.logger.Warn("quota: exceeded", "client_id", client.ID, "quota", quota)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			http.Error(w, quota+" quota of the client is exceeded", http.StatusTooManyRequests)
+			return
+		}
+		defer q.release(client.ID)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// reserve takes a token and a queued call slot, it returns the exceeded quota and when to retry.
+// The slot is held until the request is done, so concurrent requests can't exceed max queued.
+func (q *Quotas) reserve(client auth.Client) (string, time.Duration) {
+	limits := q.limits(client)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	u := q.usage(client.ID)
+	if limits.MaxQueued > 0 && len(u.outstanding)+u.reserved >= limits.MaxQueued {
+		return QuotaQueued, q.retryAfter
+	}
+	if limits.Rate > 0 {
+		now := q.clock.Now()
+		u.tokens = math.Min(float64(limits.Burst), u.tokens+now.Sub(u.updated).Seconds()*limits.Rate)
+		u.updated = now
+		if u.tokens < 1 {
+			return QuotaRate, time.Duration((1 - u.tokens) / limits.Rate * float64(time.Second))
+		}
+		u.tokens--
+	}
+	u.reserved++
+	return "", 0
+}
+
+func (q *Quotas) release(clientID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.usage(clientID).reserved--
+}
+
+// Track counts outstanding calls by statuses: every saved call is outstanding until its state is terminal.
+func (q *Quotas) Track(status call.Status, meta call.Meta) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	u := q.usage(meta.ClientID)
+	if status.State.Terminal() {
+		delete(u.outstanding, meta.ID)
+		return
+	}
+	u.outstanding[meta.ID] = struct{}{}
+}
+
+// Restore counts outstanding calls of the previous run from persistent storage, they still hold slots of their clients.
+func (q *Quotas) Restore(records []call.StatusRecord) {
+	for _, record := range records {
+		q.Track(record.Status, record.Meta)
+	}
+}
+
+// Outstanding returns calls of the client, which aren't completed yet.
+func (q *Quotas) Outstanding(clientID string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.usage(clientID).outstanding)
+}
+
+// Tracker saves statuses and counts outstanding calls of clients.
+type Tracker struct {
+	StatusStorage
+	quotas *Quotas
+}
+
+func NewTracker(statusStorage StatusStorage, quotas *Quotas) *Tracker {
+	return &Tracker{StatusStorage: statusStorage, quotas: quotas}
+}
+
+func (t *Tracker) SaveStatus(ctx context.Context, status call.Status, meta call.Meta) error {
+	if err := t.StatusStorage.SaveStatus(ctx, status, meta); err != nil {
+		return err
+	}
+	t.quotas.Track(status, meta)
+	return nil
+}
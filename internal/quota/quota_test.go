@@ -0,0 +1,129 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"test_trigger/internal/auth"
+	"test_trigger/internal/call"
+	"test_trigger/internal/logger"
+	"test_trigger/internal/metrics"
+	"test_trigger/internal/realtime"
+)
+
+func TestQuotas_Limit_Rate(t *testing.T) {
+	clock := realtime.NewFake(time.Unix(1709464831, 0))
+	ctrl := gomock.NewController(t)
+	l := logger.NewMockLogger(ctrl)
+	m := metrics.NewCalls(metrics.NewRegistry())
+	q := NewQuotas(Limits{Rate: 2}, 10*time.Second, clock, l, m)
+	handler := q.Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	send := func(client *auth.Client) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/trigger", nil)
+		if client != nil {
+			req = req.WithContext(auth.ContextWithClient(req.Context(), *client))
+		}
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	// burst is the rate rounded up.
+	assert.Equal(t, http.StatusOK, send(nil).Code)
+	assert.Equal(t, http.StatusOK, send(nil).Code)
+	l.EXPECT().Warn("quota: exceeded", "client_id", "", "quota", QuotaRate)
+	resp := send(nil)
+	assert.Equal(t, http.StatusTooManyRequests, resp.Code)
+	assert.Equal(t, "1", resp.Header().Get("Retry-After"))
+	assert.Equal(t, "rate quota of the client is exceeded\n", resp.Body.String())
+	assert.Equal(t, uint64(1), m.QuotaRejections.Value(QuotaRate))
+
+	// a token is added every 500ms.
+	clock.Advance(500 * time.Millisecond)
+	assert.Equal(t, http.StatusOK, send(nil).Code)
+
+	// clients have own buckets and limits.
+	crm := &auth.Client{ID: "crm", Rate: 0.1, Burst: 1}
+	assert.Equal(t, http.StatusOK, send(crm).Code)
+	l.EXPECT().Warn("quota: exceeded", "client_id", "crm", "quota", QuotaRate)
+	resp = send(crm)
+	assert.Equal(t, http.StatusTooManyRequests, resp.Code)
+	assert.Equal(t, "10", resp.Header().Get("Retry-After"))
+}
+
+func TestQuotas_Limit_Queued(t *testing.T) {
+	clock := realtime.NewFake(time.Unix(1709464831, 0))
+	ctrl := gomock.NewController(t)
+	l := logger.NewMockLogger(ctrl)
+	q := NewQuotas(Limits{MaxQueued: 2}, 10*time.Second, clock, l, nil)
+	crm := auth.Client{ID: "crm"}
+	statusStorage := NewMockStatusStorage(ctrl)
+	statusStorage.EXPECT().SaveStatus(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	tracker := NewTracker(statusStorage, q)
+
+	var next http.Handler
+	handler := q.Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { next.ServeHTTP(w, r) }))
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/trigger", nil)
+		req = req.WithContext(auth.ContextWithClient(req.Context(), crm))
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the request in flight holds a slot.
+		l.EXPECT().Warn("quota: exceeded", "client_id", "crm", "quota", QuotaQueued)
+		resp := send()
+		assert.Equal(t, http.StatusTooManyRequests, resp.Code)
+		assert.Equal(t, "10", resp.Header().Get("Retry-After"))
+		_ = tracker.SaveStatus(r.Context(), call.Status{State: call.StateQueued}, call.Meta{ID: "2", ClientID: "crm"})
+	})
+	_ = tracker.SaveStatus(context.Background(), call.Status{State: call.StateQueued}, call.Meta{ID: "1", ClientID: "crm"})
+	assert.Equal(t, http.StatusOK, send().Code)
+	assert.Equal(t, 2, q.Outstanding("crm"))
+
+	// retries are still outstanding, completed calls free slots.
+	_ = tracker.SaveStatus(context.Background(), call.Status{State: call.StateRetrying}, call.Meta{ID: "1", ClientID: "crm"})
+	assert.Equal(t, 2, q.Outstanding("crm"))
+	_ = tracker.SaveStatus(context.Background(), call.Status{State: call.StateFinished}, call.Meta{ID: "1", ClientID: "crm"})
+	assert.Equal(t, 1, q.Outstanding("crm"))
+	next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	assert.Equal(t, http.StatusOK, send().Code)
+	assert.Equal(t, 0, q.Outstanding("other"))
+}
+
+func TestQuotas_Restore(t *testing.T) {
+	q := NewQuotas(Limits{MaxQueued: 1}, time.Second, realtime.NewFake(time.Unix(1709464831, 0)), nil, nil)
+	q.Restore([]call.StatusRecord{
+		{Meta: call.Meta{ID: "1", ClientID: "crm"}, Status: call.Status{State: call.StateQueued}},
+		{Meta: call.Meta{ID: "2", ClientID: "crm"}, Status: call.Status{State: call.StateRetrying}},
+		{Meta: call.Meta{ID: "3", ClientID: "crm"}, Status: call.Status{State: call.StateFinished}},
+		{Meta: call.Meta{ID: "4", ClientID: "erp"}, Status: call.Status{State: call.StateExpired}},
+	})
+	assert.Equal(t, 2, q.Outstanding("crm"))
+	assert.Equal(t, 0, q.Outstanding("erp"))
+	// calls of the previous run hold slots of the client.
+	quota, _ := q.reserve(auth.Client{ID: "crm"})
+	assert.Equal(t, QuotaQueued, quota)
+}
+
+func TestTracker_SaveStatus(t *testing.T) {
+	testErr := errors.New("test")
+	ctrl := gomock.NewController(t)
+	statusStorage := NewMockStatusStorage(ctrl)
+	q := NewQuotas(Limits{}, time.Second, realtime.NewFake(time.Unix(1709464831, 0)), nil, nil)
+	tracker := NewTracker(statusStorage, q)
+
+	meta := call.Meta{ID: "1", ClientID: "crm"}
+	statusStorage.EXPECT().SaveStatus(gomock.Any(), call.Status{State: call.StateQueued}, meta).Return(testErr)
+	assert.Equal(t, testErr, tracker.SaveStatus(context.Background(), call.Status{State: call.StateQueued}, meta))
+	assert.Equal(t, 0, q.Outstanding("crm"))
+}
@@ -0,0 +1,164 @@
+package realtime
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fake is a virtual clock for tests and simulations, time moves only with Advance/Set.
+// Timers and tickers behave like in time package: channel with buffer 1, ticks are dropped if nobody reads.
+type Fake struct {
+	now     time.Time
+	seq     uint64
+	waiters []*fakeWaiter
+	mu      *sync.Mutex
+	changed *sync.Cond
+}
+
+type fakeWaiter struct {
+	fake     *Fake
+	c        chan time.Time
+	deadline time.Time
+	period   time.Duration // 0 for timers.
+	seq      uint64        // keeps firing order stable for equal deadlines.
+}
+
+func NewFake(start time.Time) *Fake {
+	mu := &sync.Mutex{}
+	return &Fake{now: start, mu: mu, changed: sync.NewCond(mu)}
+}
+
+// Now returns virtual time.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// NewTicker creates ticker which fires every d of virtual time.
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("realtime: non-positive interval for NewTicker")
+	}
+	return &fakeTicker{fakeWaiter: f.add(d, d)}
+}
+
+// NewTimer creates timer which fires once after d of virtual time.
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	return f.add(d, 0)
+}
+
+// Advance moves time forward, fires all due timers and tickers in deadline order.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	target := f.now.Add(d)
+	for {
+		w := f.earliest()
+		if w == nil || w.deadline.After(target) {
+			break
+		}
+		f.now = w.deadline
+		select {
+		case w.c <- f.now:
+		default:
+		}
+		if w.period > 0 {
+			w.deadline = w.deadline.Add(w.period)
+		} else {
+			f.remove(w)
+		}
+	}
+	f.now = target
+}
+
+// Set moves time to t, t before current time is ignored.
+func (f *Fake) Set(t time.Time) {
+	d := t.Sub(f.Now())
+	if d > 0 {
+		f.Advance(d)
+	}
+}
+
+// NextDeadline returns the closest deadline of active timers and tickers.
+func (f *Fake) NextDeadline() (time.Time, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w := f.earliest()
+	if w == nil {
+		return time.Time{}, false
+	}
+	return w.deadline, true
+}
+
+// Waiters returns number of active timers and tickers.
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+// BlockUntil blocks until there are at least n active timers and tickers.
+// Useful to be sure that goroutine has created its ticker before Advance.
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.waiters) < n {
+		f.changed.Wait()
+	}
+}
+
+func (f *Fake) add(d, period time.Duration) *fakeWaiter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seq++
+	w := &fakeWaiter{fake: f, c: make(chan time.Time, 1), deadline: f.now.Add(d), period: period, seq: f.seq}
+	f.waiters = append(f.waiters, w)
+	f.changed.Broadcast()
+	return w
+}
+
+func (f *Fake) earliest() *fakeWaiter {
+	if len(f.waiters) == 0 {
+		return nil
+	}
+	sort.Slice(f.waiters, func(i, j int) bool {
+		if f.waiters[i].deadline.Equal(f.waiters[j].deadline) {
+			return f.waiters[i].seq < f.waiters[j].seq
+		}
+		return f.waiters[i].deadline.Before(f.waiters[j].deadline)
+	})
+	return f.waiters[0]
+}
+
+// remove returns true if waiter was active.
+func (f *Fake) remove(w *fakeWaiter) bool {
+	for i, active := range f.waiters {
+		if active == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			f.changed.Broadcast()
+			return true
+		}
+	}
+	return false
+}
+
+func (w *fakeWaiter) C() <-chan time.Time {
+	return w.c
+}
+
+// Stop implements Timer, returns false if timer has already fired or been stopped.
+func (w *fakeWaiter) Stop() bool {
+	w.fake.mu.Lock()
+	defer w.fake.mu.Unlock()
+	return w.fake.remove(w)
+}
+
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+func (t *fakeTicker) Stop() {
+	t.fakeWaiter.Stop()
+}
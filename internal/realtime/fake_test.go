@@ -0,0 +1,69 @@
+package realtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFake_Advance(t *testing.T) {
+	start := time.Unix(1709464831, 0)
+	f := NewFake(start)
+	ao := assert.New(t)
+
+	ticker := f.NewTicker(time.Second)
+	timer := f.NewTimer(1500 * time.Millisecond)
+	ao.Equal(2, f.Waiters())
+	next, ok := f.NextDeadline()
+	ao.True(ok)
+	ao.Equal(start.Add(time.Second), next)
+
+	f.Advance(999 * time.Millisecond)
+	ao.Len(ticker.C(), 0)
+
+	f.Advance(time.Millisecond)
+	ao.Equal(start.Add(time.Second), <-ticker.C())
+
+	// the second tick is dropped like in time.Ticker, since nobody reads.
+	f.Advance(2 * time.Second)
+	ao.Equal(start.Add(2*time.Second), <-ticker.C())
+	ao.Len(ticker.C(), 0)
+	ao.Equal(start.Add(1500*time.Millisecond), <-timer.C())
+	ao.Equal(start.Add(3*time.Second), f.Now())
+
+	// fired timer is removed.
+	ao.False(timer.Stop())
+	ao.Equal(1, f.Waiters())
+	ticker.Stop()
+	ao.Equal(0, f.Waiters())
+	_, ok = f.NextDeadline()
+	ao.False(ok)
+}
+
+func TestFake_Set(t *testing.T) {
+	start := time.Unix(1709464831, 0)
+	f := NewFake(start)
+	timer := f.NewTimer(time.Minute)
+
+	f.Set(start.Add(-time.Hour))
+	assert.Equal(t, start, f.Now())
+
+	f.Set(start.Add(time.Hour))
+	assert.Equal(t, start.Add(time.Hour), f.Now())
+	assert.Equal(t, start.Add(time.Minute), <-timer.C())
+}
+
+func TestFake_BlockUntil(t *testing.T) {
+	f := NewFake(time.Unix(1709464831, 0))
+	done := make(chan struct{})
+	go func() {
+		ticker := f.NewTicker(time.Second)
+		defer ticker.Stop()
+		<-ticker.C()
+		close(done)
+	}()
+	f.BlockUntil(1)
+	f.Advance(time.Second)
+	<-done
+}
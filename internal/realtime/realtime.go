@@ -10,6 +10,18 @@ type (
 	// Time describe necessary methods for work with time.
 	Time interface {
 		Now() time.Time
+		NewTicker(d time.Duration) Ticker
+		NewTimer(d time.Duration) Timer
+	}
+	// Ticker is time.Ticker abstraction.
+	Ticker interface {
+		C() <-chan time.Time
+		Stop()
+	}
+	// Timer is time.Timer abstraction.
+	Timer interface {
+		C() <-chan time.Time
+		Stop() bool
 	}
 	// RealTime implements Time interface.
 	RealTime struct {
@@ -29,3 +41,37 @@ func NewRealTime(
 func (t *RealTime) Now() time.Time {
 	return t.timeNowFunc()
 }
+
+// NewTicker returns wrapped time.Ticker.
+func (t *RealTime) NewTicker(d time.Duration) Ticker {
+	return &realTicker{ticker: time.NewTicker(d)}
+}
+
+// NewTimer returns wrapped time.Timer.
+func (t *RealTime) NewTimer(d time.Duration) Timer {
+	return &realTimer{timer: time.NewTimer(d)}
+}
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (r *realTicker) C() <-chan time.Time {
+	return r.ticker.C
+}
+
+func (r *realTicker) Stop() {
+	r.ticker.Stop()
+}
+
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (r *realTimer) C() <-chan time.Time {
+	return r.timer.C
+}
+
+func (r *realTimer) Stop() bool {
+	return r.timer.Stop()
+}
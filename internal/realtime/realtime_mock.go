@@ -34,6 +34,34 @@ func (m *MockTime) EXPECT() *MockTimeMockRecorder {
 	return m.recorder
 }
 
+// NewTicker mocks base method.
+func (m *MockTime) NewTicker(d time.Duration) Ticker {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NewTicker", d)
+	ret0, _ := ret[0].(Ticker)
+	return ret0
+}
+
+// NewTicker indicates an expected call of NewTicker.
+func (mr *MockTimeMockRecorder) NewTicker(d interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewTicker", reflect.TypeOf((*MockTime)(nil).NewTicker), d)
+}
+
+// NewTimer mocks base method.
+func (m *MockTime) NewTimer(d time.Duration) Timer {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NewTimer", d)
+	ret0, _ := ret[0].(Timer)
+	return ret0
+}
+
+// NewTimer indicates an expected call of NewTimer.
+func (mr *MockTimeMockRecorder) NewTimer(d interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewTimer", reflect.TypeOf((*MockTime)(nil).NewTimer), d)
+}
+
 // Now mocks base method.
 func (m *MockTime) Now() time.Time {
 	m.ctrl.T.Helper()
@@ -47,3 +75,103 @@ func (mr *MockTimeMockRecorder) Now() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Now", reflect.TypeOf((*MockTime)(nil).Now))
 }
+
+// MockTicker is a mock of Ticker interface.
+type MockTicker struct {
+	ctrl     *gomock.Controller
+	recorder *MockTickerMockRecorder
+}
+
+// MockTickerMockRecorder is the mock recorder for MockTicker.
+type MockTickerMockRecorder struct {
+	mock *MockTicker
+}
+
+// NewMockTicker creates a new mock instance.
+func NewMockTicker(ctrl *gomock.Controller) *MockTicker {
+	mock := &MockTicker{ctrl: ctrl}
+	mock.recorder = &MockTickerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTicker) EXPECT() *MockTickerMockRecorder {
+	return m.recorder
+}
+
+// C mocks base method.
+func (m *MockTicker) C() <-chan time.Time {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "C")
+	ret0, _ := ret[0].(<-chan time.Time)
+	return ret0
+}
+
+// C indicates an expected call of C.
+func (mr *MockTickerMockRecorder) C() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "C", reflect.TypeOf((*MockTicker)(nil).C))
+}
+
+// Stop mocks base method.
+func (m *MockTicker) Stop() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Stop")
+}
+
+// Stop indicates an expected call of Stop.
+func (mr *MockTickerMockRecorder) Stop() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stop", reflect.TypeOf((*MockTicker)(nil).Stop))
+}
+
+// MockTimer is a mock of Timer interface.
+type MockTimer struct {
+	ctrl     *gomock.Controller
+	recorder *MockTimerMockRecorder
+}
+
+// MockTimerMockRecorder is the mock recorder for MockTimer.
+type MockTimerMockRecorder struct {
+	mock *MockTimer
+}
+
+// NewMockTimer creates a new mock instance.
+func NewMockTimer(ctrl *gomock.Controller) *MockTimer {
+	mock := &MockTimer{ctrl: ctrl}
+	mock.recorder = &MockTimerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTimer) EXPECT() *MockTimerMockRecorder {
+	return m.recorder
+}
+
+// C mocks base method.
+func (m *MockTimer) C() <-chan time.Time {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "C")
+	ret0, _ := ret[0].(<-chan time.Time)
+	return ret0
+}
+
+// C indicates an expected call of C.
+func (mr *MockTimerMockRecorder) C() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "C", reflect.TypeOf((*MockTimer)(nil).C))
+}
+
+// Stop mocks base method.
+func (m *MockTimer) Stop() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stop")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Stop indicates an expected call of Stop.
+func (mr *MockTimerMockRecorder) Stop() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stop", reflect.TypeOf((*MockTimer)(nil).Stop))
+}
@@ -0,0 +1,94 @@
+package retention
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+//go:generate go run github.com/golang/mock/mockgen --source=archive.go --destination=archive_mock.go --package=retention
+
+type HTTPWrapper interface {
+	MakePostRequest(ctx context.Context, url string, body []byte) ([]byte, int, error)
+}
+
+// Open returns the archiver of the target: http(s) URL is a sink, other targets are file paths.
+// Empty target returns nil, evicted statuses are dropped then. The returned func closes the archiver on shutdown.
+func Open(target string, client HTTPWrapper) (Archiver, func() error, error) {
+	switch {
+	case target == "":
+		return nil, func() error { return nil }, nil
+	case strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://"):
+		return NewSink(client, target), func() error { return nil }, nil
+	}
+	file, err := OpenFile(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, file.Close, nil
+}
+
+// File appends records to a file as JSON lines, the file is synced before statuses are removed.
+type File struct {
+	file *os.File
+	mu   *sync.Mutex
+}
+
+func OpenFile(path string) (*File, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &File{file: file, mu: &sync.Mutex{}}, nil
+}
+
+func (f *File) Archive(_ context.Context, records []Record) error {
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.file.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return f.file.Sync()
+}
+
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
+
+// Sink posts records as a JSON array to an external service, any 2xx is archived.
+type Sink struct {
+	client HTTPWrapper
+	url    string
+}
+
+func NewSink(client HTTPWrapper, url string) *Sink {
+	return &Sink{client: client, url: url}
+}
+
+func (s *Sink) Archive(ctx context.Context, records []Record) error {
+	body, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	_, code, err := s.client.MakePostRequest(ctx, s.url, body)
+	if err != nil {
+		return err
+	}
+	if code < 200 || code > 299 {
+		return fmt.Errorf("archive sink responded with %d", code)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"test_trigger/internal/call"
+)
+
+var records = []Record{
+	{CallID: "1", VirtualAgentID: "a", State: call.StateFinished, Code: 200, Outcome: call.OutcomeAnswered, CompletedAt: time.Unix(1709464831, 0).UTC()},
+	{CallID: "2", VirtualAgentID: "b", ClientID: "crm", State: call.StateExpired, CompletedAt: time.Unix(1709464832, 0).UTC()},
+}
+
+func TestFile_Archive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "statuses.jsonl")
+	archiver, closeArchiver, err := Open(path, nil)
+	require.NoError(t, err)
+	assert.NoError(t, archiver.Archive(context.Background(), records[:1]))
+	assert.NoError(t, archiver.Archive(context.Background(), records[1:]))
+	assert.NoError(t, closeArchiver())
+
+	// the file is appended after restart.
+	file, err := OpenFile(path)
+	require.NoError(t, err)
+	assert.NoError(t, file.Archive(context.Background(), records[:1]))
+	assert.NoError(t, file.Close())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	line1 := `{"call_id":"1","virtual_agent_id":"a","state":"finished","code":200,"outcome":"answered","completed_at":"2024-03-03T11:20:31Z"}` + "\n"
+	line2 := `{"call_id":"2","virtual_agent_id":"b","client_id":"crm","state":"expired","completed_at":"2024-03-03T11:20:32Z"}` + "\n"
+	assert.Equal(t, line1+line2+line1, string(content))
+
+	_, _, err = Open(filepath.Join(t.TempDir(), "missing", "statuses.jsonl"), nil)
+	assert.Error(t, err)
+}
+
+func TestSink_Archive(t *testing.T) {
+	testErr := errors.New("test")
+	tests := []struct {
+		name        string
+		code        int
+		err         error
+		expectedErr string
+	}{
+		{name: "archived", code: 204},
+		{name: "bad status", code: 500, expectedErr: "archive sink responded with 500"},
+		{name: "request error", err: testErr, expectedErr: "test"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			client := NewMockHTTPWrapper(ctrl)
+			client.EXPECT().MakePostRequest(gomock.Any(), "https://warehouse/statuses",
+				[]byte(`[{"call_id":"1","virtual_agent_id":"a","state":"finished","code":200,"outcome":"answered","completed_at":"2024-03-03T11:20:31Z"}]`)).
+				Return(nil, tt.code, tt.err)
+			archiver, _, err := Open("https://warehouse/statuses", client)
+			require.NoError(t, err)
+
+			err = archiver.Archive(context.Background(), records[:1])
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestOpen_Empty(t *testing.T) {
+	archiver, closeArchiver, err := Open("", nil)
+	assert.NoError(t, err)
+	assert.Nil(t, archiver)
+	assert.NoError(t, closeArchiver())
+}
@@ -0,0 +1,230 @@
+package retention
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"test_trigger/internal/call"
+	"test_trigger/internal/logger"
+	"test_trigger/internal/metrics"
+	"test_trigger/internal/realtime"
+)
+
+//go:generate go run github.com/golang/mock/mockgen --source=retention.go --destination=retention_mock.go --package=retention
+
+// evictBatch limits records archived at once, so a big backlog isn't one huge write.
+const evictBatch = 1000
+
+type StatusStorage interface {
+	SaveStatus(_ context.Context, status call.Status, meta call.Meta) error
+	Status(_ context.Context, id call.ID) (call.Status, bool, error)
+	RemoveStatus(_ context.Context, id call.ID, status call.Status) (bool, error)
+}
+
+// Archiver keeps evicted statuses elsewhere before they are removed, e.g. a file or a sink of the warehouse.
+type Archiver interface {
+	Archive(ctx context.Context, records []Record) error
+}
+
+// Record is an evicted status.
+type Record struct {
+	CallID         string       `json:"call_id"`
+	VirtualAgentID string       `json:"virtual_agent_id"`
+	ClientID       string       `json:"client_id,omitempty"`
+	State          call.State   `json:"state"`
+	Code           int          `json:"code,omitempty"`
+	Outcome        call.Outcome `json:"outcome,omitempty"`
+	CompletedAt    time.Time    `json:"completed_at"`
+}
+
+// Retainer saves statuses and evicts statuses of completed calls by age and count, the oldest first.
+// Statuses of calls in progress are never evicted, so the count can be exceeded by them.
+// Completed calls are remembered in order of completion, storage isn't scanned.
+type Retainer struct {
+	StatusStorage
+	maxAge   time.Duration // 0 is unlimited.
+	maxCount int           // 0 is unlimited.
+	interval time.Duration
+	archiver Archiver // nil drops evicted statuses.
+	clock    realtime.Time
+	logger   logger.Logger
+	metrics  *metrics.Calls
+
+	mu        *sync.Mutex
+	completed []Record              // in order of completion, from head.
+	head      int                   // records before it are evicted.
+	latest    map[call.ID]time.Time // completion of the current status, other records of the call are stale.
+	evictMu   *sync.Mutex           // the same records aren't archived twice by concurrent Evict.
+}
+
+func NewRetainer(statusStorage StatusStorage, maxAge time.Duration, maxCount int, interval time.Duration, archiver Archiver, clock realtime.Time, logger logger.Logger, m *metrics.Calls) *Retainer {
+	return &Retainer{
+		StatusStorage: statusStorage,
+		maxAge:        maxAge,
+		maxCount:      maxCount,
+		interval:      interval,
+		archiver:      archiver,
+		clock:         clock,
+		logger:        logger,
+		metrics:       m,
+		mu:            &sync.Mutex{},
+		completed:     make([]Record, 0),
+		latest:        make(map[call.ID]time.Time),
+		evictMu:       &sync.Mutex{},
+	}
+}
+
+func (r *Retainer) SaveStatus(ctx context.Context, status call.Status, meta call.Meta) error {
+	if err := r.StatusStorage.SaveStatus(ctx, status, meta); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !status.State.Terminal() {
+		// a replayed call isn't completed anymore, its record is stale.
+		delete(r.latest, meta.ID)
+		return nil
+	}
+	now := r.clock.Now()
+	r.completed = append(r.completed, Record{
+		CallID:         string(meta.ID),
+		VirtualAgentID: meta.VirtualAgentID,
+		ClientID:       meta.ClientID,
+		State:          status.State,
+		Code:           status.Code,
+		Outcome:        status.Outcome,
+		CompletedAt:    now,
+	})
+	r.latest[meta.ID] = now
+	return nil
+}
+
+// Restore remembers completed calls of the previous run from persistent storage, so they are evicted too.
+// Records are in order they were saved, it's called before statuses are saved.
+func (r *Retainer) Restore(records []call.StatusRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, record := range records {
+		if !record.Status.State.Terminal() {
+			continue
+		}
+		r.completed = append(r.completed, Record{
+			CallID:         string(record.ID),
+			VirtualAgentID: record.VirtualAgentID,
+			ClientID:       record.ClientID,
+			State:          record.Status.State,
+			Code:           record.Status.Code,
+			Outcome:        record.Status.Outcome,
+			CompletedAt:    record.SavedAt,
+		})
+		r.latest[record.ID] = record.SavedAt
+	}
+}
+
+// Retained returns the number of retained statuses of completed calls.
+func (r *Retainer) Retained() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.latest)
+}
+
+// Run evicts every interval until ctx is cancelled.
+func (r *Retainer) Run(ctx context.Context) {
+	ticker := r.clock.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			r.Evict(ctx)
+		}
+	}
+}
+
+// Evict archives and removes statuses beyond retention, it returns the number of removed statuses.
+// If archival fails, nothing is removed and eviction is retried next time.
+func (r *Retainer) Evict(ctx context.Context) int {
+	r.evictMu.Lock()
+	defer r.evictMu.Unlock()
+	evicted := 0
+	for {
+		n, due := r.due()
+		if len(due) == 0 {
+			// only stale records, there is nothing to archive.
+			r.pop(n)
+			return evicted
+		}
+		if r.archiver != nil {
+			if err := r.archiver.Archive(ctx, due); err != nil {
+				r.metrics.ArchiveFailed()
+				r.logger.Error("evict: Archive", "error", err)
+				return evicted
+			}
+		}
+		for _, record := range r.pop(n) {
+			status := call.Status{State: record.State, Code: record.Code, Outcome: record.Outcome}
+			removed, err := r.StatusStorage.RemoveStatus(ctx, call.ID(record.CallID), status)
+			if err != nil {
+				r.logger.Error("evict: RemoveStatus", "error", err, "call_id", record.CallID)
+				continue
+			}
+			if removed {
+				evicted++
+				r.metrics.StatusEvicted()
+			}
+		}
+	}
+}
+
+// due returns records to evict and how many completed records they cover with stale ones in between.
+func (r *Retainer) due() (int, []Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.clock.Now()
+	due := make([]Record, 0)
+	n := 0
+	for i := r.head; i < len(r.completed) && len(due) < evictBatch; i++ {
+		record := r.completed[i]
+		if !r.current(record) {
+			n++
+			continue
+		}
+		byCount := r.maxCount > 0 && len(r.latest)-len(due) > r.maxCount
+		byAge := r.maxAge > 0 && now.Sub(record.CompletedAt) >= r.maxAge
+		if !byCount && !byAge {
+			break
+		}
+		due = append(due, record)
+		n++
+	}
+	return n, due
+}
+
+// pop removes n records from the head, it returns the ones, which are still current.
+// A call replayed since due keeps its status.
+func (r *Retainer) pop(n int) []Record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := make([]Record, 0, n)
+	for _, record := range r.completed[r.head : r.head+n] {
+		if r.current(record) {
+			delete(r.latest, call.ID(record.CallID))
+			res = append(res, record)
+		}
+	}
+	clear(r.completed[r.head : r.head+n])
+	r.head += n
+	// evicted records are released, when they are the most of the slice.
+	if r.head > len(r.completed)/2 {
+		r.completed = append(make([]Record, 0, len(r.completed)-r.head), r.completed[r.head:]...)
+		r.head = 0
+	}
+	return res
+}
+
+func (r *Retainer) current(record Record) bool {
+	at, ok := r.latest[call.ID(record.CallID)]
+	return ok && at.Equal(record.CompletedAt)
+}
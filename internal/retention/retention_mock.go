@@ -0,0 +1,118 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: retention.go
+
+// Package retention is a generated GoMock package.
+package retention
+
+import (
+	context "context"
+	reflect "reflect"
+	call "test_trigger/internal/call"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockStatusStorage is a mock of StatusStorage interface.
+type MockStatusStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockStatusStorageMockRecorder
+}
+
+// MockStatusStorageMockRecorder is the mock recorder for MockStatusStorage.
+type MockStatusStorageMockRecorder struct {
+	mock *MockStatusStorage
+}
+
+// NewMockStatusStorage creates a new mock instance.
+func NewMockStatusStorage(ctrl *gomock.Controller) *MockStatusStorage {
+	mock := &MockStatusStorage{ctrl: ctrl}
+	mock.recorder = &MockStatusStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStatusStorage) EXPECT() *MockStatusStorageMockRecorder {
+	return m.recorder
+}
+
+// RemoveStatus mocks base method.
+func (m *MockStatusStorage) RemoveStatus(arg0 context.Context, id call.ID, status call.Status) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveStatus", arg0, id, status)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveStatus indicates an expected call of RemoveStatus.
+func (mr *MockStatusStorageMockRecorder) RemoveStatus(arg0, id, status interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveStatus", reflect.TypeOf((*MockStatusStorage)(nil).RemoveStatus), arg0, id, status)
+}
+
+// SaveStatus mocks base method.
+func (m *MockStatusStorage) SaveStatus(arg0 context.Context, status call.Status, meta call.Meta) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveStatus", arg0, status, meta)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveStatus indicates an expected call of SaveStatus.
+func (mr *MockStatusStorageMockRecorder) SaveStatus(arg0, status, meta interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveStatus", reflect.TypeOf((*MockStatusStorage)(nil).SaveStatus), arg0, status, meta)
+}
+
+// Status mocks base method.
+func (m *MockStatusStorage) Status(arg0 context.Context, id call.ID) (call.Status, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Status", arg0, id)
+	ret0, _ := ret[0].(call.Status)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Status indicates an expected call of Status.
+func (mr *MockStatusStorageMockRecorder) Status(arg0, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Status", reflect.TypeOf((*MockStatusStorage)(nil).Status), arg0, id)
+}
+
+// MockArchiver is a mock of Archiver interface.
+type MockArchiver struct {
+	ctrl     *gomock.Controller
+	recorder *MockArchiverMockRecorder
+}
+
+// MockArchiverMockRecorder is the mock recorder for MockArchiver.
+type MockArchiverMockRecorder struct {
+	mock *MockArchiver
+}
+
+// NewMockArchiver creates a new mock instance.
+func NewMockArchiver(ctrl *gomock.Controller) *MockArchiver {
+	mock := &MockArchiver{ctrl: ctrl}
+	mock.recorder = &MockArchiverMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockArchiver) EXPECT() *MockArchiverMockRecorder {
+	return m.recorder
+}
+
+// Archive mocks base method.
+func (m *MockArchiver) Archive(ctx context.Context, records []Record) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Archive", ctx, records)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Archive indicates an expected call of Archive.
+func (mr *MockArchiverMockRecorder) Archive(ctx, records interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Archive", reflect.TypeOf((*MockArchiver)(nil).Archive), ctx, records)
+}
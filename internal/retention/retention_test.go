@@ -0,0 +1,195 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"test_trigger/internal/call"
+	"test_trigger/internal/logger"
+	"test_trigger/internal/metrics"
+	"test_trigger/internal/realtime"
+)
+
+var finished = call.Status{State: call.StateFinished, Code: 200, Outcome: call.OutcomeAnswered}
+
+func statusOf(t *testing.T, storage *call.Storage, id call.ID) (call.Status, bool) {
+	status, ok, err := storage.Status(context.Background(), id)
+	assert.NoError(t, err)
+	return status, ok
+}
+
+func TestRetainer_Evict_Age(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1709464831, 0)
+	clock := realtime.NewFake(now)
+	ctrl := gomock.NewController(t)
+	storage := call.NewStorage()
+	archiver := NewMockArchiver(ctrl)
+	m := metrics.NewCalls(metrics.NewRegistry())
+	r := NewRetainer(storage, time.Hour, 0, time.Minute, archiver, clock, logger.NewMockLogger(ctrl), m)
+
+	assert.NoError(t, r.SaveStatus(ctx, finished, call.Meta{ID: "1", VirtualAgentID: "a", ClientID: "crm"}))
+	assert.NoError(t, r.SaveStatus(ctx, call.Status{State: call.StateQueued}, call.Meta{ID: "2", VirtualAgentID: "a"}))
+	clock.Advance(30 * time.Minute)
+	assert.NoError(t, r.SaveStatus(ctx, call.Status{State: call.StateFailed, Code: 500}, call.Meta{ID: "3", VirtualAgentID: "b"}))
+	assert.Equal(t, 2, r.Retained())
+	assert.Equal(t, 0, r.Evict(ctx))
+
+	// statuses of calls in progress are kept whatever their age.
+	clock.Advance(30 * time.Minute)
+	archiver.EXPECT().Archive(gomock.Any(), []Record{{CallID: "1", VirtualAgentID: "a", ClientID: "crm", State: call.StateFinished, Code: 200, Outcome: call.OutcomeAnswered, CompletedAt: now}}).Return(nil)
+	assert.Equal(t, 1, r.Evict(ctx))
+	_, ok := statusOf(t, storage, "1")
+	assert.False(t, ok)
+	_, ok = statusOf(t, storage, "2")
+	assert.True(t, ok)
+	_, ok = statusOf(t, storage, "3")
+	assert.True(t, ok)
+	assert.Equal(t, 1, r.Retained())
+	assert.Equal(t, uint64(1), m.Evictions.Value())
+}
+
+func TestRetainer_Evict_Count(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1709464831, 0)
+	clock := realtime.NewFake(now)
+	storage := call.NewStorage()
+	// evicted statuses are dropped without archiver.
+	r := NewRetainer(storage, 0, 2, time.Minute, nil, clock, nil, nil)
+
+	for _, id := range []call.ID{"1", "2", "3", "4"} {
+		clock.Advance(time.Second)
+		assert.NoError(t, r.SaveStatus(ctx, finished, call.Meta{ID: id}))
+	}
+	assert.Equal(t, 2, r.Evict(ctx))
+	for id, expected := range map[call.ID]bool{"1": false, "2": false, "3": true, "4": true} {
+		_, ok := statusOf(t, storage, id)
+		assert.Equal(t, expected, ok, id)
+	}
+	assert.Equal(t, 2, r.Retained())
+	assert.Equal(t, 0, r.Evict(ctx))
+}
+
+func TestRetainer_Restore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1709464831, 0)
+	storage := call.NewStorage()
+	// statuses of the previous run, which are kept by persistent storage.
+	records := []call.StatusRecord{
+		{Meta: call.Meta{ID: "1", VirtualAgentID: "a", ClientID: "crm"}, Status: finished, SavedAt: now.Add(-2 * time.Hour)},
+		{Meta: call.Meta{ID: "2", VirtualAgentID: "a"}, Status: call.Status{State: call.StateQueued}, SavedAt: now.Add(-2 * time.Hour)},
+		{Meta: call.Meta{ID: "3", VirtualAgentID: "b"}, Status: finished, SavedAt: now.Add(-time.Minute)},
+	}
+	for _, record := range records {
+		assert.NoError(t, storage.SaveStatus(ctx, record.Status, record.Meta))
+	}
+	r := NewRetainer(storage, time.Hour, 0, time.Minute, nil, realtime.NewFake(now), nil, nil)
+	r.Restore(records)
+	assert.Equal(t, 2, r.Retained())
+
+	// completed calls of the previous run are evicted by their age, calls in progress are kept.
+	assert.Equal(t, 1, r.Evict(ctx))
+	for id, expected := range map[call.ID]bool{"1": false, "2": true, "3": true} {
+		_, ok := statusOf(t, storage, id)
+		assert.Equal(t, expected, ok, id)
+	}
+	assert.Equal(t, 1, r.Retained())
+}
+
+func TestRetainer_Evict_Replayed(t *testing.T) {
+	ctx := context.Background()
+	clock := realtime.NewFake(time.Unix(1709464831, 0))
+	storage := call.NewStorage()
+	r := NewRetainer(storage, time.Hour, 0, time.Minute, nil, clock, nil, nil)
+
+	// the dead letter is replayed, its failed status isn't the current one.
+	assert.NoError(t, r.SaveStatus(ctx, call.Status{State: call.StateFailed}, call.Meta{ID: "1"}))
+	clock.Advance(time.Minute)
+	assert.NoError(t, r.SaveStatus(ctx, call.Status{State: call.StateQueued}, call.Meta{ID: "1"}))
+	clock.Advance(time.Hour)
+	assert.Equal(t, 0, r.Evict(ctx))
+	status, ok := statusOf(t, storage, "1")
+	assert.True(t, ok)
+	assert.Equal(t, call.StateQueued, status.State)
+
+	// completed again, it's evicted by the last completion.
+	assert.NoError(t, r.SaveStatus(ctx, finished, call.Meta{ID: "1"}))
+	clock.Advance(59 * time.Minute)
+	assert.Equal(t, 0, r.Evict(ctx))
+	assert.Equal(t, 0, r.head)
+	assert.Len(t, r.completed, 1)
+	clock.Advance(time.Minute)
+	assert.Equal(t, 1, r.Evict(ctx))
+	_, ok = statusOf(t, storage, "1")
+	assert.False(t, ok)
+	assert.Empty(t, r.completed)
+}
+
+func TestRetainer_Evict_Errors(t *testing.T) {
+	ctx := context.Background()
+	testErr := errors.New("test")
+	clock := realtime.NewFake(time.Unix(1709464831, 0))
+	ctrl := gomock.NewController(t)
+	statusStorage := NewMockStatusStorage(ctrl)
+	archiver := NewMockArchiver(ctrl)
+	l := logger.NewMockLogger(ctrl)
+	m := metrics.NewCalls(metrics.NewRegistry())
+	r := NewRetainer(statusStorage, 0, 1, time.Minute, archiver, clock, l, m)
+
+	statusStorage.EXPECT().SaveStatus(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(3)
+	for _, id := range []call.ID{"1", "2", "3"} {
+		assert.NoError(t, r.SaveStatus(ctx, finished, call.Meta{ID: id}))
+	}
+
+	// nothing is removed, if archival fails.
+	archiver.EXPECT().Archive(gomock.Any(), gomock.Len(2)).Return(testErr)
+	l.EXPECT().Error("evict: Archive", "error", testErr)
+	assert.Equal(t, 0, r.Evict(ctx))
+	assert.Equal(t, 3, r.Retained())
+	assert.Equal(t, uint64(1), m.ArchiveFailures.Value())
+
+	archiver.EXPECT().Archive(gomock.Any(), gomock.Len(2)).Return(nil)
+	statusStorage.EXPECT().RemoveStatus(gomock.Any(), call.ID("1"), finished).Return(false, testErr)
+	l.EXPECT().Error("evict: RemoveStatus", "error", testErr, "call_id", "1")
+	statusStorage.EXPECT().RemoveStatus(gomock.Any(), call.ID("2"), finished).Return(true, nil)
+	assert.Equal(t, 1, r.Evict(ctx))
+	assert.Equal(t, 1, r.Retained())
+
+	// a failed save isn't retained.
+	statusStorage.EXPECT().SaveStatus(gomock.Any(), finished, call.Meta{ID: "4"}).Return(testErr)
+	assert.Equal(t, testErr, r.SaveStatus(ctx, finished, call.Meta{ID: "4"}))
+	assert.Equal(t, 1, r.Retained())
+}
+
+func TestRetainer_Run(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	clock := realtime.NewFake(time.Unix(1709464831, 0))
+	ctrl := gomock.NewController(t)
+	archiver := NewMockArchiver(ctrl)
+	storage := call.NewStorage()
+	r := NewRetainer(storage, time.Minute, 0, time.Minute, archiver, clock, nil, nil)
+	assert.NoError(t, r.SaveStatus(ctx, finished, call.Meta{ID: "1"}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Run(ctx)
+	}()
+	clock.BlockUntil(1)
+	archived := make(chan struct{})
+	archiver.EXPECT().Archive(gomock.Any(), gomock.Len(1)).DoAndReturn(func(_ context.Context, _ []Record) error {
+		close(archived)
+		return nil
+	})
+	clock.Advance(time.Minute)
+	<-archived
+	cancel()
+	<-done
+	_, ok := statusOf(t, storage, "1")
+	assert.False(t, ok)
+}
@@ -0,0 +1,14 @@
+package signature
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	// echo -n '1709464831.{}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, "sha256=4b11e44d2a4405dbf5ff17aaa02b6f88417507b054a4090ef5ab25612e45d251", Sign("secret", "1709464831", []byte("{}")))
+	assert.NotEqual(t, Sign("secret", "1709464831", []byte("{}")), Sign("other", "1709464831", []byte("{}")))
+	assert.NotEqual(t, Sign("secret", "1709464831", []byte("{}")), Sign("secret", "1709464832", []byte("{}")))
+}
@@ -0,0 +1,220 @@
+package simulation
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"test_trigger/internal/call"
+	"test_trigger/internal/call/router"
+	"test_trigger/internal/call/worker"
+	"test_trigger/internal/limiter"
+	"test_trigger/internal/logger"
+	"test_trigger/internal/realtime"
+	"test_trigger/internal/simulator"
+)
+
+// Config describes one simulation run, all durations are virtual.
+type Config struct {
+	Seed             int64
+	Start            time.Time
+	Duration         time.Duration
+	ArrivalRate      float64 // /trigger requests per second, Poisson process.
+	Workers          int
+	StepTime         time.Duration
+	WindowSeconds    uint64 // our limiter.
+	Limit            uint64
+	FailureThreshold int
+	Cooldown         time.Duration
+	RateLimitBackoff time.Duration
+	Provider         simulator.Config
+}
+
+// Result is what happened during the simulation.
+type Result struct {
+	Arrived        int
+	Finished       int
+	Outcomes       map[call.Outcome]int // outcomes of finished calls.
+	Queued         int                  // calls left in the queue at the end.
+	MaxQueueLength int
+	Provider       simulator.Stats
+	Throughput     float64 // not rate limited originate requests per second.
+}
+
+// Run is a discrete-event simulation: arrivals and worker steps are events ordered by virtual time.
+// Idle workers aren't scheduled, they are woken like Storage.Ready does: by an arrival or while calls remain in the queue.
+// Real storage, limiter, router and workers are used, only the provider and the clock are simulated.
+// Everything runs in one goroutine, so the same config and seed give the same result.
+func Run(cfg Config) (Result, error) {
+	if cfg.Duration <= 0 || cfg.ArrivalRate <= 0 || cfg.Workers <= 0 || cfg.StepTime <= 0 {
+		return Result{}, errors.New("run: duration, arrival rate, workers and step time should be greater than 0")
+	}
+	if cfg.WindowSeconds == 0 || cfg.Provider.WindowSeconds == 0 {
+		return Result{}, errors.New("run: window size should be greater than 0")
+	}
+	if err := cfg.Provider.Validate(); err != nil {
+		return Result{}, fmt.Errorf("run: provider: %w", err)
+	}
+
+	ctx := context.Background()
+	clock := realtime.NewFake(cfg.Start)
+	rnd := rand.New(rand.NewSource(cfg.Seed))
+	storage := call.NewStorage()
+	statuses := newStatusCounter(storage)
+	provider := simulator.NewProvider(cfg.Provider, clock, cfg.Seed)
+	caller := &simulatedCaller{provider: provider, classifier: call.NewClassifier(call.DefaultOutcomeRules())}
+	l := logger.NewNop()
+	callRouter := router.NewRouter([]*router.Provider{
+		router.NewProvider("simulated", caller, limiter.NewSlidingWindow(cfg.WindowSeconds, cfg.Limit, clock), 1),
+	}, nil, cfg.FailureThreshold, cfg.Cooldown, cfg.RateLimitBackoff, clock, l)
+
+	workers := make([]*worker.Async, cfg.Workers)
+	q := &events{}
+	for i := range workers {
+		workers[i] = worker.NewWorker(callRouter, storage, statuses, l, callRouter, cfg.StepTime, clock, nil, nil, nil, nil, 0)
+		// all workers are started at once by the pool, they find the queue empty and wait.
+		heap.Push(q, event{at: cfg.Start, seq: q.nextSeq(), worker: i})
+	}
+	idle := make([]int, 0, cfg.Workers)
+	// wake mimics the Storage.Ready signal, one idle worker per signal.
+	wake := func(at time.Time) {
+		if length, _ := storage.QueueLength(ctx); length == 0 || len(idle) == 0 {
+			return
+		}
+		heap.Push(q, event{at: at, seq: q.nextSeq(), worker: idle[0]})
+		idle = idle[1:]
+	}
+	heap.Push(q, event{at: cfg.Start.Add(interarrival(rnd, cfg.ArrivalRate)), seq: q.nextSeq(), worker: -1})
+
+	res := Result{}
+	end := cfg.Start.Add(cfg.Duration)
+	for q.Len() > 0 {
+		e := heap.Pop(q).(event)
+		if e.at.After(end) {
+			break
+		}
+		clock.Set(e.at)
+
+		if e.worker < 0 {
+			res.Arrived++
+			meta := call.Meta{
+				PhoneNumber:    fmt.Sprintf("+4470%08d", res.Arrived),
+				VirtualAgentID: "simulated",
+				ID:             call.ID(fmt.Sprintf("call-%d", res.Arrived)),
+				EnqueuedAt:     e.at,
+			}
+			if err := statuses.SaveStatus(ctx, call.Status{State: call.StateQueued}, meta); err != nil {
+				return Result{}, fmt.Errorf("run: %v", err)
+			}
+			if err := storage.AddToQueueBack(ctx, meta); err != nil {
+				return Result{}, fmt.Errorf("run: %v", err)
+			}
+			if length, _ := storage.QueueLength(ctx); length > res.MaxQueueLength {
+				res.MaxQueueLength = length
+			}
+			heap.Push(q, event{at: e.at.Add(interarrival(rnd, cfg.ArrivalRate)), seq: q.nextSeq(), worker: -1})
+			wake(e.at)
+			continue
+		}
+
+		caller.busy = 0
+		switch workers[e.worker].ProcessOneCall(ctx) {
+		case worker.StepDone:
+			heap.Push(q, event{at: e.at.Add(caller.busy), seq: q.nextSeq(), worker: e.worker})
+		case worker.StepBackoff:
+			heap.Push(q, event{at: e.at.Add(caller.busy + cfg.StepTime), seq: q.nextSeq(), worker: e.worker})
+		case worker.StepEmpty:
+			idle = append(idle, e.worker)
+		}
+		// Next passes the signal on while calls remain, a returned call signals too.
+		wake(e.at)
+	}
+
+	res.Finished = statuses.finished
+	res.Outcomes = statuses.outcomes
+	res.Queued, _ = storage.QueueLength(ctx)
+	res.Provider = provider.Stats()
+	res.Throughput = float64(res.Provider.Requests-res.Provider.RateLimited) / cfg.Duration.Seconds()
+	return res, nil
+}
+
+func interarrival(rnd *rand.Rand, rate float64) time.Duration {
+	return time.Duration(rnd.ExpFloat64() / rate * float64(time.Second))
+}
+
+// simulatedCaller is the originate API client, which asks simulator.Provider instead of sending http requests.
+// Latency isn't slept, it is accumulated in busy and the worker is rescheduled later.
+type simulatedCaller struct {
+	provider   *simulator.Provider
+	classifier *call.Classifier
+	busy       time.Duration
+}
+
+func (s *simulatedCaller) Call(_ context.Context, phoneNumber, _ string) (call.Result, error) {
+	resp := s.provider.Decide(phoneNumber)
+	s.busy += resp.Latency
+	body := fmt.Sprintf(`{"outcome":%q}`, resp.Outcome)
+	return call.Result{StatusCode: resp.Status, Outcome: s.classifier.Classify(resp.Status, []byte(body))}, nil
+}
+
+// statusCounter counts finished calls, storage doesn't allow iterating over statuses.
+type statusCounter struct {
+	worker.StatusStorage
+	finished int
+	outcomes map[call.Outcome]int
+	mu       *sync.Mutex
+}
+
+func newStatusCounter(s worker.StatusStorage) *statusCounter {
+	return &statusCounter{StatusStorage: s, outcomes: make(map[call.Outcome]int), mu: &sync.Mutex{}}
+}
+
+func (s *statusCounter) SaveStatus(ctx context.Context, status call.Status, meta call.Meta) error {
+	s.mu.Lock()
+	if status.State == call.StateFinished {
+		s.finished++
+		s.outcomes[status.Outcome]++
+	}
+	s.mu.Unlock()
+	return s.StatusStorage.SaveStatus(ctx, status, meta)
+}
+
+type event struct {
+	at     time.Time
+	seq    uint64 // keeps order stable for equal times.
+	worker int    // -1 for arrival.
+}
+
+// events is a min-heap by time, see container/heap.
+type events struct {
+	items []event
+	seq   uint64
+}
+
+func (e *events) nextSeq() uint64 {
+	e.seq++
+	return e.seq
+}
+
+func (e *events) Len() int { return len(e.items) }
+
+func (e *events) Less(i, j int) bool {
+	if e.items[i].at.Equal(e.items[j].at) {
+		return e.items[i].seq < e.items[j].seq
+	}
+	return e.items[i].at.Before(e.items[j].at)
+}
+
+func (e *events) Swap(i, j int) { e.items[i], e.items[j] = e.items[j], e.items[i] }
+
+func (e *events) Push(x interface{}) { e.items = append(e.items, x.(event)) }
+
+func (e *events) Pop() interface{} {
+	last := e.items[len(e.items)-1]
+	e.items = e.items[:len(e.items)-1]
+	return last
+}
@@ -0,0 +1,83 @@
+package simulation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"test_trigger/internal/call"
+	"test_trigger/internal/simulator"
+)
+
+func testConfig(seed int64) Config {
+	return Config{
+		Seed:             seed,
+		Start:            time.Unix(1709464831, 0),
+		Duration:         2 * time.Hour,
+		ArrivalRate:      2,
+		Workers:          30,
+		StepTime:         500 * time.Millisecond,
+		WindowSeconds:    10,
+		Limit:            25,
+		FailureThreshold: 5,
+		Cooldown:         30 * time.Second,
+		RateLimitBackoff: 30 * time.Second,
+		Provider: simulator.Config{
+			MinLatency:    5 * time.Second,
+			MaxLatency:    10 * time.Second,
+			WindowSeconds: 10,
+			Limit:         25,
+			Penalty:       30 * time.Second,
+			Outcomes:      map[call.Outcome]int{call.OutcomeAnswered: 8, call.OutcomeBusy: 1, call.OutcomeNoAnswer: 1},
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	started := time.Now()
+	res, err := Run(testConfig(42))
+	require.NoError(t, err)
+	// hours of traffic shouldn't take hours.
+	assert.Less(t, time.Since(started), time.Minute)
+
+	assert.InDelta(t, 2*7200, res.Arrived, 500)
+	assert.Zero(t, res.Provider.RateLimited)
+	assert.LessOrEqual(t, res.Throughput, 2.5)
+	// busy and no answer are retried, so throughput is higher than arrival rate.
+	assert.Greater(t, res.Throughput, 2.0)
+	assert.Greater(t, res.Finished, res.Arrived*9/10)
+	assert.Equal(t, res.Finished, res.Outcomes[call.OutcomeAnswered])
+}
+
+func TestRun_Reproducible(t *testing.T) {
+	first, err := Run(testConfig(7))
+	require.NoError(t, err)
+	second, err := Run(testConfig(7))
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	other, err := Run(testConfig(8))
+	require.NoError(t, err)
+	assert.NotEqual(t, first, other)
+}
+
+func TestRun_Overload(t *testing.T) {
+	cfg := testConfig(1)
+	cfg.Duration = 10 * time.Minute
+	cfg.ArrivalRate = 5
+	res, err := Run(cfg)
+	require.NoError(t, err)
+
+	assert.Zero(t, res.Provider.RateLimited)
+	assert.InDelta(t, 2.5, res.Throughput, 0.1)
+	assert.Greater(t, res.Queued, 0)
+}
+
+func TestRun_InvalidConfig(t *testing.T) {
+	cfg := testConfig(1)
+	cfg.Workers = 0
+	_, err := Run(cfg)
+	assert.Error(t, err)
+}
@@ -0,0 +1,295 @@
+package simulator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"test_trigger/internal/call"
+	"test_trigger/internal/limiter"
+	"test_trigger/internal/realtime"
+)
+
+// outcomeOK is used for requests which weren't rate limited or failed, but don't have script.
+const outcomeOK = call.OutcomeAnswered
+
+// Config describes behaviour of the simulated /originate_call API.
+type Config struct {
+	MinLatency      time.Duration
+	MaxLatency      time.Duration
+	WindowSeconds   uint64
+	Limit           uint64
+	Penalty         time.Duration // all requests are rejected with 429 during penalty.
+	ErrorRate       float64       // part of requests answered with 500.
+	UnavailableRate float64       // part of requests answered with 503.
+	TimeoutRate     float64       // part of requests which hang for Hang and return 504.
+	Hang            time.Duration
+	Outcomes        map[call.Outcome]int      // weights of outcomes for numbers without script.
+	Scripts         map[string][]call.Outcome // phone number -> outcome per attempt, the last one repeats.
+}
+
+// Validate rejects configs, which would silently produce another traffic mix, e.g. a typo in an outcome name.
+func (c Config) Validate() error {
+	for _, r := range []struct {
+		name string
+		rate float64
+	}{{"error rate", c.ErrorRate}, {"unavailable rate", c.UnavailableRate}, {"timeout rate", c.TimeoutRate}} {
+		if r.rate < 0 || r.rate > 1 {
+			return fmt.Errorf("%s should be in [0, 1], got %v", r.name, r.rate)
+		}
+	}
+	if total := c.ErrorRate + c.UnavailableRate + c.TimeoutRate; total > 1 {
+		return fmt.Errorf("error, unavailable and timeout rates can't be greater than 1 in total, got %v", total)
+	}
+	for _, outcome := range sortedOutcomes(c.Outcomes) {
+		if !simulated(outcome) {
+			return fmt.Errorf("outcome %q isn't simulated", outcome)
+		}
+	}
+	for phoneNumber, script := range c.Scripts {
+		for _, outcome := range script {
+			if !simulated(outcome) {
+				return fmt.Errorf("script of %s: outcome %q isn't simulated", phoneNumber, outcome)
+			}
+		}
+	}
+	return nil
+}
+
+// simulated outcomes are the ones the provider can respond with, unknown and ambiguous are made by the classifier.
+func simulated(outcome call.Outcome) bool {
+	switch outcome {
+	case call.OutcomeAnswered, call.OutcomeBusy, call.OutcomeNoAnswer, call.OutcomeVoicemail,
+		call.OutcomeInvalidNumber, call.OutcomeProviderError, call.OutcomeRateLimited:
+		return true
+	}
+	return false
+}
+
+// Response is a decision about one originate request.
+type Response struct {
+	Status  int
+	Outcome call.Outcome
+	Latency time.Duration
+}
+
+// Stats is a snapshot of processed requests.
+type Stats struct {
+	Requests       uint64                  `json:"requests"`
+	RateLimited    uint64                  `json:"rate_limited"`
+	Errors         uint64                  `json:"errors"`
+	Outcomes       map[call.Outcome]uint64 `json:"outcomes"`
+	FirstRequestAt time.Time               `json:"first_request_at"`
+	LastRequestAt  time.Time               `json:"last_request_at"`
+}
+
+// Provider simulates originate API: latency, sliding window rate limit, penalty after 429, errors and scripts.
+type Provider struct {
+	cfg          Config
+	limiter      *limiter.SlidingWindow
+	realTime     realtime.Time
+	rand         *rand.Rand
+	penaltyUntil time.Time
+	attempts     map[string]int
+	stats        Stats
+	mu           *sync.Mutex
+}
+
+func NewProvider(cfg Config, t realtime.Time, seed int64) *Provider {
+	if len(cfg.Outcomes) == 0 {
+		cfg.Outcomes = map[call.Outcome]int{outcomeOK: 1}
+	}
+	return &Provider{
+		cfg:      cfg,
+		limiter:  limiter.NewSlidingWindow(cfg.WindowSeconds, cfg.Limit, t),
+		realTime: t,
+		rand:     rand.New(rand.NewSource(seed)),
+		attempts: make(map[string]int),
+		stats:    Stats{Outcomes: make(map[call.Outcome]uint64)},
+		mu:       &sync.Mutex{},
+	}
+}
+
+// Decide returns the response for the request right now, latency isn't awaited there.
+func (p *Provider) Decide(phoneNumber string) Response {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.realTime.Now()
+	if p.stats.Requests == 0 {
+		p.stats.FirstRequestAt = now
+	}
+	p.stats.Requests++
+	p.stats.LastRequestAt = now
+
+	if now.Before(p.penaltyUntil) {
+		p.stats.RateLimited++
+		return Response{Status: http.StatusTooManyRequests, Outcome: call.OutcomeRateLimited}
+	}
+	if !p.limiter.Allow() {
+		p.penaltyUntil = now.Add(p.cfg.Penalty)
+		p.stats.RateLimited++
+		return Response{Status: http.StatusTooManyRequests, Outcome: call.OutcomeRateLimited}
+	}
+
+	dice := p.rand.Float64()
+	switch {
+	case dice < p.cfg.ErrorRate:
+		p.stats.Errors++
+		return Response{Status: http.StatusInternalServerError, Outcome: call.OutcomeProviderError, Latency: p.latency()}
+	case dice < p.cfg.ErrorRate+p.cfg.UnavailableRate:
+		p.stats.Errors++
+		return Response{Status: http.StatusServiceUnavailable, Outcome: call.OutcomeProviderError}
+	case dice < p.cfg.ErrorRate+p.cfg.UnavailableRate+p.cfg.TimeoutRate:
+		p.stats.Errors++
+		return Response{Status: http.StatusGatewayTimeout, Outcome: call.OutcomeAmbiguous, Latency: p.cfg.Hang}
+	}
+
+	outcome := p.outcome(phoneNumber)
+	p.stats.Outcomes[outcome]++
+	return Response{Status: statusByOutcome(outcome), Outcome: outcome, Latency: p.latency()}
+}
+
+// Stats returns copy of current stats.
+func (p *Provider) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	res := p.stats
+	res.Outcomes = make(map[call.Outcome]uint64, len(p.stats.Outcomes))
+	for k, v := range p.stats.Outcomes {
+		res.Outcomes[k] = v
+	}
+	return res
+}
+
+// ServeHTTP handles POST /originate_call, waits for latency and writes outcome body.
+func (p *Provider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body := &call.Body{}
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil || body.PhoneNumber == "" {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	resp := p.Decide(body.PhoneNumber)
+	if resp.Latency > 0 {
+		timer := time.NewTimer(resp.Latency)
+		select {
+		case <-r.Context().Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.Status)
+	_, _ = fmt.Fprintf(w, `{"outcome":%q}`, resp.Outcome)
+}
+
+// StatsHandler handles GET /stats.
+func (p *Provider) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(p.Stats())
+}
+
+func (p *Provider) latency() time.Duration {
+	spread := p.cfg.MaxLatency - p.cfg.MinLatency
+	if spread <= 0 {
+		return p.cfg.MinLatency
+	}
+	return p.cfg.MinLatency + time.Duration(p.rand.Int63n(int64(spread)))
+}
+
+func (p *Provider) outcome(phoneNumber string) call.Outcome {
+	if script, ok := p.cfg.Scripts[phoneNumber]; ok && len(script) > 0 {
+		attempt := p.attempts[phoneNumber]
+		p.attempts[phoneNumber]++
+		if attempt >= len(script) {
+			attempt = len(script) - 1
+		}
+		return script[attempt]
+	}
+
+	total := 0
+	for _, weight := range p.cfg.Outcomes {
+		total += weight
+	}
+	dice := p.rand.Intn(total)
+	// map iteration order is random, sorted keys keep results reproducible with the same seed.
+	for _, outcome := range sortedOutcomes(p.cfg.Outcomes) {
+		dice -= p.cfg.Outcomes[outcome]
+		if dice < 0 {
+			return outcome
+		}
+	}
+	return outcomeOK
+}
+
+func statusByOutcome(outcome call.Outcome) int {
+	switch outcome {
+	case call.OutcomeBusy:
+		return 486
+	case call.OutcomeNoAnswer:
+		return 480
+	case call.OutcomeInvalidNumber:
+		return http.StatusNotFound
+	case call.OutcomeProviderError:
+		return http.StatusInternalServerError
+	case call.OutcomeRateLimited:
+		return http.StatusTooManyRequests
+	default:
+		// answered and voicemail, voicemail is recognized by body.
+		return http.StatusOK
+	}
+}
+
+func sortedOutcomes(outcomes map[call.Outcome]int) []call.Outcome {
+	res := make([]call.Outcome, 0, len(outcomes))
+	for outcome := range outcomes {
+		res = append(res, outcome)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res
+}
+
+// ParseOutcomes parses weights in format "answered=80,busy=10,no_answer=10".
+func ParseOutcomes(input string) (map[call.Outcome]int, error) {
+	res := make(map[call.Outcome]int)
+	total := 0
+	for _, part := range strings.Split(input, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, weight, found := strings.Cut(part, "=")
+		if !found {
+			return nil, fmt.Errorf("outcome %q: expected name=weight", part)
+		}
+		w, err := strconv.Atoi(weight)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("outcome %q: invalid weight", part)
+		}
+		if !simulated(call.Outcome(name)) {
+			return nil, fmt.Errorf("outcome %q: unknown name", part)
+		}
+		res[call.Outcome(name)] = w
+		total += w
+	}
+	if total == 0 {
+		return nil, errors.New("outcomes can't be empty")
+	}
+	return res, nil
+}
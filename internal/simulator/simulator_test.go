@@ -0,0 +1,201 @@
+package simulator
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"test_trigger/internal/call"
+	"test_trigger/internal/realtime"
+)
+
+func newTestProvider(cfg Config, now *time.Time) *Provider {
+	return NewProvider(cfg, realtime.NewRealTime(func() time.Time { return *now }), 1)
+}
+
+func TestProvider_Decide_RateLimit(t *testing.T) {
+	now := time.Unix(1709464831, 0)
+	p := newTestProvider(Config{WindowSeconds: 10, Limit: 2, Penalty: 30 * time.Second}, &now)
+	ao := assert.New(t)
+
+	ao.Equal(http.StatusOK, p.Decide("777").Status)
+	ao.Equal(http.StatusOK, p.Decide("777").Status)
+	ao.Equal(http.StatusTooManyRequests, p.Decide("777").Status)
+
+	// window is free, but penalty isn't over.
+	now = now.Add(20 * time.Second)
+	ao.Equal(http.StatusTooManyRequests, p.Decide("777").Status)
+
+	now = now.Add(10 * time.Second)
+	ao.Equal(http.StatusOK, p.Decide("777").Status)
+
+	stats := p.Stats()
+	ao.Equal(uint64(5), stats.Requests)
+	ao.Equal(uint64(2), stats.RateLimited)
+	ao.Equal(uint64(3), stats.Outcomes[call.OutcomeAnswered])
+}
+
+func TestProvider_Decide_Script(t *testing.T) {
+	now := time.Unix(1709464831, 0)
+	p := newTestProvider(Config{
+		MinLatency:    5 * time.Second,
+		MaxLatency:    10 * time.Second,
+		WindowSeconds: 10,
+		Limit:         25,
+		Scripts:       map[string][]call.Outcome{"777": {call.OutcomeBusy, call.OutcomeVoicemail}},
+	}, &now)
+	ao := assert.New(t)
+
+	first := p.Decide("777")
+	ao.Equal(486, first.Status)
+	ao.Equal(call.OutcomeBusy, first.Outcome)
+	ao.GreaterOrEqual(first.Latency, 5*time.Second)
+	ao.Less(first.Latency, 10*time.Second)
+	ao.Equal(call.OutcomeVoicemail, p.Decide("777").Outcome)
+	// the last outcome repeats.
+	ao.Equal(call.OutcomeVoicemail, p.Decide("777").Outcome)
+	ao.Equal(call.OutcomeAnswered, p.Decide("888").Outcome)
+}
+
+func TestProvider_Decide_Errors(t *testing.T) {
+	now := time.Unix(1709464831, 0)
+	p := newTestProvider(Config{WindowSeconds: 10, Limit: 25, UnavailableRate: 1}, &now)
+	resp := p.Decide("777")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Status)
+	assert.Equal(t, uint64(1), p.Stats().Errors)
+}
+
+func TestProvider_ServeHTTP(t *testing.T) {
+	now := time.Unix(1709464831, 0)
+	p := newTestProvider(Config{
+		WindowSeconds: 10,
+		Limit:         25,
+		Scripts:       map[string][]call.Outcome{"777": {call.OutcomeVoicemail}},
+	}, &now)
+	classifier := call.NewClassifier(call.DefaultOutcomeRules())
+	tests := []struct {
+		name            string
+		method          string
+		body            string
+		expectedStatus  int
+		expectedOutcome call.Outcome
+	}{
+		{
+			name:           "method not allowed",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "invalid body",
+			method:         http.MethodPost,
+			body:           "123",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:            "voicemail is recognized by default rules",
+			method:          http.MethodPost,
+			body:            `{"phone_number":"777","virtual_agent_id":"aaa"}`,
+			expectedStatus:  http.StatusOK,
+			expectedOutcome: call.OutcomeVoicemail,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/originate_call", bytes.NewBufferString(tt.body))
+			resp := httptest.NewRecorder()
+			p.ServeHTTP(resp, req)
+			assert.Equal(t, tt.expectedStatus, resp.Code)
+			if tt.expectedOutcome != "" {
+				assert.Equal(t, tt.expectedOutcome, classifier.Classify(resp.Code, resp.Body.Bytes()))
+			}
+		})
+	}
+}
+
+func TestParseOutcomes(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected map[call.Outcome]int
+		isErr    bool
+	}{
+		{
+			name:     "success",
+			input:    "answered=80, busy=20",
+			expected: map[call.Outcome]int{call.OutcomeAnswered: 80, call.OutcomeBusy: 20},
+		},
+		{
+			name:  "without weight",
+			input: "answered",
+			isErr: true,
+		},
+		{
+			name:  "zero weights",
+			input: "answered=0",
+			isErr: true,
+		},
+		{
+			name:  "unknown name",
+			input: "answered=80,bussy=20",
+			isErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ParseOutcomes(tt.input)
+			assert.Equal(t, tt.expected, actual)
+			assert.Equal(t, tt.isErr, err != nil)
+		})
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         Config
+		expectedErr string
+	}{
+		{
+			name: "valid",
+			cfg: Config{
+				ErrorRate: 0.5, UnavailableRate: 0.3, TimeoutRate: 0.2,
+				Outcomes: map[call.Outcome]int{call.OutcomeAnswered: 1, call.OutcomeVoicemail: 1},
+				Scripts:  map[string][]call.Outcome{"+4478": {call.OutcomeBusy, call.OutcomeAnswered}},
+			},
+		},
+		{
+			name:        "rates above 1 in total",
+			cfg:         Config{ErrorRate: 0.5, UnavailableRate: 0.5, TimeoutRate: 0.25},
+			expectedErr: "error, unavailable and timeout rates can't be greater than 1 in total, got 1.25",
+		},
+		{
+			name:        "negative rate",
+			cfg:         Config{TimeoutRate: -0.1},
+			expectedErr: "timeout rate should be in [0, 1], got -0.1",
+		},
+		{
+			name:        "unknown outcome",
+			cfg:         Config{Outcomes: map[call.Outcome]int{"answerd": 1}},
+			expectedErr: `outcome "answerd" isn't simulated`,
+		},
+		{
+			name:        "unknown outcome in script",
+			cfg:         Config{Scripts: map[string][]call.Outcome{"+4478": {call.OutcomeAmbiguous}}},
+			expectedErr: `script of +4478: outcome "ambiguous" isn't simulated`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.expectedErr)
+		})
+	}
+}
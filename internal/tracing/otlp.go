@@ -0,0 +1,214 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"test_trigger/internal/logger"
+	"test_trigger/internal/realtime"
+)
+
+//go:generate go run github.com/golang/mock/mockgen --source=otlp.go --destination=otlp_mock.go --package=tracing
+
+type HTTPWrapper interface {
+	MakePostRequest(ctx context.Context, url string, body []byte) ([]byte, int, error)
+}
+
+// OTLP buffers spans and sends them in batches to OTLP/HTTP JSON endpoint, usually http://collector:4318/v1/traces.
+// Spans over maxSpans are dropped, tracing shouldn't eat memory when the collector is down.
+type OTLP struct {
+	url         string
+	serviceName string
+	httpWrapper HTTPWrapper
+	logger      logger.Logger
+	maxSpans    int
+	spans       []*Span
+	dropped     int
+	mu          *sync.Mutex
+}
+
+func NewOTLP(url, serviceName string, httpWrapper HTTPWrapper, logger logger.Logger, maxSpans int) *OTLP {
+	return &OTLP{url: url, serviceName: serviceName, httpWrapper: httpWrapper, logger: logger, maxSpans: maxSpans, mu: &sync.Mutex{}}
+}
+
+// ExportSpan implements Exporter.
+func (o *OTLP) ExportSpan(span *Span) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.spans) >= o.maxSpans {
+		o.dropped++
+		return
+	}
+	o.spans = append(o.spans, span)
+}
+
+// Run flushes spans every interval, the last flush is after ctx cancellation.
+func (o *OTLP) Run(ctx context.Context, clock realtime.Time, interval time.Duration) {
+	ticker := clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// ctx is already cancelled, but spans of the shutdown are the most interesting.
+			o.flushAndLog(context.Background())
+			return
+		case <-ticker.C():
+			o.flushAndLog(ctx)
+		}
+	}
+}
+
+// Start runs Run in a goroutine, stop cancels it and waits for the last flush.
+func (o *OTLP) Start(clock realtime.Time, interval time.Duration) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		o.Run(ctx, clock, interval)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func (o *OTLP) flushAndLog(ctx context.Context) {
+	if err := o.Flush(ctx); err != nil {
+		o.logger.Error("otlp: flush", "error", err)
+	}
+}
+
+// Flush sends buffered spans, they are lost on error.
+func (o *OTLP) Flush(ctx context.Context) error {
+	o.mu.Lock()
+	spans, dropped := o.spans, o.dropped
+	o.spans, o.dropped = nil, 0
+	o.mu.Unlock()
+	if dropped > 0 {
+		o.logger.Warn("otlp: spans were dropped", "count", dropped)
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(o.request(spans))
+	if err != nil {
+		return fmt.Errorf("marshal: %v", err)
+	}
+	_, status, err := o.httpWrapper.MakePostRequest(ctx, o.url, body)
+	if err != nil {
+		return fmt.Errorf("send: %v", err)
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("send: status %v", status)
+	}
+	return nil
+}
+
+// OTLP/JSON structures, only used fields. Ids are hex, int64 are strings.
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              Kind            `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"` // 2 is error.
+		Message string `json:"message,omitempty"`
+	}
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+const statusCodeError = 2
+
+func (o *OTLP) request(spans []*Span) otlpRequest {
+	res := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		span := otlpSpan{
+			TraceID:           s.Context.TraceID.String(),
+			SpanID:            s.Context.SpanID.String(),
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        attributes(s.Attributes),
+		}
+		if s.Parent != (SpanID{}) {
+			span.ParentSpanID = s.Parent.String()
+		}
+		if s.Error != "" {
+			span.Status = otlpStatus{Code: statusCodeError, Message: s.Error}
+		}
+		s.mu.Unlock()
+		res = append(res, span)
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: attributes([]interface{}{"service.name", o.serviceName})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "test_trigger/internal/tracing"}, Spans: res}},
+	}}}
+}
+
+func attributes(kv []interface{}) []otlpAttribute {
+	res := make([]otlpAttribute, 0, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		res = append(res, otlpAttribute{Key: fmt.Sprint(kv[i]), Value: value(kv[i+1])})
+	}
+	return res
+}
+
+func value(v interface{}) otlpValue {
+	switch val := v.(type) {
+	case bool:
+		return otlpValue{BoolValue: &val}
+	case int:
+		s := strconv.Itoa(val)
+		return otlpValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(val, 10)
+		return otlpValue{IntValue: &s}
+	case float64:
+		return otlpValue{DoubleValue: &val}
+	default:
+		s := fmt.Sprint(v)
+		return otlpValue{StringValue: &s}
+	}
+}
@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"test_trigger/internal/logger"
+	"test_trigger/internal/realtime"
+)
+
+func TestOTLP_Flush(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	httpWrapper := NewMockHTTPWrapper(ctrl)
+	l := logger.NewMockLogger(ctrl)
+	otlp := NewOTLP("http://collector/v1/traces", "test_trigger", httpWrapper, l, 1)
+	clock := realtime.NewFake(time.Unix(1709464831, 0))
+	tracer := NewTracer(otlp, clock, rand.New(rand.NewSource(1)))
+
+	ctx, root := tracer.Start(context.Background(), KindServer, "trigger")
+	_, child := tracer.Start(ctx, KindClient, "originate")
+	child.SetAttributes("http.status_code", 429, "allowed", true, "outcome", "rate_limited")
+	child.RecordError(errors.New("some err"))
+	clock.Advance(time.Second)
+	child.Finish()
+	// the second span is dropped, buffer is 1.
+	root.Finish()
+
+	expected := `{"resourceSpans":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"test_trigger"}}]},` +
+		`"scopeSpans":[{"scope":{"name":"test_trigger/internal/tracing"},"spans":[{` +
+		`"traceId":"` + child.Context.TraceID.String() + `","spanId":"` + child.Context.SpanID.String() + `","parentSpanId":"` + root.Context.SpanID.String() + `",` +
+		`"name":"originate","kind":3,"startTimeUnixNano":"1709464831000000000","endTimeUnixNano":"1709464832000000000",` +
+		`"attributes":[{"key":"http.status_code","value":{"intValue":"429"}},{"key":"allowed","value":{"boolValue":true}},{"key":"outcome","value":{"stringValue":"rate_limited"}}],` +
+		`"status":{"code":2,"message":"some err"}}]}]}]}`
+	l.EXPECT().Warn("otlp: spans were dropped", "count", 1)
+	httpWrapper.EXPECT().MakePostRequest(gomock.Any(), "http://collector/v1/traces", gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ string, body []byte) ([]byte, int, error) {
+			assert.JSONEq(t, expected, string(body))
+			return nil, http.StatusOK, nil
+		})
+	assert.NoError(t, otlp.Flush(context.Background()))
+	// nothing to send.
+	assert.NoError(t, otlp.Flush(context.Background()))
+}
+
+func TestOTLP_FlushError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	httpWrapper := NewMockHTTPWrapper(ctrl)
+	otlp := NewOTLP("http://collector/v1/traces", "test_trigger", httpWrapper, logger.NewMockLogger(ctrl), 10)
+	tracer := NewTracer(otlp, realtime.NewFake(time.Unix(0, 0)), rand.New(rand.NewSource(1)))
+	_, span := tracer.Start(context.Background(), KindServer, "trigger")
+	span.Finish()
+
+	httpWrapper.EXPECT().MakePostRequest(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, http.StatusBadRequest, nil)
+	assert.EqualError(t, otlp.Flush(context.Background()), "send: status 400")
+}
@@ -0,0 +1,244 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"test_trigger/internal/realtime"
+)
+
+// HeaderTraceParent is W3C trace context header, https://www.w3.org/TR/trace-context/.
+const HeaderTraceParent = "traceparent"
+
+var ErrInvalidTraceParent = errors.New("invalid traceparent")
+
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// SpanContext is the part of the span, which is propagated between services and through the queue.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid returns false for zero trace or span id, such context is ignored.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// TraceParent formats version 00 of traceparent header, empty string for invalid context.
+func (sc SpanContext) TraceParent() string {
+	if !sc.IsValid() {
+		return ""
+	}
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceParent parses traceparent header, unknown versions are parsed as 00 like the spec says.
+func ParseTraceParent(input string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(input), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+	sc := SpanContext{}
+	if err := decodeHex(parts[1], sc.TraceID[:]); err != nil {
+		return SpanContext{}, err
+	}
+	if err := decodeHex(parts[2], sc.SpanID[:]); err != nil {
+		return SpanContext{}, err
+	}
+	flags := make([]byte, 1)
+	if err := decodeHex(parts[3], flags); err != nil {
+		return SpanContext{}, err
+	}
+	sc.Sampled = flags[0]&1 == 1
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+	return sc, nil
+}
+
+// decodeHex accepts only lowercase hex of the exact length.
+func decodeHex(input string, dst []byte) error {
+	if len(input) != hex.EncodedLen(len(dst)) || strings.ToLower(input) != input {
+		return ErrInvalidTraceParent
+	}
+	if _, err := hex.Decode(dst, []byte(input)); err != nil {
+		return ErrInvalidTraceParent
+	}
+	return nil
+}
+
+type contextKey struct{}
+
+// ContextWithSpanContext returns ctx with sc as the current span, invalid sc is ignored.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, sc)
+}
+
+// SpanContextFromContext returns the current span, zero SpanContext if there is no span.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(contextKey{}).(SpanContext)
+	return sc
+}
+
+type Kind int
+
+// Values are the same as in OTLP.
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+	KindConsumer Kind = 5
+)
+
+// Span is one timed operation. Methods of nil *Span do nothing, nil spans are returned by nil *Tracer.
+type Span struct {
+	Name       string
+	Kind       Kind
+	Context    SpanContext
+	Parent     SpanID
+	Start      time.Time
+	End        time.Time
+	Attributes []interface{} // key-value pairs like in logger.
+	Error      string
+
+	tracer *Tracer
+	ended  bool
+	mu     *sync.Mutex
+}
+
+// SetAttributes adds key-value pairs.
+func (s *Span) SetAttributes(kv ...interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Attributes = append(s.Attributes, kv...)
+}
+
+// RecordError marks the span as failed.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Error = err.Error()
+}
+
+// Finish ends the span and passes it to the exporter, the second call does nothing.
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.End = s.tracer.realTime.Now()
+	s.mu.Unlock()
+	if s.Context.Sampled {
+		s.tracer.exporter.ExportSpan(s)
+	}
+}
+
+// Exporter receives finished spans, it shouldn't block.
+type Exporter interface {
+	ExportSpan(span *Span)
+}
+
+// Nop is exporter, which drops spans. Trace context is still propagated.
+type Nop struct{}
+
+func NewNop() *Nop {
+	return &Nop{}
+}
+
+func (n *Nop) ExportSpan(_ *Span) {}
+
+// Tracer creates spans. Methods of nil *Tracer do nothing, so tracing is optional in tests.
+type Tracer struct {
+	exporter Exporter
+	realTime realtime.Time
+	ids      io.Reader // crypto/rand in production, seeded math/rand in tests.
+	mu       *sync.Mutex
+}
+
+func NewTracer(exporter Exporter, t realtime.Time, ids io.Reader) *Tracer {
+	return &Tracer{exporter: exporter, realTime: t, ids: ids, mu: &sync.Mutex{}}
+}
+
+// Start starts span, which is a child of the span in ctx or a root of a new trace.
+func (t *Tracer) Start(ctx context.Context, kind Kind, name string) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	return t.StartAt(ctx, kind, name, t.realTime.Now())
+}
+
+// StartAt is Start with explicit start time, useful for things which have already happened, like waiting in the queue.
+func (t *Tracer) StartAt(ctx context.Context, kind Kind, name string, start time.Time) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	parent := SpanContextFromContext(ctx)
+	span := &Span{Name: name, Kind: kind, Start: start, tracer: t, mu: &sync.Mutex{}}
+	if parent.IsValid() {
+		span.Context = SpanContext{TraceID: parent.TraceID, Sampled: parent.Sampled}
+		span.Parent = parent.SpanID
+	} else {
+		span.Context = SpanContext{TraceID: t.traceID(), Sampled: true}
+	}
+	span.Context.SpanID = t.spanID()
+	return ContextWithSpanContext(ctx, span.Context), span
+}
+
+func (t *Tracer) traceID() TraceID {
+	id := TraceID{}
+	for id == (TraceID{}) {
+		t.read(id[:])
+	}
+	return id
+}
+
+func (t *Tracer) spanID() SpanID {
+	id := SpanID{}
+	for id == (SpanID{}) {
+		t.read(id[:])
+	}
+	return id
+}
+
+func (t *Tracer) read(dst []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, _ = io.ReadFull(t.ids, dst)
+}
@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"test_trigger/internal/realtime"
+)
+
+// Recorder keeps finished spans, for tests.
+type recorder struct {
+	spans []*Span
+	mu    sync.Mutex
+}
+
+func (r *recorder) ExportSpan(span *Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, span)
+}
+
+func TestParseTraceParent(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string // formatted back, empty for errors.
+		sampled  bool
+	}{
+		{"sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"not sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", false},
+		{"future version with extra fields", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"empty", "", "", false},
+		{"version ff", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "", false},
+		{"extra fields in version 00", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", "", false},
+		{"uppercase", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", "", false},
+		{"zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", "", false},
+		{"zero span id", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", "", false},
+		{"short span id", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902-01", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := ParseTraceParent(tt.input)
+			if tt.expected == "" {
+				assert.ErrorIs(t, err, ErrInvalidTraceParent)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, sc.TraceParent())
+			assert.Equal(t, tt.sampled, sc.Sampled)
+		})
+	}
+}
+
+func TestTracer(t *testing.T) {
+	clock := realtime.NewFake(time.Unix(1709464831, 0))
+	rec := &recorder{}
+	tracer := NewTracer(rec, clock, rand.New(rand.NewSource(1)))
+
+	ctx, root := tracer.Start(context.Background(), KindServer, "trigger")
+	root.SetAttributes("call_id", "1")
+	clock.Advance(time.Second)
+	childCtx, child := tracer.StartAt(ctx, KindInternal, "queue wait", clock.Now().Add(-500*time.Millisecond))
+	child.RecordError(errors.New("some err"))
+	child.Finish()
+	child.Finish()
+	root.Finish()
+
+	require.Len(t, rec.spans, 2)
+	assert.Equal(t, child, rec.spans[0])
+	assert.Equal(t, root, rec.spans[1])
+	assert.True(t, root.Context.IsValid())
+	assert.True(t, root.Context.Sampled)
+	assert.Equal(t, SpanID{}, root.Parent)
+	assert.Equal(t, root.Context.TraceID, child.Context.TraceID)
+	assert.Equal(t, root.Context.SpanID, child.Parent)
+	assert.NotEqual(t, root.Context.SpanID, child.Context.SpanID)
+	assert.Equal(t, child.Context, SpanContextFromContext(childCtx))
+	assert.Equal(t, 500*time.Millisecond, child.End.Sub(child.Start))
+	assert.Equal(t, "some err", child.Error)
+	assert.Equal(t, []interface{}{"call_id", "1"}, root.Attributes)
+}
+
+func TestTracer_NotSampledParent(t *testing.T) {
+	rec := &recorder{}
+	tracer := NewTracer(rec, realtime.NewFake(time.Unix(0, 0)), rand.New(rand.NewSource(1)))
+	parent, err := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	require.NoError(t, err)
+
+	ctx, span := tracer.Start(ContextWithSpanContext(context.Background(), parent), KindConsumer, "process call")
+	span.Finish()
+
+	assert.Empty(t, rec.spans)
+	// context is propagated even if the trace isn't recorded.
+	assert.Equal(t, parent.TraceID, SpanContextFromContext(ctx).TraceID)
+}
+
+func TestTracer_Nil(t *testing.T) {
+	var tracer *Tracer
+	ctx := context.Background()
+	resCtx, span := tracer.Start(ctx, KindInternal, "limiter")
+	assert.Equal(t, ctx, resCtx)
+	assert.Nil(t, span)
+	assert.NotPanics(t, func() {
+		span.SetAttributes("allowed", true)
+		span.RecordError(errors.New("some err"))
+		span.Finish()
+	})
+}
@@ -0,0 +1,395 @@
+package webhook
+
+import (
+	"container/heap"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"test_trigger/internal/call"
+	"test_trigger/internal/http_wrapper"
+	"test_trigger/internal/logger"
+	"test_trigger/internal/metrics"
+	"test_trigger/internal/realtime"
+	"test_trigger/internal/signature"
+)
+
+//go:generate go run github.com/golang/mock/mockgen --source=webhook.go --destination=webhook_mock.go --package=webhook
+
+const (
+	HeaderID        = "X-Webhook-ID"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Results of delivery attempts.
+const (
+	ResultDelivered = "delivered"
+	ResultRetrying  = "retrying"
+	ResultFailed    = "failed"
+)
+
+type HTTPWrapper interface {
+	MakePostRequestWithHeaders(ctx context.Context, url string, body []byte, headers map[string]string) ([]byte, int, error)
+}
+
+type StatusStorage interface {
+	SaveStatus(_ context.Context, status call.Status, meta call.Meta) error
+}
+
+// Event is the body of the webhook request. ID is the same for retries, receivers deduplicate by it.
+type Event struct {
+	ID             string       `json:"id"`
+	CallID         string       `json:"call_id"`
+	VirtualAgentID string       `json:"virtual_agent_id"`
+	State          call.State   `json:"state"`
+	Code           int          `json:"code,omitempty"`
+	Outcome        call.Outcome `json:"outcome,omitempty"`
+	At             time.Time    `json:"at"`
+}
+
+// Attempt is a record of the delivery log.
+type Attempt struct {
+	EventID    string    `json:"event_id"`
+	CallID     string    `json:"call_id"`
+	URL        string    `json:"url"`
+	Attempt    int       `json:"attempt"`
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Result     string    `json:"result"`
+}
+
+// DeliveriesResponse response struct for /admin/webhooks request.
+type DeliveriesResponse struct {
+	Pending  int       `json:"pending"`
+	Attempts []Attempt `json:"attempts"`
+}
+
+// Dispatcher delivers webhooks in the background with exponential backoff, the trigger flow isn't blocked by slow receivers.
+// Deliveries are kept in memory, like the queue, and are sent by a few senders concurrently, so one slow receiver doesn't hold others.
+type Dispatcher struct {
+	httpWrapper   HTTPWrapper
+	defaultSecret string
+	secrets       map[string]string // by tenant.
+	senders       int
+	maxAttempts   int
+	backoff       time.Duration
+	maxBackoff    time.Duration
+	newID         func() string
+	clock         realtime.Time
+	logger        logger.Logger
+	metrics       *metrics.Calls
+
+	mu      *sync.Mutex
+	pending *deliveries
+	wake    chan struct{}
+	log     []Attempt // ring buffer, next is the oldest record when it's full.
+	next    int
+	logSize int
+}
+
+func NewDispatcher(httpWrapper HTTPWrapper, defaultSecret string, secrets map[string]string, senders, maxAttempts int, backoff, maxBackoff time.Duration, logSize int, newID func() string, clock realtime.Time, logger logger.Logger, m *metrics.Calls) *Dispatcher {
+	return &Dispatcher{
+		httpWrapper:   httpWrapper,
+		defaultSecret: defaultSecret,
+		secrets:       secrets,
+		senders:       senders,
+		maxAttempts:   maxAttempts,
+		backoff:       backoff,
+		maxBackoff:    maxBackoff,
+		newID:         newID,
+		clock:         clock,
+		logger:        logger,
+		metrics:       m,
+		mu:            &sync.Mutex{},
+		pending:       &deliveries{},
+		wake:          make(chan struct{}, 1),
+		log:           make([]Attempt, 0, logSize),
+		logSize:       logSize,
+	}
+}
+
+func (d *Dispatcher) secret(tenant string) string {
+	// tenants come from authenticated clients only, calls without a client are signed with the default secret.
+	if secret, ok := d.secrets[tenant]; ok && tenant != "" {
+		return secret
+	}
+	return d.defaultSecret
+}
+
+// CanNotify is false if there is no secret for the tenant, unsigned webhooks aren't sent.
+func (d *Dispatcher) CanNotify(tenant string) bool {
+	return d.secret(tenant) != ""
+}
+
+// Notify queues the webhook for the call, it's sent right away by Run.
+func (d *Dispatcher) Notify(status call.Status, meta call.Meta) {
+	event := Event{
+		ID:             d.newID(),
+		CallID:         string(meta.ID),
+		VirtualAgentID: meta.VirtualAgentID,
+		State:          status.State,
+		Code:           status.Code,
+		Outcome:        status.Outcome,
+		At:             d.clock.Now(),
+	}
+	d.mu.Lock()
+	d.pending.seq++
+	heap.Push(d.pending, delivery{event: event, url: meta.CallbackURL, tenant: meta.Tenant, nextAt: event.At, seq: d.pending.seq})
+	d.mu.Unlock()
+	d.signal()
+}
+
+// signal wakes one idle sender, it takes the due delivery or sleeps until the next one.
+func (d *Dispatcher) signal() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Pending returns the number of webhooks, which wait for delivery or retry.
+func (d *Dispatcher) Pending() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.pending.Len()
+}
+
+// Run delivers webhooks until ctx is cancelled, requests in flight are finished. Pending webhooks are kept for Flush.
+func (d *Dispatcher) Run(ctx context.Context) {
+	d.run(ctx, context.WithoutCancel(ctx), false)
+}
+
+// Flush delivers pending webhooks after Run is stopped, until there are none or ctx is done, the rest is lost.
+func (d *Dispatcher) Flush(ctx context.Context) {
+	d.run(ctx, ctx, true)
+	if pending := d.Pending(); pending > 0 {
+		d.logger.Warn("webhook: pending deliveries are lost", "pending", pending)
+	}
+}
+
+// run starts senders and waits for them. untilEmpty stops a sender, when nothing is pending.
+func (d *Dispatcher) run(ctx, requestCtx context.Context, untilEmpty bool) {
+	wg := &sync.WaitGroup{}
+	for i := 0; i < max(d.senders, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.send(ctx, requestCtx, untilEmpty)
+		}()
+	}
+	wg.Wait()
+}
+
+func (d *Dispatcher) send(ctx, requestCtx context.Context, untilEmpty bool) {
+	for ctx.Err() == nil {
+		next, wait, ok := d.due()
+		if ok {
+			d.deliver(requestCtx, next)
+			continue
+		}
+		if untilEmpty && wait == 0 {
+			return
+		}
+		var timer realtime.Timer
+		var timeout <-chan time.Time
+		if wait > 0 {
+			timer = d.clock.NewTimer(wait)
+			timeout = timer.C()
+		}
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-d.wake:
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// due pops the delivery, which should be sent now, otherwise returns time until the next one, 0 if there are none.
+func (d *Dispatcher) due() (delivery, time.Duration, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.pending.Len() == 0 {
+		return delivery{}, 0, false
+	}
+	if wait := d.pending.items[0].nextAt.Sub(d.clock.Now()); wait > 0 {
+		return delivery{}, wait, false
+	}
+	next := heap.Pop(d.pending).(delivery)
+	if d.pending.Len() > 0 {
+		// another sender takes the rest.
+		d.signal()
+	}
+	return next, 0, true
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, next delivery) {
+	next.attempts++
+	attempt := Attempt{EventID: next.event.ID, CallID: next.event.CallID, URL: next.url, Attempt: next.attempts, At: d.clock.Now()}
+	log := d.logger.With("call_id", next.event.CallID, "event_id", next.event.ID)
+	body, err := json.Marshal(next.event)
+	if err != nil {
+		log.Error("webhook: marshall", "error", err)
+		return
+	}
+	timestamp := strconv.FormatInt(attempt.At.Unix(), 10)
+	_, status, err := d.httpWrapper.MakePostRequestWithHeaders(ctx, next.url, body, map[string]string{
+		HeaderID:        next.event.ID,
+		HeaderTimestamp: timestamp,
+		HeaderSignature: signature.Sign(d.secret(next.tenant), timestamp, body),
+	})
+	attempt.StatusCode = status
+	switch {
+	case err == nil && status >= 200 && status < 300:
+		attempt.Result = ResultDelivered
+	case err == nil && !retryable(status):
+		attempt.Result = ResultFailed
+	case errors.Is(err, http_wrapper.ErrNotPublic):
+		attempt.Result = ResultFailed
+	case next.attempts >= d.maxAttempts:
+		attempt.Result = ResultFailed
+	default:
+		attempt.Result = ResultRetrying
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	d.metrics.WebhookAttempt(attempt.Result)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.record(attempt)
+	switch attempt.Result {
+	case ResultRetrying:
+		next.nextAt = attempt.At.Add(d.retryAfter(next.attempts))
+		heap.Push(d.pending, next)
+		// idle senders may sleep until a later delivery.
+		d.signal()
+	case ResultFailed:
+		log.Warn("webhook: delivery failed", "attempts", next.attempts, "status", status, "error", attempt.Error)
+	}
+}
+
+// retryable statuses are temporary, other 4xx won't change on retry.
+func retryable(status int) bool {
+	return status >= 500 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests
+}
+
+// retryAfter doubles backoff after every attempt, up to maxBackoff.
+func (d *Dispatcher) retryAfter(attempts int) time.Duration {
+	wait := d.backoff
+	for i := 1; i < attempts && wait < d.maxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, d.maxBackoff)
+}
+
+func (d *Dispatcher) record(attempt Attempt) {
+	if d.logSize <= 0 {
+		return
+	}
+	if len(d.log) < d.logSize {
+		d.log = append(d.log, attempt)
+		return
+	}
+	d.log[d.next] = attempt
+	d.next = (d.next + 1) % d.logSize
+}
+
+// Attempts returns the delivery log, the oldest first. Empty callID returns all records.
+func (d *Dispatcher) Attempts(callID string) []Attempt {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res := make([]Attempt, 0)
+	for i := range d.log {
+		attempt := d.log[(d.next+i)%len(d.log)]
+		if callID == "" || attempt.CallID == callID {
+			res = append(res, attempt)
+		}
+	}
+	return res
+}
+
+// HandleDeliveries returns the delivery log, path is /admin/webhooks?call_id=.
+func (d *Dispatcher) HandleDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	respBody, err := json.Marshal(DeliveriesResponse{Pending: d.Pending(), Attempts: d.Attempts(r.URL.Query().Get("call_id"))})
+	if err != nil {
+		d.logger.Error("webhook: marshall", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(respBody)
+}
+
+// Notifier saves statuses and notifies about completed calls with callback url.
+type Notifier struct {
+	StatusStorage
+	dispatcher *Dispatcher
+}
+
+func NewNotifier(statusStorage StatusStorage, dispatcher *Dispatcher) *Notifier {
+	return &Notifier{StatusStorage: statusStorage, dispatcher: dispatcher}
+}
+
+// SaveStatus notifies only after the status is saved, so the receiver can query the same status.
+// Terminal states are finished, failed and expired, there is no cancelled one, calls can't be cancelled.
+func (n *Notifier) SaveStatus(ctx context.Context, status call.Status, meta call.Meta) error {
+	if err := n.StatusStorage.SaveStatus(ctx, status, meta); err != nil {
+		return err
+	}
+	if meta.CallbackURL != "" && status.State.Terminal() {
+		n.dispatcher.Notify(status, meta)
+	}
+	return nil
+}
+
+type delivery struct {
+	event    Event
+	url      string
+	tenant   string
+	attempts int
+	nextAt   time.Time
+	seq      uint64 // keeps order stable for equal times.
+}
+
+// deliveries is a min-heap by nextAt, see container/heap.
+type deliveries struct {
+	items []delivery
+	seq   uint64
+}
+
+func (d *deliveries) Len() int { return len(d.items) }
+
+func (d *deliveries) Less(i, j int) bool {
+	if d.items[i].nextAt.Equal(d.items[j].nextAt) {
+		return d.items[i].seq < d.items[j].seq
+	}
+	return d.items[i].nextAt.Before(d.items[j].nextAt)
+}
+
+func (d *deliveries) Swap(i, j int) { d.items[i], d.items[j] = d.items[j], d.items[i] }
+
+func (d *deliveries) Push(x interface{}) { d.items = append(d.items, x.(delivery)) }
+
+func (d *deliveries) Pop() interface{} {
+	last := d.items[len(d.items)-1]
+	d.items = d.items[:len(d.items)-1]
+	return last
+}
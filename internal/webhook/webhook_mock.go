@@ -0,0 +1,89 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webhook.go
+
+// Package webhook is a generated GoMock package.
+package webhook
+
+import (
+	context "context"
+	reflect "reflect"
+	call "test_trigger/internal/call"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockHTTPWrapper is a mock of HTTPWrapper interface.
+type MockHTTPWrapper struct {
+	ctrl     *gomock.Controller
+	recorder *MockHTTPWrapperMockRecorder
+}
+
+// MockHTTPWrapperMockRecorder is the mock recorder for MockHTTPWrapper.
+type MockHTTPWrapperMockRecorder struct {
+	mock *MockHTTPWrapper
+}
+
+// NewMockHTTPWrapper creates a new mock instance.
+func NewMockHTTPWrapper(ctrl *gomock.Controller) *MockHTTPWrapper {
+	mock := &MockHTTPWrapper{ctrl: ctrl}
+	mock.recorder = &MockHTTPWrapperMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHTTPWrapper) EXPECT() *MockHTTPWrapperMockRecorder {
+	return m.recorder
+}
+
+// MakePostRequestWithHeaders mocks base method.
+func (m *MockHTTPWrapper) MakePostRequestWithHeaders(ctx context.Context, url string, body []byte, headers map[string]string) ([]byte, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MakePostRequestWithHeaders", ctx, url, body, headers)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// MakePostRequestWithHeaders indicates an expected call of MakePostRequestWithHeaders.
+func (mr *MockHTTPWrapperMockRecorder) MakePostRequestWithHeaders(ctx, url, body, headers interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakePostRequestWithHeaders", reflect.TypeOf((*MockHTTPWrapper)(nil).MakePostRequestWithHeaders), ctx, url, body, headers)
+}
+
+// MockStatusStorage is a mock of StatusStorage interface.
+type MockStatusStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockStatusStorageMockRecorder
+}
+
+// MockStatusStorageMockRecorder is the mock recorder for MockStatusStorage.
+type MockStatusStorageMockRecorder struct {
+	mock *MockStatusStorage
+}
+
+// NewMockStatusStorage creates a new mock instance.
+func NewMockStatusStorage(ctrl *gomock.Controller) *MockStatusStorage {
+	mock := &MockStatusStorage{ctrl: ctrl}
+	mock.recorder = &MockStatusStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStatusStorage) EXPECT() *MockStatusStorageMockRecorder {
+	return m.recorder
+}
+
+// SaveStatus mocks base method.
+func (m *MockStatusStorage) SaveStatus(arg0 context.Context, status call.Status, meta call.Meta) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveStatus", arg0, status, meta)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveStatus indicates an expected call of SaveStatus.
+func (mr *MockStatusStorageMockRecorder) SaveStatus(arg0, status, meta interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveStatus", reflect.TypeOf((*MockStatusStorage)(nil).SaveStatus), arg0, status, meta)
+}
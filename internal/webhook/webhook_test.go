@@ -0,0 +1,264 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"test_trigger/internal/call"
+	"test_trigger/internal/http_wrapper"
+	"test_trigger/internal/logger"
+	"test_trigger/internal/metrics"
+	"test_trigger/internal/realtime"
+	"test_trigger/internal/signature"
+)
+
+func TestDispatcher_CanNotify(t *testing.T) {
+	d := NewDispatcher(nil, "", map[string]string{"a": "secret"}, 1, 3, time.Second, time.Minute, 10, nil, nil, nil, nil)
+	assert.True(t, d.CanNotify("a"))
+	assert.False(t, d.CanNotify("b"))
+	// calls without an authenticated client have no tenant, only the default secret is used for them.
+	d = NewDispatcher(nil, "", map[string]string{"": "secret"}, 1, 3, time.Second, time.Minute, 10, nil, nil, nil, nil)
+	assert.False(t, d.CanNotify(""))
+	d = NewDispatcher(nil, "default", map[string]string{"a": "secret"}, 1, 3, time.Second, time.Minute, 10, nil, nil, nil, nil)
+	assert.True(t, d.CanNotify("b"))
+}
+
+func TestDispatcher_retryAfter(t *testing.T) {
+	d := NewDispatcher(nil, "", nil, 1, 10, time.Second, 5*time.Second, 10, nil, nil, nil, nil)
+	for attempts, expected := range []time.Duration{time.Second, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if attempts == 0 {
+			continue
+		}
+		assert.Equal(t, expected, d.retryAfter(attempts), attempts)
+	}
+}
+
+func TestDispatcher_Run(t *testing.T) {
+	now := time.Unix(1709464831, 0)
+	body := `{"id":"event-1","call_id":"1","virtual_agent_id":"aaa","state":"finished","code":200,"outcome":"answered","at":"` + now.Format(time.RFC3339) + `"}`
+	tests := []struct {
+		name           string
+		responses      []int // 0 means connection error, -1 means the address isn't public.
+		expectedResult []string
+		expectedWaits  []time.Duration // virtual time between attempts.
+	}{
+		{
+			name:           "delivered",
+			responses:      []int{http.StatusNoContent},
+			expectedResult: []string{ResultDelivered},
+		},
+		{
+			name:           "retried with backoff",
+			responses:      []int{0, http.StatusServiceUnavailable, http.StatusOK},
+			expectedResult: []string{ResultRetrying, ResultRetrying, ResultDelivered},
+			expectedWaits:  []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:           "not retryable",
+			responses:      []int{http.StatusGone},
+			expectedResult: []string{ResultFailed},
+		},
+		{
+			name:           "address isn't public",
+			responses:      []int{-1},
+			expectedResult: []string{ResultFailed},
+		},
+		{
+			name:           "max attempts",
+			responses:      []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests},
+			expectedResult: []string{ResultRetrying, ResultRetrying, ResultFailed},
+			expectedWaits:  []time.Duration{time.Second, 2 * time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			httpWrapper := NewMockHTTPWrapper(ctrl)
+			l := logger.NewMockLogger(ctrl)
+			l.EXPECT().With("call_id", "1", "event_id", "event-1").Return(l).AnyTimes()
+			l.EXPECT().Warn("webhook: delivery failed", "attempts", gomock.Any(), "status", gomock.Any(), "error", gomock.Any()).AnyTimes()
+			clock := realtime.NewFake(now)
+			m := metrics.NewCalls(metrics.NewRegistry())
+			d := NewDispatcher(httpWrapper, "default", map[string]string{"tenant": "secret"}, 1, 3, time.Second, time.Minute, 10, func() string { return "event-1" }, clock, l, m)
+
+			sent := make(chan struct{})
+			for _, status := range tt.responses {
+				status := status
+				httpWrapper.EXPECT().MakePostRequestWithHeaders(gomock.Any(), "http://crm/hook", gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ string, b []byte, headers map[string]string) ([]byte, int, error) {
+						defer func() { sent <- struct{}{} }()
+						assert.JSONEq(t, body, string(b))
+						timestamp := strconv.FormatInt(clock.Now().Unix(), 10)
+						assert.Equal(t, map[string]string{
+							HeaderID:        "event-1",
+							HeaderTimestamp: timestamp,
+							HeaderSignature: signature.Sign("secret", timestamp, b),
+						}, headers)
+						if status == 0 {
+							return nil, 0, errors.New("connection refused")
+						}
+						if status == -1 {
+							return nil, 0, fmt.Errorf("dial: %w", http_wrapper.ErrNotPublic)
+						}
+						return nil, status, nil
+					})
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				d.Run(ctx)
+			}()
+			d.Notify(call.Status{State: call.StateFinished, Code: 200, Outcome: call.OutcomeAnswered}, call.Meta{ID: "1", VirtualAgentID: "aaa", CallbackURL: "http://crm/hook", Tenant: "tenant"})
+			<-sent
+			for _, wait := range tt.expectedWaits {
+				clock.BlockUntil(1)
+				assert.Equal(t, 1, d.Pending())
+				clock.Advance(wait)
+				<-sent
+			}
+			cancel()
+			<-done
+
+			assert.Equal(t, 0, d.Pending())
+			attempts := d.Attempts("1")
+			results := make([]string, 0)
+			for i, a := range attempts {
+				assert.Equal(t, i+1, a.Attempt)
+				results = append(results, a.Result)
+			}
+			assert.Equal(t, tt.expectedResult, results)
+			assert.Equal(t, uint64(1), m.Webhooks.Value(tt.expectedResult[len(tt.expectedResult)-1]))
+		})
+	}
+}
+
+func TestDispatcher_Run_Senders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	httpWrapper := NewMockHTTPWrapper(ctrl)
+	l := logger.NewMockLogger(ctrl)
+	l.EXPECT().With(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(l).AnyTimes()
+	d := NewDispatcher(httpWrapper, "default", nil, 2, 3, time.Second, time.Minute, 10, func() string { return "event" }, realtime.NewFake(time.Unix(1709464831, 0)), l, nil)
+
+	// the slow receiver holds one sender, the other one delivers the next webhook.
+	slow := make(chan struct{})
+	sent := make(chan struct{})
+	httpWrapper.EXPECT().MakePostRequestWithHeaders(gomock.Any(), "http://slow/hook", gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, _ []byte, _ map[string]string) ([]byte, int, error) {
+			<-slow
+			return nil, http.StatusOK, nil
+		})
+	httpWrapper.EXPECT().MakePostRequestWithHeaders(gomock.Any(), "http://fast/hook", gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, _ []byte, _ map[string]string) ([]byte, int, error) {
+			close(sent)
+			return nil, http.StatusOK, nil
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.Run(ctx)
+	}()
+	d.Notify(call.Status{State: call.StateFinished}, call.Meta{ID: "1", CallbackURL: "http://slow/hook"})
+	d.Notify(call.Status{State: call.StateFinished}, call.Meta{ID: "2", CallbackURL: "http://fast/hook"})
+	<-sent
+	cancel()
+	// the request in flight isn't aborted by cancellation.
+	close(slow)
+	<-done
+	assert.Equal(t, 0, d.Pending())
+	assert.Len(t, d.Attempts(""), 2)
+}
+
+func TestDispatcher_Flush(t *testing.T) {
+	now := time.Unix(1709464831, 0)
+	ctrl := gomock.NewController(t)
+	httpWrapper := NewMockHTTPWrapper(ctrl)
+	l := logger.NewMockLogger(ctrl)
+	l.EXPECT().With(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(l).AnyTimes()
+	clock := realtime.NewFake(now)
+	d := NewDispatcher(httpWrapper, "default", nil, 2, 3, time.Second, time.Minute, 10, func() string { return "event" }, clock, l, nil)
+
+	// Run is stopped, webhooks of the last calls are sent by Flush.
+	d.Notify(call.Status{State: call.StateFinished}, call.Meta{ID: "1", CallbackURL: "http://crm/hook"})
+	d.Notify(call.Status{State: call.StateExpired}, call.Meta{ID: "2", CallbackURL: "http://crm/hook"})
+	httpWrapper.EXPECT().MakePostRequestWithHeaders(gomock.Any(), "http://crm/hook", gomock.Any(), gomock.Any()).Return(nil, http.StatusOK, nil).Times(2)
+	d.Flush(context.Background())
+	assert.Equal(t, 0, d.Pending())
+
+	// a retry, which is later than the deadline, is lost.
+	d.Notify(call.Status{State: call.StateFinished}, call.Meta{ID: "3", CallbackURL: "http://crm/hook"})
+	httpWrapper.EXPECT().MakePostRequestWithHeaders(gomock.Any(), "http://crm/hook", gomock.Any(), gomock.Any()).Return(nil, http.StatusServiceUnavailable, nil)
+	l.EXPECT().Warn("webhook: pending deliveries are lost", "pending", 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.Flush(ctx)
+	}()
+	clock.BlockUntil(2)
+	cancel()
+	<-done
+	assert.Equal(t, 1, d.Pending())
+}
+
+func TestDispatcher_Attempts(t *testing.T) {
+	d := NewDispatcher(nil, "", nil, 1, 3, time.Second, time.Minute, 3, nil, nil, nil, nil)
+	for i := 1; i <= 4; i++ {
+		d.record(Attempt{CallID: strconv.Itoa(i % 2), Attempt: i})
+	}
+	// the first record is overwritten.
+	assert.Equal(t, []Attempt{{CallID: "0", Attempt: 2}, {CallID: "1", Attempt: 3}, {CallID: "0", Attempt: 4}}, d.Attempts(""))
+	assert.Equal(t, []Attempt{{CallID: "0", Attempt: 2}, {CallID: "0", Attempt: 4}}, d.Attempts("0"))
+
+	resp := httptest.NewRecorder()
+	d.HandleDeliveries(resp, httptest.NewRequest(http.MethodGet, "/admin/webhooks?call_id=1", nil))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"pending":0,"attempts":[{"event_id":"","call_id":"1","url":"","attempt":3,"at":"0001-01-01T00:00:00Z","result":""}]}`, resp.Body.String())
+}
+
+func TestNotifier_SaveStatus(t *testing.T) {
+	testErr := errors.New("test")
+	withCallback := call.Meta{ID: "1", CallbackURL: "http://crm/hook"}
+	tests := []struct {
+		name        string
+		status      call.Status
+		meta        call.Meta
+		saveErr     error
+		expectedErr error
+		notified    bool
+	}{
+		{"finished", call.Status{State: call.StateFinished}, withCallback, nil, nil, true},
+		{"failed", call.Status{State: call.StateFailed}, withCallback, nil, nil, true},
+		{"retrying isn't completed", call.Status{State: call.StateRetrying}, withCallback, nil, nil, false},
+		{"no callback", call.Status{State: call.StateFinished}, call.Meta{ID: "1"}, nil, nil, false},
+		{"save error", call.Status{State: call.StateFinished}, withCallback, testErr, testErr, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			statusStorage := NewMockStatusStorage(ctrl)
+			statusStorage.EXPECT().SaveStatus(gomock.Any(), tt.status, tt.meta).Return(tt.saveErr)
+			d := NewDispatcher(nil, "", nil, 1, 3, time.Second, time.Minute, 10, func() string { return "event-1" }, realtime.NewFake(time.Unix(1709464831, 0)), nil, nil)
+			n := NewNotifier(statusStorage, d)
+
+			assert.Equal(t, tt.expectedErr, n.SaveStatus(context.Background(), tt.status, tt.meta))
+			if tt.notified {
+				assert.Equal(t, 1, d.Pending())
+			} else {
+				assert.Equal(t, 0, d.Pending())
+			}
+		})
+	}
+}